@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	"math/big"
 	"strings"
 
 	"yy/token"
@@ -47,7 +48,7 @@ func (de *DeclareExpression) String() string {
 }
 
 type AssignExpression struct {
-	Token token.Token // '=' token
+	Token token.Token // '=' token, or eg '+=' in which case Value is desugared into Left + Value
 	Left  Expression
 	Value Expression
 }
@@ -58,6 +59,96 @@ func (ae *AssignExpression) String() string {
 	return fmt.Sprintf("(%s = %s)", ae.Left.String(), ae.Value.String())
 }
 
+// DestructureExpression declares (or assigns to) the targets of Pattern, taking Value apart.
+type DestructureExpression struct {
+	Token   token.Token // the ':=' or '=' token
+	Pattern Expression  // an *ArrayPattern or a *HashmapPattern
+	Value   Expression
+}
+
+func (de *DestructureExpression) Pos() int             { return de.Token.Offset }
+func (de *DestructureExpression) TokenLiteral() string { return de.Token.Literal }
+func (de *DestructureExpression) String() string {
+	return fmt.Sprintf("(%s %s %s)", de.Pattern.String(), de.Token.Literal, de.Value.String())
+}
+
+// Declare reports whether the targets are declared, rather than assigned to.
+func (de *DestructureExpression) Declare() bool { return de.Token.Type == token.WALRUS }
+
+// ArrayPattern takes an array apart, eg [a, b = 2, ...rest].
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	Elements []PatternElement
+	Rest     Expression // target of the remaining elements, nil if there's no '...'
+	Rbracket int        // offset of the closing ']'
+}
+
+func (ap *ArrayPattern) Pos() int             { return ap.Token.Offset }
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Required is the number of elements an array needs to have to fit the pattern. Only the elements
+// at the end can be left out, if they have default values.
+func (ap *ArrayPattern) Required() int {
+	n := len(ap.Elements)
+	for n > 0 && ap.Elements[n-1].Default != nil {
+		n--
+	}
+	return n
+}
+
+// HashmapPattern takes a hashmap apart, eg %{"name": n, "age": a = 0}.
+type HashmapPattern struct {
+	Token  token.Token // the '%{' token
+	Pairs  []PatternElement
+	Rbrace int // offset of the closing '}'
+}
+
+func (hp *HashmapPattern) Pos() int             { return hp.Token.Offset }
+func (hp *HashmapPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashmapPattern) String() string {
+	pairs := []string{}
+	for _, pair := range hp.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// PatternElement is a part of a pattern: the target a value goes to, and the default used instead
+// of a missing (or null) value. Key is only set in hashmap patterns.
+type PatternElement struct {
+	Key     Expression
+	Target  Expression // an identifier, index or dot expression, or a nested pattern
+	Default Expression // nil if there's none
+}
+
+func (pe PatternElement) String() string {
+	if pe.Default == nil {
+		return pe.Target.String()
+	}
+	return pe.Target.String() + " = " + pe.Default.String()
+}
+
+// TypePattern matches values of a type, eg integer(n), and matches Target against them.
+type TypePattern struct {
+	Token  token.Token // the type name
+	Type   string
+	Target Expression
+}
+
+func (tp *TypePattern) Pos() int             { return tp.Token.Offset }
+func (tp *TypePattern) TokenLiteral() string { return tp.Token.Literal }
+func (tp *TypePattern) String() string       { return tp.Type + "(" + tp.Target.String() + ")" }
+
 type YeetExpression struct {
 	Token       token.Token // the 'yeet' token
 	ReturnValue Expression
@@ -78,15 +169,39 @@ func (ys *YeetExpression) String() string {
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
+
+	// where the variable lives, filled in by the resolver
+	Resolution Resolution
+	Scope      *Scope // scope the variable is declared in, if it's Local
+	Depth      int    // number of environments between the identifier and the variable's one
+	Slot       int    // index of the variable in the environment
 }
 
 func (i *Identifier) Pos() int             { return i.Token.Offset }
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
 
+// Resolution tells the evaluator how to find the variable an identifier refers to.
+type Resolution int
+
+const (
+	Unresolved Resolution = iota // by name, in all enclosing environments
+	Local                        // in a slot of an enclosing environment
+	Global                       // by name, skipping environments of scopes known to the resolver
+)
+
+// Scope lists variables declared in a part of the code that runs in its own environment, like a
+// block or a lambda, so they can be kept in slots rather than looked up by name. Scopes are filled
+// in by the resolver, code without them runs in environments with variables looked up by name.
+type Scope struct {
+	Names  []string // names of the variables, indexed by slot
+	Shared bool     // nothing is declared in the scope, it shares the enclosing environment
+}
+
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // the value if it doesn't fit in int64, nil otherwise
 }
 
 func (i *IntegerLiteral) Pos() int             { return i.Token.Offset }
@@ -129,7 +244,7 @@ func (s *StringLiteral) TokenLiteral() string { return s.Token.Literal }
 func (s *StringLiteral) String() string       { return `"` + s.Token.Literal + `"` }
 
 type TemplateStringLiteral struct {
-	Token    token.Token
+	Token    token.Token // the first part of the string, up to the first '{'
 	Template string
 	Values   []Expression
 }
@@ -141,6 +256,7 @@ func (ts *TemplateStringLiteral) String() string       { return `"` + ts.Token.L
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	Rbracket int // offset of the closing ']'
 }
 
 func (a *ArrayLiteral) Pos() int             { return a.Token.Offset }
@@ -172,8 +288,14 @@ func (rl *RangeLiteral) String() string {
 }
 
 type HashmapLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  token.Token   // the '%{' token
+	Pairs  []HashmapPair // in the order they were written in
+	Rbrace int           // offset of the closing '}'
+}
+
+type HashmapPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashmapLiteral) Pos() int             { return hl.Token.Offset }
@@ -181,8 +303,8 @@ func (hl *HashmapLiteral) TokenLiteral() string { return hl.Token.Literal }
 func (hl *HashmapLiteral) String() string {
 	var b strings.Builder
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 	b.WriteString("{")
 	b.WriteString(strings.Join(pairs, ", "))
@@ -209,6 +331,20 @@ func (ie *IndexExpression) String() string {
 	return b.String()
 }
 
+// DotExpression accesses a field of a hashmap, a.b is a shorthand for a["b"].
+type DotExpression struct {
+	Token   token.Token // The . token
+	Left    Expression
+	Name    string
+	NamePos int
+}
+
+func (de *DotExpression) Pos() int             { return de.Left.Pos() }
+func (de *DotExpression) TokenLiteral() string { return de.Token.Literal }
+func (de *DotExpression) String() string {
+	return fmt.Sprintf("(%s.%s)", de.Left.String(), de.Name)
+}
+
 type PrefixExpression struct {
 	Token    token.Token // prefix token e.g. !
 	Operator string      // '-' or '!'
@@ -234,6 +370,31 @@ func (ie *InfixExpression) String() string {
 	return fmt.Sprintf("(%s %s %s)", ie.Left.String(), ie.Operator, ie.Right.String())
 }
 
+// PipeExpression passes Left as the first argument of the function called on the Right, so
+// 'x |> f(a)' stands for 'f(x, a)', and 'x |> f' for 'f(x)'.
+type PipeExpression struct {
+	Token token.Token // the '|>' token
+	Left  Expression
+	Right Expression
+	Call  *CallExpression // what the pipe stands for
+}
+
+// NewPipeExpression creates a pipe, and the call it stands for.
+func NewPipeExpression(tok token.Token, left, right Expression) *PipeExpression {
+	call := &CallExpression{Token: tok, Function: right, Arguments: []Expression{left}, Rparen: -1}
+	if rightCall, ok := right.(*CallExpression); ok {
+		call.Token, call.Function, call.Rparen = rightCall.Token, rightCall.Function, rightCall.Rparen
+		call.Arguments = append(call.Arguments, rightCall.Arguments...)
+	}
+	return &PipeExpression{Token: tok, Left: left, Right: right, Call: call}
+}
+
+func (pe *PipeExpression) Pos() int             { return pe.Token.Offset }
+func (pe *PipeExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PipeExpression) String() string {
+	return fmt.Sprintf("(%s |> %s)", pe.Left.String(), pe.Right.String())
+}
+
 type AndExpression struct {
 	Token token.Token
 	Left  Expression
@@ -280,6 +441,7 @@ func (ye *YifExpression) String() string {
 type YoloExpression struct {
 	Token token.Token
 	Body  *BlockExpression
+	Scope *Scope // the environment in yolo mode
 }
 
 func (ye *YoloExpression) Pos() int             { return ye.Token.Offset }
@@ -290,32 +452,144 @@ func (ye *YoloExpression) String() string {
 
 type YoyoExpression struct {
 	Token     token.Token
+	Label     string // name ybreak & ycontinue can refer to the loop by, empty if not given
+	LabelPos  int    // offset of the '@' before Label
 	Condition Expression
 	Body      *BlockExpression
+	Scope     *Scope // the environment of the whole loop
 }
 
 func (ye *YoyoExpression) Pos() int             { return ye.Token.Offset }
 func (ye *YoyoExpression) TokenLiteral() string { return ye.Token.Literal }
 func (ye *YoyoExpression) String() string {
-	return fmt.Sprintf("yoyo %s { %s }", ye.Condition.String(), ye.Body.String())
+	return fmt.Sprintf("%syoyo %s { %s }", labelString(ye.Label), ye.Condition.String(), ye.Body.String())
 }
 
 type YallExpression struct {
-	Token    token.Token
-	Iterable Expression
-	KeyName  string
-	Body     *BlockExpression
+	Token     token.Token
+	Label     string // like in YoyoExpression
+	LabelPos  int
+	Iterable  Expression
+	IndexName string // name of the index, or of the key when iterating over a hashmap, empty if not given
+	ElemName  string // name of the element, which is the key when iterating over a hashmap without IndexName
+	Body      *BlockExpression
+	Scope     *Scope // the environment of the whole loop, holding IndexName & ElemName
 }
 
 func (ye *YallExpression) Pos() int             { return ye.Token.Offset }
 func (ye *YallExpression) TokenLiteral() string { return ye.Token.Literal }
 func (ye *YallExpression) String() string {
-	return fmt.Sprintf("yall %s: %s { %s }", ye.KeyName, ye.Iterable.String(), ye.Body.String())
+	names := ye.ElemName
+	if ye.IndexName != "" {
+		names = ye.IndexName + ", " + ye.ElemName
+	}
+	return fmt.Sprintf("%syall %s: %s { %s }", labelString(ye.Label), names, ye.Iterable.String(), ye.Body.String())
+}
+
+// YbreakExpression leaves the innermost loop, or the one with Label, which yeets Value.
+type YbreakExpression struct {
+	Token token.Token // the 'ybreak' token
+	Label string
+	Value Expression // nil if not given
+}
+
+func (yb *YbreakExpression) Pos() int             { return yb.Token.Offset }
+func (yb *YbreakExpression) TokenLiteral() string { return yb.Token.Literal }
+func (yb *YbreakExpression) String() string {
+	s := "ybreak"
+	if yb.Label != "" {
+		s += " @" + yb.Label
+	}
+	if yb.Value != nil {
+		s += " " + yb.Value.String()
+	}
+	return s
+}
+
+// YcontinueExpression skips to the next iteration of the innermost loop, or the one with Label.
+type YcontinueExpression struct {
+	Token token.Token // the 'ycontinue' token
+	Label string
+}
+
+func (yc *YcontinueExpression) Pos() int             { return yc.Token.Offset }
+func (yc *YcontinueExpression) TokenLiteral() string { return yc.Token.Literal }
+func (yc *YcontinueExpression) String() string {
+	if yc.Label != "" {
+		return "ycontinue @" + yc.Label
+	}
+	return "ycontinue"
+}
+
+func labelString(label string) string {
+	if label == "" {
+		return ""
+	}
+	return "@" + label + " "
+}
+
+type YtryExpression struct {
+	Token      token.Token
+	Body       *BlockExpression
+	ErrName    string
+	Catch      *BlockExpression
+	CatchScope *Scope // the environment holding ErrName
+}
+
+func (ye *YtryExpression) Pos() int             { return ye.Token.Offset }
+func (ye *YtryExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YtryExpression) String() string {
+	return fmt.Sprintf("ytry %s ycatch %s %s", ye.Body.String(), ye.ErrName, ye.Catch.String())
 }
 
+// YmatchExpression evaluates to Body of the first arm whose pattern Subject matches.
+type YmatchExpression struct {
+	Token   token.Token // the 'ymatch' token
+	Subject Expression
+	Arms    []*MatchArm
+	Lbrace  int // offset of the opening '{'
+	Rbrace  int // offset of the closing '}'
+}
+
+func (ye *YmatchExpression) Pos() int             { return ye.Token.Offset }
+func (ye *YmatchExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YmatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range ye.Arms {
+		arms = append(arms, arm.String())
+	}
+	return fmt.Sprintf("ymatch %s { %s }", ye.Subject.String(), strings.Join(arms, ", "))
+}
+
+// MatchArm is a pattern of a ymatch, with an optional guard, and the expression it evaluates to.
+type MatchArm struct {
+	Pattern Expression
+	Guard   Expression // nil if there's none
+	Body    Expression
+	Scope   *Scope // the environment holding variables bound by Pattern
+}
+
+func (ma *MatchArm) String() string {
+	if ma.Guard == nil {
+		return fmt.Sprintf("%s => %s", ma.Pattern.String(), ma.Body.String())
+	}
+	return fmt.Sprintf("%s yif %s => %s", ma.Pattern.String(), ma.Guard.String(), ma.Body.String())
+}
+
+type ImportExpression struct {
+	Token token.Token
+	Path  string
+}
+
+func (ie *ImportExpression) Pos() int             { return ie.Token.Offset }
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ImportExpression) String() string       { return fmt.Sprintf("yimport %q", ie.Path) }
+
 type BlockExpression struct {
 	Token       token.Token // the { token
 	Expressions []Expression
+	Rbrace      int // offset of the closing '}'
+	Scope       *Scope
 }
 
 func (be *BlockExpression) Pos() int             { return be.Token.Offset }
@@ -336,7 +610,11 @@ func (be *BlockExpression) String() string {
 type LambdaLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	Defaults   []Expression // default values of the parameters, nil for the required ones
+	Rest       *Identifier  // the parameter collecting the remaining args, nil if there's no '...'
 	Body       *BlockExpression
+	Name       string // name of the variable the lambda is declared as, if any
+	Scope      *Scope // the environment of a call, holding parameters and variables of the body
 }
 
 func (ll *LambdaLiteral) Pos() int             { return ll.Token.Offset }
@@ -345,9 +623,16 @@ func (ll *LambdaLiteral) String() string {
 	var b strings.Builder
 
 	params := []string{}
-	for _, p := range ll.Parameters {
+	for i, p := range ll.Parameters {
+		if def := DefaultOf(ll.Defaults, i); def != nil {
+			params = append(params, p.String()+" = "+def.String())
+			continue
+		}
 		params = append(params, p.String())
 	}
+	if ll.Rest != nil {
+		params = append(params, "..."+ll.Rest.String())
+	}
 
 	b.WriteString(ll.TokenLiteral())
 	b.WriteString("(")
@@ -358,6 +643,26 @@ func (ll *LambdaLiteral) String() string {
 	return b.String()
 }
 
+// DefaultOf yeets the default value of the i-th parameter, nil if it has none. Defaults can be
+// shorter than the parameters (or nil), if the ones at the end are required.
+func DefaultOf(defaults []Expression, i int) Expression {
+	if i < len(defaults) {
+		return defaults[i]
+	}
+	return nil
+}
+
+// Required is the number of parameters that have to be given an arg. Only the parameters at the
+// end can have default values.
+func Required(defaults []Expression, n int) int {
+	for i, def := range defaults {
+		if def != nil {
+			return i
+		}
+	}
+	return n
+}
+
 type MacroLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
@@ -387,6 +692,7 @@ type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Rparen    int // offset of the closing ')'
 }
 
 func (ce *CallExpression) Pos() int             { return ce.Token.Offset }
@@ -407,6 +713,17 @@ func (ce *CallExpression) String() string {
 	return b.String()
 }
 
+// NamedArgument passes Value to the parameter called Name, eg greet(message: "hi").
+type NamedArgument struct {
+	Token token.Token // the name
+	Name  string
+	Value Expression
+}
+
+func (na *NamedArgument) Pos() int             { return na.Token.Offset }
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string       { return na.Name + ": " + na.Value.String() }
+
 type BadExpression struct {
 	Token token.Token // the token.IDENT token
 }
@@ -0,0 +1,236 @@
+package ast
+
+type ModifierFunc func(Expression) Expression
+
+// Modify walks the tree depth-first and replaces every node with the result of calling modifier on
+// it. Children are modified before their parents. The original tree is left untouched: every node
+// on the way is copied, so the same code (eg a macro body) can be safely modified many times.
+func Modify(node Expression, modifier ModifierFunc) Expression {
+	switch node := node.(type) {
+	case *Program:
+		n := *node
+		n.Expressions = modifyAll(node.Expressions, modifier)
+		return modifier(&n)
+
+	case *BlockExpression:
+		return modifier(modifyBlock(node, modifier))
+
+	case *DeclareExpression:
+		n := *node
+		n.Value = Modify(node.Value, modifier)
+		return modifier(&n)
+
+	case *AssignExpression:
+		n := *node
+		n.Left = Modify(node.Left, modifier)
+		n.Value = Modify(node.Value, modifier)
+		return modifier(&n)
+
+	case *DestructureExpression:
+		n := *node
+		n.Pattern = Modify(node.Pattern, modifier)
+		n.Value = Modify(node.Value, modifier)
+		return modifier(&n)
+
+	case *ArrayPattern:
+		n := *node
+		n.Elements = modifyPattern(node.Elements, modifier)
+		if node.Rest != nil {
+			n.Rest = Modify(node.Rest, modifier)
+		}
+		return modifier(&n)
+
+	case *HashmapPattern:
+		n := *node
+		n.Pairs = modifyPattern(node.Pairs, modifier)
+		return modifier(&n)
+
+	case *TypePattern:
+		n := *node
+		n.Target = Modify(node.Target, modifier)
+		return modifier(&n)
+
+	case *YeetExpression:
+		n := *node
+		n.ReturnValue = Modify(node.ReturnValue, modifier)
+		return modifier(&n)
+
+	case *YbreakExpression:
+		n := *node
+		if node.Value != nil {
+			n.Value = Modify(node.Value, modifier)
+		}
+		return modifier(&n)
+
+	case *PrefixExpression:
+		n := *node
+		n.Right = Modify(node.Right, modifier)
+		return modifier(&n)
+
+	case *InfixExpression:
+		n := *node
+		n.Left = Modify(node.Left, modifier)
+		n.Right = Modify(node.Right, modifier)
+		return modifier(&n)
+
+	case *PipeExpression:
+		return modifier(NewPipeExpression(node.Token, Modify(node.Left, modifier), Modify(node.Right, modifier)))
+
+	case *AndExpression:
+		n := *node
+		n.Left = Modify(node.Left, modifier)
+		n.Right = Modify(node.Right, modifier)
+		return modifier(&n)
+
+	case *OrExpression:
+		n := *node
+		n.Left = Modify(node.Left, modifier)
+		n.Right = Modify(node.Right, modifier)
+		return modifier(&n)
+
+	case *IndexExpression:
+		n := *node
+		n.Left = Modify(node.Left, modifier)
+		n.Index = Modify(node.Index, modifier)
+		return modifier(&n)
+
+	case *DotExpression:
+		n := *node
+		n.Left = Modify(node.Left, modifier)
+		return modifier(&n)
+
+	case *YifExpression:
+		n := *node
+		n.Condition = Modify(node.Condition, modifier)
+		n.Consequence = modifyBlock(node.Consequence, modifier)
+		n.Alternative = modifyBlock(node.Alternative, modifier)
+		return modifier(&n)
+
+	case *YoloExpression:
+		n := *node
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *YoyoExpression:
+		n := *node
+		n.Condition = Modify(node.Condition, modifier)
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *YallExpression:
+		n := *node
+		n.Iterable = Modify(node.Iterable, modifier)
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *YtryExpression:
+		n := *node
+		n.Body = modifyBlock(node.Body, modifier)
+		n.Catch = modifyBlock(node.Catch, modifier)
+		return modifier(&n)
+
+	case *YmatchExpression:
+		n := *node
+		n.Subject = Modify(node.Subject, modifier)
+		n.Arms = make([]*MatchArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			a := *arm
+			a.Pattern = Modify(arm.Pattern, modifier)
+			if arm.Guard != nil {
+				a.Guard = Modify(arm.Guard, modifier)
+			}
+			a.Body = Modify(arm.Body, modifier)
+			n.Arms[i] = &a
+		}
+		return modifier(&n)
+
+	case *LambdaLiteral:
+		n := *node
+		if node.Defaults != nil {
+			n.Defaults = make([]Expression, len(node.Defaults))
+			for i, def := range node.Defaults {
+				if def != nil {
+					n.Defaults[i] = Modify(def, modifier)
+				}
+			}
+		}
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *NamedArgument:
+		n := *node
+		n.Value = Modify(node.Value, modifier)
+		return modifier(&n)
+
+	case *CallExpression:
+		n := *node
+		n.Function = Modify(node.Function, modifier)
+		n.Arguments = modifyAll(node.Arguments, modifier)
+		return modifier(&n)
+
+	case *ArrayLiteral:
+		n := *node
+		n.Elements = modifyAll(node.Elements, modifier)
+		return modifier(&n)
+
+	case *HashmapLiteral:
+		n := *node
+		n.Pairs = make([]HashmapPair, len(node.Pairs))
+		for i, pair := range node.Pairs {
+			n.Pairs[i] = HashmapPair{Key: Modify(pair.Key, modifier), Value: Modify(pair.Value, modifier)}
+		}
+		return modifier(&n)
+
+	case *RangeLiteral:
+		n := *node
+		n.Start = Modify(node.Start, modifier)
+		n.End = Modify(node.End, modifier)
+		return modifier(&n)
+
+	case *TemplateStringLiteral:
+		n := *node
+		n.Values = modifyAll(node.Values, modifier)
+		return modifier(&n)
+
+	default:
+		return modifier(node)
+	}
+}
+
+func modifyAll(exprs []Expression, modifier ModifierFunc) []Expression {
+	if exprs == nil {
+		return nil
+	}
+
+	result := make([]Expression, len(exprs))
+	for i, expr := range exprs {
+		result[i] = Modify(expr, modifier)
+	}
+	return result
+}
+
+// modifyBlock modifies expressions inside the block, but keeps the block itself, as places that
+// expect a block (eg a lambda body) can't hold any other kind of expression.
+func modifyBlock(block *BlockExpression, modifier ModifierFunc) *BlockExpression {
+	if block == nil {
+		return nil
+	}
+
+	n := *block
+	n.Expressions = modifyAll(block.Expressions, modifier)
+	return &n
+}
+
+func modifyPattern(elements []PatternElement, modifier ModifierFunc) []PatternElement {
+	result := make([]PatternElement, len(elements))
+	for i, el := range elements {
+		if el.Key != nil {
+			result[i].Key = Modify(el.Key, modifier)
+		}
+		result[i].Target = Modify(el.Target, modifier)
+		if el.Default != nil {
+			result[i].Default = Modify(el.Default, modifier)
+		}
+	}
+	return result
+}
@@ -0,0 +1,141 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"yy/ast"
+	"yy/token"
+)
+
+func TestModify(t *testing.T) {
+	one := func() ast.Expression {
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1}
+	}
+	two := func() ast.Expression {
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2}
+	}
+	block := func(expr ast.Expression) *ast.BlockExpression {
+		return &ast.BlockExpression{Expressions: []ast.Expression{expr}}
+	}
+
+	turnOneIntoTwo := func(node ast.Expression) ast.Expression {
+		integer, ok := node.(*ast.IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	tests := []struct {
+		input    ast.Expression
+		expected ast.Expression
+	}{
+		{one(), two()},
+		{
+			&ast.Program{Expressions: []ast.Expression{one()}},
+			&ast.Program{Expressions: []ast.Expression{two()}},
+		},
+		{
+			&ast.InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&ast.InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&ast.PrefixExpression{Operator: "-", Right: one()},
+			&ast.PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&ast.AndExpression{Left: one(), Right: one()},
+			&ast.AndExpression{Left: two(), Right: two()},
+		},
+		{
+			&ast.OrExpression{Left: one(), Right: one()},
+			&ast.OrExpression{Left: two(), Right: two()},
+		},
+		{
+			&ast.IndexExpression{Left: one(), Index: one()},
+			&ast.IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&ast.YifExpression{Condition: one(), Consequence: block(one()), Alternative: block(one())},
+			&ast.YifExpression{Condition: two(), Consequence: block(two()), Alternative: block(two())},
+		},
+		{
+			&ast.YoloExpression{Body: block(one())},
+			&ast.YoloExpression{Body: block(two())},
+		},
+		{
+			&ast.YoyoExpression{Condition: one(), Body: block(one())},
+			&ast.YoyoExpression{Condition: two(), Body: block(two())},
+		},
+		{
+			&ast.YallExpression{Iterable: one(), ElemName: "yt", Body: block(one())},
+			&ast.YallExpression{Iterable: two(), ElemName: "yt", Body: block(two())},
+		},
+		{
+			&ast.YeetExpression{ReturnValue: one()},
+			&ast.YeetExpression{ReturnValue: two()},
+		},
+		{
+			&ast.DeclareExpression{Name: &ast.Identifier{Value: "x"}, Value: one()},
+			&ast.DeclareExpression{Name: &ast.Identifier{Value: "x"}, Value: two()},
+		},
+		{
+			&ast.AssignExpression{Left: &ast.Identifier{Value: "x"}, Value: one()},
+			&ast.AssignExpression{Left: &ast.Identifier{Value: "x"}, Value: two()},
+		},
+		{
+			&ast.LambdaLiteral{Parameters: []*ast.Identifier{}, Body: block(one())},
+			&ast.LambdaLiteral{Parameters: []*ast.Identifier{}, Body: block(two())},
+		},
+		{
+			&ast.CallExpression{Function: one(), Arguments: []ast.Expression{one(), two()}},
+			&ast.CallExpression{Function: two(), Arguments: []ast.Expression{two(), two()}},
+		},
+		{
+			&ast.ArrayLiteral{Elements: []ast.Expression{one(), one()}},
+			&ast.ArrayLiteral{Elements: []ast.Expression{two(), two()}},
+		},
+		{
+			&ast.RangeLiteral{Start: one(), End: one()},
+			&ast.RangeLiteral{Start: two(), End: two()},
+		},
+		{
+			&ast.TemplateStringLiteral{Template: "%s", Values: []ast.Expression{one()}},
+			&ast.TemplateStringLiteral{Template: "%s", Values: []ast.Expression{two()}},
+		},
+	}
+
+	for _, tt := range tests {
+		original := tt.input.String()
+
+		modified := ast.Modify(tt.input, turnOneIntoTwo)
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. want %#v, got %#v", tt.expected, modified)
+		}
+
+		if tt.input.String() != original {
+			t.Errorf("original tree was modified. want %s, got %s", original, tt.input.String())
+		}
+	}
+
+	hashmapLiteral := &ast.HashmapLiteral{
+		Pairs: []ast.HashmapPair{{Key: one(), Value: one()}},
+	}
+
+	modified := ast.Modify(hashmapLiteral, turnOneIntoTwo).(*ast.HashmapLiteral)
+	for _, pair := range modified.Pairs {
+		if pair.Key.(*ast.IntegerLiteral).Value != 2 || pair.Value.(*ast.IntegerLiteral).Value != 2 {
+			t.Errorf("value is not %d, got %s: %s", 2, pair.Key, pair.Value)
+		}
+	}
+	for _, pair := range hashmapLiteral.Pairs {
+		if pair.Key.(*ast.IntegerLiteral).Value != 1 || pair.Value.(*ast.IntegerLiteral).Value != 1 {
+			t.Errorf("original hashmap was modified, got %s: %s", pair.Key, pair.Value)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+// Package check finds mistakes in YY code without running it: uses of undeclared variables,
+// calls to lambdas with the wrong number of arguments and code that can never run.
+package check
+
+import (
+	"fmt"
+	"sort"
+
+	"yy/ast"
+	"yy/eval"
+	"yy/lexer"
+	"yy/object"
+	"yy/parser"
+	"yy/yikes"
+)
+
+// Source parses src, expands its macros and checks the resulting program. Syntax errors are
+// returned instead, if there are any.
+func Source(src []byte) []yikes.YYError {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return p.Errors()
+	}
+
+	macroEnv := object.NewEnvironment()
+	eval.DefineMacros(program, macroEnv)
+	expanded, macroErr := eval.ExpandMacros(program, macroEnv)
+	if macroErr != nil {
+		return []yikes.YYError{{Msg: macroErr.Msg, Offset: macroErr.Pos}}
+	}
+
+	return Program(expanded.(*ast.Program))
+}
+
+// Program checks a program whose macros have been expanded already. Problems are ordered by their
+// position in the code.
+func Program(program *ast.Program) []yikes.YYError {
+	c := &checker{}
+	c.statements(program.Expressions, newScope(nil))
+
+	// lambda bodies run when they're called, so they are checked once all variables around them are
+	// declared: a lambda can use a variable declared after it, as long as it's called later
+	for i := 0; i < len(c.lambdas); i++ {
+		c.lambdaBody(c.lambdas[i].lambda, c.lambdas[i].scope)
+	}
+
+	for _, call := range c.calls {
+		if fn := call.variable.knownLambda(); fn != nil {
+			c.args(call.node, fn)
+		}
+	}
+
+	sort.SliceStable(c.errors, func(i, j int) bool { return c.errors[i].Offset < c.errors[j].Offset })
+
+	// the same mistake can be found twice, eg an undeclared variable in 'x += 1' is both used and
+	// assigned to
+	errors := []yikes.YYError{}
+	for i, err := range c.errors {
+		if i == 0 || err.Offset != c.errors[i-1].Offset {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+// scope mirrors object.Environment: variables declared in it and the scope it's enclosed by.
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+	yolo  bool
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, vars: map[string]*variable{}}
+}
+
+func (s *scope) lookup(name string) *variable {
+	for sc := s; sc != nil; sc = sc.outer {
+		if v, ok := sc.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (s *scope) isYolo() bool {
+	for sc := s; sc != nil; sc = sc.outer {
+		if sc.yolo {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scope) declare(name string, value ast.Expression) *variable {
+	v, ok := s.vars[name]
+	if !ok {
+		v = &variable{}
+		s.vars[name] = v
+	}
+	v.declarations++
+	v.lambda, _ = value.(*ast.LambdaLiteral)
+	return v
+}
+
+type variable struct {
+	declarations int
+	assigned     bool
+	lambda       *ast.LambdaLiteral // value of the latest declaration, if it's a lambda
+}
+
+// knownLambda returns the lambda the variable holds, if it can't ever hold anything else.
+func (v *variable) knownLambda() *ast.LambdaLiteral {
+	if v.declarations != 1 || v.assigned {
+		return nil
+	}
+	return v.lambda
+}
+
+type checker struct {
+	errors  []yikes.YYError
+	lambdas []lambda // lambdas whose bodies are yet to be checked
+	calls   []call   // calls to variables, checked for arity at the very end
+}
+
+type lambda struct {
+	lambda *ast.LambdaLiteral
+	scope  *scope
+}
+
+type call struct {
+	node     *ast.CallExpression
+	variable *variable
+}
+
+func (c *checker) errorf(offset int, format string, args ...any) {
+	c.errors = append(c.errors, yikes.YYError{Msg: fmt.Sprintf(format, args...), Offset: offset})
+}
+
+// statements checks expressions in the order they run, like the ones in a program or a block.
+func (c *checker) statements(exprs []ast.Expression, sc *scope) {
+	for i, expr := range exprs {
+		c.expr(expr, sc)
+
+		if keyword := jumpKeyword(expr); keyword != "" && i+1 < len(exprs) {
+			c.errorf(startOf(exprs[i+1]), "unreachable code after %s", keyword)
+			return
+		}
+	}
+}
+
+func (c *checker) block(block *ast.BlockExpression, sc *scope) {
+	if block == nil {
+		return
+	}
+	c.statements(block.Expressions, newScope(sc))
+}
+
+func (c *checker) lambdaBody(fn *ast.LambdaLiteral, sc *scope) {
+	params := newScope(sc)
+	params.declare("self", nil)
+	for _, param := range fn.Parameters {
+		params.declare(param.Value, nil)
+	}
+	if fn.Rest != nil {
+		params.declare(fn.Rest.Value, nil)
+	}
+	for _, def := range fn.Defaults {
+		if def != nil {
+			c.expr(def, params)
+		}
+	}
+	c.block(fn.Body, params)
+}
+
+func (c *checker) expr(node ast.Expression, sc *scope) {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		if sc.lookup(node.Value) == nil && !isBuiltin(node.Value) {
+			c.errorf(node.Pos(), "identifier not found: %s", node.Value)
+		}
+
+	case *ast.DeclareExpression:
+		c.expr(node.Value, sc)
+		sc.declare(node.Name.Value, node.Value)
+
+	case *ast.AssignExpression:
+		c.expr(node.Value, sc)
+		c.assign(node.Left, node.Value, sc)
+
+	case *ast.DestructureExpression:
+		c.expr(node.Value, sc)
+		c.pattern(node.Pattern, node.Declare(), sc)
+
+	case *ast.YeetExpression:
+		c.expr(node.ReturnValue, sc)
+
+	case *ast.YbreakExpression:
+		if node.Value != nil {
+			c.expr(node.Value, sc)
+		}
+
+	case *ast.PrefixExpression:
+		c.expr(node.Right, sc)
+
+	case *ast.InfixExpression:
+		c.expr(node.Left, sc)
+		c.expr(node.Right, sc)
+
+	case *ast.PipeExpression:
+		c.expr(node.Call, sc)
+
+	case *ast.AndExpression:
+		c.expr(node.Left, sc)
+		c.expr(node.Right, sc)
+
+	case *ast.OrExpression:
+		c.expr(node.Left, sc)
+		c.expr(node.Right, sc)
+
+	case *ast.IndexExpression:
+		c.expr(node.Left, sc)
+		c.expr(node.Index, sc)
+
+	case *ast.DotExpression:
+		c.expr(node.Left, sc)
+
+	case *ast.RangeLiteral:
+		c.expr(node.Start, sc)
+		c.expr(node.End, sc)
+
+	case *ast.ArrayLiteral:
+		c.exprs(node.Elements, sc)
+
+	case *ast.HashmapLiteral:
+		for _, pair := range node.Pairs {
+			c.expr(pair.Key, sc)
+			c.expr(pair.Value, sc)
+		}
+
+	case *ast.TemplateStringLiteral:
+		c.exprs(node.Values, sc)
+
+	case *ast.BlockExpression:
+		c.block(node, sc)
+
+	case *ast.YifExpression:
+		c.expr(node.Condition, sc)
+		c.block(node.Consequence, sc)
+		c.block(node.Alternative, sc)
+
+	case *ast.YoloExpression:
+		yolo := newScope(sc)
+		yolo.yolo = true
+		c.block(node.Body, yolo)
+
+	case *ast.YoyoExpression:
+		loop := newScope(sc)
+		c.expr(node.Condition, loop)
+		c.block(node.Body, loop)
+
+	case *ast.YallExpression:
+		c.expr(node.Iterable, sc)
+		loop := newScope(sc)
+		if node.IndexName != "" {
+			loop.declare(node.IndexName, nil)
+		}
+		loop.declare(node.ElemName, nil)
+		c.block(node.Body, loop)
+
+	case *ast.YtryExpression:
+		c.block(node.Body, sc)
+		catch := newScope(sc)
+		catch.declare(node.ErrName, nil)
+		c.block(node.Catch, catch)
+
+	case *ast.YmatchExpression:
+		c.expr(node.Subject, sc)
+		for _, arm := range node.Arms {
+			inner := newScope(sc)
+			c.pattern(arm.Pattern, true, inner)
+			if arm.Guard != nil {
+				c.expr(arm.Guard, inner)
+			}
+			c.expr(arm.Body, inner)
+		}
+
+	case *ast.LambdaLiteral:
+		c.lambdas = append(c.lambdas, lambda{node, sc})
+
+	case *ast.CallExpression:
+		if isIdent(node.Function, "quote") {
+			c.quoted(node.Arguments, sc)
+			return
+		}
+
+		c.expr(node.Function, sc)
+		c.exprs(node.Arguments, sc)
+
+		switch fn := node.Function.(type) {
+		case *ast.Identifier:
+			if v := sc.lookup(fn.Value); v != nil {
+				c.calls = append(c.calls, call{node, v})
+			}
+		case *ast.LambdaLiteral:
+			c.args(node, fn)
+		}
+
+	case *ast.NamedArgument:
+		c.expr(node.Value, sc)
+	}
+}
+
+// args checks that args of a call match parameters of the lambda it calls.
+func (c *checker) args(node *ast.CallExpression, fn *ast.LambdaLiteral) {
+	params := make([]string, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		params[i] = param.Value
+	}
+
+	// only the number of args and their names matter
+	args := make([]object.Object, len(node.Arguments))
+	var names []string
+	for i, arg := range node.Arguments {
+		args[i] = object.NULL
+		if named, ok := arg.(*ast.NamedArgument); ok {
+			names = append(names, named.Name)
+		}
+	}
+
+	required := ast.Required(fn.Defaults, len(params))
+	if _, _, err := eval.BindArgs(node.Function.TokenLiteral(), params, required, fn.Rest != nil, args, names); err != nil {
+		c.errorf(node.Pos(), "%s", err.Msg)
+	}
+}
+
+// assign checks assigning value to target. Value is nil if it isn't known.
+func (c *checker) assign(target, value ast.Expression, sc *scope) {
+	ident, ok := target.(*ast.Identifier)
+	if !ok {
+		c.expr(target, sc)
+		return
+	}
+
+	switch v := sc.lookup(ident.Value); {
+	case v != nil:
+		v.assigned = true
+	case sc.isYolo():
+		sc.declare(ident.Value, value)
+	default:
+		c.errorf(ident.Pos(), "identifier not found: %s (to declare a variable use := operator)", ident.Value)
+	}
+}
+
+// pattern checks a destructuring pattern, declaring its targets (or assigning to them).
+func (c *checker) pattern(pattern ast.Expression, declaring bool, sc *scope) {
+	var elements []ast.PatternElement
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		elements = pattern.Elements
+	case *ast.HashmapPattern:
+		elements = pattern.Pairs
+	case *ast.TypePattern:
+		c.pattern(pattern.Target, declaring, sc)
+		return
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			return
+		}
+		if declaring {
+			sc.declare(pattern.Value, nil)
+			return
+		}
+		c.assign(pattern, nil, sc)
+		return
+	default:
+		c.assign(pattern, nil, sc)
+		return
+	}
+
+	for _, el := range elements {
+		if el.Key != nil {
+			c.expr(el.Key, sc)
+		}
+		if el.Default != nil {
+			c.expr(el.Default, sc)
+		}
+		c.pattern(el.Target, declaring, sc)
+	}
+	if arr, ok := pattern.(*ast.ArrayPattern); ok && arr.Rest != nil {
+		c.pattern(arr.Rest, declaring, sc)
+	}
+}
+
+func (c *checker) exprs(exprs []ast.Expression, sc *scope) {
+	for _, expr := range exprs {
+		c.expr(expr, sc)
+	}
+}
+
+// quoted checks code passed to quote(): it isn't evaluated, apart from calls to unquote() inside it.
+func (c *checker) quoted(exprs []ast.Expression, sc *scope) {
+	for _, expr := range exprs {
+		ast.Modify(expr, func(node ast.Expression) ast.Expression {
+			if call, ok := node.(*ast.CallExpression); ok && isIdent(call.Function, "unquote") {
+				c.exprs(call.Arguments, sc)
+			}
+			return node
+		})
+	}
+}
+
+func isIdent(node ast.Expression, name string) bool {
+	ident, ok := node.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+func isBuiltin(name string) bool {
+	_, ok := eval.LookupBuiltin(name)
+	return ok
+}
+
+// jumpKeyword returns the keyword of expr if it always leaves the code it's in, or "" if it doesn't.
+func jumpKeyword(expr ast.Expression) string {
+	switch expr.(type) {
+	case *ast.YeetExpression, *ast.YbreakExpression, *ast.YcontinueExpression:
+		return expr.TokenLiteral()
+	}
+	return ""
+}
+
+// startOf returns the offset where the code of expr begins, which for some expressions is before
+// their Pos().
+func startOf(expr ast.Expression) int {
+	switch node := expr.(type) {
+	case *ast.DeclareExpression:
+		return node.Name.Pos()
+	case *ast.AssignExpression:
+		return startOf(node.Left)
+	case *ast.DestructureExpression:
+		return node.Pattern.Pos()
+	case *ast.InfixExpression:
+		return startOf(node.Left)
+	case *ast.PipeExpression:
+		return startOf(node.Left)
+	case *ast.AndExpression:
+		return startOf(node.Left)
+	case *ast.OrExpression:
+		return startOf(node.Left)
+	case *ast.RangeLiteral:
+		return startOf(node.Start)
+	case *ast.IndexExpression:
+		return startOf(node.Left)
+	case *ast.DotExpression:
+		return startOf(node.Left)
+	case *ast.CallExpression:
+		return startOf(node.Function)
+	case *ast.YoyoExpression:
+		if node.Label != "" {
+			return node.LabelPos
+		}
+	case *ast.YallExpression:
+		if node.Label != "" {
+			return node.LabelPos
+		}
+	case *ast.StringLiteral, *ast.TemplateStringLiteral:
+		return node.Pos() - 1 // the opening quote
+	}
+	return expr.Pos()
+}
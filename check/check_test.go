@@ -0,0 +1,128 @@
+package check_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yy/check"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // problems as "offset: msg"
+	}{
+		{"x := 1; yap(x)", nil},
+		{"yap(x)", []string{"4: identifier not found: x"}},
+		{"yap(x); x := 1", []string{"4: identifier not found: x"}},
+		{"x := x + 1", []string{"5: identifier not found: x"}},
+		{"len([1]); quote(x + unquote(1)); quote(unquote(y))", []string{"47: identifier not found: y"}},
+
+		// scopes
+		{"{ x := 1 }; x", []string{"12: identifier not found: x"}},
+		{"yif true { x := 1 } yels { x }", []string{"27: identifier not found: x"}},
+		{"x := 1; { x := 2; x }; x", nil},
+		{"yall [1] { yt }; yall e: [1] { e }", nil},
+		{"yall e: [1] { yt }", []string{"14: identifier not found: yt"}},
+		{"yall [1] { yt }; yt", []string{"17: identifier not found: yt"}},
+		{"yall i, e: [1] { i + e }; i", []string{"26: identifier not found: i"}},
+		{"ytry { 1 } ycatch { err }; ytry { 1 } ycatch e { e }", nil},
+		{"ytry { 1 } ycatch e { err }", []string{"22: identifier not found: err"}},
+		{"i := 0; yoyo i < 3 { i += 1 }", nil},
+
+		// lambdas see variables declared after them, as they run later
+		{"f := \\n { n + g() }; g := \\{ 1 }", nil},
+		{"fib := \\n { yif n < 2 { n } yels { fib(n - 1) + fib(n - 2) } }", nil},
+		{"f := \\a { b }", []string{"10: identifier not found: b"}},
+		{"f := \\a { a }; a", []string{"15: identifier not found: a"}},
+		{"h := %{\"f\": \\{ self }}; self", []string{"24: identifier not found: self"}},
+		{"f := \\{ yap(x); x := 1 }", []string{"12: identifier not found: x"}},
+
+		// assignments
+		{"x := 1; x = 2; x += 3", nil},
+		{"x = 2", []string{"0: identifier not found: x (to declare a variable use := operator)"}},
+		{"x += 2", []string{"0: identifier not found: x"}},
+		{"arr := [1]; arr[0] = 2; nope[0] = 1", []string{"24: identifier not found: nope"}},
+		{"yolo { x = 2; yap(x) }", nil},
+		{"yolo { f := \\{ x = 1; x } }", nil},
+		{"yolo { x = 2 }; x", []string{"16: identifier not found: x"}},
+
+		// destructuring
+		{"[a, [b, ...c], %{\"d\": d = a}] := x(); yap(a, b, c, d)", []string{"33: identifier not found: x"}},
+		{"{ [a] := [1] }; a", []string{"16: identifier not found: a"}},
+		{"[_, b] := [1, 2]; b; _", []string{"21: identifier not found: _"}},
+
+		// ymatch
+		{"ymatch x() { [a, ...b] yif a > 0 => b, integer(n) => n, _ => c }", []string{"7: identifier not found: x", "61: identifier not found: c"}},
+		{"ymatch 1 { n => n }; n", []string{"21: identifier not found: n"}},
+		{"a := 1; [a, b] = [1, 2]", []string{"12: identifier not found: b (to declare a variable use := operator)"}},
+		{"[f] := [\\{ 1 }]; f(1)", nil},
+
+		// arity
+		{"add := \\a b { a + b }; add(1, 2)", nil},
+		{"add := \\a b { a + b }; add(1)", []string{"26: wrong number of args for add (got 1, want 2)"}},
+		{"f := \\{ g(1, 2) }; g := \\x { x }", []string{"9: wrong number of args for g (got 2, want 1)"}},
+		{"add := \\a b { a + b }; 1 |> add(2)", nil},
+		{"add := \\a b { a + b }; 1 |> add", []string{"25: wrong number of args for add (got 1, want 2)"}},
+		{"add := \\a b { a + b }; 1 |> add(2, 3)", []string{"31: wrong number of args for add (got 3, want 2)"}},
+		{"\\x { x }(1, 2)", []string{"8: wrong number of args for \\ (got 2, want 1)"}},
+		{"f := \\x { x }; f = \\{ 1 }; f()", nil},
+		{"f := \\x { x }; f := \\{ 1 }; f()", nil},
+		{"f := \\x { x }; { f := \\{ 1 }; f() }", nil},
+		{"yolo { add := \\a b { a + b }; add1 := add + 1; add1(2) }", nil},
+		{"f := \\a, b = 1, ...rest { a + b + len(rest) }; f(1); f(1, 2, 3, 4)", nil},
+		{"f := \\a, b = 1 { a }; f()", []string{"23: wrong number of args for f (got 0, want at least 1)"}},
+		{"f := \\a, b = 1 { a }; f(1, 2, 3)", []string{"23: wrong number of args for f (got 3, want at most 2)"}},
+		{"f := \\a, b = a { a + c }", []string{"21: identifier not found: c"}},
+		{"f := \\a = c { a }", []string{"10: identifier not found: c"}},
+		{"f := \\...rest { rest }; f(1, 2)", nil},
+		{"f := \\name, msg { msg }; f(msg: 1, name: 2)", nil},
+		{"f := \\name, msg { msg }; f(1, nme: 2)", []string{"26: no parameter called nme for f"}},
+		{"f := \\name, msg { msg }; f(msg: x)", []string{"26: missing arg for parameter name for f", "32: identifier not found: x"}},
+
+		// unreachable code
+		{"f := \\x {\n  yeet x\n  x + 1\n}", []string{"21: unreachable code after yeet"}},
+		{"f := \\x { yif x { yeet 1 }; 2 }", nil},
+		{"yeet 1; \"nope\"", []string{"8: unreachable code after yeet"}},
+		{"yall [1] { ybreak; 2 }", []string{"19: unreachable code after ybreak"}},
+		{"yall [1] { yif yt { ycontinue; 2 } }", []string{"31: unreachable code after ycontinue"}},
+
+		// macros are expanded before checking
+		{"unless := @\\c t f { quote(yif !(unquote(c)) { unquote(t) } yels { unquote(f) }) }; unless(true, 1, nope)", []string{"99: identifier not found: nope"}},
+
+		// syntax errors are reported as they are
+		{"x := (1 + ", []string{"8: unexpected token 'EOF'"}},
+	}
+
+	for _, tt := range tests {
+		problems := []string{}
+		for _, p := range check.Source([]byte(tt.input)) {
+			problems = append(problems, fmt.Sprintf("%d: %s", p.Offset, p.Msg))
+		}
+
+		if strings.Join(problems, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: wrong problems.\nexpected:\n%s\ngot:\n%s",
+				tt.input, strings.Join(tt.expected, "\n"), strings.Join(problems, "\n"))
+		}
+	}
+}
+
+func TestExampleFiles(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.yeet")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("couldn't find example files: %v", err)
+	}
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range check.Source(src) {
+			t.Errorf("%s: unexpected problem at %d: %s", file, p.Offset, p.Msg)
+		}
+	}
+}
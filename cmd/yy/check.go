@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"yy/check"
+	"yy/yikes"
+)
+
+// checkCmd implements 'yy check'. It looks for mistakes in *.yeet files found in paths without
+// running them, and returns the exit code.
+func checkCmd(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: yy check [paths...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := findFiles(paths, ".yeet")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return 2
+	}
+
+	failed := false
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: couldn't read file: %s\n", err)
+			failed = true
+			continue
+		}
+		if !checkFile(os.Stdout, file, src) {
+			failed = true
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// checkFile prints problems found in src to out and reports whether there were none.
+func checkFile(out io.Writer, file string, src []byte) bool {
+	problems := check.Source(src)
+	for _, p := range problems {
+		fmt.Fprintf(out, "%s:\n%s\n", file, yikes.PrettyError(src, p.Offset, p.Msg))
+	}
+	return len(problems) == 0
+}
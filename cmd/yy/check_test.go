@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCheckFile(t *testing.T) {
+	tests := []struct {
+		src      string
+		ok       bool
+		expected string
+	}{
+		{"x := 1\nyap(x)\n", true, ""},
+		{
+			"x := 1\nyap(y)\n",
+			false,
+			"bad.yeet:\nerror: identifier not found: y\n  2 | yap(y)\n          ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if ok := checkFile(&out, "bad.yeet", []byte(tt.src)); ok != tt.ok {
+			t.Errorf("%q: wrong result. expected=%t, got=%t", tt.src, tt.ok, ok)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("%q: wrong output. expected=%q, got=%q", tt.src, tt.expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"yy/format"
+	"yy/yikes"
+)
+
+// fmtCmd implements 'yy fmt'. It formats *.yeet files found in paths, or stdin if there are none,
+// and returns the exit code.
+func fmtCmd(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	list := flags.Bool("l", false, "list files whose formatting differs from yy fmt's")
+	diff := flags.Bool("d", false, "print diffs instead of the formatted code")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: yy fmt [-w] [-l] [-d] [paths...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	f := &formatter{out: os.Stdout, errOut: os.Stderr, write: *write, list: *list, diff: *diff}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "error: cannot use -w with standard input")
+			return 2
+		}
+
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return 2
+		}
+		f.format("<stdin>", src)
+	} else {
+		files, err := findFiles(paths, ".yeet")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return 2
+		}
+
+		for _, file := range files {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: couldn't read file: %s\n", err)
+				f.failed = true
+				continue
+			}
+			f.format(file, src)
+		}
+	}
+
+	if f.failed {
+		return 1
+	}
+	return 0
+}
+
+type formatter struct {
+	out    io.Writer
+	errOut io.Writer
+
+	write bool // overwrite files with the formatted code
+	list  bool // list names of files that aren't formatted
+	diff  bool // print diffs of files that aren't formatted
+
+	failed bool
+}
+
+func (f *formatter) format(file string, src []byte) {
+	res, err := format.Source(src)
+	if err != nil {
+		var yyErr *yikes.YYError
+		if errors.As(err, &yyErr) {
+			fmt.Fprintf(f.errOut, "%s:\n%s\n", file, yikes.PrettyError(src, yyErr.Offset, yyErr.Msg))
+		} else {
+			fmt.Fprintf(f.errOut, "%s: error: %s\n", file, err)
+		}
+		f.failed = true
+		return
+	}
+
+	changed := !bytes.Equal(src, res)
+
+	if f.list && changed {
+		fmt.Fprintln(f.out, file)
+	}
+
+	if f.write && changed {
+		info, err := os.Stat(file)
+		if err == nil {
+			err = os.WriteFile(file, res, info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintf(f.errOut, "error: couldn't write file: %s\n", err)
+			f.failed = true
+			return
+		}
+	}
+
+	if f.diff && changed {
+		fmt.Fprint(f.out, unifiedDiff(file, src, res))
+	}
+
+	if !f.list && !f.write && !f.diff {
+		f.out.Write(res)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatter(t *testing.T) {
+	const (
+		messy     = "x:=1\nyap( x )\n"
+		formatted = "x := 1\nyap(x)\n"
+	)
+
+	tests := []struct {
+		formatter formatter
+		out       string
+		written   string
+	}{
+		{formatter{}, formatted, messy},
+		{formatter{list: true}, "{file}\n", messy},
+		{formatter{diff: true}, "--- {file}.orig\n+++ {file}\n@@ -1,2 +1,2 @@\n-x:=1\n-yap( x )\n+x := 1\n+yap(x)\n", messy},
+		{formatter{write: true}, "", formatted},
+		{formatter{write: true, list: true}, "{file}\n", formatted},
+	}
+
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "messy.yeet")
+		if err := os.WriteFile(file, []byte(messy), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		var out, errOut bytes.Buffer
+		f := tt.formatter
+		f.out, f.errOut = &out, &errOut
+		f.format(file, []byte(messy))
+
+		if f.failed || errOut.Len() > 0 {
+			t.Errorf("unexpected failure: %s", errOut.String())
+		}
+		if expected := strings.ReplaceAll(tt.out, "{file}", file); out.String() != expected {
+			t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+		}
+		if written, _ := os.ReadFile(file); string(written) != tt.written {
+			t.Errorf("wrong file contents. expected=%q, got=%q", tt.written, written)
+		}
+	}
+}
+
+func TestFormatterSyntaxError(t *testing.T) {
+	var out, errOut bytes.Buffer
+	f := formatter{out: &out, errOut: &errOut}
+	f.format("bad.yeet", []byte("x := (1 + "))
+
+	if !f.failed {
+		t.Errorf("expected formatting to fail")
+	}
+	if out.Len() > 0 {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+	if !strings.HasPrefix(errOut.String(), "bad.yeet:\nerror: ") {
+		t.Errorf("wrong error: %q", errOut.String())
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(from, to int) string {
+		var b strings.Builder
+		for i := from; i <= to; i++ {
+			b.WriteString(strings.Repeat("x", i) + "\n")
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		old, new string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"", "a\n", "--- f.orig\n+++ f\n@@ -0,0 +1,1 @@\n+a\n"},
+		{"a\nb\nc\n", "a\nc\n", "--- f.orig\n+++ f\n@@ -1,3 +1,2 @@\n a\n-b\n c\n"},
+		{
+			lines(1, 10) + "old\n" + lines(11, 20),
+			lines(1, 10) + "new\n" + lines(11, 20),
+			"--- f.orig\n+++ f\n@@ -8,7 +8,7 @@\n" +
+				" xxxxxxxx\n xxxxxxxxx\n xxxxxxxxxx\n-old\n+new\n xxxxxxxxxxx\n xxxxxxxxxxxx\n xxxxxxxxxxxxx\n",
+		},
+		{
+			"a\n" + lines(1, 7) + "b\n",
+			"A\n" + lines(1, 7) + "B\n",
+			"--- f.orig\n+++ f\n@@ -1,4 +1,4 @@\n-a\n+A\n x\n xx\n xxx\n@@ -6,4 +6,4 @@\n xxxxx\n xxxxxx\n xxxxxxx\n-b\n+B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		result := unifiedDiff("f", []byte(tt.old), []byte(tt.new))
+		if result != tt.expected {
+			t.Errorf("wrong diff of %q and %q.\nexpected:\n%s\ngot:\n%s", tt.old, tt.new, tt.expected, result)
+		}
+	}
+}
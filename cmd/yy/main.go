@@ -2,15 +2,21 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"io"
 	"math/rand"
 	"os"
 
+	"yy"
+	"yy/compiler"
 	"yy/eval"
 	"yy/lexer"
+	"yy/lsp"
 	"yy/object"
 	"yy/parser"
+	"yy/vm"
 	"yy/yikes"
 )
 
@@ -18,16 +24,45 @@ const version = "v0.0.1"
 
 var debug = false
 
+var (
+	useVM     = flag.Bool("vm", false, "compile to bytecode and run it on the virtual machine")
+	maxDepth  = flag.Int("max-depth", object.DefaultMaxDepth, "maximum depth of nested function calls")
+	maxSteps  = flag.Int("max-steps", 0, "maximum number of evaluation steps, 0 means no limit")
+	maxLength = flag.Int("max-length", 0, "maximum length of strings and arrays built by the program, 0 means no limit")
+	timeout   = flag.Duration("timeout", 0, "maximum running time, eg 5s, 0 means no limit")
+)
+
 func main() {
-	switch len(os.Args) {
-	case 1:
+	flag.Parse()
+
+	switch {
+	case flag.NArg() == 0:
 		repl()
 
-	case 2:
-		runFile(os.Args[1])
+	case flag.Arg(0) == "test":
+		os.Exit(testCmd(flag.Args()[1:]))
+
+	case flag.Arg(0) == "fmt":
+		os.Exit(fmtCmd(flag.Args()[1:]))
+
+	case flag.Arg(0) == "check":
+		os.Exit(checkCmd(flag.Args()[1:]))
+
+	case flag.Arg(0) == "lsp":
+		if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+			fmt.Fprintln(os.Stderr, "error: "+err.Error())
+			os.Exit(1)
+		}
+
+	case flag.NArg() == 1:
+		runFile(flag.Arg(0))
 
 	default:
-		fmt.Println("usage: yy [path_to_script]")
+		fmt.Println("usage: yy [--vm] [--max-depth n] [--max-steps n] [--max-length n] [--timeout d] [path_to_script]")
+		fmt.Println("       yy [--vm] [--max-steps n] [--timeout d] test [-run regexp] [paths...]")
+		fmt.Println("       yy fmt [-w] [-l] [-d] [paths...]")
+		fmt.Println("       yy check [paths...]")
+		fmt.Println("       yy lsp")
 	}
 }
 
@@ -49,15 +84,56 @@ func runFile(f string) {
 		os.Exit(1)
 	}
 
-	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+	eval.DefineMacros(program, macroEnv)
+	expanded, macroErr := eval.ExpandMacros(program, macroEnv)
+	if macroErr != nil {
+		fmt.Println(yikes.PrettyError(src, macroErr.Pos, macroErr.Msg))
+		os.Exit(1)
+	}
+
+	guard, cancel := newGuard()
+	defer cancel()
+
+	var result object.Object
+	if *useVM {
+		comp := compiler.New()
+		comp.SetFile(f)
+		if err := comp.Compile(expanded); err != nil {
+			compileErr := err.(*yikes.YYError)
+			fmt.Println(yikes.PrettyError(src, compileErr.Offset, compileErr.Msg))
+			os.Exit(1)
+		}
+		machine := vm.New(comp.Bytecode())
+		machine.SetSource(src)
+		machine.SetGuard(guard)
+		result = machine.Run()
+	} else {
+		env := object.NewEnvironment()
+		module := object.NewModule(f)
+		module.SetSource(src)
+		env.SetModule(module)
+		env.SetGuard(guard)
+		eval.Resolve(expanded)
+		result = eval.Eval(expanded, env)
+	}
 
-	result := eval.Eval(program, env)
 	if evalError, ok := result.(*object.Error); ok {
-		fmt.Println(yikes.PrettyError(src, evalError.Pos, evalError.Msg))
+		fmt.Println(yikes.PrettyTrace(src, f, evalError.Msg, yy.StackTrace(evalError)))
 		os.Exit(1)
 	}
 }
 
+// newGuard sets up a guard enforcing the limits given on the command line. Cancel has to be called
+// once the run is over.
+func newGuard() (*object.Guard, context.CancelFunc) {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if *timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+	}
+	return object.NewGuard(ctx, object.Limits{MaxSteps: *maxSteps, MaxDepth: *maxDepth, MaxLength: *maxLength}), cancel
+}
+
 const (
 	greet   = "YeetYoink " + version
 	prompt  = "yy> "
@@ -70,6 +146,13 @@ func repl() {
 	scanner := bufio.NewScanner(in)
 
 	env := object.NewEnvironment()
+	env.SetModule(object.NewModule(""))
+	macroEnv := object.NewEnvironment()
+
+	// the VM needs its state to survive between lines as well
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
 
 	fmt.Println(greet)
 
@@ -97,7 +180,36 @@ func repl() {
 			continue
 		}
 
-		result := eval.Eval(program, env)
+		eval.DefineMacros(program, macroEnv)
+		expanded, macroErr := eval.ExpandMacros(program, macroEnv)
+		if macroErr != nil {
+			io.WriteString(out, macroErr.Msg+"\n")
+			continue
+		}
+
+		// every line gets limits of its own
+		guard, cancel := newGuard()
+
+		var result object.Object
+		if *useVM {
+			comp := compiler.NewWithState(symbolTable, constants)
+			if err := comp.Compile(expanded); err != nil {
+				cancel()
+				io.WriteString(out, err.Error()+"\n")
+				continue
+			}
+			bytecode := comp.Bytecode()
+			constants = bytecode.Constants
+			machine := vm.NewWithGlobals(bytecode, globals)
+			machine.SetGuard(guard)
+			result = machine.Run()
+		} else {
+			eval.Resolve(expanded)
+			env.SetGuard(guard)
+			result = eval.Eval(expanded, env)
+		}
+		cancel()
+
 		if result != nil {
 			io.WriteString(out, result.String())
 			io.WriteString(out, "\n")
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"yy"
+	"yy/ast"
+	"yy/compiler"
+	"yy/eval"
+	"yy/lexer"
+	"yy/object"
+	"yy/parser"
+	"yy/token"
+	"yy/vm"
+	"yy/yikes"
+)
+
+// testCmd implements 'yy test'. It runs every top-level function named test_* in *_test.yeet files
+// found in paths, and returns the exit code.
+func testCmd(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	run := flags.String("run", "", "only run tests with names matching the regexp")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: yy [--vm] test [-run regexp] [paths...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	filter, err := regexp.Compile(*run)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: invalid -run regexp: %s\n", err)
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := findFiles(paths, "_test.yeet")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return 2
+	}
+
+	r := &testRunner{out: os.Stdout, stdout: os.Stdout, filter: filter, useVM: *useVM, start: time.Now()}
+	for _, f := range files {
+		r.runFile(f)
+	}
+
+	if !r.report() {
+		return 1
+	}
+	return 0
+}
+
+// findFiles lists files with names ending in suffix in paths. Directories are searched recursively,
+// files are taken as they are.
+func findFiles(paths []string, suffix string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, suffix) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+type testRunner struct {
+	out    io.Writer // where results are reported
+	stdout io.Writer // where the tests print (yap & co)
+	filter *regexp.Regexp
+	useVM  bool
+
+	passed int
+	failed int
+	start  time.Time
+}
+
+// runFile runs tests in a single file. Each test gets a fresh environment: the code of the file is
+// run from scratch and then the test function is called. Only the first run of the file prints,
+// and reports the errors it runs into, the other ones run quietly.
+func (r *testRunner) runFile(file string) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(r.out, "FAIL %s\n    error: couldn't read file: %s\n", file, err)
+		r.failed++
+		return
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintf(r.out, "FAIL %s\n", file)
+		for _, parseErr := range p.Errors() {
+			fmt.Fprintln(r.out, indent(yikes.PrettyError(src, parseErr.Offset, parseErr.Msg)))
+		}
+		r.failed++
+		return
+	}
+
+	macroEnv := object.NewEnvironment()
+	eval.DefineMacros(program, macroEnv)
+	expanded, macroErr := eval.ExpandMacros(program, macroEnv)
+	if macroErr != nil {
+		fmt.Fprintf(r.out, "FAIL %s\n%s\n", file, indent(yikes.PrettyError(src, macroErr.Pos, macroErr.Msg)))
+		r.failed++
+		return
+	}
+	expandedProgram := expanded.(*ast.Program)
+	eval.Resolve(expandedProgram)
+
+	tests := findTests(expandedProgram)
+	if len(tests) == 0 {
+		fmt.Fprintf(r.out, "?    %s [no tests]\n", file)
+		return
+	}
+
+	fmt.Fprintf(r.out, "=== %s\n", file)
+	selected := []*ast.Identifier{}
+	for _, test := range tests {
+		if r.filter.MatchString(test.Value) {
+			selected = append(selected, test)
+		}
+	}
+	if len(selected) == 0 {
+		return
+	}
+
+	s := r.newSession(file)
+	if result := s.load(expandedProgram, r.stdout); isError(result) {
+		// the tests can't run without the code they test
+		r.failed++
+		fmt.Fprintf(r.out, "FAIL %s\n", file)
+		fmt.Fprintln(r.out, indent(errorTrace(src, file, result)))
+		return
+	}
+
+	for i, test := range selected {
+		// the first test runs in the environment the file has just been run in
+		var result object.Object
+		if i > 0 {
+			s = r.newSession(file)
+			result = s.load(expandedProgram, io.Discard)
+		}
+
+		start := time.Now()
+		if !isError(result) {
+			result = s.call(test)
+		}
+		elapsed := time.Since(start)
+
+		if isError(result) {
+			r.failed++
+			fmt.Fprintf(r.out, "FAIL %s (%s)\n", test.Value, formatDuration(elapsed))
+			fmt.Fprintln(r.out, indent(errorTrace(src, file, result)))
+			continue
+		}
+
+		r.passed++
+		fmt.Fprintf(r.out, "ok   %s (%s)\n", test.Value, formatDuration(elapsed))
+	}
+}
+
+// newSession creates a session for a test file, running it with the backend chosen by the user.
+func (r *testRunner) newSession(file string) testSession {
+	if r.useVM {
+		return &vmSession{file: file}
+	}
+	return &evalSession{file: file}
+}
+
+// testSession runs the code of a test file, then calls its tests.
+type testSession interface {
+	// load runs the program, with print builtins printing to stdout.
+	load(program *ast.Program, stdout io.Writer) object.Object
+	// call calls a test declared by the program.
+	call(test *ast.Identifier) object.Object
+}
+
+type evalSession struct {
+	file string
+	env  *object.Environment
+}
+
+func (s *evalSession) load(program *ast.Program, stdout io.Writer) object.Object {
+	// print builtins are shared with modules the file imports, so they don't print either
+	host := object.NewEnvironment()
+	for name, builtin := range eval.PrintBuiltins(stdout) {
+		host.Set(name, builtin)
+	}
+	s.env = object.NewEnclosedEnvironment(host)
+	s.env.SetModule(object.NewModule(s.file))
+
+	guard, cancel := newGuard()
+	defer cancel()
+	s.env.SetGuard(guard)
+
+	return eval.Eval(program, s.env)
+}
+
+func (s *evalSession) call(test *ast.Identifier) object.Object {
+	// each test gets limits of its own
+	guard, cancel := newGuard()
+	defer cancel()
+	s.env.SetGuard(guard)
+
+	return eval.Eval(testCall(test), s.env)
+}
+
+type vmSession struct {
+	file        string
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	host        map[string]object.Object
+}
+
+func (s *vmSession) load(program *ast.Program, stdout io.Writer) object.Object {
+	s.symbolTable = compiler.NewSymbolTable()
+	s.constants = []object.Object{}
+	s.globals = make([]object.Object, vm.GlobalsSize)
+	s.host = map[string]object.Object{}
+	for name, builtin := range eval.PrintBuiltins(stdout) {
+		s.globals[s.symbolTable.Define(name).Index] = builtin
+		s.host[name] = builtin
+	}
+
+	return s.run(program)
+}
+
+func (s *vmSession) call(test *ast.Identifier) object.Object {
+	return s.run(&ast.Program{Expressions: []ast.Expression{testCall(test)}})
+}
+
+// run compiles program and runs it with the globals of the session.
+func (s *vmSession) run(program *ast.Program) object.Object {
+	comp := compiler.NewWithState(s.symbolTable, s.constants)
+	comp.SetFile(s.file)
+	if err := comp.Compile(program); err != nil {
+		compileErr := err.(*yikes.YYError)
+		return &object.Error{Msg: compileErr.Msg, Pos: compileErr.Offset, File: s.file}
+	}
+	bytecode := comp.Bytecode()
+	s.constants = bytecode.Constants
+
+	// each run gets limits of its own
+	guard, cancel := newGuard()
+	defer cancel()
+
+	machine := vm.NewWithGlobals(bytecode, s.globals)
+	machine.SetHost(s.host)
+	machine.SetGuard(guard)
+	return machine.Run()
+}
+
+// testCall creates a call to test, pointing at its declaration.
+func testCall(test *ast.Identifier) *ast.CallExpression {
+	return &ast.CallExpression{
+		Token:    token.Token{Type: token.LPAREN, Literal: "(", Offset: test.Pos()},
+		Function: test,
+	}
+}
+
+func errorTrace(src []byte, file string, result object.Object) string {
+	errObj := result.(*object.Error)
+	return yikes.PrettyTrace(src, file, errObj.Msg, yy.StackTrace(errObj))
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
+
+// report prints the summary of all tests run so far and returns true if none of them failed.
+func (r *testRunner) report() bool {
+	elapsed := formatDuration(time.Since(r.start))
+	if r.failed > 0 {
+		fmt.Fprintf(r.out, "FAIL: %d passed, %d failed (%s)\n", r.passed, r.failed, elapsed)
+		return false
+	}
+	fmt.Fprintf(r.out, "PASS: %d passed (%s)\n", r.passed, elapsed)
+	return true
+}
+
+// findTests lists top-level functions with names starting with 'test_', in the order they're declared.
+func findTests(program *ast.Program) []*ast.Identifier {
+	tests := []*ast.Identifier{}
+	for _, expr := range program.Expressions {
+		decl, ok := expr.(*ast.DeclareExpression)
+		if !ok || !strings.HasPrefix(decl.Name.Value, "test_") {
+			continue
+		}
+		if _, ok := decl.Value.(*ast.LambdaLiteral); ok {
+			tests = append(tests, decl.Name)
+		}
+	}
+	return tests
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
+
+func indent(s string) string {
+	return "    " + strings.ReplaceAll(s, "\n", "\n    ")
+}
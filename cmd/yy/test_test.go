@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTestRunner(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"math_test.yeet": `
+add := \a, b { a + b }
+counter := 0
+
+test_add := \ {
+    counter += 1
+    yassert_eq(add(2, 2), 4)
+}
+
+test_broken := \ {
+    yassert_eq(add(2, 2), 5)
+}
+
+test_fresh_env := \ {
+    counter += 1
+    yassert_eq(counter, 1)
+}
+
+not_a_test := \ { yikes("nope") }
+test_not_a_function := 5
+`,
+		"nested/empty_test.yeet": "x := 1",
+		"nested/helper.yeet":     `test_ignored := \ { yikes("nope") }`,
+		"nested/bad_test.yeet":   "test_bad := \\ { 5 + }",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter   string
+		passed   int
+		failed   int
+		expected []string
+	}{
+		{
+			"",
+			2,
+			2,
+			[]string{
+				"ok   test_add",
+				"FAIL test_broken",
+				"yassert failed: want 4, got 5",
+				"math_test.yeet:11:5 in test_broken",
+				"ok   test_fresh_env",
+				"empty_test.yeet [no tests]",
+				"FAIL " + filepath.Join(dir, "nested/bad_test.yeet"),
+				"FAIL: 2 passed, 2 failed",
+			},
+		},
+		{"add|fresh", 2, 1, []string{"ok   test_add", "ok   test_fresh_env"}},
+	}
+
+	for _, useVM := range []bool{false, true} {
+		for _, tt := range tests {
+			found, err := findFiles([]string{dir}, "_test.yeet")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			r := &testRunner{out: &out, stdout: io.Discard, filter: regexp.MustCompile(tt.filter), useVM: useVM}
+			for _, f := range found {
+				r.runFile(f)
+			}
+			ok := r.report()
+
+			if r.passed != tt.passed || r.failed != tt.failed {
+				t.Errorf("[vm=%t, %q] wrong results. want %d passed & %d failed, got %d & %d\n%s",
+					useVM, tt.filter, tt.passed, tt.failed, r.passed, r.failed, out.String())
+			}
+			if ok != (tt.failed == 0) {
+				t.Errorf("[vm=%t, %q] wrong report result, got %t", useVM, tt.filter, ok)
+			}
+			for _, s := range tt.expected {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("[vm=%t, %q] output doesn't contain %q\n%s", useVM, tt.filter, s, out.String())
+				}
+			}
+			if strings.Contains(out.String(), "nope") {
+				t.Errorf("[vm=%t, %q] ran a function that's not a test\n%s", useVM, tt.filter, out.String())
+			}
+		}
+	}
+}
+
+func TestTestRunnerSetup(t *testing.T) {
+	tests := []struct {
+		src      string
+		passed   int
+		failed   int
+		expected []string
+	}{
+		{
+			`
+yap("setup ran")
+list := [1]
+
+test_push := \ {
+    list << 2
+    yassert_eq(list, [1, 2])
+}
+
+test_untouched := \ {
+    yassert_eq(list, [1])
+}
+`,
+			2,
+			0,
+			[]string{"ok   test_push", "ok   test_untouched"},
+		},
+		{
+			`
+yap("setup ran")
+make := \ { n := 0; \ { n += 1; n } }
+c := make()
+
+test_a := \ { yassert_eq(c(), 1) }
+test_b := \ { yassert_eq(c(), 1) }
+`,
+			2,
+			0,
+			[]string{"ok   test_a", "ok   test_b"},
+		},
+		{
+			`
+helper := yimport "helper.yeet"
+
+test_a := \ { yassert_eq(helper["value"], 1) }
+test_b := \ { yassert_eq(helper["value"], 1) }
+test_c := \ { yassert_eq(helper["value"], 1) }
+`,
+			3,
+			0,
+			[]string{"ok   test_a", "ok   test_b", "ok   test_c"},
+		},
+		{
+			`
+yap("setup ran")
+yassert(false, "broken setup")
+
+test_one := \ { yikes("nope") }
+test_two := \ { yikes("nope") }
+`,
+			0,
+			1,
+			[]string{"broken setup", "setup_test.yeet:3:1", "FAIL: 0 passed, 1 failed"},
+		},
+	}
+
+	for _, useVM := range []bool{false, true} {
+		for _, tt := range tests {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "setup_test.yeet")
+			if err := os.WriteFile(file, []byte(tt.src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			helper := "yap(\"setup ran\")\nvalue := 1"
+			if err := os.WriteFile(filepath.Join(dir, "helper.yeet"), []byte(helper), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var out, stdout bytes.Buffer
+			r := &testRunner{out: &out, stdout: &stdout, filter: regexp.MustCompile(""), useVM: useVM}
+			r.runFile(file)
+			r.report()
+
+			if r.passed != tt.passed || r.failed != tt.failed {
+				t.Errorf("[vm=%t] wrong results. want %d passed & %d failed, got %d & %d\n%s",
+					useVM, tt.passed, tt.failed, r.passed, r.failed, out.String())
+			}
+			if got := strings.Count(stdout.String(), "setup ran"); got != 1 {
+				t.Errorf("[vm=%t] setup should print once, printed %d times", useVM, got)
+			}
+			for _, s := range tt.expected {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("[vm=%t] output doesn't contain %q\n%s", useVM, s, out.String())
+				}
+			}
+			if strings.Contains(out.String(), "nope") {
+				t.Errorf("[vm=%t] ran tests despite broken setup\n%s", useVM, out.String())
+			}
+		}
+	}
+}
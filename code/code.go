@@ -0,0 +1,276 @@
+package code
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var b strings.Builder
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&b, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&b, "%04d %s\n", i, fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return b.String()
+}
+
+func fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), len(def.OperandWidths))
+	}
+
+	switch len(operands) {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	case 3:
+		return fmt.Sprintf("%s %d %d %d", def.Name, operands[0], operands[1], operands[2])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operand count for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpDup
+	OpTrue
+	OpFalse
+	OpNull
+
+	// Operators.
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpLessEqual
+	OpGreaterEqual
+	OpAppend
+	OpMinus
+	OpBang
+	OpYoloInfix
+	OpYoloMinus
+
+	// Control flow.
+
+	OpJump
+	OpJumpNotTruthy
+	OpJumpTruthy
+	OpGetIter
+	OpIterNext
+	OpEnterLoop
+	OpBreak
+	OpTry
+	OpEndTry
+	OpImport
+
+	// Variables.
+
+	OpGetGlobal
+	OpSetGlobal
+	OpAssignGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetFree
+	OpSetFree
+	OpCloseUpvalues
+	OpGetLocalOr
+	OpSetLocalOr
+	OpGetFreeOr
+	OpSetFreeOr
+	OpClearLocals
+
+	// Data structures.
+
+	OpArray
+	OpHashmap
+	OpRange
+	OpTemplate
+	OpIndex
+	OpSetIndex
+	OpGetField
+	OpSetField
+	OpQuote
+	OpUnpackArray
+	OpUnpackHashmap
+	OpUnpackKey
+	OpMatch
+	OpNoMatch
+
+	// Functions.
+
+	OpClosure
+	OpCall
+	OpCallMethod
+	OpReturnValue
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	OpDup:      {"OpDup", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpNull:     {"OpNull", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpAppend:       {"OpAppend", []int{}},
+	OpMinus:        {"OpMinus", []int{}},
+	OpBang:         {"OpBang", []int{}},
+	OpYoloInfix:    {"OpYoloInfix", []int{1}}, // operator
+	OpYoloMinus:    {"OpYoloMinus", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJumpTruthy:    {"OpJumpTruthy", []int{2}},
+	OpGetIter:       {"OpGetIter", []int{}},
+	OpIterNext:      {"OpIterNext", []int{2, 2, 1}}, // local holding the iterator, jump target when done, 1 to push the index too
+	OpEnterLoop:     {"OpEnterLoop", []int{2}},      // local to save the state of the loop in
+	OpBreak:         {"OpBreak", []int{2, 2, 2}},    // local holding the state of the loop, jump target, first local of loop body
+	OpTry:           {"OpTry", []int{2, 2}},         // jump target of ycatch block, first local of ytry block
+	OpEndTry:        {"OpEndTry", []int{}},
+	OpImport:        {"OpImport", []int{}}, // path is taken from the source code
+
+	OpGetGlobal:     {"OpGetGlobal", []int{2}},
+	OpSetGlobal:     {"OpSetGlobal", []int{2}},
+	OpAssignGlobal:  {"OpAssignGlobal", []int{2}},
+	OpGetLocal:      {"OpGetLocal", []int{2}},
+	OpSetLocal:      {"OpSetLocal", []int{2}},
+	OpGetFree:       {"OpGetFree", []int{1}},
+	OpSetFree:       {"OpSetFree", []int{1}},
+	OpCloseUpvalues: {"OpCloseUpvalues", []int{2}}, // first local to close
+
+	// variables declared later on in their block: if the variable has a value, these work like their
+	// counterparts above and jump to the target, otherwise they fall through to the code handling
+	// the variable of the same name from outside of the block
+	OpGetLocalOr:  {"OpGetLocalOr", []int{2, 2}},  // local, jump target
+	OpSetLocalOr:  {"OpSetLocalOr", []int{2, 2}},  // local, jump target
+	OpGetFreeOr:   {"OpGetFreeOr", []int{1, 2}},   // free variable, jump target
+	OpSetFreeOr:   {"OpSetFreeOr", []int{1, 2}},   // free variable, jump target
+	OpClearLocals: {"OpClearLocals", []int{2, 2}}, // first local to clear, number of locals
+
+	OpArray:    {"OpArray", []int{2}},       // number of elements
+	OpHashmap:  {"OpHashmap", []int{2}},     // number of keys + values
+	OpRange:    {"OpRange", []int{}},        //
+	OpTemplate: {"OpTemplate", []int{2, 1}}, // template constant, number of values
+	OpIndex:    {"OpIndex", []int{}},        //
+	OpSetIndex: {"OpSetIndex", []int{}},     //
+	OpGetField: {"OpGetField", []int{2}},    // field name constant
+	OpSetField: {"OpSetField", []int{2}},    // field name constant
+	OpQuote:    {"OpQuote", []int{2, 1}},    // quoted code constant, number of unquoted values
+
+	OpUnpackArray:   {"OpUnpackArray", []int{2, 2, 1}}, // number of elements, number of required ones, 1 if there's a rest
+	OpUnpackHashmap: {"OpUnpackHashmap", []int{}},
+	OpUnpackKey:     {"OpUnpackKey", []int{1}}, // 1 if the key can be missing
+	OpMatch:         {"OpMatch", []int{2}},     // jump target if the pattern doesn't match
+	OpNoMatch:       {"OpNoMatch", []int{}},
+
+	OpClosure:     {"OpClosure", []int{2}},    // compiled function constant
+	OpCall:        {"OpCall", []int{1}},       // number of args
+	OpCallMethod:  {"OpCallMethod", []int{1}}, // number of args, the receiver sits below the callee
+	OpReturnValue: {"OpReturnValue", []int{}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return ins[0]
+}
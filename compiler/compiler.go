@@ -0,0 +1,950 @@
+package compiler
+
+import (
+	"fmt"
+
+	"yy/ast"
+	"yy/code"
+	"yy/eval"
+	"yy/object"
+	"yy/yikes"
+)
+
+type Bytecode struct {
+	Main      *object.CompiledFunction
+	Constants []object.Object
+}
+
+type CompilationScope struct {
+	instructions code.Instructions
+	sourceMap    map[int]ast.Expression
+	loops        []*loop // loops being compiled, the innermost last
+}
+
+// loop is what ybreak & ycontinue need to know about a loop they're in.
+type loop struct {
+	label      string
+	state      int   // local holding the state of the loop saved by OpEnterLoop
+	start      int   // where ycontinue jumps to
+	bodyLocals int   // first local of the loop body, closed over when jumping out of it
+	breaks     []int // positions of OpBreak to patch with the end of the loop
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	scopes      []CompilationScope
+	file        string
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState creates a compiler that carries on where a previous one left off, eg in the REPL,
+// where globals declared in one line have to be available in the next one.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		scopes:      []CompilationScope{{sourceMap: map[int]ast.Expression{}}},
+	}
+}
+
+// SetFile sets the file the compiled code comes from, so errors can point at it.
+func (c *Compiler) SetFile(path string) {
+	c.file = path
+}
+
+var infixOps = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+	"<<": code.OpAppend,
+}
+
+func (c *Compiler) Compile(node ast.Expression) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		if len(node.Expressions) == 0 {
+			c.emit(code.OpNull)
+		}
+		for i, expr := range node.Expressions {
+			if err := c.Compile(expr); err != nil {
+				return err
+			}
+			if i < len(node.Expressions)-1 {
+				c.emit(code.OpPop)
+			}
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.BlockExpression:
+		return c.compileBlock(node, false)
+
+	case *ast.YeetExpression:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.CallExpression:
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return c.compileQuote(node)
+		}
+
+		op := code.OpCall
+		switch fn := node.Function.(type) {
+		case *ast.DotExpression, *ast.IndexExpression:
+			// functions taken from a hashmap are called as its methods
+			if err := c.compileMethod(fn); err != nil {
+				return err
+			}
+			op = code.OpCallMethod
+		default:
+			if err := c.Compile(fn); err != nil {
+				return err
+			}
+		}
+		for _, arg := range node.Arguments {
+			if err := c.Compile(arg); err != nil {
+				return err
+			}
+		}
+		c.emitWithSource(node, op, len(node.Arguments))
+
+	case *ast.PipeExpression:
+		return c.Compile(node.Call)
+
+	case *ast.NamedArgument:
+		// the called function matches args with its parameters by the names the call has
+		return c.Compile(node.Value)
+
+	case *ast.DeclareExpression:
+		var sym Symbol
+
+		// functions are declared before their body is compiled, so they can call themselves
+		if _, ok := node.Value.(*ast.LambdaLiteral); ok {
+			sym = c.symbolTable.Define(node.Name.Value)
+			if err := c.Compile(node.Value); err != nil {
+				return err
+			}
+		} else {
+			if err := c.Compile(node.Value); err != nil {
+				return err
+			}
+			sym = c.symbolTable.Define(node.Name.Value)
+		}
+
+		if sym.Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, sym.Index)
+		} else {
+			c.emit(code.OpSetLocal, sym.Index)
+		}
+
+	case *ast.AssignExpression:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		return c.compileStore(node.Left)
+
+	case *ast.DestructureExpression:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpDup)
+		return c.compileDestructure(node.Pattern, node.Declare())
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emitWithSource(node, code.OpIndex)
+
+	case *ast.DotExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		c.emitWithSource(node, code.OpGetField, c.addConstant(&object.String{Value: node.Name}))
+
+	case *ast.Identifier:
+		sym, ok, jumps := c.resolveDeclared(node.Value, code.OpGetLocalOr, code.OpGetFreeOr)
+		if !ok {
+			sym = c.symbolTable.ReserveGlobal(node.Value)
+		}
+		c.loadSymbol(node, sym)
+		c.patchDeclared(jumps)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch {
+		case node.Operator == "!":
+			c.emitWithSource(node, code.OpBang)
+		case node.Operator == "-" && c.symbolTable.yolo:
+			c.emitWithSource(node, code.OpYoloMinus)
+		case node.Operator == "-":
+			c.emitWithSource(node, code.OpMinus)
+		default:
+			return newError(node.Pos(), "unknown operator: %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		op, ok := infixOps[node.Operator]
+		if !ok {
+			return newError(node.Pos(), "unknown operator: %s", node.Operator)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		if c.symbolTable.yolo {
+			c.emitWithSource(node, code.OpYoloInfix, int(op))
+		} else {
+			c.emitWithSource(node, op)
+		}
+
+	case *ast.YoloExpression:
+		return c.compileBlock(node.Body, true)
+
+	// CONTROL FLOW
+
+	case *ast.AndExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		c.emit(code.OpDup)
+		jumpPos := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpPop)
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.OrExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		c.emit(code.OpDup)
+		jumpPos := c.emit(code.OpJumpTruthy, 9999)
+		c.emit(code.OpPop)
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.YifExpression:
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.compileBlock(node.Consequence, false); err != nil {
+			return err
+		}
+
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else if err := c.compileBlock(node.Alternative, false); err != nil {
+			return err
+		}
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.YoyoExpression:
+		// the condition gets its own scope, shared by all iterations of the loop
+		c.enterBlockScope(false)
+
+		state := c.symbolTable.DefineHidden()
+		c.emit(code.OpEnterLoop, state)
+		c.emit(code.OpNull) // result of the loop if the body never runs
+
+		loopStart := len(c.currentInstructions())
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		c.emit(code.OpPop) // drop the result of the previous iteration
+		l := &loop{label: node.Label, state: state, start: loopStart}
+		if err := c.compileLoopBody(l, node.Body); err != nil {
+			return err
+		}
+		c.emitWithSource(node, code.OpJump, loopStart) // so a run stopped inside the loop can point at it
+
+		c.changeOperand(exitPos, len(c.currentInstructions()))
+		c.patchBreaks(l)
+		c.leaveBlockScope()
+
+	case *ast.YallExpression:
+		if err := c.Compile(node.Iterable); err != nil {
+			return err
+		}
+
+		// the loop variable gets its own scope, shared by all iterations of the loop
+		c.enterBlockScope(false)
+
+		c.emitWithSource(node, code.OpGetIter)
+		iterSlot := c.symbolTable.DefineHidden()
+		c.emit(code.OpSetLocal, iterSlot)
+		c.emit(code.OpPop)
+
+		var index Symbol
+		withIndex := 0
+		if node.IndexName != "" {
+			index = c.symbolTable.Define(node.IndexName)
+			withIndex = 1
+		}
+		elt := c.symbolTable.Define(node.ElemName)
+
+		state := c.symbolTable.DefineHidden()
+		c.emit(code.OpEnterLoop, state)
+		c.emit(code.OpNull) // result of the loop if the body never runs
+
+		loopStart := c.emit(code.OpIterNext, iterSlot, 9999, withIndex)
+		c.emit(code.OpSetLocal, elt.Index)
+		c.emit(code.OpPop)
+		if withIndex == 1 {
+			c.emit(code.OpSetLocal, index.Index)
+			c.emit(code.OpPop)
+		}
+		c.emit(code.OpPop) // drop the result of the previous iteration
+
+		l := &loop{label: node.Label, state: state, start: loopStart}
+		if err := c.compileLoopBody(l, node.Body); err != nil {
+			return err
+		}
+		c.emitWithSource(node, code.OpJump, loopStart) // so a run stopped inside the loop can point at it
+
+		c.changeOperand(loopStart, iterSlot, len(c.currentInstructions()), withIndex)
+		c.patchBreaks(l)
+		c.leaveBlockScope()
+
+	case *ast.YbreakExpression:
+		l := c.findLoop(node.Label)
+		if l == nil {
+			return newError(node.Pos(), "'ybreak' outside of a loop")
+		}
+		if node.Value == nil {
+			c.emit(code.OpNull)
+		} else if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		l.breaks = append(l.breaks, c.emit(code.OpBreak, l.state, 9999, l.bodyLocals))
+
+	case *ast.YcontinueExpression:
+		l := c.findLoop(node.Label)
+		if l == nil {
+			return newError(node.Pos(), "'ycontinue' outside of a loop")
+		}
+		c.emit(code.OpNull) // result of the iteration
+		c.emit(code.OpBreak, l.state, l.start, l.bodyLocals)
+
+	case *ast.YtryExpression:
+		tryPos := c.emit(code.OpTry, 9999, 9999)
+		firstLocal := c.symbolTable.fn.numLocals
+
+		if err := c.compileBlock(node.Body, false); err != nil {
+			return err
+		}
+		c.emit(code.OpEndTry)
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		// when an error is raised, the vm lands here with the error on top of the stack
+		c.changeOperand(tryPos, len(c.currentInstructions()), firstLocal)
+
+		c.enterBlockScope(false)
+		errSym := c.symbolTable.Define(node.ErrName)
+		c.emit(code.OpSetLocal, errSym.Index)
+		c.emit(code.OpPop)
+		if err := c.compileBlock(node.Catch, false); err != nil {
+			return err
+		}
+		c.leaveBlockScope()
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.YmatchExpression:
+		if err := c.Compile(node.Subject); err != nil {
+			return err
+		}
+
+		endJumps := []int{}
+		for _, arm := range node.Arms {
+			// the subject stays on the stack until an arm is picked
+			c.enterBlockScope(false)
+			armScope := c.symbolTable
+			c.emit(code.OpDup)
+			matchPos := c.emitWithSource(arm.Pattern, code.OpMatch, 9999)
+			if err := c.compileDestructure(arm.Pattern, true); err != nil {
+				return err
+			}
+
+			guardPos := -1
+			if arm.Guard != nil {
+				if err := c.Compile(arm.Guard); err != nil {
+					return err
+				}
+				guardPos = c.emit(code.OpJumpNotTruthy, 9999)
+			}
+
+			c.emit(code.OpPop)
+			if err := c.Compile(arm.Body); err != nil {
+				return err
+			}
+			c.leaveBlockScope()
+			endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+
+			if guardPos >= 0 {
+				c.changeOperand(guardPos, len(c.currentInstructions()))
+				if armScope.captured {
+					c.emit(code.OpCloseUpvalues, armScope.firstLocal)
+				}
+			}
+			c.changeOperand(matchPos, len(c.currentInstructions()))
+		}
+		c.emitWithSource(node, code.OpNoMatch)
+
+		for _, pos := range endJumps {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+
+	// LITERALS
+
+	case *ast.ImportExpression:
+		c.emitWithSource(node, code.OpImport)
+
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
+
+	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			c.emit(code.OpConstant, c.addConstant(&object.BigInteger{Value: node.Big}))
+			break
+		}
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+
+	case *ast.NumberLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Number{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.TemplateStringLiteral:
+		for _, v := range node.Values {
+			if err := c.Compile(v); err != nil {
+				return err
+			}
+		}
+		template := c.addConstant(&object.String{Value: node.Template})
+		c.emitWithSource(node, code.OpTemplate, template, len(node.Values))
+
+	case *ast.BooleanLiteral:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.LambdaLiteral:
+		return c.compileLambda(node)
+
+	case *ast.MacroLiteral:
+		return newError(node.Pos(), "macros can only be declared at the top level of a program")
+
+	case *ast.RangeLiteral:
+		if err := c.Compile(node.Start); err != nil {
+			return err
+		}
+		if err := c.Compile(node.End); err != nil {
+			return err
+		}
+		c.emitWithSource(node, code.OpRange)
+
+	case *ast.ArrayLiteral:
+		for _, elt := range node.Elements {
+			if err := c.Compile(elt); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashmapLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emitWithSource(node, code.OpHashmap, len(node.Pairs)*2)
+
+	// if there was a parsing error, we shouldn't get here anyway
+	case *ast.BadExpression:
+		return newError(node.Pos(), "couldn't parse %s", node.TokenLiteral())
+
+	// if there was a parsing error, we shouldn't get here anyway
+	case nil:
+		return newError(-1, "unexpected error: something went wrong somewhere (that's all we know).")
+
+	default:
+		return newError(node.Pos(), "ast object not supported %q %T", node, node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Main: &object.CompiledFunction{
+			Instructions: c.currentInstructions(),
+			NumLocals:    c.symbolTable.fn.numLocals,
+			File:         c.file,
+			SourceMap:    c.scopes[len(c.scopes)-1].sourceMap,
+		},
+		Constants: c.constants,
+	}
+}
+
+func (c *Compiler) compileBlock(block *ast.BlockExpression, yolo bool) error {
+	c.enterBlockScope(yolo)
+
+	// variables declared in the block are known in all of it, even before they're declared
+	names := declaredNames(block.Expressions)
+	for _, name := range names {
+		c.symbolTable.Hoist(name)
+	}
+	// in a loop, they'd still hold values from the previous run of the block otherwise
+	if len(names) > 0 && len(c.scopes[len(c.scopes)-1].loops) > 0 {
+		c.emit(code.OpClearLocals, c.symbolTable.firstLocal, len(names))
+	}
+
+	if len(block.Expressions) == 0 {
+		c.emit(code.OpNull)
+	}
+
+	for i, expr := range block.Expressions {
+		if err := c.Compile(expr); err != nil {
+			return err
+		}
+		if i < len(block.Expressions)-1 {
+			c.emit(code.OpPop)
+		}
+	}
+
+	c.leaveBlockScope()
+	return nil
+}
+
+// compileLoopBody compiles the body of loop l, which ybreak & ycontinue in it can jump out of.
+func (c *Compiler) compileLoopBody(l *loop, body *ast.BlockExpression) error {
+	scope := len(c.scopes) - 1 // not a pointer, as compiling lambdas in the body can move scopes
+	l.bodyLocals = c.symbolTable.fn.numLocals
+	c.scopes[scope].loops = append(c.scopes[scope].loops, l)
+	defer func() { c.scopes[scope].loops = c.scopes[scope].loops[:len(c.scopes[scope].loops)-1] }()
+
+	return c.compileBlock(body, false)
+}
+
+// patchBreaks makes ybreaks of loop l jump to the current position, just after the loop.
+func (c *Compiler) patchBreaks(l *loop) {
+	end := len(c.currentInstructions())
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, l.state, end, l.bodyLocals)
+	}
+}
+
+// findLoop returns the innermost loop with the label, or the innermost loop at all if label is
+// empty. It returns nil if there's no such loop in the function being compiled.
+func (c *Compiler) findLoop(label string) *loop {
+	loops := c.scopes[len(c.scopes)-1].loops
+	for i := len(loops) - 1; i >= 0; i-- {
+		if label == "" || loops[i].label == label {
+			return loops[i]
+		}
+	}
+	return nil
+}
+
+// compileStore assigns the value on top of the stack to a variable, an index or a field, leaving the
+// value on the stack.
+func (c *Compiler) compileStore(node ast.Expression) error {
+	switch left := node.(type) {
+	case *ast.Identifier:
+		sym, ok, jumps := c.resolveDeclared(left.Value, code.OpSetLocalOr, code.OpSetFreeOr)
+
+		switch {
+		case !ok && c.symbolTable.yolo && !c.symbolTable.isGlobal() && len(jumps) == 0:
+			// in yolo mode, assigning to an undeclared variable declares it
+			sym = c.symbolTable.Define(left.Value)
+		case !ok:
+			sym = c.symbolTable.ReserveGlobal(left.Value)
+		}
+
+		switch {
+		case sym.Scope == GlobalScope && c.symbolTable.yolo:
+			c.emit(code.OpSetGlobal, sym.Index)
+		case sym.Scope == GlobalScope:
+			c.emitWithSource(left, code.OpAssignGlobal, sym.Index)
+		case sym.Scope == LocalScope:
+			c.emit(code.OpSetLocal, sym.Index)
+		case sym.Scope == FreeScope:
+			c.emit(code.OpSetFree, sym.Index)
+		}
+		c.patchDeclared(jumps)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(left.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(left.Index); err != nil {
+			return err
+		}
+		c.emitWithSource(left, code.OpSetIndex)
+
+	case *ast.DotExpression:
+		if err := c.Compile(left.Left); err != nil {
+			return err
+		}
+		c.emitWithSource(left, code.OpSetField, c.addConstant(&object.String{Value: left.Name}))
+
+	default:
+		return newError(node.Pos(), "identifier not found: "+node.String())
+	}
+
+	return nil
+}
+
+// compileDestructure takes apart the value on top of the stack as target shows, declaring the
+// variables in it (or assigning to them). The value is popped off the stack.
+func (c *Compiler) compileDestructure(target ast.Expression, declaring bool) error {
+	switch target := target.(type) {
+	case *ast.ArrayPattern:
+		rest := 0
+		if target.Rest != nil {
+			rest = 1
+		}
+		// elements are pushed last to first, followed by the rest, so they can be popped in order
+		c.emitWithSource(target, code.OpUnpackArray, len(target.Elements), target.Required(), rest)
+		for _, el := range target.Elements {
+			if err := c.compilePatternElement(el, declaring); err != nil {
+				return err
+			}
+		}
+		if target.Rest != nil {
+			return c.compileDestructure(target.Rest, declaring)
+		}
+		return nil
+
+	case *ast.HashmapPattern:
+		c.emitWithSource(target, code.OpUnpackHashmap)
+		for _, el := range target.Pairs {
+			c.emit(code.OpDup)
+			if err := c.Compile(el.Key); err != nil {
+				return err
+			}
+			optional := 0
+			if el.Default != nil {
+				optional = 1
+			}
+			c.emitWithSource(el.Key, code.OpUnpackKey, optional)
+			if err := c.compilePatternElement(el, declaring); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpPop)
+		return nil
+
+	case *ast.TypePattern:
+		return c.compileDestructure(target.Target, declaring)
+
+	case *ast.IntegerLiteral, *ast.NumberLiteral, *ast.StringLiteral, *ast.BooleanLiteral, *ast.NullLiteral,
+		*ast.PrefixExpression, *ast.RangeLiteral:
+		// parts of a ymatch pattern with nothing to bind, which have already matched
+		c.emit(code.OpPop)
+		return nil
+
+	case *ast.Identifier:
+		if target.Value == "_" {
+			c.emit(code.OpPop)
+			return nil
+		}
+		if declaring {
+			sym := c.symbolTable.Define(target.Value)
+			if sym.Scope == GlobalScope {
+				c.emit(code.OpSetGlobal, sym.Index)
+			} else {
+				c.emit(code.OpSetLocal, sym.Index)
+			}
+			c.emit(code.OpPop)
+			return nil
+		}
+	}
+
+	if err := c.compileStore(target); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	return nil
+}
+
+func (c *Compiler) compilePatternElement(el ast.PatternElement, declaring bool) error {
+	if el.Default != nil {
+		// a missing (or null) value is replaced by the default
+		c.emit(code.OpDup)
+		c.emit(code.OpNull)
+		c.emitWithSource(el.Default, code.OpEqual)
+		jumpPos := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpPop)
+		if err := c.Compile(el.Default); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+	return c.compileDestructure(el.Target, declaring)
+}
+
+// compileMethod compiles a function taken from a value, leaving the value below the function.
+func (c *Compiler) compileMethod(node ast.Expression) error {
+	switch node := node.(type) {
+	case *ast.DotExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		c.emit(code.OpDup)
+		c.emitWithSource(node, code.OpGetField, c.addConstant(&object.String{Value: node.Name}))
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		c.emit(code.OpDup)
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emitWithSource(node, code.OpIndex)
+	}
+	return nil
+}
+
+func (c *Compiler) compileLambda(node *ast.LambdaLiteral) error {
+	c.enterFunctionScope()
+
+	params := make([]string, len(node.Parameters))
+	for i, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+		params[i] = p.Value
+	}
+	// the rest parameter comes right after the others, in the slot of the last arg
+	rest := ""
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+		rest = node.Rest.Value
+	}
+	c.symbolTable.DefineSelf()
+
+	// parameters with a default value get it if they're null
+	for i := range node.Parameters {
+		def := ast.DefaultOf(node.Defaults, i)
+		if def == nil {
+			continue
+		}
+		c.emit(code.OpGetLocal, i)
+		c.emit(code.OpNull)
+		c.emitWithSource(def, code.OpEqual)
+		jumpPos := c.emit(code.OpJumpNotTruthy, 9999)
+		if err := c.Compile(def); err != nil {
+			return err
+		}
+		c.emit(code.OpSetLocal, i)
+		c.emit(code.OpPop)
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+
+	if err := c.compileBlock(node.Body, false); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	fn := c.symbolTable.fn
+	var outerSelf Symbol
+	if fn.selfUsed {
+		outerSelf = c.symbolTable.outerSelf()
+	}
+	yolo := c.symbolTable.yolo
+	instructions, sourceMap := c.leaveFunctionScope()
+
+	compiledFn := &object.CompiledFunction{
+		Instructions: instructions,
+		NumLocals:    fn.numLocals,
+		Parameters:   params,
+		Required:     ast.Required(node.Defaults, len(params)),
+		Rest:         rest,
+		Upvalues:     fn.upvalues,
+		Yolo:         yolo,
+		Body:         node.Body,
+		Name:         node.Name,
+		File:         c.file,
+		SourceMap:    sourceMap,
+
+		UsesSelf:        fn.selfUsed,
+		Self:            fn.self,
+		OuterSelf:       outerSelf.Index,
+		OuterSelfGlobal: outerSelf.Scope == GlobalScope,
+	}
+
+	c.emit(code.OpClosure, c.addConstant(compiledFn))
+	return nil
+}
+
+// compileQuote compiles quote(...) special form. Quoted code isn't compiled, apart from arguments of
+// unquote(...) calls: their values are spliced into the quoted code when it's evaluated.
+func (c *Compiler) compileQuote(node *ast.CallExpression) error {
+	if len(node.Arguments) != 1 {
+		return newError(node.Pos(), "wrong number of args for quote (got %d, want 1)", len(node.Arguments))
+	}
+
+	quoted := node.Arguments[0]
+	calls := eval.UnquoteCalls(quoted)
+	for _, call := range calls {
+		if len(call.Arguments) != 1 {
+			return newError(call.Pos(), "wrong number of args for unquote (got %d, want 1)", len(call.Arguments))
+		}
+		if err := c.Compile(call.Arguments[0]); err != nil {
+			return err
+		}
+	}
+
+	c.emitWithSource(node, code.OpQuote, c.addConstant(&object.Quote{Node: quoted}), len(calls))
+	return nil
+}
+
+// declaredJump is an instruction jumping over the code handling a variable from outside of the
+// block its namesake is declared in, once the namesake has a value.
+type declaredJump struct {
+	pos   int
+	index int
+}
+
+// resolveDeclared resolves name, like SymbolTable.Resolve does. Variables that are declared later
+// on in their block don't have a value until the declaration runs, and till then name refers to
+// what it refers to outside of the block. For each of them, localOp (or freeOp) is emitted, jumping
+// over the code handling the rest when the variable has a value. The jumps have to be patched with
+// patchDeclared once the code handling the variable yeeted last is emitted.
+func (c *Compiler) resolveDeclared(name string, localOp, freeOp code.Opcode) (Symbol, bool, []declaredJump) {
+	var jumps []declaredJump
+	var outside *SymbolTable
+	for {
+		sym, _, decl, ok := c.symbolTable.resolve(name, outside)
+		if decl == nil {
+			return sym, ok, jumps
+		}
+
+		op := localOp
+		if sym.Scope == FreeScope {
+			op = freeOp
+		}
+		jumps = append(jumps, declaredJump{pos: c.emit(op, sym.Index, 9999), index: sym.Index})
+		outside = decl
+	}
+}
+
+func (c *Compiler) patchDeclared(jumps []declaredJump) {
+	for _, j := range jumps {
+		c.changeOperand(j.pos, j.index, len(c.currentInstructions()))
+	}
+}
+
+func (c *Compiler) loadSymbol(node ast.Expression, sym Symbol) {
+	switch sym.Scope {
+	case GlobalScope:
+		c.emitWithSource(node, code.OpGetGlobal, sym.Index)
+	case LocalScope:
+		c.emitWithSource(node, code.OpGetLocal, sym.Index)
+	case FreeScope:
+		c.emitWithSource(node, code.OpGetFree, sym.Index)
+	}
+}
+
+func (c *Compiler) enterBlockScope(yolo bool) {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	c.symbolTable.yolo = c.symbolTable.yolo || yolo
+}
+
+// leaveBlockScope closes over variables declared in the block that were captured by closures, so
+// every run of the block gets its own copy of them.
+func (c *Compiler) leaveBlockScope() {
+	if c.symbolTable.captured {
+		c.emit(code.OpCloseUpvalues, c.symbolTable.firstLocal)
+	}
+	c.symbolTable = c.symbolTable.Outer
+}
+
+func (c *Compiler) enterFunctionScope() {
+	c.scopes = append(c.scopes, CompilationScope{sourceMap: map[int]ast.Expression{}})
+	c.symbolTable = NewFunctionSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveFunctionScope() (code.Instructions, map[int]ast.Expression) {
+	scope := c.scopes[len(c.scopes)-1]
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.symbolTable = c.symbolTable.Outer
+	return scope.instructions, scope.sourceMap
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[len(c.scopes)-1].instructions
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	scope := &c.scopes[len(c.scopes)-1]
+	pos := len(scope.instructions)
+	scope.instructions = append(scope.instructions, ins...)
+	return pos
+}
+
+// emitWithSource emits an instruction that can fail at runtime, remembering which code it comes
+// from, so the error can point at it.
+func (c *Compiler) emitWithSource(node ast.Expression, op code.Opcode, operands ...int) int {
+	pos := c.emit(op, operands...)
+	c.scopes[len(c.scopes)-1].sourceMap[pos] = node
+	return pos
+}
+
+func (c *Compiler) changeOperand(pos int, operands ...int) {
+	ins := c.currentInstructions()
+	op := code.Opcode(ins[pos])
+	copy(ins[pos:], code.Make(op, operands...))
+}
+
+func newError(offset int, format string, args ...any) *yikes.YYError {
+	return &yikes.YYError{Msg: fmt.Sprintf(format, args...), Offset: offset}
+}
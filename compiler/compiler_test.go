@@ -0,0 +1,486 @@
+package compiler_test
+
+import (
+	"fmt"
+	"testing"
+
+	"yy/ast"
+	"yy/code"
+	"yy/compiler"
+	"yy/lexer"
+	"yy/object"
+	"yy/parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []any
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "1; 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "-1",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "yolo { 1 * 2 }",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpYoloInfix, int(code.OpMul)),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "yif true { 10 }; 3333",
+			expectedConstants: []any{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "true && false",
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpDup),
+				// 0002
+				code.Make(code.OpJumpNotTruthy, 7),
+				// 0005
+				code.Make(code.OpPop),
+				// 0006
+				code.Make(code.OpFalse),
+				// 0007
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestVariables(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "a := 1; a = 2; a",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "{ a := 1; a }",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// unknown names are looked up at runtime, they may be builtins
+			input:             "len",
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `\a { \b { a + b } }`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpClosure, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// captured variable is closed over when the block it was declared in ends
+			input: `{ a := 1; \{ a } }`,
+			expectedConstants: []any{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpCloseUpvalues, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestDefaultParameters(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// a parameter that's null gets its default value before the body runs
+			input: `\a, b = 10 { b }`,
+			expectedConstants: []any{
+				10,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpNull),
+					code.Make(code.OpEqual),
+					code.Make(code.OpJumpNotTruthy, 15),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpPop),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// the rest parameter is the local after the others
+			input: `\a, ...rest { rest }`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// elements are unpacked onto the stack, the first one on top
+			input:             `[a, b = 2, ...c] := x`,
+			expectedConstants: []any{2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpUnpackArray, 2, 1, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpDup),
+				code.Make(code.OpNull),
+				code.Make(code.OpEqual),
+				code.Make(code.OpJumpNotTruthy, 24),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 2),
+				code.Make(code.OpPop),
+				code.Make(code.OpSetGlobal, 3),
+				code.Make(code.OpPop),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             `%{"k": v} = h`,
+			expectedConstants: []any{"k"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpUnpackHashmap),
+				code.Make(code.OpDup),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpUnpackKey, 0),
+				code.Make(code.OpAssignGlobal, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpPop),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestYmatch(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// the subject stays on the stack until an arm is picked
+			input:             `ymatch x { 1 yif y => 2, n => n }`,
+			expectedConstants: []any{2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpMatch, 21),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpJumpNotTruthy, 21),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 37),
+				code.Make(code.OpDup),
+				code.Make(code.OpMatch, 36),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpJump, 37),
+				code.Make(code.OpNoMatch),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestMethods(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// the hashmap a function is taken from stays on the stack, as the receiver of the call
+			input:             `h.f(1); h["g"]()`,
+			expectedConstants: []any{"f", 1, "g"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpGetField, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCallMethod, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpCallMethod, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	program := parse(t, `\a { \{ self.x } }; \self { self }`)
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	constants := comp.Bytecode().Constants
+
+	// self of a lambda not called as a method comes from the lambda around it
+	inner := constants[1].(*object.CompiledFunction)
+	outer := constants[2].(*object.CompiledFunction)
+	param := constants[3].(*object.CompiledFunction)
+	if !inner.UsesSelf || inner.Self != 0 || inner.OuterSelfGlobal || inner.OuterSelf != 0 {
+		t.Errorf("wrong self of the inner lambda: local %d, outer %d", inner.Self, inner.OuterSelf)
+	}
+	if !outer.UsesSelf || outer.Self != 1 || !outer.OuterSelfGlobal {
+		t.Errorf("wrong self of the outer lambda: local %d, outer %d", outer.Self, outer.OuterSelf)
+	}
+	if param.UsesSelf {
+		t.Errorf("parameter called self is treated as self")
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{"quote(1, 2)", "wrong number of args for quote (got 2, want 1)"},
+		{"quote(unquote())", "wrong number of args for unquote (got 0, want 1)"},
+		{`\{ @\x { x } }`, "macros can only be declared at the top level of a program"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		err := compiler.New().Compile(program)
+		if err == nil {
+			t.Errorf("expected compilation error for %q", tt.input)
+			continue
+		}
+		if err.Error() != tt.expectedMsg {
+			t.Errorf("wrong error message. want %q, got %q", tt.expectedMsg, err.Error())
+		}
+	}
+}
+
+//
+// HELPERS
+//
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := c.Bytecode()
+
+		if err := testInstructions(tt.expectedInstructions, bytecode.Main.Instructions); err != nil {
+			t.Errorf("testInstructions failed for %q: %s", tt.input, err)
+		}
+
+		if err := testConstants(tt.expectedConstants, bytecode.Constants); err != nil {
+			t.Errorf("testConstants failed for %q: %s", tt.input, err)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func testInstructions(expected []code.Instructions, actual code.Instructions) error {
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if len(actual) != len(concatted) {
+		return fmt.Errorf("wrong instructions length.\nwant\n%s\ngot\n%s", concatted, actual)
+	}
+
+	for i, ins := range concatted {
+		if actual[i] != ins {
+			return fmt.Errorf("wrong instruction at %d.\nwant\n%s\ngot\n%s", i, concatted, actual)
+		}
+	}
+
+	return nil
+}
+
+func testConstants(expected []any, actual []object.Object) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("wrong number of constants. want %d, got %d", len(expected), len(actual))
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			result, ok := actual[i].(*object.Integer)
+			if !ok {
+				return fmt.Errorf("constant %d is not Integer. got %T (%+v)", i, actual[i], actual[i])
+			}
+			if result.Value != int64(constant) {
+				return fmt.Errorf("constant %d has wrong value. want %d, got %d", i, constant, result.Value)
+			}
+
+		case string:
+			result, ok := actual[i].(*object.String)
+			if !ok {
+				return fmt.Errorf("constant %d is not String. got %T (%+v)", i, actual[i], actual[i])
+			}
+			if result.Value != constant {
+				return fmt.Errorf("constant %d has wrong value. want %q, got %q", i, constant, result.Value)
+			}
+
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("constant %d is not a function. got %T (%+v)", i, actual[i], actual[i])
+			}
+			if err := testInstructions(constant, fn.Instructions); err != nil {
+				return fmt.Errorf("constant %d: %s", i, err)
+			}
+		}
+	}
+
+	return nil
+}
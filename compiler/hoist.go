@@ -0,0 +1,156 @@
+package compiler
+
+import (
+	"yy/ast"
+	"yy/eval"
+)
+
+// declaredNames lists the variables declared with := in a block made of exprs, in the order they're
+// declared in. Blocks, lambdas and the like nested in the block declare variables of their own.
+func declaredNames(exprs []ast.Expression) []string {
+	h := &hoister{seen: map[string]bool{}}
+	for _, expr := range exprs {
+		h.expr(expr)
+	}
+	return h.names
+}
+
+type hoister struct {
+	names []string
+	seen  map[string]bool
+}
+
+func (h *hoister) declare(name string) {
+	if name != "_" && !h.seen[name] {
+		h.seen[name] = true
+		h.names = append(h.names, name)
+	}
+}
+
+// pattern declares the targets of a destructuring pattern.
+func (h *hoister) pattern(pattern ast.Expression) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		h.declare(pattern.Value)
+	case *ast.TypePattern:
+		h.pattern(pattern.Target)
+	case *ast.ArrayPattern:
+		h.elements(pattern.Elements)
+		if pattern.Rest != nil {
+			h.pattern(pattern.Rest)
+		}
+	case *ast.HashmapPattern:
+		h.elements(pattern.Pairs)
+	}
+}
+
+func (h *hoister) elements(elements []ast.PatternElement) {
+	for _, el := range elements {
+		if el.Key != nil {
+			h.expr(el.Key)
+		}
+		if el.Default != nil {
+			h.expr(el.Default)
+		}
+		h.pattern(el.Target)
+	}
+}
+
+func (h *hoister) expr(node ast.Expression) {
+	switch node := node.(type) {
+	case *ast.DeclareExpression:
+		h.expr(node.Value)
+		h.declare(node.Name.Value)
+
+	case *ast.DestructureExpression:
+		h.expr(node.Value)
+		if node.Declare() {
+			h.pattern(node.Pattern)
+		}
+
+	case *ast.AssignExpression:
+		h.expr(node.Value)
+
+	case *ast.YeetExpression:
+		h.expr(node.ReturnValue)
+
+	case *ast.YbreakExpression:
+		if node.Value != nil {
+			h.expr(node.Value)
+		}
+
+	case *ast.PrefixExpression:
+		h.expr(node.Right)
+
+	case *ast.InfixExpression:
+		h.expr(node.Left)
+		h.expr(node.Right)
+
+	case *ast.PipeExpression:
+		h.expr(node.Call)
+
+	case *ast.AndExpression:
+		h.expr(node.Left)
+		h.expr(node.Right)
+
+	case *ast.OrExpression:
+		h.expr(node.Left)
+		h.expr(node.Right)
+
+	case *ast.IndexExpression:
+		h.expr(node.Left)
+		h.expr(node.Index)
+
+	case *ast.DotExpression:
+		h.expr(node.Left)
+
+	case *ast.RangeLiteral:
+		h.expr(node.Start)
+		h.expr(node.End)
+
+	case *ast.ArrayLiteral:
+		for _, elt := range node.Elements {
+			h.expr(elt)
+		}
+
+	case *ast.HashmapLiteral:
+		for _, pair := range node.Pairs {
+			h.expr(pair.Key)
+			h.expr(pair.Value)
+		}
+
+	case *ast.TemplateStringLiteral:
+		for _, v := range node.Values {
+			h.expr(v)
+		}
+
+	// the condition of yif, the iterable of yall and the subject of ymatch belong to the enclosing
+	// block, the rest of them has its own scope
+	case *ast.YifExpression:
+		h.expr(node.Condition)
+
+	case *ast.YallExpression:
+		h.expr(node.Iterable)
+
+	case *ast.YmatchExpression:
+		h.expr(node.Subject)
+
+	case *ast.NamedArgument:
+		h.expr(node.Value)
+
+	case *ast.CallExpression:
+		// quoted code isn't run, apart from arguments of unquote() calls
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" && len(node.Arguments) == 1 {
+			for _, call := range eval.UnquoteCalls(node.Arguments[0]) {
+				for _, arg := range call.Arguments {
+					h.expr(arg)
+				}
+			}
+			return
+		}
+		h.expr(node.Function)
+		for _, arg := range node.Arguments {
+			h.expr(arg)
+		}
+	}
+}
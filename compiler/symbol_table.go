@@ -0,0 +1,225 @@
+package compiler
+
+import "yy/object"
+
+type SymbolScope int
+
+const (
+	GlobalScope SymbolScope = iota
+	LocalScope
+	FreeScope
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// funcScope holds the state shared by all the blocks of a single function.
+type funcScope struct {
+	numLocals int
+	upvalues  []object.Upvalue
+	self      int  // local holding self, -1 if the function doesn't have one
+	selfUsed  bool // self is referred to by the function, or captured by a nested one
+}
+
+// SymbolTable keeps track of the names declared in a single block. Every block gets its own table,
+// linked to the table of the enclosing block. Locals of all the blocks of a function live in
+// the same stack frame, so indices of locals are handed out by the function the block belongs to.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store      map[string]Symbol
+	fn         *funcScope
+	numGlobals int
+
+	// pending maps names declared later on in the block (or captured from such a block) to the
+	// table of the block. Until the declaration runs, the variable has no value and the name refers
+	// to whatever it refers to outside of that block.
+	pending map[string]*SymbolTable
+
+	// firstLocal is the index of the first local declared in this block. captured is set when
+	// any of the locals declared in this block is referenced by a nested function.
+	firstLocal int
+	captured   bool
+	yolo       bool
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store: map[string]Symbol{},
+		fn:    &funcScope{self: -1},
+	}
+}
+
+// NewBlockSymbolTable creates a table for a block nested inside outer.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	return &SymbolTable{
+		Outer:      outer,
+		store:      map[string]Symbol{},
+		fn:         outer.fn,
+		firstLocal: outer.fn.numLocals,
+		yolo:       outer.yolo,
+	}
+}
+
+// NewFunctionSymbolTable creates a table for parameters of a function declared inside outer.
+func NewFunctionSymbolTable(outer *SymbolTable) *SymbolTable {
+	return &SymbolTable{
+		Outer: outer,
+		store: map[string]Symbol{},
+		fn:    &funcScope{self: -1},
+		yolo:  outer.yolo,
+	}
+}
+
+func (s *SymbolTable) isGlobal() bool {
+	return s.Outer == nil
+}
+
+func (s *SymbolTable) global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
+
+// Define declares name in this block. Declaring a name that's already declared in the same block
+// reuses the existing symbol.
+func (s *SymbolTable) Define(name string) Symbol {
+	if sym, ok := s.store[name]; ok && sym.Scope != FreeScope {
+		delete(s.pending, name)
+		return sym
+	}
+
+	var sym Symbol
+	if s.isGlobal() {
+		sym = Symbol{Name: name, Scope: GlobalScope, Index: s.numGlobals}
+		s.numGlobals++
+	} else {
+		sym = Symbol{Name: name, Scope: LocalScope, Index: s.fn.numLocals}
+		s.fn.numLocals++
+	}
+
+	s.store[name] = sym
+	return sym
+}
+
+// Hoist declares name in this block ahead of its declaration, so code preceding the declaration
+// (eg a function declared earlier in the block) refers to it too.
+func (s *SymbolTable) Hoist(name string) {
+	if _, ok := s.store[name]; ok {
+		return
+	}
+	s.Define(name)
+	if s.pending == nil {
+		s.pending = map[string]*SymbolTable{}
+	}
+	s.pending[name] = s
+}
+
+// DefineHidden reserves a local slot that can't be referenced by name.
+func (s *SymbolTable) DefineHidden() int {
+	idx := s.fn.numLocals
+	s.fn.numLocals++
+	return idx
+}
+
+// DefineSelf declares self of a function, set when the function is called as a method. If one of
+// the parameters is called self, that's what self refers to instead.
+func (s *SymbolTable) DefineSelf() {
+	if _, ok := s.store["self"]; !ok {
+		s.fn.self = s.Define("self").Index
+	}
+}
+
+// outerSelf resolves self of the code a function is declared in, from the table of the function's
+// parameters. Self of an enclosing function is captured, like any other variable.
+func (s *SymbolTable) outerSelf() Symbol {
+	sym, owner, _, ok := s.Outer.resolve("self", nil)
+	switch {
+	case !ok:
+		return s.ReserveGlobal("self")
+	case sym.Scope == GlobalScope:
+		return sym
+	case sym.Scope == LocalScope:
+		owner.captured = true
+	}
+	return s.defineFree(sym)
+}
+
+// ReserveGlobal declares name in the global scope. It's used for names that can't be resolved at
+// compile time, as they may still be declared later on (or refer to a builtin).
+func (s *SymbolTable) ReserveGlobal(name string) Symbol {
+	return s.global().Define(name)
+}
+
+// Globals lists all the names declared in the global scope.
+func (s *SymbolTable) Globals() []Symbol {
+	global := s.global()
+
+	symbols := make([]Symbol, 0, len(global.store))
+	for _, sym := range global.store {
+		symbols = append(symbols, sym)
+	}
+	return symbols
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	sym, _, _, ok := s.resolve(name, nil)
+	return sym, ok
+}
+
+// resolve finds the symbol name refers to, along with the table it's stored in. If the variable
+// is declared later on in its block, resolve also yeets the table of that block, which can be
+// passed back as outside to find what name refers to when the variable has no value yet: only
+// tables outside of it are looked at then.
+func (s *SymbolTable) resolve(name string, outside *SymbolTable) (Symbol, *SymbolTable, *SymbolTable, bool) {
+	if sym, ok := s.store[name]; ok && outside == nil {
+		if sym.Scope == LocalScope && sym.Index == s.fn.self {
+			s.fn.selfUsed = true
+		}
+		return sym, s, s.pending[name], true
+	}
+	if s == outside {
+		outside = nil
+	}
+	if s.Outer == nil {
+		return Symbol{}, nil, nil, false
+	}
+
+	sym, owner, decl, ok := s.Outer.resolve(name, outside)
+	if !ok || sym.Scope == GlobalScope || s.Outer.fn == s.fn {
+		return sym, owner, decl, ok
+	}
+
+	// the name belongs to an enclosing function, so it has to be captured
+	if sym.Scope == LocalScope {
+		owner.captured = true
+	}
+	free := s.defineFree(sym)
+	if outside == nil {
+		// what the name refers to outside of a block is resolved again on every lookup
+		s.store[name] = free
+		if decl != nil {
+			if s.pending == nil {
+				s.pending = map[string]*SymbolTable{}
+			}
+			s.pending[name] = decl
+		}
+	}
+	return free, s, decl, true
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	uv := object.Upvalue{IsLocal: original.Scope == LocalScope, Index: original.Index}
+	for i, captured := range s.fn.upvalues {
+		if captured == uv {
+			return Symbol{Name: original.Name, Scope: FreeScope, Index: i}
+		}
+	}
+
+	s.fn.upvalues = append(s.fn.upvalues, uv)
+	return Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.fn.upvalues) - 1}
+}
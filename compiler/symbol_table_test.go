@@ -0,0 +1,92 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"yy/compiler"
+)
+
+func TestDefineAndResolve(t *testing.T) {
+	global := compiler.NewSymbolTable()
+	global.Define("a")
+
+	block := compiler.NewBlockSymbolTable(compiler.NewFunctionSymbolTable(global))
+	block.Define("b")
+
+	inner := compiler.NewBlockSymbolTable(block)
+	inner.Define("c")
+
+	tests := []struct {
+		table    *compiler.SymbolTable
+		expected compiler.Symbol
+	}{
+		{global, compiler.Symbol{Name: "a", Scope: compiler.GlobalScope, Index: 0}},
+		{block, compiler.Symbol{Name: "a", Scope: compiler.GlobalScope, Index: 0}},
+		{block, compiler.Symbol{Name: "b", Scope: compiler.LocalScope, Index: 0}},
+		// blocks of the same function share the stack frame, so indices keep going up
+		{inner, compiler.Symbol{Name: "b", Scope: compiler.LocalScope, Index: 0}},
+		{inner, compiler.Symbol{Name: "c", Scope: compiler.LocalScope, Index: 1}},
+	}
+
+	for _, tt := range tests {
+		sym, ok := tt.table.Resolve(tt.expected.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.expected.Name)
+			continue
+		}
+		if sym != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got %+v", tt.expected.Name, tt.expected, sym)
+		}
+	}
+
+	if _, ok := block.Resolve("c"); ok {
+		t.Errorf("name c resolved outside of its block")
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := compiler.NewSymbolTable()
+	global.Define("a")
+
+	outer := compiler.NewFunctionSymbolTable(global)
+	outer.Define("b")
+
+	nested := compiler.NewBlockSymbolTable(compiler.NewFunctionSymbolTable(outer))
+	nested.Define("c")
+
+	tests := []compiler.Symbol{
+		{Name: "a", Scope: compiler.GlobalScope, Index: 0},
+		{Name: "b", Scope: compiler.FreeScope, Index: 0},
+		{Name: "c", Scope: compiler.LocalScope, Index: 0},
+	}
+
+	for _, expected := range tests {
+		sym, ok := nested.Resolve(expected.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", expected.Name)
+			continue
+		}
+		if sym != expected {
+			t.Errorf("expected %s to resolve to %+v, got %+v", expected.Name, expected, sym)
+		}
+	}
+
+	if _, ok := nested.Resolve("d"); ok {
+		t.Errorf("name d resolved, but was never defined")
+	}
+}
+
+func TestReserveGlobal(t *testing.T) {
+	global := compiler.NewSymbolTable()
+	block := compiler.NewBlockSymbolTable(compiler.NewFunctionSymbolTable(global))
+
+	block.ReserveGlobal("len")
+
+	sym, ok := global.Resolve("len")
+	if !ok {
+		t.Fatalf("reserved name not resolvable from global scope")
+	}
+	if sym.Scope != compiler.GlobalScope {
+		t.Errorf("reserved name has wrong scope. want %d, got %d", compiler.GlobalScope, sym.Scope)
+	}
+}
@@ -0,0 +1,390 @@
+package yy
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+
+	"yy/eval"
+	"yy/object"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// ToObject converts a Go value to a YY object:
+//
+//   - nil becomes null
+//   - bools, strings, integers (*big.Int too) and floats become their YY counterparts
+//   - slices and arrays become arrays
+//   - maps become hashmaps
+//   - functions become builtins (see Interpreter.RegisterBuiltin)
+//   - object.Object values are passed through as they are
+func ToObject(v any) (object.Object, error) {
+	if v == nil {
+		return object.NULL, nil
+	}
+	if obj, ok := v.(object.Object); ok {
+		return obj, nil
+	}
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return object.TRUE, nil
+		}
+		return object.FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return object.NewInteger(new(big.Int).SetUint64(v.Uint())), nil
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Number{Value: v.Float()}, nil
+
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return object.NULL, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			elt, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elt
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		// Go maps have no order, sorting the keys gives the hashmap a predictable one
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+
+		hashmap := object.NewHashmap(len(keys))
+		for _, k := range keys {
+			key, err := toObject(k)
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hashmap key: %s", key.Type())
+			}
+			val, err := toObject(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			hashmap.Set(hashable, val)
+		}
+		return hashmap, nil
+
+	case reflect.Func:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		return wrapFunc(v)
+
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+		if obj, ok := v.Interface().(object.Object); ok {
+			return obj, nil
+		}
+		if n, ok := v.Interface().(*big.Int); ok {
+			return object.NewInteger(new(big.Int).Set(n)), nil
+		}
+		return toObject(v.Elem())
+
+	default:
+		return nil, fmt.Errorf("can't convert %s to a YY object", v.Type())
+	}
+}
+
+// ToGo converts a YY object to a Go value:
+//
+//   - null becomes nil
+//   - integers become int (or *big.Int if they don't fit in int64), numbers become float64
+//   - booleans and strings become bool and string
+//   - arrays become []any
+//   - hashmaps with string keys become map[string]any, other hashmaps become map[any]any
+//   - functions become func(args ...any) (any, error)
+//   - everything else (eg ranges) is returned as it is
+func ToGo(obj object.Object) any {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+
+	case *object.Integer:
+		return int(obj.Value)
+
+	case *object.BigInteger:
+		return new(big.Int).Set(obj.Value)
+
+	case *object.Number:
+		return obj.Value
+
+	case *object.Boolean:
+		return obj.Value
+
+	case *object.String:
+		return obj.Value
+
+	case *object.Array:
+		elements := make([]any, len(obj.Elements))
+		for i, elt := range obj.Elements {
+			elements[i] = ToGo(elt)
+		}
+		return elements
+
+	case *object.Hashmap:
+		if hasStringKeys(obj) {
+			m := make(map[string]any, obj.Len())
+			for _, pair := range obj.Pairs() {
+				m[pair.Key.(*object.String).Value] = ToGo(pair.Value)
+			}
+			return m
+		}
+
+		m := make(map[any]any, obj.Len())
+		for _, pair := range obj.Pairs() {
+			key := ToGo(pair.Key)
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				// arrays & hashmaps can't be keys of a Go map
+				key = pair.Key.String()
+			}
+			m[key] = ToGo(pair.Value)
+		}
+		return m
+
+	case *object.Lambda, *object.Builtin:
+		return func(args ...any) (any, error) {
+			objArgs := make([]object.Object, len(args))
+			for i, arg := range args {
+				objArg, err := ToObject(arg)
+				if err != nil {
+					return nil, err
+				}
+				objArgs[i] = objArg
+			}
+
+			result := eval.Apply(obj, objArgs)
+			if errObj, ok := result.(*object.Error); ok {
+				return nil, errors.New(errObj.Msg)
+			}
+			return ToGo(result), nil
+		}
+
+	default:
+		return obj
+	}
+}
+
+// lessKey orders keys of a Go map: numbers, strings & bools by their value, keys of different
+// kinds by their kind.
+func lessKey(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface {
+		b = b.Elem()
+	}
+	if a.Kind() != b.Kind() {
+		return a.Kind() < b.Kind()
+	}
+
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	default:
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	}
+}
+
+func hasStringKeys(hashmap *object.Hashmap) bool {
+	for _, pair := range hashmap.Pairs() {
+		if _, ok := pair.Key.(*object.String); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fromObject converts obj to a Go value of type t.
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if t == bigIntType {
+		switch n := obj.(type) {
+		case *object.Integer:
+			return reflect.ValueOf(big.NewInt(n.Value)), nil
+		case *object.BigInteger:
+			return reflect.ValueOf(new(big.Int).Set(n.Value)), nil
+		}
+	}
+	if t.Implements(objectType) {
+		if !reflect.TypeOf(obj).AssignableTo(t) {
+			return reflect.Value{}, fmt.Errorf("can't use %s as %s", obj.Type(), t)
+		}
+		return reflect.ValueOf(obj), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		v := ToGo(obj)
+		if v == nil {
+			return reflect.Zero(t), nil
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(t) {
+			return reflect.Value{}, fmt.Errorf("can't use %s as %s", obj.Type(), t)
+		}
+		return rv, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*object.Integer); ok {
+			return reflect.ValueOf(i.Value).Convert(t), nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Number:
+			return reflect.ValueOf(n.Value).Convert(t), nil
+		case *object.Integer:
+			return reflect.ValueOf(float64(n.Value)).Convert(t), nil
+		}
+
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(t), nil
+		}
+
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(s.Value).Convert(t), nil
+		}
+
+	case reflect.Slice:
+		if arr, ok := obj.(*object.Array); ok {
+			slice := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+			for i, elt := range arr.Elements {
+				v, err := fromObject(elt, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				slice.Index(i).Set(v)
+			}
+			return slice, nil
+		}
+
+	case reflect.Map:
+		if hashmap, ok := obj.(*object.Hashmap); ok {
+			m := reflect.MakeMapWithSize(t, hashmap.Len())
+			for _, pair := range hashmap.Pairs() {
+				k, err := fromObject(pair.Key, t.Key())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				v, err := fromObject(pair.Value, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				m.SetMapIndex(k, v)
+			}
+			return m, nil
+		}
+
+	case reflect.Func:
+		fn := reflect.ValueOf(ToGo(obj))
+		if fn.Kind() == reflect.Func && fn.Type().AssignableTo(t) {
+			return fn, nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("can't use %s as %s", obj.Type(), t)
+}
+
+// wrapFunc turns a Go function into a builtin. The function can return nothing, a single value,
+// an error, or a value and an error.
+func wrapFunc(fn reflect.Value) (*object.Builtin, error) {
+	t := fn.Type()
+
+	returnsErr := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	numResults := t.NumOut()
+	if returnsErr {
+		numResults--
+	}
+	if numResults > 1 {
+		return nil, fmt.Errorf("can't convert %s to a builtin, it returns too many values", t)
+	}
+
+	builtin := func(args ...object.Object) object.Object {
+		numParams := t.NumIn()
+		if t.IsVariadic() {
+			if len(args) < numParams-1 {
+				return &object.Error{Msg: fmt.Sprintf("wrong number of args (got %d, want at least %d)", len(args), numParams-1), Pos: -1}
+			}
+		} else if len(args) != numParams {
+			return &object.Error{Msg: fmt.Sprintf("wrong number of args (got %d, want %d)", len(args), numParams), Pos: -1}
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && i >= numParams-1 {
+				paramType = t.In(numParams - 1).Elem()
+			} else {
+				paramType = t.In(i)
+			}
+
+			v, err := fromObject(arg, paramType)
+			if err != nil {
+				return &object.Error{Msg: fmt.Sprintf("wrong type of arg #%d: %s", i+1, err), Pos: -1}
+			}
+			in[i] = v
+		}
+
+		out := fn.Call(in)
+
+		if returnsErr {
+			if err := out[len(out)-1]; !err.IsNil() {
+				return &object.Error{Msg: err.Interface().(error).Error(), Pos: -1}
+			}
+		}
+		if numResults == 0 {
+			return object.NULL
+		}
+
+		result, err := toObject(out[0])
+		if err != nil {
+			return &object.Error{Msg: err.Error(), Pos: -1}
+		}
+		return result
+	}
+
+	return &object.Builtin{Fn: builtin}, nil
+}
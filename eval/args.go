@@ -0,0 +1,94 @@
+package eval
+
+import (
+	"slices"
+
+	"yy/ast"
+	"yy/object"
+)
+
+// bindArgs matches args of a call with parameters of fn, see BindArgs.
+func bindArgs(fn *object.Lambda, callee string, args []object.Object, names []string) ([]object.Object, []object.Object, *object.Error) {
+	if names == nil && len(args) == len(fn.Parameters) && fn.Rest == nil {
+		return args, nil, nil
+	}
+
+	params := make([]string, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		params[i] = p.Value
+	}
+	return BindArgs(callee, params, ast.Required(fn.Defaults, len(params)), fn.Rest != nil, args, names)
+}
+
+// CalleeName names the function called by fn in errors: the variable it's stored in or the
+// field it's looked up by, eg o.m. Empty if fn isn't either.
+func CalleeName(fn ast.Expression) string {
+	switch fn := fn.(type) {
+	case *ast.Identifier:
+		return fn.Value
+	case *ast.DotExpression:
+		if left := CalleeName(fn.Left); left != "" {
+			return left + "." + fn.Name
+		}
+		return fn.Name
+	}
+	return ""
+}
+
+// BindArgs matches args of a call with parameters of the function called, whose name is callee
+// (empty if it isn't called by name). The last len(names) args are passed by name, the others go
+// to the parameters in order. Only the first required parameters have to be given an arg, the
+// others are null if they aren't. Args left over are yeeted as extra, which only a function with
+// a rest parameter can take.
+func BindArgs(callee string, params []string, required int, rest bool, args []object.Object, names []string) (bound, extra []object.Object, err *object.Error) {
+	positional := len(args) - len(names)
+	if len(names) == 0 && positional == len(params) {
+		return args, nil, nil
+	}
+
+	forCallee := ""
+	if callee != "" {
+		forCallee = " for " + callee
+	}
+
+	if positional > len(params) && !rest {
+		if required < len(params) {
+			return nil, nil, newErrorWithoutPos("wrong number of args%s (got %d, want at most %d)", forCallee, len(args), len(params))
+		}
+		return nil, nil, newErrorWithoutPos("wrong number of args%s (got %d, want %d)", forCallee, len(args), len(params))
+	}
+
+	bound = make([]object.Object, len(params))
+	copy(bound, args[:min(positional, len(params))])
+	if positional > len(params) {
+		extra = args[len(params):positional]
+	}
+
+	for i, name := range names {
+		idx := slices.Index(params, name)
+		switch {
+		case idx < 0:
+			return nil, nil, newErrorWithoutPos("no parameter called %s%s", name, forCallee)
+		case bound[idx] != nil:
+			return nil, nil, newErrorWithoutPos("multiple values for parameter %s%s", name, forCallee)
+		}
+		bound[idx] = args[positional+i]
+	}
+
+	for i := range bound {
+		switch {
+		case bound[i] != nil:
+			continue
+		case i >= required:
+			bound[i] = object.NULL
+		case len(names) > 0:
+			return nil, nil, newErrorWithoutPos("missing arg for parameter %s%s", params[i], forCallee)
+		case rest || required < len(params):
+			return nil, nil, newErrorWithoutPos("wrong number of args%s (got %d, want at least %d)", forCallee, len(args), required)
+		default:
+			return nil, nil, newErrorWithoutPos("wrong number of args%s (got %d, want %d)", forCallee, len(args), required)
+		}
+	}
+
+	return bound, extra, nil
+}
@@ -2,14 +2,55 @@ package eval
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"math/rand"
-	"reflect"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 
 	"yy/object"
 )
 
+func init() {
+	for name, builtin := range printBuiltins(os.Stdout) {
+		builtins[name] = builtin
+	}
+}
+
+// printBuiltins creates builtins printing to w.
+func printBuiltins(w io.Writer) map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"yowl": {
+			Fn: func(args ...object.Object) object.Object {
+				for _, arg := range args {
+					fmt.Fprintln(w, strings.ToUpper(arg.String()))
+				}
+				fmt.Fprintln(w)
+				return object.NULL
+			},
+		},
+
+		"yap": {
+			Fn: func(args ...object.Object) object.Object {
+				msg := spaceSeparatedArgs(args...)
+				fmt.Fprintln(w, msg)
+				return object.NULL
+			},
+		},
+
+		"yelp": {
+			Fn: func(args ...object.Object) object.Object {
+				msg := spaceSeparatedArgs(args...)
+				fmt.Fprint(w, msg)
+				return object.NULL
+			},
+		},
+	}
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": {
 		Fn: func(args ...object.Object) object.Object {
@@ -25,7 +66,7 @@ var builtins = map[string]*object.Builtin{
 				return &object.Integer{Value: int64(len(arg.Value))}
 
 			case *object.Hashmap:
-				return &object.Integer{Value: int64(len(arg.Pairs))}
+				return &object.Integer{Value: int64(arg.Len())}
 
 			case *object.Range:
 				length := arg.End - arg.Start
@@ -131,7 +172,10 @@ var builtins = map[string]*object.Builtin{
 				if len(args) == 2 {
 					pos = int(args[1].(*object.Integer).Value)
 				}
-				if pos >= len(arg.Elements) {
+				if pos < 0 {
+					pos += len(arg.Elements)
+				}
+				if pos < 0 || pos >= len(arg.Elements) {
 					return object.NULL
 				}
 
@@ -144,7 +188,10 @@ var builtins = map[string]*object.Builtin{
 				if len(args) == 2 {
 					pos = int(args[1].(*object.Integer).Value)
 				}
-				if pos >= len(arg.Value) {
+				if pos < 0 {
+					pos += len(arg.Value)
+				}
+				if pos < 0 || pos >= len(arg.Value) {
 					return object.NULL
 				}
 
@@ -193,13 +240,17 @@ var builtins = map[string]*object.Builtin{
 				return &object.Integer{Value: rand.Int63n(arg.Value)}
 
 			case *object.Array:
-				max := len(arg.Elements) - 1
-				i := rand.Intn(max)
+				if len(arg.Elements) == 0 {
+					return newErrorWithoutPos("cannot pick from an empty array")
+				}
+				i := rand.Intn(len(arg.Elements))
 				return arg.Elements[i]
 
 			case *object.String:
-				max := len(arg.Value) - 1
-				i := rand.Intn(max)
+				if len(arg.Value) == 0 {
+					return newErrorWithoutPos("cannot pick from an empty string")
+				}
+				i := rand.Intn(len(arg.Value))
 				return &object.String{Value: string(arg.Value[i])}
 
 			case *object.Range:
@@ -207,7 +258,7 @@ var builtins = map[string]*object.Builtin{
 				if min > max {
 					min, max = max, min
 				}
-				v := min + rand.Int63n(max-min)
+				v := min + rand.Int63n(max-min+1) // ranges are inclusive
 				return &object.Integer{Value: v}
 
 			default:
@@ -216,34 +267,6 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 
-	// PRINT
-
-	"yowl": {
-		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(strings.ToUpper(arg.String()))
-			}
-			fmt.Println()
-			return object.NULL
-		},
-	},
-
-	"yap": {
-		Fn: func(args ...object.Object) object.Object {
-			msg := spaceSeparatedArgs(args...)
-			fmt.Println(msg)
-			return object.NULL
-		},
-	},
-
-	"yelp": {
-		Fn: func(args ...object.Object) object.Object {
-			msg := spaceSeparatedArgs(args...)
-			fmt.Print(msg)
-			return object.NULL
-		},
-	},
-
 	// CONVERT
 
 	"yarn": {
@@ -284,11 +307,15 @@ var builtins = map[string]*object.Builtin{
 			}
 
 			switch arg := args[0].(type) {
-			case *object.Integer:
+			case *object.Integer, *object.BigInteger:
 				return arg
 
 			case *object.Number:
-				return &object.Integer{Value: int64(arg.Value)}
+				if math.IsInf(arg.Value, 0) || math.IsNaN(arg.Value) {
+					return newErrorWithoutPos("could not convert %s to integer", arg)
+				}
+				n, _ := big.NewFloat(arg.Value).Int(nil)
+				return object.NewInteger(n)
 
 			case *object.Boolean:
 				v := 0
@@ -299,10 +326,15 @@ var builtins = map[string]*object.Builtin{
 
 			case *object.String:
 				val, err := strconv.ParseInt(arg.Value, 0, 64)
-				if err != nil {
+				if err == nil {
+					return &object.Integer{Value: val}
+				}
+				// the number may just be too big for int64
+				n, ok := new(big.Int).SetString(arg.Value, 0)
+				if !ok {
 					return newErrorWithoutPos("could not parse %s as integer", arg.Value)
 				}
-				return &object.Integer{Value: val}
+				return object.NewInteger(n)
 
 			default:
 				return newErrorWithoutPos("unsupported argument type for int, got %s", arg.Type())
@@ -320,6 +352,8 @@ var builtins = map[string]*object.Builtin{
 				return arg
 			case *object.Integer:
 				return &object.Number{Value: float64(arg.Value)}
+			case *object.BigInteger:
+				return bigToNumber(arg)
 			case *object.String:
 				val, err := strconv.ParseFloat(arg.Value, 64)
 				if err != nil {
@@ -338,6 +372,256 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 
+	// HIGHER ORDER
+
+	// calls fn with every element and collects the results; hashmaps keep their keys
+	"map": {
+		HigherOrder: func(apply object.ApplyFunction, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newErrorWithoutPos("wrong number of args for map (got %d, want 2)", len(args))
+			}
+
+			if hashmap, ok := args[0].(*object.Hashmap); ok {
+				result := object.NewHashmap(hashmap.Len())
+				for _, pair := range hashmap.Pairs() {
+					val := apply(args[1], pair.Key, pair.Value)
+					if isError(val) {
+						return val
+					}
+					result.Set(pair.Key.(object.Hashable), val)
+				}
+				return result
+			}
+
+			elements := []object.Object{}
+			var err object.Object
+			ok := iterate(args[0], func(elt ...object.Object) bool {
+				val := apply(args[1], elt...)
+				if isError(val) {
+					err = val
+					return false
+				}
+				elements = append(elements, val)
+				return true
+			})
+			if !ok {
+				return newErrorWithoutPos("argument to `map` not supported, got %s", args[0].Type())
+			}
+			if err != nil {
+				return err
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	// keeps elements for which fn yeets something truthy
+	"filter": {
+		HigherOrder: func(apply object.ApplyFunction, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newErrorWithoutPos("wrong number of args for filter (got %d, want 2)", len(args))
+			}
+
+			if hashmap, ok := args[0].(*object.Hashmap); ok {
+				result := object.NewHashmap(0)
+				for _, pair := range hashmap.Pairs() {
+					keep := apply(args[1], pair.Key, pair.Value)
+					if isError(keep) {
+						return keep
+					}
+					if isTruthy(keep) {
+						result.Set(pair.Key.(object.Hashable), pair.Value)
+					}
+				}
+				return result
+			}
+
+			elements := []object.Object{}
+			var err object.Object
+			ok := iterate(args[0], func(elt ...object.Object) bool {
+				keep := apply(args[1], elt...)
+				if isError(keep) {
+					err = keep
+					return false
+				}
+				if isTruthy(keep) {
+					elements = append(elements, elt[0])
+				}
+				return true
+			})
+			if !ok {
+				return newErrorWithoutPos("argument to `filter` not supported, got %s", args[0].Type())
+			}
+			if err != nil {
+				return err
+			}
+
+			if args[0].Type() == object.STRING_OBJ {
+				var b strings.Builder
+				for _, elt := range elements {
+					b.WriteString(elt.(*object.String).Value)
+				}
+				return &object.String{Value: b.String()}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	// folds elements into a single value, starting with init (or the first element if there's none)
+	"reduce": {
+		HigherOrder: func(apply object.ApplyFunction, args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newErrorWithoutPos("wrong number of args for reduce (got %d, want 2 or 3)", len(args))
+			}
+
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			} else if args[0].Type() == object.HASHMAP_OBJ {
+				return newErrorWithoutPos("reducing a hashmap needs an initial value")
+			}
+
+			var err object.Object
+			ok := iterate(args[0], func(elt ...object.Object) bool {
+				if acc == nil {
+					acc = elt[0]
+					return true
+				}
+				acc = apply(args[1], append([]object.Object{acc}, elt...)...)
+				if isError(acc) {
+					err = acc
+					return false
+				}
+				return true
+			})
+			if !ok {
+				return newErrorWithoutPos("argument to `reduce` not supported, got %s", args[0].Type())
+			}
+			if err != nil {
+				return err
+			}
+			if acc == nil {
+				return newErrorWithoutPos("cannot reduce an empty %s without an initial value", args[0].Type())
+			}
+			return acc
+		},
+	},
+
+	// sorts elements by keys that fn yeets for them; hashmaps yeet their keys sorted
+	"sort_by": {
+		HigherOrder: func(apply object.ApplyFunction, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newErrorWithoutPos("wrong number of args for sort_by (got %d, want 2)", len(args))
+			}
+
+			elements := []object.Object{}
+			keys := []object.Object{}
+			var err object.Object
+			ok := iterate(args[0], func(elt ...object.Object) bool {
+				key := apply(args[1], elt...)
+				if isError(key) {
+					err = key
+					return false
+				}
+				elements = append(elements, elt[0])
+				keys = append(keys, key)
+				return true
+			})
+			if !ok {
+				return newErrorWithoutPos("argument to `sort_by` not supported, got %s", args[0].Type())
+			}
+			if err != nil {
+				return err
+			}
+
+			idx := make([]int, len(elements))
+			for i := range idx {
+				idx[i] = i
+			}
+			sort.SliceStable(idx, func(i, j int) bool {
+				less, cmpErr := lessThan(keys[idx[i]], keys[idx[j]])
+				if cmpErr != nil && err == nil {
+					err = cmpErr
+				}
+				return less
+			})
+			if err != nil {
+				return err
+			}
+
+			sorted := make([]object.Object, len(elements))
+			for i, j := range idx {
+				sorted[i] = elements[j]
+			}
+			return &object.Array{Elements: sorted}
+		},
+	},
+
+	// yeets the first element for which fn yeets something truthy (the key for hashmaps), or null
+	"find": {
+		HigherOrder: func(apply object.ApplyFunction, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newErrorWithoutPos("wrong number of args for find (got %d, want 2)", len(args))
+			}
+
+			var found object.Object = object.NULL
+			ok := iterate(args[0], func(elt ...object.Object) bool {
+				match := apply(args[1], elt...)
+				if isError(match) {
+					found = match
+					return false
+				}
+				if isTruthy(match) {
+					found = elt[0]
+					return false
+				}
+				return true
+			})
+			if !ok {
+				return newErrorWithoutPos("argument to `find` not supported, got %s", args[0].Type())
+			}
+			return found
+		},
+	},
+
+	// checks if fn yeets something truthy for any of the elements
+	"any": {
+		HigherOrder: func(apply object.ApplyFunction, args ...object.Object) object.Object {
+			return checkElements("any", true, apply, args...)
+		},
+	},
+
+	// checks if fn yeets something truthy for all of the elements
+	"all": {
+		HigherOrder: func(apply object.ApplyFunction, args ...object.Object) object.Object {
+			return checkElements("all", false, apply, args...)
+		},
+	},
+
+	// calls fn with every element, for its side effects
+	"each": {
+		HigherOrder: func(apply object.ApplyFunction, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newErrorWithoutPos("wrong number of args for each (got %d, want 2)", len(args))
+			}
+
+			var err object.Object
+			ok := iterate(args[0], func(elt ...object.Object) bool {
+				if result := apply(args[1], elt...); isError(result) {
+					err = result
+					return false
+				}
+				return true
+			})
+			if !ok {
+				return newErrorWithoutPos("argument to `each` not supported, got %s", args[0].Type())
+			}
+			if err != nil {
+				return err
+			}
+			return object.NULL
+		},
+	},
+
 	// ERROR THROWING
 
 	// throws an error, effectively terminating the program
@@ -347,7 +631,17 @@ var builtins = map[string]*object.Builtin{
 			if msg == "" {
 				msg = "yikes!"
 			}
-			return newErrorWithoutPos(msg)
+
+			err := newErrorWithoutPos(msg)
+			switch len(args) {
+			case 0:
+				err.Payload = object.NULL
+			case 1:
+				err.Payload = args[0]
+			default:
+				err.Payload = &object.Array{Elements: append([]object.Object{}, args...)}
+			}
+			return err
 		},
 	},
 
@@ -377,7 +671,8 @@ var builtins = map[string]*object.Builtin{
 				return newErrorWithoutPos("wrong number of args for yassert_eq (got %d, want 2 or 3)", len(args))
 			}
 
-			if reflect.DeepEqual(args[0], args[1]) {
+			// hashmaps are equal if they hold the same pairs, whatever order they were set in
+			if object.EqualKeys(args[0], args[1]) {
 				return object.NULL // all good, nothing to see here
 			}
 
@@ -400,3 +695,114 @@ func spaceSeparatedArgs(args ...object.Object) string {
 	}
 	return fmt.Sprint(s...)
 }
+
+// iterate calls f with every element of coll, until f reports false. Hashmaps pass both the key and
+// the value. It reports false if coll can't be iterated over.
+func iterate(coll object.Object, f func(elt ...object.Object) bool) bool {
+	switch coll := coll.(type) {
+	case *object.Array:
+		for _, elt := range coll.Elements {
+			if !f(elt) {
+				break
+			}
+		}
+
+	case *object.String:
+		for _, r := range coll.Value {
+			if !f(&object.String{Value: string(r)}) {
+				break
+			}
+		}
+
+	case *object.Range:
+		incr := int64(1)
+		if coll.Start > coll.End {
+			incr = -1
+		}
+		for i := coll.Start; i != coll.End+incr; i += incr {
+			if !f(&object.Integer{Value: i}) {
+				break
+			}
+		}
+
+	case *object.Hashmap:
+		for _, pair := range coll.Pairs() {
+			if !f(pair.Key, pair.Value) {
+				break
+			}
+		}
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// checkElements implements any & all, stopping at the first element for which fn yeets stopAt.
+func checkElements(name string, stopAt bool, apply object.ApplyFunction, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newErrorWithoutPos("wrong number of args for %s (got %d, want 2)", name, len(args))
+	}
+
+	var result object.Object = toYeetBool(!stopAt)
+	ok := iterate(args[0], func(elt ...object.Object) bool {
+		val := apply(args[1], elt...)
+		if isError(val) {
+			result = val
+			return false
+		}
+		if isTruthy(val) == stopAt {
+			result = toYeetBool(stopAt)
+			return false
+		}
+		return true
+	})
+	if !ok {
+		return newErrorWithoutPos("argument to `%s` not supported, got %s", name, args[0].Type())
+	}
+	return result
+}
+
+// lessThan compares keys used for sorting: numbers with numbers and strings with strings.
+func lessThan(a, b object.Object) (bool, *object.Error) {
+	// big integers are compared exactly with integers, and approximately with numbers
+	if a.Type() == object.BIG_INTEGER_OBJ || b.Type() == object.BIG_INTEGER_OBJ {
+		if x, ok := toBigInt(a); ok {
+			if y, ok := toBigInt(b); ok {
+				return x.Cmp(y) < 0, nil
+			}
+		}
+	}
+	if n, ok := a.(*object.BigInteger); ok {
+		a = bigToNumber(n)
+	}
+	if n, ok := b.(*object.BigInteger); ok {
+		b = bigToNumber(n)
+	}
+
+	switch a := a.(type) {
+	case *object.Integer:
+		switch b := b.(type) {
+		case *object.Integer:
+			return a.Value < b.Value, nil
+		case *object.Number:
+			return float64(a.Value) < b.Value, nil
+		}
+
+	case *object.Number:
+		switch b := b.(type) {
+		case *object.Integer:
+			return a.Value < float64(b.Value), nil
+		case *object.Number:
+			return a.Value < b.Value, nil
+		}
+
+	case *object.String:
+		if b, ok := b.(*object.String); ok {
+			return a.Value < b.Value, nil
+		}
+	}
+
+	return false, newErrorWithoutPos("cannot compare %s with %s", a.Type(), b.Type())
+}
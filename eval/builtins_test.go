@@ -26,6 +26,18 @@ func TestBuiltinYassertFunction(t *testing.T) {
 	})
 }
 
+func TestBuiltinYassertEqFunction(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`yassert_eq(1, 1)`, nil},
+		{`yassert_eq([1, [2, "a"]], [1, [2, "a"]])`, nil},
+		{`yassert_eq(%{"a": 1, "b": 2}, %{"b": 2, "a": 1})`, nil},
+		{`yassert_eq([%{"a": [1], "b": %{"c": 2, "d": 3}}], [%{"b": %{"d": 3, "c": 2}, "a": [1]}])`, nil},
+		{`yassert_eq(%{"a": 1}, %{"a": 1, "b": 2})`, errmsg{"yassert failed: want {a: 1}, got {a: 1, b: 2}"}},
+		{`yassert_eq([1, 2], [2, 1])`, errmsg{"yassert failed: want [1, 2], got [2, 1]"}},
+		{`yassert_eq(1, 2, "nope")`, errmsg{"yassert failed: want 1, got 2 (nope)"}},
+	})
+}
+
 func TestBuiltinCastingFunctions(t *testing.T) {
 	runEvalTests(t, []evalTestCase{
 		{`yarn(5)`, "5"},
@@ -70,12 +82,19 @@ func TestBuiltinYoinkFunction(t *testing.T) {
 		{`arr := [1, 2, 3]; x := yoink(arr, 1); x`, 2},
 		{`arr := [1, 2, 3]; x := yoink(arr, 1); arr`, []int64{1, 3}},
 		{`arr := [1, 2, 3]; x := yoink(arr, 100); x`, nil},
+		{`arr := [1, 2, 3]; x := yoink(arr, -1); [x, len(arr)]`, []int64{3, 2}},
+		{`arr := [1, 2, 3]; x := yoink(arr, -3); [x, len(arr)]`, []int64{1, 2}},
+		{`arr := [1, 2, 3]; x := yoink(arr, -4); x`, nil},
+		{`arr := []; x := yoink(arr); x`, nil},
 
 		{`str := "howdy"; x := yoink(str); x`, "y"},
 		{`str := "howdy"; x := yoink(str); str`, "howd"},
 		{`str := "howdy"; x := yoink(str, 1); x`, "o"},
 		{`str := "howdy"; x := yoink(str, 1); str`, "hwdy"},
 		{`str := "howdy"; x := yoink(str, 100); x`, nil},
+		{`str := "howdy"; x := yoink(str, -2); x`, "d"},
+		{`str := "howdy"; x := yoink(str, -6); x`, nil},
+		{`str := ""; x := yoink(str); x`, nil},
 
 		{`a := 69; b := yoink(a); [a, b]`, []int64{0, 69}},
 		{`a := -69; b := yoink(a); [a, b]`, []int64{0, -69}},
@@ -86,3 +105,81 @@ func TestBuiltinYoinkFunction(t *testing.T) {
 		{`yoink(0..69)`, errmsg{"cannot yoink from RANGE"}},
 	})
 }
+
+func TestBuiltinYahtzeeFunction(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`yahtzee([7])`, 7},
+		{`yahtzee("y")`, "y"},
+		{`yahtzee(5..5)`, 5},
+		{`yahtzee(1)`, 0},
+		{`x := yahtzee([1, 2]); x == 1 || x == 2`, true},
+		{`x := yahtzee(3..1); x >= 1 && x <= 3`, true},
+		{`yahtzee([])`, errmsg{"cannot pick from an empty array"}},
+		{`yahtzee("")`, errmsg{"cannot pick from an empty string"}},
+		{`yahtzee(0)`, errmsg{"negative integer not supported by yahtzee"}},
+	})
+}
+
+func TestBuiltinHigherOrderFunctions(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`map([1, 2, 3], \x { x * 2 })`, []int64{2, 4, 6}},
+		{`map(1..3, \x { x * x })`, []int64{1, 4, 9}},
+		{`map(3..1, \x { x })`, []int64{3, 2, 1}},
+		{`map("abc", \c { c + c })`, []string{"aa", "bb", "cc"}},
+		{`map([], \x { x })`, []int64{}},
+		{`map([1, 2], len)`, errmsg{"argument to `len` not supported, got INTEGER"}},
+		{`h := map(%{"a": 1, "b": 2}, \k, v { k + yarn(v) }); h["a"] + h["b"]`, "a1b2"},
+		{`map(5, \x { x })`, errmsg{"argument to `map` not supported, got INTEGER"}},
+		{`map([1])`, errmsg{"wrong number of args for map (got 1, want 2)"}},
+		{`map([1, 2], \x { yikes("nope") })`, errmsg{"nope"}},
+		{`map([1, 2], \x, y { x })`, errmsg{"wrong number of args (got 1, want 2)"}},
+
+		{`filter([1, 2, 3, 4], \x { x % 2 == 0 })`, []int64{2, 4}},
+		{`filter(1..6, \x { x > 4 })`, []int64{5, 6}},
+		{`filter("yeet yoink", \c { c != " " })`, "yeetyoink"},
+		{`len(filter(%{"a": 1, "b": 2, "c": 3}, \k, v { v > 1 }))`, 2},
+		{`filter(%{"a": 1}, \k, v { v == 1 })["a"]`, 1},
+
+		{`reduce([1, 2, 3, 4], \acc, x { acc + x })`, 10},
+		{`reduce([1, 2, 3], \acc, x { acc + x }, 10)`, 16},
+		{`reduce(1..4, \acc, x { acc * x })`, 24},
+		{`reduce("abc", \acc, c { c + acc }, "")`, "cba"},
+		{`reduce(%{"a": 1, "b": 2}, \acc, k, v { acc + v }, 0)`, 3},
+		{`reduce([], \acc, x { acc + x }, 7)`, 7},
+		{`reduce([5], \acc, x { acc + x })`, 5},
+		{`reduce([], \acc, x { acc + x })`, errmsg{"cannot reduce an empty ARRAY without an initial value"}},
+		{`reduce(%{"a": 1}, \acc, k, v { v })`, errmsg{"reducing a hashmap needs an initial value"}},
+
+		{`sort_by([3, 1, 2], \x { x })`, []int64{1, 2, 3}},
+		{`sort_by([3, 1, 2], \x { -x })`, []int64{3, 2, 1}},
+		{`sort_by(["bb", "a", "ccc"], \s { len(s) })`, []string{"a", "bb", "ccc"}},
+		{`sort_by(["b", "c", "a"], \s { s })`, []string{"a", "b", "c"}},
+		{`sort_by([1, 2, 3, 4], \x { x % 2 })`, []int64{2, 4, 1, 3}},
+		{`sort_by([1, 2, 3], \x { 2.5 - x })`, []int64{3, 2, 1}},
+		{`sort_by(%{"a": 3, "b": 1, "c": 2}, \k, v { v })`, []string{"b", "c", "a"}},
+		{`sort_by([1, "a"], \x { x })`, errmsg{"cannot compare STRING with INTEGER"}},
+
+		{`find([1, 2, 3, 4], \x { x > 2 })`, 3},
+		{`find([1, 2], \x { x > 2 })`, nil},
+		{`find("yeet", \c { c == "e" })`, "e"},
+		{`find(%{"a": 1, "b": 2}, \k, v { v == 2 })`, "b"},
+
+		{`any([1, 2, 3], \x { x > 2 })`, true},
+		{`any([1, 2, 3], \x { x > 3 })`, false},
+		{`any([], \x { true })`, false},
+		{`all([1, 2, 3], \x { x > 0 })`, true},
+		{`all([1, 2, 3], \x { x > 1 })`, false},
+		{`all([], \x { false })`, true},
+		{`all(%{"a": 1, "b": 2}, \k, v { v > 0 })`, true},
+		{`any([1, 2], \x { yikes("nope") })`, errmsg{"nope"}},
+
+		{`sum := 0; each(1..4, \x { sum = sum + x }); sum`, 10},
+		{`keys := ""; each(%{"a": 1}, \k, v { keys = keys + k }); keys`, "a"},
+		{`each([1], \x { 1 + true })`, errmsg{"type mismatch: INTEGER + BOOLEAN"}},
+
+		// builtins can be passed around too
+		{`map(["a", "bb"], len)`, []int64{1, 2}},
+		{`map([[1, 2]], \a { map(a, \x { x * 10 }) })[0]`, []int64{10, 20}},
+		{`ytry { map([1], \x { yikes("inner") }) } ycatch { err["msg"] }`, "inner"},
+	})
+}
@@ -0,0 +1,149 @@
+package eval
+
+import (
+	"yy/ast"
+	"yy/object"
+)
+
+// destructure takes val apart as pattern shows, declaring its targets (or assigning to them). It
+// yeets an error if val doesn't fit the pattern, nil otherwise.
+func destructure(pattern ast.Expression, val object.Object, declaring bool, env *object.Environment) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		elements, err := UnpackArray(val, len(pattern.Elements), pattern.Required(), pattern.Rest != nil)
+		if err != nil {
+			err.Pos = pattern.Pos()
+			return err
+		}
+		for i, el := range pattern.Elements {
+			if err := destructureElement(el, elements[i], declaring, env); err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil {
+			return destructureTarget(pattern.Rest, elements[len(elements)-1], declaring, env)
+		}
+
+	case *ast.HashmapPattern:
+		hashmap, err := UnpackHashmap(val)
+		if err != nil {
+			err.Pos = pattern.Pos()
+			return err
+		}
+		for _, el := range pattern.Pairs {
+			key := Eval(el.Key, env)
+			if isError(key) {
+				return key
+			}
+			val := UnpackKey(hashmap, key, el.Default != nil)
+			if errObj, ok := val.(*object.Error); ok {
+				errObj.Pos = el.Key.Pos()
+				return errObj
+			}
+			if err := destructureElement(el, val, declaring, env); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func destructureElement(el ast.PatternElement, val object.Object, declaring bool, env *object.Environment) object.Object {
+	if val == object.NULL && el.Default != nil {
+		val = Eval(el.Default, env)
+		if isError(val) {
+			return val
+		}
+	}
+	return destructureTarget(el.Target, val, declaring, env)
+}
+
+func destructureTarget(target ast.Expression, val object.Object, declaring bool, env *object.Environment) object.Object {
+	switch target := target.(type) {
+	case *ast.ArrayPattern, *ast.HashmapPattern:
+		return destructure(target, val, declaring, env)
+	case *ast.TypePattern:
+		return destructureTarget(target.Target, val, declaring, env)
+	case *ast.IntegerLiteral, *ast.NumberLiteral, *ast.StringLiteral, *ast.BooleanLiteral, *ast.NullLiteral,
+		*ast.PrefixExpression, *ast.RangeLiteral:
+		// parts of a ymatch pattern with nothing to bind, which have already matched
+		return nil
+	case *ast.Identifier:
+		if target.Value == "_" {
+			return nil
+		}
+		if declaring {
+			declare(target, val, env)
+			return nil
+		}
+	}
+
+	if result := assign(target, val, env); isError(result) {
+		return result
+	}
+	return nil
+}
+
+// UnpackArray yeets elements of an array taken apart by a pattern of n elements, the first required
+// of which the array must have. Missing elements are null. If the pattern has a rest, the remaining
+// elements follow in an array of their own, otherwise there can't be any.
+func UnpackArray(val object.Object, n, required int, rest bool) ([]object.Object, *object.Error) {
+	arr, ok := val.(*object.Array)
+	if !ok {
+		return nil, newErrorWithoutPos("cannot destructure %s as an array", val.Type())
+	}
+
+	got := len(arr.Elements)
+	switch {
+	case got < required && (rest || required < n):
+		return nil, newErrorWithoutPos("not enough elements to destructure (got %d, want at least %d)", got, required)
+	case got < required:
+		return nil, newErrorWithoutPos("not enough elements to destructure (got %d, want %d)", got, required)
+	case got > n && !rest && required < n:
+		return nil, newErrorWithoutPos("too many elements to destructure (got %d, want at most %d)", got, n)
+	case got > n && !rest:
+		return nil, newErrorWithoutPos("too many elements to destructure (got %d, want %d)", got, n)
+	}
+
+	elements := make([]object.Object, n, n+1)
+	for i := range elements {
+		elements[i] = object.NULL
+	}
+	copy(elements, arr.Elements)
+	if rest {
+		remaining := []object.Object{}
+		if got > n {
+			remaining = append(remaining, arr.Elements[n:]...)
+		}
+		elements = append(elements, &object.Array{Elements: remaining})
+	}
+	return elements, nil
+}
+
+// UnpackHashmap checks that a value taken apart by a hashmap pattern is a hashmap.
+func UnpackHashmap(val object.Object) (*object.Hashmap, *object.Error) {
+	hashmap, ok := val.(*object.Hashmap)
+	if !ok {
+		return nil, newErrorWithoutPos("cannot destructure %s as a hashmap", val.Type())
+	}
+	return hashmap, nil
+}
+
+// UnpackKey yeets the value under key in a hashmap taken apart by a pattern. Unless the key is
+// optional (ie it has a default value in the pattern), the hashmap must have it.
+func UnpackKey(hashmap *object.Hashmap, key object.Object, optional bool) object.Object {
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return newErrorWithoutPos("key not hashable: %s", key.Type())
+	}
+
+	val, ok := lookupKey(hashmap, hashable)
+	switch {
+	case ok:
+		return val
+	case optional:
+		return object.NULL
+	}
+	return newErrorWithoutPos("key not found when destructuring: %s", key.String())
+}
@@ -2,19 +2,27 @@ package eval
 
 import (
 	"fmt"
-	"reflect"
+	"math"
+	"math/big"
+	"os"
 
 	"yy/ast"
 	"yy/object"
+	"yy/yikes"
 )
 
 func Eval(node ast.Expression, env *object.Environment) object.Object {
+	if err := env.Guard().Step(); err != nil {
+		err.Pos = node.Pos()
+		return err
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node.Expressions, env)
 
 	case *ast.BlockExpression:
-		return evalBlockExpression(node.Expressions, env)
+		return evalBlockExpression(node, env)
 
 	case *ast.YeetExpression:
 		val := Eval(node.ReturnValue, env)
@@ -23,25 +31,60 @@ func Eval(node ast.Expression, env *object.Environment) object.Object {
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.YbreakExpression:
+		var val object.Object = object.NULL
+		if node.Value != nil {
+			val = Eval(node.Value, env)
+			if isError(val) {
+				return val
+			}
+		}
+		return &object.Break{Label: node.Label, Value: val}
+
+	case *ast.YcontinueExpression:
+		return &object.Break{Label: node.Label, Value: object.NULL, Continue: true}
+
 	case *ast.CallExpression:
 		return evalCallExpr(node, env)
 
+	case *ast.PipeExpression:
+		return evalCallExpr(node.Call, env)
+
 	case *ast.DeclareExpression:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		declare(node.Name, val, env)
 		return val
 
 	case *ast.AssignExpression:
-		return evalAssignExpression(node, env)
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return assign(node.Left, val, env)
+
+	case *ast.DestructureExpression:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if err := destructure(node.Pattern, val, node.Declare(), env); err != nil {
+			return err
+		}
+		return val
 
 	case *ast.IndexExpression:
-		return evalIndexExpression(node, env)
+		result, _ := evalIndexExpression(node, env)
+		return result
+
+	case *ast.DotExpression:
+		result, _ := evalDotExpression(node, env)
+		return result
 
 	case *ast.Identifier:
-		if val, ok := env.Get(node.Value); ok {
+		if val, ok := lookup(node, env); ok {
 			return val
 		}
 		if builtin, ok := builtins[node.Value]; ok {
@@ -69,14 +112,14 @@ func Eval(node ast.Expression, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		result := evalInfixExpression(node.Operator, left, right, env.IsYoloMode())
+		result := guardedInfix(node.Operator, left, right, env.IsYoloMode(), env.Guard())
 		if errObj, ok := result.(*object.Error); ok {
 			errObj.Pos = node.Pos()
 		}
 		return result
 
 	case *ast.YoloExpression:
-		extendedEnv := object.NewEnclosedEnvironment(env)
+		extendedEnv := enterScope(node.Scope, env)
 		extendedEnv.SetYoloMode()
 		return Eval(node.Body, extendedEnv)
 
@@ -132,9 +175,9 @@ func Eval(node ast.Expression, env *object.Environment) object.Object {
 		}
 
 	case *ast.YoyoExpression:
-		extendedEnv := object.NewEnclosedEnvironment(env)
+		extendedEnv := enterScope(node.Scope, env)
 
-		var result object.Object
+		var result object.Object = object.NULL // if the body never runs
 
 		for {
 			condition := Eval(node.Condition, extendedEnv)
@@ -146,72 +189,33 @@ func Eval(node ast.Expression, env *object.Environment) object.Object {
 				return result
 			}
 
-			result = Eval(node.Body, extendedEnv)
-			if isErrorOrReturn(result) {
+			var ok bool
+			result, ok = afterIteration(node.Label, Eval(node.Body, extendedEnv))
+			if !ok {
 				return result
 			}
 		}
 
 	case *ast.YallExpression:
-		var result object.Object
-		iter := Eval(node.Iterable, env)
-		extendedEnv := object.NewEnclosedEnvironment(env)
-
-		switch iter := iter.(type) {
-		case *object.Array:
-			for _, v := range iter.Elements {
-				extendedEnv.Set(node.KeyName, v)
-				result = Eval(node.Body, extendedEnv)
-				if isErrorOrReturn(result) {
-					return result
-				}
-			}
+		return evalYallExpression(node, env)
 
-		case *object.String:
-			for _, v := range iter.Value {
-				extendedEnv.Set(node.KeyName, &object.String{Value: string(v)})
-				result = Eval(node.Body, extendedEnv)
-				if isErrorOrReturn(result) {
-					return result
-				}
-			}
+	case *ast.YmatchExpression:
+		return evalYmatchExpression(node, env)
 
-		case *object.Range:
-			incr := int64(1)
-			if iter.Start > iter.End {
-				incr = -1
-			}
+	case *ast.ImportExpression:
+		return importModule(node, env)
 
-			for i := iter.Start; i != iter.End+incr; i += incr {
-				extendedEnv.Set(node.KeyName, &object.Integer{Value: i})
-				result = Eval(node.Body, extendedEnv)
-				if isErrorOrReturn(result) {
-					return result
-				}
-			}
-
-		case *object.Integer:
-			start := int64(0)
-			end := iter.Value
-
-			if iter.Value < 0 {
-				start = iter.Value
-				end = 0
-			}
-
-			for i := start; i <= end; i++ {
-				extendedEnv.Set(node.KeyName, &object.Integer{Value: i})
-				result = Eval(node.Body, extendedEnv)
-				if isErrorOrReturn(result) {
-					return result
-				}
-			}
-
-		default:
-			return newError(node.Iterable.Pos(), "cannot iterate over %s, type of %s", iter, iter.Type())
+	case *ast.YtryExpression:
+		result := Eval(node.Body, env)
+		errObj, ok := result.(*object.Error)
+		if !ok || errObj.Fatal {
+			return result
 		}
 
-		return result
+		blame(errObj, env)
+		extendedEnv := enterScope(node.CatchScope, env)
+		extendedEnv.Set(node.ErrName, errorToHashmap(errObj, env.Module()))
+		return Eval(node.Catch, extendedEnv)
 
 	// LITERALS
 
@@ -219,6 +223,9 @@ func Eval(node ast.Expression, env *object.Environment) object.Object {
 		return object.NULL
 
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
 
 	case *ast.NumberLiteral:
@@ -238,7 +245,11 @@ func Eval(node ast.Expression, env *object.Environment) object.Object {
 		}
 		value := fmt.Sprintf(node.Template, vals...)
 
-		return &object.String{Value: value}
+		result := checkLength(&object.String{Value: value}, env.Guard())
+		if errObj, ok := result.(*object.Error); ok {
+			errObj.Pos = node.Pos()
+		}
+		return result
 
 	case *ast.BooleanLiteral:
 		return toYeetBool(node.Value)
@@ -246,10 +257,17 @@ func Eval(node ast.Expression, env *object.Environment) object.Object {
 	case *ast.LambdaLiteral:
 		return &object.Lambda{
 			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
 			Body:       node.Body,
 			Env:        env,
+			Name:       node.Name,
+			Scope:      node.Scope,
 		}
 
+	case *ast.MacroLiteral:
+		return newError(node.Pos(), "macros can only be declared at the top level of a program")
+
 	case *ast.RangeLiteral:
 		start := Eval(node.Start, env)
 		if isError(start) {
@@ -259,14 +277,11 @@ func Eval(node ast.Expression, env *object.Environment) object.Object {
 		if isError(end) {
 			return end
 		}
-		if start.Type() != object.INTEGER_OBJ || end.Type() != object.INTEGER_OBJ {
-			return newError(node.Start.Pos(), "only integers can be used to create a range (got %s..%s)", start.Type(), end.Type())
-		}
-
-		return &object.Range{
-			Start: start.(*object.Integer).Value,
-			End:   end.(*object.Integer).Value,
+		result := newRange(start, end)
+		if errObj, ok := result.(*object.Error); ok {
+			errObj.Pos = node.Start.Pos()
 		}
+		return result
 
 	case *ast.ArrayLiteral:
 		elts := []object.Object{}
@@ -280,24 +295,23 @@ func Eval(node ast.Expression, env *object.Environment) object.Object {
 		return &object.Array{Elements: elts}
 
 	case *ast.HashmapLiteral:
-		hashmap := &object.Hashmap{Pairs: map[object.HashKey]object.HashPair{}}
-		for k, v := range node.Pairs {
-			key := Eval(k, env)
+		hashmap := object.NewHashmap(len(node.Pairs))
+		for _, pair := range node.Pairs {
+			key := Eval(pair.Key, env)
 			if isError(key) {
 				return key
 			}
 			hashKey, ok := key.(object.Hashable)
 			if !ok {
-				return newError(k.Pos(), "key not hashable: %s", key.Type())
+				return newError(pair.Key.Pos(), "key not hashable: %s", key.Type())
 			}
 
-			val := Eval(v, env)
+			val := Eval(pair.Value, env)
 			if isError(val) {
 				return val
 			}
 
-			pair := object.HashPair{Key: key, Value: val}
-			hashmap.Pairs[hashKey.HashKey()] = pair
+			hashmap.Set(hashKey, val)
 		}
 		return hashmap
 
@@ -314,8 +328,9 @@ func Eval(node ast.Expression, env *object.Environment) object.Object {
 	}
 }
 
-func evalProgram(expressions []ast.Expression, env *object.Environment) object.Object {
-	var result object.Object
+func evalProgram(expressions []ast.Expression, env *object.Environment) (result object.Object) {
+	defer recoverPanic(-1, &result)
+
 	for _, stmt := range expressions {
 		result = Eval(stmt, env)
 
@@ -323,6 +338,7 @@ func evalProgram(expressions []ast.Expression, env *object.Environment) object.O
 		case *object.ReturnValue:
 			return result.Value
 		case *object.Error:
+			blame(result, env)
 			return result
 		}
 	}
@@ -330,16 +346,16 @@ func evalProgram(expressions []ast.Expression, env *object.Environment) object.O
 	return result
 }
 
-func evalBlockExpression(exprs []ast.Expression, env *object.Environment) object.Object {
+func evalBlockExpression(block *ast.BlockExpression, env *object.Environment) object.Object {
 	var result object.Object
-	extendedEnv := object.NewEnclosedEnvironment(env)
-	for _, expr := range exprs {
+	extendedEnv := enterScope(block.Scope, env)
+	for _, expr := range block.Expressions {
 		result = Eval(expr, extendedEnv)
 
 		if result != nil {
 			rtype := result.Type()
-			if rtype == object.RETURN_VALUE_OBJ || rtype == object.ERROR_OBJ {
-				// don't unwrap return value and let it bubble so it stops execution in outer block
+			if rtype == object.RETURN_VALUE_OBJ || rtype == object.ERROR_OBJ || rtype == object.BREAK_OBJ {
+				// don't unwrap return value (or break) and let it bubble so it stops execution in outer block
 				return result
 			}
 		}
@@ -348,14 +364,34 @@ func evalBlockExpression(exprs []ast.Expression, env *object.Environment) object
 	return result
 }
 
-func evalCallExpr(callExpr *ast.CallExpression, env *object.Environment) object.Object {
-	fn := Eval(callExpr.Function, env)
+// evalCallExpr calls a function. Panics of the interpreter are most likely to come from calls
+// (host functions included), so that's where they're turned into errors pointing at the code.
+func evalCallExpr(callExpr *ast.CallExpression, env *object.Environment) (result object.Object) {
+	// a function with a single return is cheap to defer in
+	defer recoverPanic(callExpr.Pos(), &result)
+	return evalCall(callExpr, env)
+}
+
+func evalCall(callExpr *ast.CallExpression, env *object.Environment) object.Object {
+	if ident, ok := callExpr.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+		if len(callExpr.Arguments) != 1 {
+			return newError(callExpr.Pos(), "wrong number of args for quote (got %d, want 1)", len(callExpr.Arguments))
+		}
+		return quote(callExpr.Arguments[0], env)
+	}
+
+	fn, receiver := evalCallee(callExpr.Function, env)
 	if isError(fn) {
 		return fn
 	}
 
 	args := make([]object.Object, 0, len(callExpr.Arguments))
+	var names []string
 	for _, a := range callExpr.Arguments {
+		if named, ok := a.(*ast.NamedArgument); ok {
+			names = append(names, named.Name)
+			a = named.Value
+		}
 		evaluated := Eval(a, env)
 		if isError(evaluated) {
 			return evaluated
@@ -365,29 +401,33 @@ func evalCallExpr(callExpr *ast.CallExpression, env *object.Environment) object.
 
 	switch fn := fn.(type) {
 	case *object.Lambda:
-		if len(fn.Parameters) != len(args) {
-			return newError(
-				callExpr.Pos(),
-				"wrong number of args for %s (got %d, want %d)",
-				callExpr.Function.TokenLiteral(), len(args), len(fn.Parameters))
+		bound, extra, err := bindArgs(fn, CalleeName(callExpr.Function), args, names)
+		if err != nil {
+			err.Pos = callExpr.Pos()
+			return err
 		}
 
-		extendedEnv := object.NewEnclosedEnvironment(fn.Env)
-		for paramIdx, param := range fn.Parameters {
-			extendedEnv.Set(param.Value, args[paramIdx])
+		if env.Depth() >= env.Guard().MaxCallDepth() {
+			return newError(callExpr.Function.Pos(), "maximum recursion depth exceeded")
 		}
 
-		evaluated := Eval(fn.Body, extendedEnv)
-
-		// unwrap return value so it doesn't stop eval in outer scope
-		if returnValue, ok := evaluated.(*object.ReturnValue); ok {
-			return returnValue.Value
+		evaluated := callLambda(fn, bound, extra, env, receiver)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			addTraceFrame(errObj, fn, callExpr.Function.Pos(), env)
 		}
 		return evaluated
 
 	case *object.Builtin:
-		result := fn.Fn(args...)
-		if errObj, ok := result.(*object.Error); ok {
+		if names != nil {
+			return newError(callExpr.Pos(), "named args can't be passed to builtins")
+		}
+		apply := func(f object.Object, args ...object.Object) object.Object {
+			return applyFunction(f, args, env, callExpr.Function.Pos())
+		}
+
+		result := checkLength(fn.Call(apply, args...), env.Guard())
+		// errors raised by functions the builtin called already point at the right place
+		if errObj, ok := result.(*object.Error); ok && errObj.Pos < 0 {
 			errObj.Pos = callExpr.Function.Pos()
 		}
 		return result
@@ -397,15 +437,99 @@ func evalCallExpr(callExpr *ast.CallExpression, env *object.Environment) object.
 	}
 }
 
-func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
-	val := Eval(node.Value, env)
-	if isError(val) {
-		return val
+// applyFunction calls fn on behalf of Go code, eg a builtin. The call is made from the code at pos,
+// running in caller env.
+func applyFunction(fn object.Object, args []object.Object, caller *object.Environment, pos int) object.Object {
+	switch fn := fn.(type) {
+	case *object.Lambda:
+		bound, extra, err := bindArgs(fn, "", args, nil)
+		if err != nil {
+			return err
+		}
+		if caller.Depth() >= caller.Guard().MaxCallDepth() {
+			return newErrorWithoutPos("maximum recursion depth exceeded")
+		}
+
+		result := callLambda(fn, bound, extra, caller, nil)
+		if errObj, ok := result.(*object.Error); ok {
+			addTraceFrame(errObj, fn, pos, caller)
+		}
+		return result
+
+	case *object.Builtin:
+		apply := func(f object.Object, args ...object.Object) object.Object {
+			return applyFunction(f, args, caller, pos)
+		}
+		return checkLength(fn.Call(apply, args...), caller.Guard())
+
+	default:
+		return newErrorWithoutPos("not a function: %s", fn.Type())
+	}
+}
+
+// addTraceFrame records that err went through a call of fn, made at pos by code running in caller.
+func addTraceFrame(err *object.Error, fn *object.Lambda, pos int, caller *object.Environment) {
+	blame(err, fn.Env)
+	err.Trace = append(err.Trace, object.TraceFrame{Fn: fn.Name, Pos: pos, File: modulePath(caller)})
+}
+
+// evalCallee evaluates the function called by a call expression. A function taken from a hashmap,
+// like obj.method or obj["method"], is called as a method of the hashmap, which is yeeted as the
+// receiver of the call.
+func evalCallee(node ast.Expression, env *object.Environment) (object.Object, *object.Hashmap) {
+	var fn, left object.Object
+	switch node := node.(type) {
+	case *ast.DotExpression:
+		fn, left = evalDotExpression(node, env)
+	case *ast.IndexExpression:
+		fn, left = evalIndexExpression(node, env)
+	default:
+		return Eval(node, env), nil
+	}
+
+	receiver, _ := left.(*object.Hashmap)
+	return fn, receiver
+}
+
+// callLambda runs the body of fn with args bound to its parameters, and extra args collected by
+// its rest parameter. Parameters bound to null get their default values, if they have any. The
+// caller env is only used to keep track of the call depth. A lambda called as a method gets the
+// receiver as self, which hides self of the code it was declared in, otherwise it sees that one.
+func callLambda(fn *object.Lambda, args, extra []object.Object, caller *object.Environment, receiver *object.Hashmap) object.Object {
+	extendedEnv := object.NewCallEnvironment(fn.Env, caller, fn.Scope)
+	if receiver != nil {
+		extendedEnv.Set("self", receiver)
+	}
+	for paramIdx, param := range fn.Parameters {
+		val := args[paramIdx]
+		// defaults are evaluated in the call, so they can refer to the parameters before them
+		if def := ast.DefaultOf(fn.Defaults, paramIdx); def != nil && val == object.NULL {
+			val = Eval(def, extendedEnv)
+			if isError(val) {
+				return val
+			}
+		}
+		extendedEnv.Set(param.Value, val)
+	}
+	if fn.Rest != nil {
+		rest := append(append([]object.Object{}, fn.BakedRest...), extra...)
+		extendedEnv.Set(fn.Rest.Value, &object.Array{Elements: rest})
 	}
 
-	switch node := node.Left.(type) {
+	evaluated := Eval(fn.Body, extendedEnv)
+
+	// unwrap return value so it doesn't stop eval in outer scope
+	if returnValue, ok := evaluated.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	return evaluated
+}
+
+// assign assigns val to a variable, an index or a field, yeeting val (or an error).
+func assign(left ast.Expression, val object.Object, env *object.Environment) object.Object {
+	switch node := left.(type) {
 	case *ast.Identifier:
-		if ok := env.Update(node.Value, val); ok {
+		if ok := update(node, val, env); ok {
 			return val
 		}
 		if env.IsYoloMode() {
@@ -427,65 +551,159 @@ func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) o
 			return idx
 		}
 
-		switch {
-		case left.Type() == object.ARRAY_OBJ && idx.Type() == object.INTEGER_OBJ:
-			i := idx.(*object.Integer).Value
-			arr := left.(*object.Array)
-			if i < 0 {
-				i += int64(len(arr.Elements))
-			}
-			if i < 0 || i >= int64(len(arr.Elements)) {
-				return newError(
-					node.Left.Pos(),
-					"attempted to assign out of bounds for array '%s'",
-					node.Left.(*ast.Identifier).Value)
-			}
-			arr.Elements[i] = val
-			return val
+		result := setIndex(left, idx, val, node.Left.String())
+		if errObj, ok := result.(*object.Error); ok {
+			errObj.Pos = node.Index.Pos()
+		}
+		return result
 
-		case left.Type() == object.STRING_OBJ && idx.Type() == object.INTEGER_OBJ:
-			i := idx.(*object.Integer).Value
-			str := left.(*object.String)
-			if i < 0 {
-				i += int64(len(str.Value))
-			}
-			if i < 0 || i >= int64(len(str.Value)) {
-				return newError(
-					node.Left.Pos(),
-					"attempted to assign out of bounds for string '%s'",
-					node.Left.(*ast.Identifier).Value)
-			}
-			str.Value = str.Value[:i] + val.String() + str.Value[i+1:]
-			return val
+	case *ast.DotExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
 
-		case left.Type() == object.HASHMAP_OBJ:
-			hashmap := left.(*object.Hashmap)
-			key, ok := idx.(object.Hashable)
-			if !ok {
-				return newError(node.Index.Pos(), "key not hashable: %s", idx.Type())
-			}
+		result := setField(left, node.Name, val)
+		if errObj, ok := result.(*object.Error); ok {
+			errObj.Pos = node.NamePos
+		}
+		return result
+	}
 
-			hashmap.Pairs[key.HashKey()] = object.HashPair{Key: idx, Value: val}
-			return val
+	return newError(left.Pos(), "identifier not found: "+left.String())
+}
 
-		default:
-			return newError(node.Index.Pos(), "index operator not supported: %s, type of %s", idx.String(), idx.Type())
+// enterScope returns the environment code in scope runs in. Unresolved code gets an environment with
+// variables looked up by name.
+func enterScope(scope *ast.Scope, env *object.Environment) *object.Environment {
+	switch {
+	case scope == nil:
+		return object.NewEnclosedEnvironment(env)
+	case scope.Shared:
+		return env
+	}
+	return object.NewScopeEnvironment(env, scope)
+}
+
+// lookup finds the variable ident refers to, going by name if the resolver couldn't tell where it is.
+func lookup(ident *ast.Identifier, env *object.Environment) (object.Object, bool) {
+	switch ident.Resolution {
+	case ast.Local:
+		if slot := env.Slot(ident.Scope, ident.Depth, ident.Slot); slot != nil && *slot != nil {
+			return *slot, true
 		}
+	case ast.Global:
+		return env.GetGlobal(ident.Value)
 	}
+	return env.Get(ident.Value)
+}
 
-	return newError(node.Left.Pos(), "identifier not found: "+node.Left.String())
+func declare(ident *ast.Identifier, val object.Object, env *object.Environment) {
+	if ident.Resolution == ast.Local {
+		if slot := env.Slot(ident.Scope, ident.Depth, ident.Slot); slot != nil {
+			*slot = val
+			return
+		}
+	}
+	env.Set(ident.Value, val)
 }
 
-func evalIndexExpression(node *ast.IndexExpression, env *object.Environment) object.Object {
+func update(ident *ast.Identifier, val object.Object, env *object.Environment) bool {
+	switch ident.Resolution {
+	case ast.Local:
+		if slot := env.Slot(ident.Scope, ident.Depth, ident.Slot); slot != nil && *slot != nil {
+			*slot = val
+			return true
+		}
+	case ast.Global:
+		return env.UpdateGlobal(ident.Value, val)
+	}
+	return env.Update(ident.Value, val)
+}
+
+// evalIndexExpression yeets the value of the expression, along with the indexed value.
+func evalIndexExpression(node *ast.IndexExpression, env *object.Environment) (object.Object, object.Object) {
 	left := Eval(node.Left, env)
 	if isError(left) {
-		return left
+		return left, nil
 	}
 	idx := Eval(node.Index, env)
 	if isError(idx) {
-		return idx
+		return idx, nil
+	}
+
+	result := index(left, idx)
+	if errObj, ok := result.(*object.Error); ok {
+		errObj.Pos = node.Index.Pos()
+	}
+	return result, left
+}
+
+// evalDotExpression yeets the value of the expression, along with the hashmap it comes from.
+func evalDotExpression(node *ast.DotExpression, env *object.Environment) (object.Object, object.Object) {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left, nil
+	}
+
+	result := field(left, node.Name)
+	if errObj, ok := result.(*object.Error); ok {
+		errObj.Pos = node.NamePos
+	}
+	return result, left
+}
+
+// field yeets the value of a hashmap under the string key name, or null if there's no such key.
+func field(obj object.Object, name string) object.Object {
+	hashmap, ok := obj.(*object.Hashmap)
+	if !ok {
+		return newErrorWithoutPos("field access not supported: %s.%s", obj.Type(), name)
+	}
+
+	val, ok := lookupKey(hashmap, &object.String{Value: name})
+	if !ok {
+		return object.NULL
 	}
+	return val
+}
+
+// protoKey is the key under which a hashmap keeps its prototype: a hashmap whose keys are looked
+// up when the hashmap doesn't have them.
+const protoKey = "proto"
+
+// lookupKey finds key in hashmap, or in the chain of its prototypes.
+func lookupKey(hashmap *object.Hashmap, key object.Hashable) (object.Object, bool) {
+	visited := []*object.Hashmap{}
+	for hashmap != nil {
+		// prototypes can go round in circles
+		for _, h := range visited {
+			if h == hashmap {
+				return nil, false
+			}
+		}
+		visited = append(visited, hashmap)
+
+		if val, ok := hashmap.Get(key); ok {
+			return val, true
+		}
 
+		proto, _ := hashmap.Get(&object.String{Value: protoKey})
+		hashmap, _ = proto.(*object.Hashmap)
+	}
+	return nil, false
+}
+
+func setField(obj object.Object, name string, val object.Object) object.Object {
+	hashmap, ok := obj.(*object.Hashmap)
+	if !ok {
+		return newErrorWithoutPos("field access not supported: %s.%s", obj.Type(), name)
+	}
+
+	hashmap.Set(&object.String{Value: name}, val)
+	return val
+}
+
+func index(left, idx object.Object) object.Object {
 	switch left := left.(type) {
 	case *object.Array:
 		switch idx := idx.(type) {
@@ -526,17 +744,90 @@ func evalIndexExpression(node *ast.IndexExpression, env *object.Environment) obj
 	case *object.Hashmap:
 		key, ok := idx.(object.Hashable)
 		if !ok {
-			return newError(node.Index.Pos(), "key not hashable: %s", idx.Type())
+			return newErrorWithoutPos("key not hashable: %s", idx.Type())
 		}
 
-		pair, ok := left.Pairs[key.HashKey()]
+		val, ok := lookupKey(left, key)
 		if !ok {
 			return object.NULL
 		}
-		return pair.Value
+		return val
+	}
+
+	if idx.Type() == object.BIG_INTEGER_OBJ && (left.Type() == object.ARRAY_OBJ || left.Type() == object.STRING_OBJ) {
+		return newErrorWithoutPos("integer too big for an index: %s", idx)
+	}
+	return newErrorWithoutPos("index operator not supported: %s", idx.Type())
+}
+
+// setIndex assigns val to left[idx]. The name of the indexed variable is only used to make error
+// messages more helpful.
+func setIndex(left, idx, val object.Object, name string) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && idx.Type() == object.INTEGER_OBJ:
+		i := idx.(*object.Integer).Value
+		arr := left.(*object.Array)
+		if i < 0 {
+			i += int64(len(arr.Elements))
+		}
+		if i < 0 || i >= int64(len(arr.Elements)) {
+			return newErrorWithoutPos("attempted to assign out of bounds for array '%s'", name)
+		}
+		arr.Elements[i] = val
+		return val
+
+	case left.Type() == object.STRING_OBJ && idx.Type() == object.INTEGER_OBJ:
+		i := idx.(*object.Integer).Value
+		str := left.(*object.String)
+		if i < 0 {
+			i += int64(len(str.Value))
+		}
+		if i < 0 || i >= int64(len(str.Value)) {
+			return newErrorWithoutPos("attempted to assign out of bounds for string '%s'", name)
+		}
+		str.Value = str.Value[:i] + val.String() + str.Value[i+1:]
+		return val
+
+	case left.Type() == object.HASHMAP_OBJ:
+		hashmap := left.(*object.Hashmap)
+		key, ok := idx.(object.Hashable)
+		if !ok {
+			return newErrorWithoutPos("key not hashable: %s", idx.Type())
+		}
+
+		hashmap.Set(key, val)
+		return val
+
+	case idx.Type() == object.BIG_INTEGER_OBJ && (left.Type() == object.ARRAY_OBJ || left.Type() == object.STRING_OBJ):
+		return newErrorWithoutPos("integer too big for an index: %s", idx)
+
+	default:
+		return newErrorWithoutPos("index operator not supported: %s, type of %s", idx.String(), idx.Type())
 	}
+}
 
-	return newError(node.Index.Pos(), "index operator not supported: %s", idx.Type())
+// cannotIterate yeets the error raised by yall when iter can't be iterated over.
+func cannotIterate(pos int, iter object.Object) *object.Error {
+	if iter.Type() == object.BIG_INTEGER_OBJ {
+		return newError(pos, "integer too big to iterate over: %s", iter)
+	}
+	return newError(pos, "cannot iterate over %s, type of %s", iter, iter.Type())
+}
+
+func newRange(start, end object.Object) object.Object {
+	for _, bound := range []object.Object{start, end} {
+		if bound.Type() == object.BIG_INTEGER_OBJ {
+			return newErrorWithoutPos("integer too big for a range: %s", bound)
+		}
+	}
+	if start.Type() != object.INTEGER_OBJ || end.Type() != object.INTEGER_OBJ {
+		return newErrorWithoutPos("only integers can be used to create a range (got %s..%s)", start.Type(), end.Type())
+	}
+
+	return &object.Range{
+		Start: start.(*object.Integer).Value,
+		End:   end.(*object.Integer).Value,
+	}
 }
 
 func adjustIndices(start, end, length int64) (int64, int64) {
@@ -564,8 +855,15 @@ func evalPrefixExpression(op string, right object.Object, yoloOK bool) object.Ob
 		switch {
 		case right.Type() == object.INTEGER_OBJ:
 			rightVal := right.(*object.Integer).Value
+			if rightVal == math.MinInt64 {
+				return object.NewInteger(new(big.Int).Neg(big.NewInt(rightVal)))
+			}
 			return &object.Integer{Value: -rightVal}
 
+		case right.Type() == object.BIG_INTEGER_OBJ:
+			rightVal := right.(*object.BigInteger).Value
+			return object.NewInteger(new(big.Int).Neg(rightVal))
+
 		case right.Type() == object.NUMBER_OBJ:
 			rightVal := right.(*object.Number).Value
 			return &object.Number{Value: -rightVal}
@@ -601,6 +899,9 @@ func evalInfixExpression(op string, left, right object.Object, yoloOK bool) obje
 		case "/":
 			return &object.Number{Value: left.Value / rVal}
 		case "%":
+			if right.Value == 0 {
+				return newErrorWithoutPos("division by zero")
+			}
 			return &object.Number{Value: float64(int64(left.Value) % right.Value)}
 		case "<":
 			return toYeetBool(left.Value < rVal)
@@ -631,6 +932,9 @@ func evalInfixExpression(op string, left, right object.Object, yoloOK bool) obje
 		case "/":
 			return &object.Number{Value: lVal / right.Value}
 		case "%":
+			if int64(right.Value) == 0 {
+				return newErrorWithoutPos("division by zero")
+			}
 			return &object.Number{Value: float64(left.Value % int64(right.Value))}
 		case "<":
 			return toYeetBool(lVal < right.Value)
@@ -646,6 +950,21 @@ func evalInfixExpression(op string, left, right object.Object, yoloOK bool) obje
 			return toYeetBool(lVal != right.Value)
 		}
 
+	// big integers mix with integers and numbers, like integers do
+	case left.Type() == object.BIG_INTEGER_OBJ && right.Type() == object.NUMBER_OBJ:
+		return evalInfixExpression(op, bigToNumber(left.(*object.BigInteger)), right, yoloOK)
+
+	case left.Type() == object.NUMBER_OBJ && right.Type() == object.BIG_INTEGER_OBJ:
+		return evalInfixExpression(op, left, bigToNumber(right.(*object.BigInteger)), yoloOK)
+
+	case isInteger(left) && isInteger(right) && left.Type() != right.Type(),
+		left.Type() == object.BIG_INTEGER_OBJ && right.Type() == object.BIG_INTEGER_OBJ:
+		l, _ := toBigInt(left)
+		r, _ := toBigInt(right)
+		if result := evalBigIntegerInfixExpression(op, l, r); result != nil {
+			return result
+		}
+
 	// mixing of all the other types is allowed only in yolo mode
 	case left.Type() != right.Type():
 		switch op {
@@ -669,32 +988,9 @@ func evalInfixExpression(op string, left, right object.Object, yoloOK bool) obje
 		}
 
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		right := right.(*object.Integer)
-		left := left.(*object.Integer)
-
-		switch op {
-		case "+":
-			return &object.Integer{Value: left.Value + right.Value}
-		case "-":
-			return &object.Integer{Value: left.Value - right.Value}
-		case "*":
-			return &object.Integer{Value: left.Value * right.Value}
-		case "/":
-			return &object.Integer{Value: left.Value / right.Value}
-		case "%":
-			return &object.Integer{Value: left.Value % right.Value}
-		case "<":
-			return toYeetBool(left.Value < right.Value)
-		case ">":
-			return toYeetBool(left.Value > right.Value)
-		case "<=":
-			return toYeetBool(left.Value <= right.Value)
-		case ">=":
-			return toYeetBool(left.Value >= right.Value)
-		case "==":
-			return toYeetBool(left.Value == right.Value)
-		case "!=":
-			return toYeetBool(left.Value != right.Value)
+		result := evalIntegerInfixExpression(op, left.(*object.Integer).Value, right.(*object.Integer).Value)
+		if result != nil {
+			return result
 		}
 
 	case left.Type() == object.NUMBER_OBJ && right.Type() == object.NUMBER_OBJ:
@@ -711,6 +1007,9 @@ func evalInfixExpression(op string, left, right object.Object, yoloOK bool) obje
 		case "/":
 			return &object.Number{Value: left.Value / right.Value}
 		case "%":
+			if int64(right.Value) == 0 {
+				return newErrorWithoutPos("division by zero")
+			}
 			return &object.Number{Value: float64(int64(left.Value) % int64(right.Value))}
 		case "<":
 			return toYeetBool(left.Value < right.Value)
@@ -758,9 +1057,17 @@ func evalInfixExpression(op string, left, right object.Object, yoloOK bool) obje
 		case "+":
 			return &object.Array{Elements: append(left.Elements, right.Elements...)}
 		case "==":
-			return toYeetBool(reflect.DeepEqual(left.Elements, right.Elements))
+			return toYeetBool(object.EqualKeys(left, right))
+		case "!=":
+			return toYeetBool(!object.EqualKeys(left, right))
+		}
+
+	case left.Type() == object.HASHMAP_OBJ && right.Type() == object.HASHMAP_OBJ:
+		switch op {
+		case "==":
+			return toYeetBool(object.EqualKeys(left, right))
 		case "!=":
-			return toYeetBool(!reflect.DeepEqual(left.Elements, right.Elements))
+			return toYeetBool(!object.EqualKeys(left, right))
 		}
 	}
 
@@ -771,6 +1078,115 @@ func evalInfixExpression(op string, left, right object.Object, yoloOK bool) obje
 	return newErrorWithoutPos("unknown operator: %s %s %s", left.Type(), op, right.Type())
 }
 
+func evalYallExpression(node *ast.YallExpression, env *object.Environment) object.Object {
+	iter := Eval(node.Iterable, env)
+	if isError(iter) {
+		return iter
+	}
+
+	var result object.Object = object.NULL // if the body never runs
+	extendedEnv := enterScope(node.Scope, env)
+	// step runs the body for a single element, reporting false if the loop has to stop
+	step := func(index, elt object.Object) bool {
+		if node.IndexName != "" {
+			extendedEnv.Set(node.IndexName, index)
+		}
+		extendedEnv.Set(node.ElemName, elt)
+		var ok bool
+		result, ok = afterIteration(node.Label, Eval(node.Body, extendedEnv))
+		return ok
+	}
+
+	switch iter := iter.(type) {
+	case *object.Array:
+		for i, v := range iter.Elements {
+			if !step(&object.Integer{Value: int64(i)}, v) {
+				break
+			}
+		}
+
+	case *object.String:
+		i := int64(0)
+		for _, v := range iter.Value {
+			if !step(&object.Integer{Value: i}, &object.String{Value: string(v)}) {
+				break
+			}
+			i++
+		}
+
+	case *object.Hashmap:
+		for _, pair := range iter.Pairs() {
+			elt := pair.Value
+			if node.IndexName == "" {
+				elt = pair.Key
+			}
+			if !step(pair.Key, elt) {
+				break
+			}
+		}
+
+	case *object.Range:
+		incr := int64(1)
+		if iter.Start > iter.End {
+			incr = -1
+		}
+
+		for i, index := iter.Start, int64(0); i != iter.End+incr; i, index = i+incr, index+1 {
+			if !step(&object.Integer{Value: index}, &object.Integer{Value: i}) {
+				break
+			}
+		}
+
+	case *object.Integer:
+		start := int64(0)
+		end := iter.Value
+
+		if iter.Value < 0 {
+			start = iter.Value
+			end = 0
+		}
+
+		for i := start; i <= end; i++ {
+			if !step(&object.Integer{Value: i - start}, &object.Integer{Value: i}) {
+				break
+			}
+		}
+
+	default:
+		return cannotIterate(node.Iterable.Pos(), iter)
+	}
+
+	return result
+}
+
+// guardedInfix applies op like evalInfixExpression, unless it would build a string or array longer
+// than guard allows. Strings repeated in yolo mode are checked before they're built, as they can
+// get huge in one go.
+func guardedInfix(op string, left, right object.Object, yoloOK bool, guard *object.Guard) object.Object {
+	if yoloOK {
+		if err := guard.CheckLength(repeatedLength(op, left, right)); err != nil {
+			return err
+		}
+	}
+
+	return checkLength(evalInfixExpression(op, left, right, yoloOK), guard)
+}
+
+// checkLength yeets an error if obj is a string or array longer than guard allows, obj otherwise.
+func checkLength(obj object.Object, guard *object.Guard) object.Object {
+	switch o := obj.(type) {
+	case *object.String:
+		if err := guard.CheckLength(len(o.Value)); err != nil {
+			return err
+		}
+	case *object.Array:
+		if err := guard.CheckLength(len(o.Elements)); err != nil {
+			return err
+		}
+	}
+	return obj
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj := obj.(type) {
 	case *object.Null:
@@ -782,7 +1198,7 @@ func isTruthy(obj object.Object) bool {
 	case *object.Array:
 		return len(obj.Elements) > 0
 	case *object.Hashmap:
-		return len(obj.Pairs) > 0
+		return obj.Len() > 0
 	default:
 		return true
 	}
@@ -799,10 +1215,69 @@ func isError(obj object.Object) bool {
 	return obj != nil && obj.Type() == object.ERROR_OBJ
 }
 
+// errorToHashmap turns a caught error into a value that can be inspected in ycatch block. The source
+// code of the file the error was raised in is looked up in module, or read from disk, to turn the
+// position of the error into a line and a column. Both are null if it's unknown.
+func errorToHashmap(err *object.Error, module *object.Module) *object.Hashmap {
+	payload := err.Payload
+	if payload == nil {
+		payload = object.NULL
+	}
+
+	var src []byte
+	if module != nil {
+		src = module.Source(err.File)
+	}
+	if src == nil && err.File != "" {
+		src, _ = os.ReadFile(err.File)
+	}
+
+	var line, col, file object.Object = object.NULL, object.NULL, object.NULL
+	if err.Pos >= 0 && err.Pos <= len(src) {
+		ln, c := yikes.Position(src, err.Pos)
+		line, col = &object.Integer{Value: int64(ln)}, &object.Integer{Value: int64(c)}
+	}
+	if err.File != "" {
+		file = &object.String{Value: err.File}
+	}
+
+	hashmap := object.NewHashmap(5)
+	hashmap.Set(&object.String{Value: "msg"}, &object.String{Value: err.Msg})
+	hashmap.Set(&object.String{Value: "line"}, line)
+	hashmap.Set(&object.String{Value: "col"}, col)
+	hashmap.Set(&object.String{Value: "file"}, file)
+	hashmap.Set(&object.String{Value: "payload"}, payload)
+	return hashmap
+}
+
+// recoverPanic turns a panic into an error at pos, so bad code can't take down the whole
+// interpreter. The error is fatal, as the interpreter may be left in a bad state.
+func recoverPanic(pos int, result *object.Object) {
+	if r := recover(); r != nil {
+		*result = &object.Error{Msg: fmt.Sprintf("internal error: %v", r), Pos: pos, Fatal: true}
+	}
+}
+
 func isErrorOrReturn(obj object.Object) bool {
 	return obj != nil && (obj.Type() == object.ERROR_OBJ || obj.Type() == object.RETURN_VALUE_OBJ)
 }
 
+// afterIteration works out what a loop with label does after its body yeeted result. It reports
+// false if the loop is done, yeeting what the loop (or the code it's in) has to yeet.
+func afterIteration(label string, result object.Object) (object.Object, bool) {
+	if brk, ok := result.(*object.Break); ok {
+		switch {
+		case brk.Label != "" && brk.Label != label:
+			return brk, false // for an outer loop
+		case brk.Continue:
+			return object.NULL, true
+		default:
+			return brk.Value, false
+		}
+	}
+	return result, !isErrorOrReturn(result)
+}
+
 func newErrorWithoutPos(format string, args ...any) *object.Error {
 	return newError(-1, format, args...)
 }
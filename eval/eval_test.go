@@ -1,15 +1,21 @@
 package eval_test
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
+	"yy/compiler"
 	"yy/eval"
 	"yy/lexer"
 	"yy/object"
 	"yy/parser"
+	"yy/vm"
+	"yy/yikes"
 )
 
 type evalTestCase struct {
@@ -38,6 +44,60 @@ func TestEvalIntegerExpression(t *testing.T) {
 	})
 }
 
+func TestBigIntegers(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{"max := 9223372036854775807; max + 1", bigint("9223372036854775808")},
+		{"max := 9223372036854775807; max + 1 - 1", 9223372036854775807},
+		{"max := 9223372036854775807; max + max", bigint("18446744073709551614")},
+		{"-9223372036854775807 - 2", bigint("-9223372036854775809")},
+		{"4611686018427387904 * 2", bigint("9223372036854775808")},
+		{"4611686018427387904 * -2", -9223372036854775807 - 1},
+		{"min := -9223372036854775807 - 1; -min", bigint("9223372036854775808")},
+		{"min := -9223372036854775807 - 1; min / -1", bigint("9223372036854775808")},
+		{"min := -9223372036854775807 - 1; min * -1", bigint("9223372036854775808")},
+		{"big := 9223372036854775807 * 10; big / 10", 9223372036854775807},
+		{"big := 9223372036854775807 * 10; big % 7", 0},
+		{"big := 9223372036854775807 * 10; -big / 3", bigint("-30744573456182586023")},
+		{"big := 9223372036854775807 * 10; big / 0", errmsg{"division by zero"}},
+		{"f := \\n { yif n == 0 { 1 } yels { n * f(n - 1) } }; f(25)", bigint("15511210043330985984000000")},
+
+		// literals
+		{"9223372036854775808", bigint("9223372036854775808")},
+		{"-9223372036854775808", -9223372036854775807 - 1},
+		{"15511210043330985984000000 / 25", bigint("620448401733239439360000")},
+		{"f := \\n { yif n == 0 { 1 } yels { n * f(n - 1) } }; yassert_eq(f(25), 15511210043330985984000000)", nil},
+		{`ymatch 9223372036854775807 + 1 { 9223372036854775808 => "big", _ => "small" }`, "big"},
+
+		// comparisons
+		{"big := 9223372036854775807 + 1; big > 1", true},
+		{"big := 9223372036854775807 + 1; 1 >= big", false},
+		{"big := 9223372036854775807 + 1; big == 9223372036854775807 + 1", true},
+		{"big := 9223372036854775807 + 1; big != big + 1", true},
+		{"big := 9223372036854775807 + 1; big < 1.5", false},
+		{"big := 9223372036854775807 + 1; big == 9223372036854775807", false},
+		{"big := 9223372036854775807 + 1; big + 0.5", 9223372036854775808.0},
+		{"big := 9223372036854775807 + 1; big + true", errmsg{"type mismatch: INTEGER + BOOLEAN"}},
+
+		// builtins & hashmaps
+		{"big := 9223372036854775807 + 1; yarn(big)", "9223372036854775808"},
+		{"big := 9223372036854775807 + 1; float(big)", 9223372036854775808.0},
+		{`int("100000000000000000000")`, bigint("100000000000000000000")},
+		{`int(float("1e20"))`, bigint("100000000000000000000")},
+		{`int(float("1e18"))`, 1000000000000000000},
+		{"big := 9223372036854775807 + 1; %{big: 1}[9223372036854775807 + 1]", 1},
+		{"big := 9223372036854775807 + 1; sorted := sort_by([big, 2, -big, 1.5], \\x { x }); sorted[0] == -big && sorted[3] == big", true},
+		{`big := 9223372036854775807 + 1; "{big}!"`, "9223372036854775808!"},
+
+		// integers too big for int64 can't be used where int64 is needed
+		{"big := 9223372036854775807 + 1; [1, 2][big]", errmsg{"integer too big for an index: 9223372036854775808"}},
+		{`big := 9223372036854775807 + 1; "ab"[-big - 1]`, errmsg{"integer too big for an index: -9223372036854775809"}},
+		{"big := 9223372036854775807 + 1; a := [1]; a[big] = 2", errmsg{"integer too big for an index: 9223372036854775808"}},
+		{"big := 9223372036854775807 + 1; 1..big", errmsg{"integer too big for a range: 9223372036854775808"}},
+		{"big := 9223372036854775807 + 1; -big - 1..1", errmsg{"integer too big for a range: -9223372036854775809"}},
+		{"big := 9223372036854775807 + 1; yall big { }", errmsg{"integer too big to iterate over: 9223372036854775808"}},
+	})
+}
+
 func TestEvalFloatExpression(t *testing.T) {
 	runEvalTests(t, []evalTestCase{
 		{"5.0", 5.0},
@@ -235,6 +295,20 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{`"1" != 1`, true},
 		{`"1" == [1]`, false},
 		{`"1" != [1]`, true},
+
+		// collections are compared by value, hashmaps whatever order their keys were set in
+		{`[1, [2, "a"]] == [1, [2, "a"]]`, true},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, 2] != [1, 2, 3]`, true},
+		{`[%{"a": 1, "b": 2}] == [%{"b": 2, "a": 1}]`, true},
+		{`[%{"a": 1, "b": 2}] != [%{"b": 2, "a": 1}]`, false},
+		{`[%{"a": 1}] == [%{"a": 2}]`, false},
+		{`%{"a": 1} == %{"a": 1}`, true},
+		{`%{"a": 1, "b": [2]} == %{"b": [2], "a": 1}`, true},
+		{`%{"a": 1, "b": 2} != %{"b": 2, "a": 1}`, false},
+		{`%{"a": 1} == %{"a": 1, "b": 2}`, false},
+		{`%{"a": 1} != %{"a": 2}`, true},
+		{`%{} == %{}`, true},
 	})
 }
 
@@ -342,28 +416,38 @@ two := "two";
 	"thr" + "ee": 6 / 2,
 	4: 4,
 }`
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
+	// in the order they were written in
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
 	}
 
-	evaluated := testEval(t, input)
-	result, ok := evaluated.(*object.Hashmap)
-	if !ok {
-		t.Fatalf("Eval didn't return Hash. got %T (%+v)", evaluated, evaluated)
-	}
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got %d", len(result.Pairs))
-	}
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, b := range backends {
+		evaluated := b.eval(t, input)
+		result, ok := evaluated.(*object.Hashmap)
 		if !ok {
-			t.Errorf("no pair for given key %q in Pairs", expectedKey)
+			t.Fatalf("[%s] Eval didn't return Hash. got %T (%+v)", b.name, evaluated, evaluated)
 		}
-		if err := testIntegerObject(pair.Value, expectedValue); err != nil {
-			t.Error(err)
+		if result.Len() != len(expected) {
+			t.Fatalf("[%s] Hash has wrong num of pairs. got %d", b.name, result.Len())
+		}
+		for i, pair := range result.Pairs() {
+			if pair.Key.(object.Hashable).HashKey() != expected[i].key.HashKey() {
+				t.Errorf("[%s] wrong key at %d. want %s, got %s", b.name, i, expected[i].key, pair.Key)
+			}
+			val, ok := result.Get(expected[i].key)
+			if !ok {
+				t.Errorf("[%s] no pair for given key %s", b.name, expected[i].key)
+				continue
+			}
+			if err := testIntegerObject(val, expected[i].value); err != nil {
+				t.Errorf("[%s] %s", b.name, err)
+			}
 		}
 	}
 }
@@ -458,6 +542,221 @@ func TestHashIndexExpressions(t *testing.T) {
 	})
 }
 
+func TestDotExpressions(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`%{"foo": 5}.foo`, 5},
+		{`%{"foo": 5}.bar`, nil},
+		{`h := %{"a": %{"b": %{"c": 3}}}; h.a.b.c`, 3},
+		{`h := %{"a": 1}; h.a = 2; h.b = 3; [h.a, h.b]`, []int64{2, 3}},
+		{`h := %{"a": %{"n": 1}}; h.a.n += 5; h.a.n`, 6},
+		{`h := %{}; h.x = h.y = 1; h.x + h.y`, 2},
+		{`h := %{"add": \a b { a + b }}; h.add(1, 2)`, 3},
+		{`h := %{"make": \{ %{"v": 7} }}; h.make().v`, 7},
+		{`h := %{"a": [1, 2]}; h.a[1]`, 2},
+		{`arr := [%{"x": 4}]; arr[0].x`, 4},
+		{`h := %{"n": 2}; map(h.n..4, \x { x })`, []int64{2, 3, 4}},
+		{`h := %{"f": 1.5}; h.f * 2`, 3.0},
+		{`h := %{"name": "Yakub"}; "hi {h.name}"`, "hi Yakub"},
+
+		{`5.foo`, errmsg{"field access not supported: INTEGER.foo"}},
+		{`h := %{}; h.a.b`, errmsg{"field access not supported: NULL.b"}},
+		{`s := "abc"; s.len = 3`, errmsg{"field access not supported: STRING.len"}},
+	})
+}
+
+func TestDestructuring(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`[a, b] := [1, 2]; a * 10 + b`, 12},
+		{`[a, b] := [1, 2]`, []int64{1, 2}},
+		{`[head, ...tail] := [1, 2, 3]; tail`, []int64{2, 3}},
+		{`[head, ...tail] := [1]; len(tail)`, 0},
+		{`[...all] := [1, 2]; all`, []int64{1, 2}},
+		{`%{"name": n, "age": a} := %{"name": "Yeti", "age": 300}; "{n} {a}"`, "Yeti 300"},
+		{`[a, b = 5, c = a] := [1]; [a, b, c]`, []int64{1, 5, 1}},
+		{`[a, b = 5] := [1, null]; b`, 5},
+		{`%{"a": a = 7} := %{}; a`, 7},
+		{`%{"a": a = 7} := %{"a": 3}; a`, 3},
+		{`%{"a": a} := %{"a": null}; a`, nil},
+		{`[[a, b], %{"c": [c, ...d]}] := [[1, 2], %{"c": [3, 4]}]; [a, b, c] + d`, []int64{1, 2, 3, 4}},
+		{`[a, [b, c] = [8, 9]] := [1]; [a, b, c]`, []int64{1, 8, 9}},
+		{`proto := %{"x": 1}; %{"x": x} := %{"proto": proto}; x`, 1},
+		{`k := "key"; %{k: v} := %{"key": 4}; v`, 4},
+
+		// assigning
+		{`a := 1; b := 2; [a, b] = [b, a]; [a, b]`, []int64{2, 1}},
+		{`h := %{"p": [0, 0]}; [h.p[0], h.q] = [5, 6]; [h.p[0], h.q]`, []int64{5, 6}},
+		{`f := \{ a := 0; r := 0; [a, ...r] = [1, 2]; a + len(r) }; f()`, 2},
+		{`[x] = [1]`, errmsg{"identifier not found: x (to declare a variable use := operator)"}},
+
+		// scopes
+		{`f := \pair { [a, b] := pair; a - b }; f([5, 3])`, 2},
+		{`f := \{ [a, b] := [1, 2]; \{ a + b } }; f()()`, 3},
+		{`[a] := [1]; { [a] := [2] }; a`, 1},
+		{`s := 0; yall p: [[1, 2], [3, 4]] { [a, b] := p; s += a * b }; s`, 14},
+
+		{`[a, b] := [1]`, errmsg{"not enough elements to destructure (got 1, want 2)"}},
+		{`[a, b = 2, ...c] := []`, errmsg{"not enough elements to destructure (got 0, want at least 1)"}},
+		{`[a] := [1, 2]`, errmsg{"too many elements to destructure (got 2, want 1)"}},
+		{`[a, b = 1] := [1, 2, 3]`, errmsg{"too many elements to destructure (got 3, want at most 2)"}},
+		{`[a, ...b] := "ab"`, errmsg{"cannot destructure STRING as an array"}},
+		{`%{"a": a} := [1]`, errmsg{"cannot destructure ARRAY as a hashmap"}},
+		{`%{"a": a} := %{"b": 1}`, errmsg{"key not found when destructuring: a"}},
+		{`[a, [b]] := [1, 2]`, errmsg{"cannot destructure INTEGER as an array"}},
+	})
+}
+
+func TestYmatch(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`ymatch 2 { 1 => "one", 2 => "two", _ => "many" }`, "two"},
+		{`ymatch 7 { 1 => "one", 2 => "two", _ => "many" }`, "many"},
+		{`ymatch "yo" { "hi" => 1, "yo" => 2 }`, 2},
+		{`ymatch null { false => 1, null => 2 }`, 2},
+		{`ymatch -3 { -3 => true, _ => false }`, true},
+		{`ymatch 2.0 { 2 => "int", 2.0 => "num" }`, "int"},
+		{`ymatch 5 { 1..4 => "low", 5..9 => "high" }`, "high"},
+		{`ymatch 4.5 { 1..4 => "low", 5..9 => "high", _ => "between" }`, "between"},
+		{`ymatch "5" { 1..9 => "digit", _ => "other" }`, "other"},
+		{`ymatch 1.5 { 1..9 => "digit", _ => "other" }`, "other"},
+		{`ymatch 9223372036854775807 + 1 { 0..9223372036854775807 => "int64", -1..9223372036854775808 => "big", _ => "other" }`, "big"},
+		{`ymatch 5 { n => n * 2 }`, 10},
+
+		// types
+		{`ymatch 3 { string(s) => s, integer(n) => n + 1 }`, 4},
+		{`ymatch 3.5 { integer(_) => "int", number(_) => "num" }`, "num"},
+		{`ymatch 9223372036854775807 * 2 { integer(_) => "int", _ => "other" }`, "int"},
+		{`ymatch len { function(f) => f("abc") }`, 3},
+		{`ymatch \x { x } { function(f) => f(4) }`, 4},
+		{`ymatch [1, 2] { hashmap(_) => 1, array([a, b]) => a + b }`, 3},
+		{`ymatch 1..3 { array(_) => "array", range(_) => "range" }`, "range"},
+		{`ymatch true { boolean(b) => !b }`, false},
+
+		// structures
+		{`ymatch [1, 2, 3] { [] => 0, [a] => a, [a, b] => a + b, [a, ...rest] => rest }`, []int64{2, 3}},
+		{`ymatch [1, 2] { [a, b, c] => 3, [a, b] => 2 }`, 2},
+		{`ymatch [] { [a, b = 2] => 1, [] => 0 }`, 0},
+		{`ymatch [1] { [a, b = 2] => a + b }`, 3},
+		{`ymatch [0, 5] { [1, x] => x, [0, x] => -x }`, -5},
+		{`ymatch [1, [2, 3]] { [a, [b, c]] => a + b + c }`, 6},
+		{`ymatch [1, 2, 3] { [_, _, x] => x }`, 3},
+		{`ymatch %{"type": "circle", "r": 2} { %{"type": "square", "a": a} => a * a, %{"type": "circle", "r": r} => 3 * r * r }`, 12},
+		{`ymatch %{"a": 1} { %{"b": b} => b, %{"a": a, "b": b = 5} => a + b }`, 6},
+		{`ymatch %{"n": "x"} { %{"n": integer(n)} => n, %{"n": string(n)} => n }`, "x"},
+		{`ymatch %{"proto": %{"x": 1}} { %{"x": x} => x }`, 1},
+
+		// guards
+		{`ymatch 5 { n yif n % 2 == 0 => "even", n => "odd" }`, "odd"},
+		{`ymatch [3, 4] { [a, b] yif a > b => a, [a, b] => b }`, 4},
+		{`ymatch 1 { 0..9 yif false => 1, _ => 2 }`, 2},
+
+		// scopes
+		{`n := 1; ymatch 5 { n => n }; n`, 1},
+		{`x := 2; ymatch 5 { n => n * x }`, 10},
+		{`f := \v { ymatch v { [a, b] => \{ a + b }, _ => \{ 0 } } }; f([1, 2])()`, 3},
+		{`fs := []; yall [1, 2] { ymatch yt { n => fs << \{ n } } }; fs[0]() + fs[1]()`, 3},
+		{`f := \v { ymatch v { 0 => "zero", n yif n > 0 => yeet "pos", _ => "neg" }; "unreachable?" }; f(1)`, "pos"},
+		{`s := 0; yall [[1, 2], 3, [4, 5]] { s += ymatch yt { [a, b] => a * b, _ => 0 } }; s`, 22},
+		{`ymatch 1 { 1 => ymatch 2 { 2 => "nested" } }`, "nested"},
+		{`fs := []; ymatch 1 { n yif len(fs << \{ n }) > 1 => 0, n => fs[0]() + n }`, 2},
+		{`i := 0; yoyo { i += 1; ymatch i { 3 => ybreak, _ => ycontinue } }; i`, 3},
+
+		{`ymatch 5 { 1 => "one" }`, errmsg{"no pattern matches 5"}},
+		{`ymatch [1] { [a, b] => 1 }`, errmsg{"no pattern matches [1]"}},
+		{`ymatch 5 { n yif nope => 1 }`, errmsg{"identifier not found: nope"}},
+	})
+}
+
+func TestMethods(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`h := %{"n": 2, "get": \{ self.n }}; h.get()`, 2},
+		{`h := %{"n": 2, "get": \{ self["n"] }}; h["get"]()`, 2},
+		{`h := %{"n": 0, "inc": \by { self.n += by; self }}; h.inc(2).inc(3); h.n`, 5},
+		{`get := \{ self.n }; a := %{"n": 1, "get": get}; b := %{"n": 2, "get": get}; [a.get(), b.get()]`, []int64{1, 2}},
+		{`h := %{"k": 10, "f": \{ map([1, 2], \x { x * self.k }) }}; h.f()`, []int64{10, 20}},
+		{`h := %{"n": 1, "f": \{ g := \{ self.n }; g() }}; h.f()`, 1},
+		{`h := %{"f": \self { self }}; h.f(5)`, 5},
+		{`h := %{"f": \{ self := 3; self }}; h.f()`, 3},
+		{`make := \n { self := %{"n": n}; self.get = \{ self.n }; self }; make(4).get()`, 4},
+		{`inner := %{"v": 1, "f": \{ self.v }}; outer := %{"v": 2, "inner": inner}; outer.inner.f()`, 1},
+		{`fns := [\{ self }]; h := %{"f": \{ fns[0]() }}; ytry { h.f() } ycatch e { e.msg }`, "identifier not found: self"},
+
+		// self is only set by calls of functions taken from hashmaps
+		{`h := %{"get": \{ self }}; f := h.get; f()`, errmsg{"identifier not found: self"}},
+		{`f := \{ self }; f()`, errmsg{"identifier not found: self"}},
+		{`self := 1; f := \{ self }; f()`, 1},
+		{`f := \{ self }; self := 1; f()`, 1},
+		{`self := 1; h := %{"n": 7, "f": \{ self.n }}; h.f()`, 7},
+
+		// the receiver hides self of the code a method was declared in
+		{`self := "global self"; g := \{ self }; H := %{"g": g}; g()`, "global self"},
+		{`self := "global self"; g := \{ self }; H := %{"g": g}; H.g() == H`, true},
+		{`h := %{"n": 1, "f": \{ \{ self.n } }}; other := %{"n": 2, "g": h.f()}; [h.f()(), other.g()]`, []int64{1, 2}},
+
+		// errors name the method called
+		{`o := %{"m": \x { x }}; o.m()`, errmsg{"wrong number of args for o.m (got 0, want 1)"}},
+		{`o := %{"in": %{"m": \x { x }}}; o.in.m(y: 1)`, errmsg{"no parameter called y for o.in.m"}},
+		{`make := \ { %{"m": \x { x }} }; make().m(1, 2)`, errmsg{"wrong number of args for m (got 2, want 1)"}},
+	})
+}
+
+func TestPrototypes(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`base := %{"a": 1}; h := %{"proto": base}; h.a`, 1},
+		{`base := %{"a": 1}; h := %{"proto": base}; h["a"]`, 1},
+		{`base := %{"a": 1}; h := %{"proto": base, "a": 2}; h.a`, 2},
+		{`base := %{"a": 1}; h := %{"proto": base}; h.a = 2; [h.a, base.a]`, []int64{2, 1}},
+		{`base := %{"a": 1}; h := %{"proto": base}; base.a = 3; h.a`, 3},
+		{`base := %{"a": 1}; h := %{"proto": base}; h.b`, nil},
+		{`a := %{"x": 1}; b := %{"proto": a}; c := %{"proto": b}; c.x`, 1},
+		{`h := %{"proto": 5}; h.x`, nil},
+		{`a := %{}; b := %{"proto": a}; a.proto = b; a.x == null && b.x == null`, true},
+		{`base := %{"a": 1, "b": 2}; h := %{"proto": base}; len(h)`, 1},
+
+		// methods of prototypes are called with the hashmap they were found through
+		{
+			`Animal := %{"speak": \{ "{self.name} says {self.sound}" }}
+			dog := %{"proto": Animal, "name": "Rex", "sound": "woof"}
+			dog.speak()`,
+			"Rex says woof",
+		},
+		{
+			`Counter := %{"inc": \{ self.n += 1 }}
+			c := %{"proto": Counter, "n": 0}
+			c.inc(); c.inc()
+			c.n * 10 + len(Counter)`,
+			21,
+		},
+	})
+}
+
+func TestHashmapOrder(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`h := %{"b": 1, "a": 2, 3: 3}; "{h}"`, "{b: 1, a: 2, 3: 3}"},
+		{`h := %{"b": 1, "a": 2}; h["a"] = 5; h["c"] = 6; "{h}"`, "{b: 1, a: 5, c: 6}"},
+		{`h := %{"b": 1, "a": 2, "b": 3}; "{h}"`, "{b: 3, a: 2}"},
+		{`h := map(%{"z": 1, "y": 2, "x": 3}, \k v { v * 2 }); "{h}"`, "{z: 2, y: 4, x: 6}"},
+		{`h := filter(%{"z": 1, "y": 2, "x": 3}, \k v { v != 2 }); "{h}"`, "{z: 1, x: 3}"},
+		{`order := []; %{"a": order << 1, "b": order << 2}; order`, []int64{1, 2}},
+	})
+}
+
+func TestHashKeys(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`h := %{1.5: "a", 1.7: "b"}; h[1.5] + h[1.7]`, "ab"},
+		{`%{0.0: "zero"}[-0.0]`, "zero"},
+		{`%{1: "int", 1.0: "float"}[1]`, "int"},
+		{`%{[1, 2]: "a"}[[1, 2]]`, "a"},
+		{`%{[1, 2]: "a"}[[2, 1]]`, nil},
+		{`%{%{"a": 1, "b": 2}: "a"}[%{"b": 2, "a": 1}]`, "a"},
+		{`%{%{"a": 1}: "a"}[%{"a": 2}]`, nil},
+		{`%{1..3: "a"}[1..3]`, "a"},
+
+		// keys with the same hash
+		{`h := %{[0, 31]: "a", [1, 0]: "b"}; h[[0, 31]] + h[[1, 0]]`, "ab"},
+		{`h := %{0..31: "a", 1..0: "b"}; h[1..0] = "c"; h[0..31] + h[1..0] + "{len(h)}"`, "ac2"},
+		{`h := %{0..31: "a", 1..0: "b"}; "{h}"`, "{0..31: a, 1..0: b}"},
+	})
+}
+
 func TestYifYelsExpressions(t *testing.T) {
 	runEvalTests(t, []evalTestCase{
 		{"yif true { 10 }", 10},
@@ -525,12 +824,119 @@ func TestYallExpressions(t *testing.T) {
 		{`yall [1, 2, 3] { yif yt == 1 { yeet 69 }; -1 }`, 69},
 		{`yall "testme" { yif yt == "t" { yeet 69 }; -1 }`, 69},
 
+		// hashmaps
+		{`keys := []; yall %{"b": 1, "a": 2} { keys << yt }; keys`, []string{"b", "a"}},
+		{`vals := []; yall k: %{"b": 1, "a": 2} { vals << k }; vals`, []string{"b", "a"}},
+		{`s := ""; yall k, v: %{"b": 1, "a": 2} { s += "{k}={v};" }; s`, "b=1;a=2;"},
+		{`h := %{"a": 1, "b": 2}; yall k, v: h { h[k] = v * 10 }; h["a"] + h["b"]`, 30},
+		{`yall k, v: %{"a": 1, "b": 2} { yif k == "a" { yeet v }; -1 }`, 1},
+		{`yall %{} { yt }`, nil},
+
+		// index & element
+		{`s := ""; yall i, elt: ["a", "b", "c"] { s += "{i}{elt}" }; s`, "0a1b2c"},
+		{`s := ""; yall i, c: "yół" { s += "{i}{c}" }; s`, "0y1ó2ł"},
+		{`arr := []; yall i, n: 5..3 { arr << i * 10 + n }; arr`, []int64{5, 14, 23}},
+		{`arr := []; yall i, n: -2 { arr << i * 10 + n }; arr`, []int64{-2, 9, 20}},
+		{`arr := []; yall i, n: 2 { arr << i * 10 + n }; arr`, []int64{0, 11, 22}},
+
 		// scope leaking
 		{`yall 0..5 { x }`, errmsg{"identifier not found: x"}},
 		{`yall i: 0..5 { yt }`, errmsg{"identifier not found: yt"}},
+		{`yall i, elt: 0..5 { yt }`, errmsg{"identifier not found: yt"}},
+		{`yall i, elt: 0..5 { 1 }; i`, errmsg{"identifier not found: i"}},
 	})
 }
 
+func TestYbreakYcontinue(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`yall 1..10 { yif yt == 3 { ybreak }; yt }`, nil},
+		{`yall 1..10 { yif yt == 3 { ybreak yt * 10 }; yt }`, 30},
+		{`arr := []; yall 1..5 { yif yt % 2 == 0 { ycontinue }; arr << yt }; arr`, []int64{1, 3, 5}},
+		{`yall 1..4 { yif yt == 4 { ycontinue }; yt }`, nil},
+		{`i := 0; yoyo { i += 1; yif i == 5 { ybreak i } }`, 5},
+		{`i := 0; yoyo i < 10 { i += 1; yif i > 3 { ybreak } }; i`, 4},
+		{`i := 0; arr := []; yoyo i < 5 { i += 1; yif i == 2 { ycontinue }; arr << i }; arr`, []int64{1, 3, 4, 5}},
+		{`x := yall [1, 2, 3] { ybreak "done" }; x`, "done"},
+
+		// nested blocks
+		{`yall 1..10 { ytry { yif yt == 2 { ybreak yt } } ycatch { 0 } }`, 2},
+		{`yall 1..3 { ytry { ybreak 1 } ycatch { 0 } }; ytry { yeet 5 } ycatch { 0 }`, 5},
+		{`yall 1..10 { { { yif yt > 1 { ybreak yt } } } }`, 2},
+
+		// labels
+		{
+			`pairs := []
+			@outer yall i: 1..3 {
+				yall j: 1..3 {
+					yif j == 2 { ycontinue @outer }
+					pairs << i * 10 + j
+				}
+			}
+			pairs`,
+			[]int64{11, 21, 31},
+		},
+		{`@outer yall i: 1..3 { yall j: 1..3 { yif i * j == 4 { ybreak @outer [i, j] } } }`, []int64{2, 2}},
+		{`@outer yoyo { yall 1..3 { ybreak @outer yt } }`, 1},
+		{`@l yall [1] { @l yall [2] { ybreak @l yt } }`, 2},
+
+		// closures keep what they captured in the iteration they were created in
+		{
+			`fns := []
+			yall i: 1..3 { x := i; fns << \{ x }; yif i == 2 { ycontinue } }
+			fns[0]() + fns[1]() * 10 + fns[2]() * 100`,
+			321,
+		},
+
+		// yeet still leaves the whole function
+		{`f := \{ yall 1..3 { yeet 42 }; 0 }; f()`, 42},
+		{`f := \{ yall 1..3 { ybreak 42 }; 0 }; f()`, 0},
+	})
+}
+
+func TestPipes(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`5 |> \x { x + 1 }`, 6},
+		{`f := \a b { a - b }; 10 |> f(3)`, 7},
+		{`[3, 1, 2] |> sort_by(\x { x }) |> map(\x { x * 2 })`, []int64{2, 4, 6}},
+		{`"abc" |> len |> \n { n * n }`, 9},
+		{`x := 1 + 2 |> \n { n * 10 }; x`, 30},
+		{`add := \a { \b { a + b } }; 1 |> add(2)()`, 3},
+		{`f := \a b { a - b }; 10 |> f`, errmsg{"wrong number of args for f (got 1, want 2)"}},
+		{`1 |> nope`, errmsg{"identifier not found: nope"}},
+	})
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos int
+	}{
+		{`f := \x { x }; 1 |> f |> f(2) |> f`, 26},
+		{`f := \x { x }; 1 |> f |> nope |> f`, 25},
+		{`f := \x { x }; 1 |> f |> f |> len`, 30},
+		{`h := %{"a": 1}; h.a.b`, 20},
+		{`h := %{"a": 1}; h.a.b = 2`, 20},
+		{`[a, [b, c]] := [1, [2]]`, 4},
+		{`%{"a": a, "b": b} := %{"a": 1}`, 11},
+		{`x := 1; [a, b] := x`, 8},
+		{`x := 1; ymatch x { 2 => 3 }`, 8},
+		{`f := \a { a }; f(b: 1)`, 16},
+	}
+
+	for _, b := range backends {
+		for _, tt := range tests {
+			errObj, ok := b.eval(t, tt.input).(*object.Error)
+			if !ok {
+				t.Errorf("[%s] no error object returned (%s)", b.name, tt.input)
+				continue
+			}
+			if errObj.Pos != tt.expectedPos {
+				t.Errorf("[%s] wrong error position. want %d, got %d (%s)", b.name, tt.expectedPos, errObj.Pos, tt.input)
+			}
+		}
+	}
+}
+
 func TestYeetStatements(t *testing.T) {
 	runEvalTests(t, []evalTestCase{
 		{"yeet 10;", 10},
@@ -550,6 +956,43 @@ yif 10 > 1 {
 	})
 }
 
+func TestYtryExpressions(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`ytry { 1 } ycatch { 2 }`, 1},
+		{`ytry { yikes("boom") } ycatch { err["msg"] }`, "boom"},
+		{`ytry { int("abc") } ycatch e { e["msg"] }`, "could not parse abc as integer"},
+		{`ytry { 1 + true } ycatch e { [e["line"], e["col"]] }`, []int64{1, 10}},
+		{"x := 1\nytry {\n\tx + true\n} ycatch e { [e[\"line\"], e[\"col\"]] }", []int64{3, 4}},
+		{`ytry { 1 + true } ycatch e { e["file"] }`, nil},
+		{`ytry { yikes(42) } ycatch e { e["payload"] }`, 42},
+		{`ytry { yikes(4, 2) } ycatch e { e["payload"] }`, []int64{4, 2}},
+		{`ytry { 1 / "a" } ycatch e { e["payload"] }`, nil},
+		{`ytry { yikes("a") } ycatch { yikes("b") }`, errmsg{"b"}},
+		{`ytry { ytry { yikes("in") } ycatch e { yikes(e["msg"] + "!") } } ycatch e { e["msg"] }`, "in!"},
+		{`ytry { ytry { 5 } ycatch { 6 } } ycatch { 7 }`, 5},
+		{`ytry { yikes() } ycatch e { e["msg"] }`, "yikes!"},
+
+		// errors raised deep inside helpers
+		{`f := \n { yif n == 0 { yikes("bottom") }; f(n - 1) }; ytry { f(10) } ycatch { err["msg"] }`, "bottom"},
+		{`f := \{ yikes("deep") }; g := \{ f() + 1 }; ytry { g() } ycatch { err["msg"] }`, "deep"},
+		{`f := \{ ytry { yikes("x") } ycatch { 1 } }; f() + f()`, 2},
+
+		// code after the error isn't executed, code after ytry is
+		{`a := 0; ytry { a = 1; yikes(); a = 2 } ycatch { a = a + 10 }; a`, 11},
+		{`s := 0; yall i: 1..3 { s = s + ytry { yif i == 2 { yikes() }; i } ycatch { 100 } }; s`, 104},
+
+		// yeet isn't an error, it leaves ytry block without running ycatch block
+		{`f := \{ ytry { yeet 1 } ycatch { 2 }; 3 }; f()`, 1},
+		{`f := \{ ytry { yeet 1 } ycatch { 2 } }; ytry { f(); yikes("after") } ycatch { err["msg"] }`, "after"},
+
+		// variables captured in ytry block survive the error
+		{`fns := []; ytry { x := 5; fns = fns << \{ x }; yikes() } ycatch { 0 }; fns[0]()`, 5},
+
+		// error variable is only visible in ycatch block
+		{`ytry { yikes() } ycatch { 1 }; err`, errmsg{"identifier not found: err"}},
+	})
+}
+
 func TestRangeLiterals(t *testing.T) {
 	runEvalTests(t, []evalTestCase{
 		{"0..5", rng{0, 5}},
@@ -586,6 +1029,59 @@ func TestLambdaApplication(t *testing.T) {
 	})
 }
 
+func TestDefaultParameters(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`f := \a, b = 10 { a + b }; f(1)`, 11},
+		{`f := \a, b = 10 { a + b }; f(1, 2)`, 3},
+		{`f := \a b = 10 { a + b }; f(1, null)`, 11},
+		{`f := \a = 1, b = 2 { a * 10 + b }; f()`, 12},
+		{`f := \a, b = a * 2 { a + b }; f(3)`, 9},
+		{`x := 5; f := \a = x { a }; x = 6; f()`, 6},
+		{`f := \a = [] { a << 1 }; f(); f()`, []int64{1}},
+		{`f := \a, b = 10 { a + b }; map([1, 2], f)`, []int64{11, 12}},
+		{`f := \n, acc = 1 { yif n < 2 { acc } yels { f(n - 1, acc * n) } }; f(5)`, 120},
+		{`f := \a = \x { x * 2 } { a(3) }; f()`, 6},
+		{`f := \a = nope { a }; f()`, errmsg{"identifier not found: nope"}},
+		{`f := \a, b = 10 { a + b }; f()`, errmsg{"wrong number of args for f (got 0, want at least 1)"}},
+		{`f := \a, b = 10 { a + b }; f(1, 2, 3)`, errmsg{"wrong number of args for f (got 3, want at most 2)"}},
+		{`map([1], \a, b, c = 1 { a })`, errmsg{"wrong number of args (got 1, want at least 2)"}},
+	})
+}
+
+func TestRestParameters(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`f := \...rest { rest }; f()`, []int64{}},
+		{`f := \...rest { rest }; f(1, 2, 3)`, []int64{1, 2, 3}},
+		{`f := \a, ...rest { rest }; f(1, 2, 3)`, []int64{2, 3}},
+		{`f := \a, ...rest { a }; f(1, 2, 3)`, 1},
+		{`f := \a, b = 2, ...rest { [a, b] + rest }; f(1)`, []int64{1, 2}},
+		{`f := \a, b = 2, ...rest { [a, b] + rest }; f(1, 3, 5, 7)`, []int64{1, 3, 5, 7}},
+		{`sum := \...nums { t := 0; yall nums { t += yt }; t }; sum(1, 2, 3, 4)`, 10},
+		{`f := \...rest { rest << 1 }; f(); f()`, []int64{1}},
+		{`f := \...rest { \{ rest } }; f(1, 2)()`, []int64{1, 2}},
+		{`[1, 2] |> \...rest { rest } |> len`, 1},
+		{`f := \a, ...rest { a }; f()`, errmsg{"wrong number of args for f (got 0, want at least 1)"}},
+	})
+}
+
+func TestNamedArguments(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`greet := \name, message { "{message}, {name}!" }; greet(message: "hi", name: "Bob")`, "hi, Bob!"},
+		{`greet := \name, message = "hello" { "{message}, {name}!" }; greet(name: "Bob")`, "hello, Bob!"},
+		{`greet := \name, message = "hello" { "{message}, {name}!" }; greet("Bob", message: "yo")`, "yo, Bob!"},
+		{`f := \a = 1, b = 2, c = 3 { [a, b, c] }; f(c: 30)`, []int64{1, 2, 30}},
+		{`f := \a, b { a - b }; 10 |> f(b: 3)`, 7},
+		{`f := \a, ...rest { [a] + rest }; f(a: 1)`, []int64{1}},
+		{`f := \a, b { a - b }; f(b: 1)`, errmsg{"missing arg for parameter a for f"}},
+		{`f := \a { a }; f(b: 1)`, errmsg{"no parameter called b for f"}},
+		{`f := \a { a }; f(1, a: 2)`, errmsg{"multiple values for parameter a for f"}},
+		{`f := \a { a }; f(a: 1, a: 2)`, errmsg{"multiple values for parameter a for f"}},
+		{`f := \...rest { rest }; f(rest: 1)`, errmsg{"no parameter called rest for f"}},
+		{`len(x: "abc")`, errmsg{"named args can't be passed to builtins"}},
+		{`m := @\a { a }; m(a: 1)`, errmsg{"named args can't be passed to macro m"}},
+	})
+}
+
 func TestRecursion(t *testing.T) {
 	runEvalTests(t, []evalTestCase{
 		{
@@ -606,6 +1102,17 @@ factorial := \n {
 `,
 			[]int64{1, 1, 120},
 		},
+		// local functions can call the ones declared after them
+		{
+			`
+h := \ {
+    even := \n { yif n == 0 { true } yels { odd(n - 1) } }
+    odd := \n { yif n == 0 { false } yels { even(n - 1) } }
+    even(4)
+}
+h()`,
+			true,
+		},
 	})
 }
 
@@ -630,6 +1137,11 @@ gen := newGenerator()
 gen() + gen() + gen()`,
 			12, // 2 + 4 + 6
 		},
+		// until a variable is declared, its name refers to the variable outside of the block
+		{`x := 1; f := \ { g := \ { x }; a := g(); x := 2; [a, g()] }; f()`, []int64{1, 2}},
+		{`x := 1; f := \ { x = 5; x := 2; x }; [f(), x]`, []int64{2, 5}},
+		{`fns := []; yall i: 0..2 { fns = fns << \ { y }; y := i }; y := 9; [fns[0](), fns[2]()]`, []int64{0, 2}},
+		{`y := 9; r := []; yall i: 0..1 { r = r << (yif i > 0 { y } yels { 7 }); y := i }; r`, []int64{7, 9}},
 	})
 }
 
@@ -707,10 +1219,203 @@ yif (10 > 1) {
 		},
 	}
 
-	for _, tt := range tests {
-		evaluated := testEval(t, tt.input)
-		if err := testErrorObject(evaluated, tt.expectedMessage); err != nil {
-			t.Error(err)
+	for _, b := range backends {
+		for _, tt := range tests {
+			evaluated := b.eval(t, tt.input)
+			if err := testErrorObject(evaluated, tt.expectedMessage); err != nil {
+				t.Errorf("[%s] %s", b.name, err)
+			}
+		}
+	}
+}
+
+func TestRuntimePanics(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`5 / 0`, errmsg{"division by zero"}},
+		{`5 % 0`, errmsg{"division by zero"}},
+		{`5.5 % 0`, errmsg{"division by zero"}},
+		{`5 % 0.5`, errmsg{"division by zero"}},
+		{`5.5 % 0.5`, errmsg{"division by zero"}},
+		{`a := 0; \{ 10 / a }()`, errmsg{"division by zero"}},
+		{`yolo { (1..5) / 0 }`, errmsg{"division by zero"}},
+		{`yolo { 10 / (0..5) }`, errmsg{"division by zero"}},
+		{`yolo { 10 / [1, 0] }`, errmsg{"division by zero"}},
+		{`ytry { 1 % 0 } ycatch { err["msg"] }`, "division by zero"},
+		{`f := \n { f(n + 1) }; f(0)`, errmsg{"maximum recursion depth exceeded"}},
+		{`f := \n { yif n == 0 { yeet 0 }; 1 + f(n - 1) }; f(1000)`, 1000},
+		// frames with lots of locals and temporaries don't fit into the stack the vm starts with
+		{`f := \a b c d e g h { yif a == 0 { yeet 0 }; 1 + f(a - 1, b, c, d, e, g, h) }; f(9000, 1, 2, 3, 4, 5, 6)`, 9000},
+		{`f := \a b c d e g h { yif a == 0 { yeet 0 }; k := \{ a }; r := 1 + f(a - 1, b, c, d, e, g, h); a = a * 2; r + k() - a }; f(9000, 1, 2, 3, 4, 5, 6)`, 9000},
+		{`f := \n { f(n + 1) }; ytry { f(0) } ycatch { "caught" }`, "caught"},
+	})
+}
+
+func TestLimits(t *testing.T) {
+	defer func() { testLimits = object.Limits{} }()
+
+	testLimits = object.Limits{MaxSteps: 10_000}
+	runEvalTests(t, []evalTestCase{
+		{`x := 0; yoyo x < 10 { x += 1 }; x`, 10},
+		{`yoyo { }`, errmsg{"step limit exceeded (max 10000 steps)"}},
+		{`f := \{ f() }; f()`, errmsg{"step limit exceeded (max 10000 steps)"}},
+		{`yall 1000000 { }`, errmsg{"step limit exceeded (max 10000 steps)"}},
+		{`map(1..1000000, \x { x })`, errmsg{"step limit exceeded (max 10000 steps)"}},
+		// ytry can't get the program out of trouble
+		{`ytry { yoyo { } } ycatch { 1 }`, errmsg{"step limit exceeded (max 10000 steps)"}},
+		{`yoyo { ytry { yoyo { } } ycatch { 1 } }`, errmsg{"step limit exceeded (max 10000 steps)"}},
+	})
+
+	testLimits = object.Limits{MaxDepth: 10}
+	runEvalTests(t, []evalTestCase{
+		{`f := \n { yif n == 0 { yeet 0 }; 1 + f(n - 1) }; f(9)`, 9},
+		{`f := \n { yif n == 0 { yeet 0 }; 1 + f(n - 1) }; f(10)`, errmsg{"maximum recursion depth exceeded"}},
+	})
+
+	// the default depth can be raised too
+	testLimits = object.Limits{MaxDepth: 20_000}
+	runEvalTests(t, []evalTestCase{
+		{`f := \n { yif n == 0 { yeet 0 }; 1 + f(n - 1) }; f(15000)`, 15000},
+		{`f := \a b c d e g h { yif a == 0 { yeet 0 }; 1 + f(a - 1, b, c, d, e, g, h) }; f(15000, 1, 2, 3, 4, 5, 6)`, 15000},
+		{`f := \n { f(n + 1) }; f(0)`, errmsg{"maximum recursion depth exceeded"}},
+	})
+
+	testLimits = object.Limits{MaxLength: 10}
+	runEvalTests(t, []evalTestCase{
+		{`a := []; yall 10 { a << 1 }; len(a)`, errmsg{"length limit exceeded (max 10)"}},
+		{`a := []; yall 9 { a << 1 }; len(a)`, 10},
+		{`s := "a"; yoyo true { s = s + s }`, errmsg{"length limit exceeded (max 10)"}},
+		{`[1, 2, 3, 4, 5] + [6, 7, 8, 9, 10, 11]`, errmsg{"length limit exceeded (max 10)"}},
+		{`yolo { "ab" * 5 }`, "ababababab"},
+		{`yolo { "ab" * 1000000000000 }`, errmsg{"length limit exceeded (max 10)"}},
+		{`yolo { 1000000000000 * ["ab"] }`, errmsg{"length limit exceeded (max 10)"}},
+		{`ytry { yolo { "ab" * 1000000000000 } } ycatch { 1 }`, errmsg{"length limit exceeded (max 10)"}},
+		// templates
+		{`s := "abcde"; "{s}{s}"`, "abcdeabcde"},
+		{`s := "abcde"; "{s}{s}!"`, errmsg{"length limit exceeded (max 10)"}},
+		{`s := "ab"; yoyo { s = "{s}{s}" }`, errmsg{"length limit exceeded (max 10)"}},
+		// builtins
+		{`push([1, 2, 3, 4, 5, 6, 7, 8, 9], 10)`, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{`push([1, 2, 3, 4, 5, 6, 7, 8, 9, 10], 11)`, errmsg{"length limit exceeded (max 10)"}},
+		{`a := []; yoyo { a = push(a, 1) }`, errmsg{"length limit exceeded (max 10)"}},
+		{`yarn([1000, 2000, 3000])`, errmsg{"length limit exceeded (max 10)"}},
+		{`map(1..20, \x { x })`, errmsg{"length limit exceeded (max 10)"}},
+		// builtins called by builtins
+		{`reduce([[1, 2, 3, 4, 5, 6, 7, 8, 9, 10], 11], push)`, errmsg{"length limit exceeded (max 10)"}},
+	})
+}
+
+func TestStackTraces(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedTrace []object.TraceFrame
+	}{
+		{`1 + true`, nil},
+		{`f := \{ yikes() }; f()`, []object.TraceFrame{{Fn: "f", Pos: 19}}},
+		{`\{ yikes() }()`, []object.TraceFrame{{Fn: "", Pos: 0}}},
+		{
+			`a := \{ yikes() }; b := \x { 1 + a() }; b(5)`,
+			[]object.TraceFrame{{Fn: "a", Pos: 33}, {Fn: "b", Pos: 40}},
+		},
+		{
+			`f := \n { yif n == 0 { yikes() }; f(n - 1) }; f(2)`,
+			[]object.TraceFrame{{Fn: "f", Pos: 34}, {Fn: "f", Pos: 34}, {Fn: "f", Pos: 46}},
+		},
+		{
+			`f := \{ yikes() }; g := \{ ytry { 1 + true } ycatch { f() } }; g()`,
+			[]object.TraceFrame{{Fn: "f", Pos: 54}, {Fn: "g", Pos: 63}},
+		},
+		{`f := \x { yikes() }; map([1], f)`, []object.TraceFrame{{Fn: "f", Pos: 21}}},
+		{`f := \x { yikes() }; 1 |> f`, []object.TraceFrame{{Fn: "f", Pos: 26}}},
+		{`f := \x y { yikes() }; 1 |> f(2)`, []object.TraceFrame{{Fn: "f", Pos: 28}}},
+	}
+
+	for _, b := range backends {
+		for _, tt := range tests {
+			evaluated := b.eval(t, tt.input)
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("[%s] no error object returned. got %T (%+v) (%s)", b.name, evaluated, evaluated, tt.input)
+				continue
+			}
+
+			if len(errObj.Trace) != len(tt.expectedTrace) {
+				t.Errorf("[%s] wrong trace length. want %+v, got %+v (%s)", b.name, tt.expectedTrace, errObj.Trace, tt.input)
+				continue
+			}
+
+			for i, frame := range tt.expectedTrace {
+				if errObj.Trace[i] != frame {
+					t.Errorf("[%s] wrong frame %d. want %+v, got %+v (%s)", b.name, i, frame, errObj.Trace[i], tt.input)
+				}
+			}
+		}
+	}
+
+	// too deep recursion is reported where the recursive calls are, so they all look the same
+	defer func() { testLimits = object.Limits{} }()
+	testLimits = object.Limits{MaxDepth: 3}
+	input := `f := \n { f(n + 1) }; f(0)`
+	expectedTrace := []object.TraceFrame{{Fn: "f", Pos: 10}, {Fn: "f", Pos: 10}, {Fn: "f", Pos: 22}}
+	for _, b := range backends {
+		errObj, ok := b.eval(t, input).(*object.Error)
+		if !ok {
+			t.Errorf("[%s] no error object returned (%s)", b.name, input)
+			continue
+		}
+		if errObj.Pos != 10 || !slices.Equal(errObj.Trace, expectedTrace) {
+			t.Errorf("[%s] wrong error location. want 10 %+v, got %d %+v", b.name, expectedTrace, errObj.Pos, errObj.Trace)
+		}
+	}
+}
+
+func TestImports(t *testing.T) {
+	dir := t.TempDir()
+	modules := map[string]string{
+		"math.yeet":       "double := \\x { x * 2 }; answer := double(21)",
+		"lib/nested.yeet": `helper := yimport "helper.yeet"; value := helper["value"] + 1`,
+		"lib/helper.yeet": "value := 41",
+		"a.yeet":          `b := yimport "b.yeet"`,
+		"b.yeet":          `a := yimport "a.yeet"`,
+		"broken.yeet":     "x := 5 +",
+		"fail.yeet":       "fail := \\ { yikes(\"boom\") }",
+		"counter.yeet":    "count := 0",
+	}
+	for name, src := range modules {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runImportTests(t, filepath.Join(dir, "main.yeet"), []evalTestCase{
+		{`m := yimport "math.yeet"; m["answer"]`, 42},
+		{`m := yimport "math.yeet"; m["double"](4)`, 8},
+		{`(yimport "lib/nested.yeet")["value"]`, 42},
+		{`a := yimport "counter.yeet"; b := yimport "counter.yeet"; a["count"] = 5; b["count"]`, 5},
+		{`yimport "a.yeet"`, errmsg{"import cycle: " + filepath.Join(dir, "a.yeet") + " -> " + filepath.Join(dir, "b.yeet") + " -> " + filepath.Join(dir, "a.yeet")}},
+		{`yimport "broken.yeet"`, errmsg{"unexpected token 'EOF'"}},
+		{`yimport "nope.yeet"`, errmsg{"cannot import " + filepath.Join(dir, "nope.yeet") + ": no such file or directory"}},
+		{`m := yimport "fail.yeet"; ytry { m["fail"]() } ycatch e { [e["line"], e["col"]] }`, []int64{1, 13}},
+		{`m := yimport "fail.yeet"; ytry { m["fail"]() } ycatch e { e["file"] }`, filepath.Join(dir, "fail.yeet")},
+	})
+
+	// errors raised in a module point at the module's file
+	for _, b := range backends {
+		input := `m := yimport "fail.yeet"; m["fail"]()`
+		errObj, ok := b.evalFile(t, input, filepath.Join(dir, "main.yeet")).(*object.Error)
+		if !ok {
+			t.Errorf("[%s] no error object returned (%s)", b.name, input)
+			continue
+		}
+		if errObj.File != filepath.Join(dir, "fail.yeet") || errObj.Pos != 12 {
+			t.Errorf("[%s] wrong error location. want %s:12, got %s:%d", b.name, filepath.Join(dir, "fail.yeet"), errObj.File, errObj.Pos)
+		}
+		expectedTrace := []object.TraceFrame{{Fn: "fail", Pos: 26, File: filepath.Join(dir, "main.yeet")}}
+		if len(errObj.Trace) != 1 || errObj.Trace[0] != expectedTrace[0] {
+			t.Errorf("[%s] wrong trace. want %+v, got %+v", b.name, expectedTrace, errObj.Trace)
 		}
 	}
 }
@@ -744,9 +1449,11 @@ func TestExampleFiles(t *testing.T) {
 				t.Fatalf("couldn't read test file: %s", err)
 			}
 
-			result := testEval(t, string(src))
-			if evalError, ok := result.(*object.Error); ok {
-				t.Errorf("runtime error: %q", evalError.Msg)
+			for _, b := range backends {
+				result := b.evalFile(t, string(src), filename)
+				if evalError, ok := result.(*object.Error); ok {
+					t.Errorf("[%s] runtime error: %q", b.name, evalError.Msg)
+				}
 			}
 		})
 	}
@@ -756,12 +1463,65 @@ func TestExampleFiles(t *testing.T) {
 // BENCHMARKS
 //
 
+// BenchmarkEval runs examples with variables looked up by name, and resolved before running them.
 func BenchmarkEval(b *testing.B) {
 	testFiles, err := os.ReadDir(examplesDir)
 	if err != nil {
 		b.Fatalf("couldn't read example files dir: %s", err)
 	}
 
+	for _, f := range testFiles {
+		for _, resolve := range []bool{false, true} {
+			name := f.Name() + "/by_name"
+			if resolve {
+				name = f.Name() + "/resolved"
+			}
+
+			b.Run(name, func(b *testing.B) {
+				b.StopTimer()
+				filename := filepath.Join(examplesDir, f.Name())
+				src, err := os.ReadFile(filename)
+				if err != nil {
+					b.Fatalf("couldn't read test file: %s", err)
+				}
+
+				program := parser.New(lexer.New(string(src))).ParseProgram()
+				macroEnv := object.NewEnvironment()
+				eval.DefineMacros(program, macroEnv)
+				expanded, macroErr := eval.ExpandMacros(program, macroEnv)
+				if macroErr != nil {
+					b.Fatalf("macro expansion error: %s", macroErr.Msg)
+				}
+				if resolve {
+					eval.Resolve(expanded)
+				}
+
+				// imported modules are run once, like they would be in a program importing them repeatedly
+				module := object.NewModule(filename)
+
+				b.StartTimer()
+				for i := 0; i < b.N; i++ {
+					env := object.NewEnvironment()
+					env.SetModule(module)
+					for name, builtin := range eval.PrintBuiltins(io.Discard) {
+						env.Set(name, builtin)
+					}
+
+					if result, ok := eval.Eval(expanded, env).(*object.Error); ok {
+						b.Fatalf("error: %s", result.Msg)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkVM(b *testing.B) {
+	testFiles, err := os.ReadDir(examplesDir)
+	if err != nil {
+		b.Fatalf("couldn't read example files dir: %s", err)
+	}
+
 	for _, f := range testFiles {
 		b.Run(f.Name(), func(b *testing.B) {
 			b.StopTimer()
@@ -775,11 +1535,40 @@ func BenchmarkEval(b *testing.B) {
 			l := lexer.New(sSrc)
 			p := parser.New(l)
 			program := p.ParseProgram()
-			env := object.NewEnvironment()
+
+			macroEnv := object.NewEnvironment()
+			eval.DefineMacros(program, macroEnv)
+			expanded, macroErr := eval.ExpandMacros(program, macroEnv)
+			if macroErr != nil {
+				b.Fatalf("macro expansion error: %s", macroErr.Msg)
+			}
+
+			// print builtins are globals, so they have to be defined before compiling
+			symbolTable := compiler.NewSymbolTable()
+			host := map[string]object.Object{}
+			initial := make([]object.Object, vm.GlobalsSize)
+			for name, builtin := range eval.PrintBuiltins(io.Discard) {
+				initial[symbolTable.Define(name).Index] = builtin
+				host[name] = builtin
+			}
+
+			comp := compiler.NewWithState(symbolTable, []object.Object{})
+			comp.SetFile(filename)
+			if err := comp.Compile(expanded); err != nil {
+				b.Fatalf("compilation error: %s", err)
+			}
+			bytecode := comp.Bytecode()
 
 			b.StartTimer()
 			for i := 0; i < b.N; i++ {
-				_ = eval.Eval(program, env)
+				globals := make([]object.Object, vm.GlobalsSize)
+				copy(globals, initial)
+
+				machine := vm.NewWithGlobals(bytecode, globals)
+				machine.SetHost(host)
+				if result, ok := machine.Run().(*object.Error); ok {
+					b.Fatalf("error: %s", result.Msg)
+				}
 			}
 		})
 	}
@@ -793,69 +1582,108 @@ type errmsg struct {
 	msg string
 }
 
+// bigint is a big integer, written in decimal
+type bigint string
+
 type rng struct {
 	start int64
 	end   int64
 }
 
+// testLimits restrict every run of a backend, each run gets a fresh guard
+var testLimits object.Limits
+
+// backends run YY code, every test table is checked against each one of them
+var backends = []struct {
+	name     string
+	eval     func(t *testing.T, input string) object.Object
+	evalFile func(t *testing.T, input, file string) object.Object
+}{
+	{"eval", testEval, testEvalFile},
+	{"eval (unresolved)", testEvalUnresolved, testEvalFileUnresolved},
+	{"vm", testVM, testVMFile},
+}
+
 func runEvalTests(t *testing.T, tests []evalTestCase) {
 	t.Helper()
 
-	for _, tt := range tests {
-		evaluated := testEval(t, tt.input)
-		switch expected := tt.expected.(type) {
-		case int:
-			if err := testIntegerObject(evaluated, int64(expected)); err != nil {
-				t.Errorf("%s (%s)", err, tt.input)
-			}
-
-		case []int64:
-			if err := testIntegerArray(evaluated, expected); err != nil {
-				t.Errorf("%s (%s)", err, tt.input)
-			}
-
-		case float64:
-			if err := testNumberObject(evaluated, expected); err != nil {
-				t.Errorf("%s (%s)", err, tt.input)
+	for _, b := range backends {
+		for _, tt := range tests {
+			evaluated := b.eval(t, tt.input)
+			if err := testObject(evaluated, tt.expected); err != nil {
+				t.Errorf("[%s] %s (%s)", b.name, err, tt.input)
 			}
+		}
+	}
+}
 
-		case []float64:
-			if err := testNumberArray(evaluated, expected); err != nil {
-				t.Errorf("%s (%s)", err, tt.input)
-			}
+// runImportTests is like runEvalTests, but runs the code as if it was read from file
+func runImportTests(t *testing.T, file string, tests []evalTestCase) {
+	t.Helper()
 
-		case bool:
-			if err := testBooleanObject(evaluated, expected); err != nil {
-				t.Errorf("%s (%s)", err, tt.input)
+	for _, b := range backends {
+		for _, tt := range tests {
+			evaluated := b.evalFile(t, tt.input, file)
+			if err := testObject(evaluated, tt.expected); err != nil {
+				t.Errorf("[%s] %s (%s)", b.name, err, tt.input)
 			}
+		}
+	}
+}
 
-		case string:
-			if err := testStringObject(evaluated, expected); err != nil {
-				t.Errorf("%s (%s)", err, tt.input)
-			}
+func testObject(obj object.Object, expected any) error {
+	switch expected := expected.(type) {
+	case int:
+		return testIntegerObject(obj, int64(expected))
+	case []int64:
+		return testIntegerArray(obj, expected)
+	case float64:
+		return testNumberObject(obj, expected)
+	case []float64:
+		return testNumberArray(obj, expected)
+	case bool:
+		return testBooleanObject(obj, expected)
+	case string:
+		return testStringObject(obj, expected)
+	case []string:
+		return testStringArray(obj, expected)
+	case errmsg:
+		return testErrorObject(obj, expected.msg)
+	case rng:
+		return testRangeObject(obj, expected)
+	case bigint:
+		return testBigIntegerObject(obj, string(expected))
+	case nil:
+		return testNullObject(obj)
+	default:
+		return fmt.Errorf("unexpected type, got %T", expected)
+	}
+}
 
-		case errmsg:
-			if err := testErrorObject(evaluated, expected.msg); err != nil {
-				t.Errorf("%s (%s)", err, tt.input)
-			}
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+	return testEvalFile(t, input, "")
+}
 
-		case rng:
-			if err := testRangeObject(evaluated, expected); err != nil {
-				t.Errorf("%s (%s)", err, tt.input)
-			}
+// testEvalFile evaluates input as if it was read from file, so it can import modules next to it.
+func testEvalFile(t *testing.T, input, file string) object.Object {
+	t.Helper()
+	return evalFile(t, input, file, true)
+}
 
-		case nil:
-			if err := testNullObject(evaluated); err != nil {
-				t.Errorf("%s (%s)", err, tt.input)
-			}
+func testEvalUnresolved(t *testing.T, input string) object.Object {
+	t.Helper()
+	return evalFile(t, input, "", false)
+}
 
-		default:
-			t.Errorf("unexpected type, got %T", expected)
-		}
-	}
+// testEvalFileUnresolved is like testEvalFile, but skips the resolver, so all variables are looked
+// up by name.
+func testEvalFileUnresolved(t *testing.T, input, file string) object.Object {
+	t.Helper()
+	return evalFile(t, input, file, false)
 }
 
-func testEval(t *testing.T, input string) object.Object {
+func evalFile(t *testing.T, input, file string, resolve bool) object.Object {
 	t.Helper()
 
 	l := lexer.New(input)
@@ -869,9 +1697,54 @@ func testEval(t *testing.T, input string) object.Object {
 		t.Fatalf("parsing errors, bailing")
 	}
 
+	macroEnv := object.NewEnvironment()
+	eval.DefineMacros(program, macroEnv)
+	expanded, macroErr := eval.ExpandMacros(program, macroEnv)
+	if macroErr != nil {
+		return macroErr
+	}
+
+	if resolve {
+		eval.Resolve(expanded)
+	}
+
+	module := object.NewModule(file)
+	module.SetSource([]byte(input))
 	env := object.NewEnvironment()
+	env.SetModule(module)
+	env.SetGuard(object.NewGuard(context.Background(), testLimits))
 
-	return eval.Eval(program, env)
+	return eval.Eval(expanded, env)
+}
+
+func testVM(t *testing.T, input string) object.Object {
+	t.Helper()
+	return testVMFile(t, input, "")
+}
+
+func testVMFile(t *testing.T, input, file string) object.Object {
+	t.Helper()
+
+	program := parseProgram(t, input)
+
+	macroEnv := object.NewEnvironment()
+	eval.DefineMacros(program, macroEnv)
+	expanded, macroErr := eval.ExpandMacros(program, macroEnv)
+	if macroErr != nil {
+		return macroErr
+	}
+
+	comp := compiler.New()
+	comp.SetFile(file)
+	if err := comp.Compile(expanded); err != nil {
+		compileErr := err.(*yikes.YYError)
+		return &object.Error{Msg: compileErr.Msg, Pos: compileErr.Offset, File: file}
+	}
+
+	machine := vm.New(comp.Bytecode())
+	machine.SetSource([]byte(input))
+	machine.SetGuard(object.NewGuard(context.Background(), testLimits))
+	return machine.Run()
 }
 
 func testIntegerObject(obj object.Object, expected int64) error {
@@ -937,6 +1810,23 @@ func testStringObject(obj object.Object, expected string) error {
 	return nil
 }
 
+func testStringArray(obj object.Object, expected []string) error {
+	result, ok := obj.(*object.Array)
+	if !ok {
+		return fmt.Errorf("object is not Array. got %T (%+v)", obj, obj)
+	}
+	if len(result.Elements) != len(expected) {
+		return fmt.Errorf("Array has wrong number of elements. got %d, want %d",
+			len(result.Elements), len(expected))
+	}
+	for i := range expected {
+		if err := testStringObject(result.Elements[i], expected[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func testRangeObject(obj object.Object, expectedRng rng) error {
 	result, ok := obj.(*object.Range)
 	if !ok {
@@ -951,6 +1841,17 @@ func testRangeObject(obj object.Object, expectedRng rng) error {
 	return nil
 }
 
+func testBigIntegerObject(obj object.Object, expected string) error {
+	result, ok := obj.(*object.BigInteger)
+	if !ok {
+		return fmt.Errorf("object is not BigInteger. got %T (%+v)", obj, obj)
+	}
+	if result.String() != expected {
+		return fmt.Errorf("BigInteger object has wrong value. got %s, want %s", result, expected)
+	}
+	return nil
+}
+
 func testBooleanObject(obj object.Object, expected bool) error {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -0,0 +1,130 @@
+package eval
+
+import (
+	"io"
+	"sort"
+
+	"yy/ast"
+	"yy/object"
+)
+
+// The functions below expose the semantics of YY's operators, so other backends (eg the bytecode
+// vm) behave exactly like the tree-walking evaluator. Errors returned from them don't carry
+// a position, it's up to the caller to point at the offending code.
+
+// Infix applies op to left and right. Guard, if it isn't nil, limits length of strings and arrays
+// built by op.
+func Infix(op string, left, right object.Object, yoloOK bool, guard *object.Guard) object.Object {
+	return guardedInfix(op, left, right, yoloOK, guard)
+}
+
+// CheckLength yeets an error if obj is a string or array longer than guard allows, obj otherwise.
+func CheckLength(obj object.Object, guard *object.Guard) object.Object {
+	return checkLength(obj, guard)
+}
+
+func Prefix(op string, right object.Object, yoloOK bool) object.Object {
+	return evalPrefixExpression(op, right, yoloOK)
+}
+
+func Index(left, idx object.Object) object.Object {
+	return index(left, idx)
+}
+
+func SetIndex(left, idx, val object.Object, name string) object.Object {
+	return setIndex(left, idx, val, name)
+}
+
+func Field(obj object.Object, name string) object.Object {
+	return field(obj, name)
+}
+
+func SetField(obj object.Object, name string, val object.Object) object.Object {
+	return setField(obj, name, val)
+}
+
+func ErrorToHashmap(err *object.Error, module *object.Module) *object.Hashmap {
+	return errorToHashmap(err, module)
+}
+
+func NewRange(start, end object.Object) object.Object {
+	return newRange(start, end)
+}
+
+func CannotIterate(pos int, iter object.Object) *object.Error {
+	return cannotIterate(pos, iter)
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// PrintBuiltins returns the builtins that print stuff (yap, yelp & co), set up to print to w.
+func PrintBuiltins(w io.Writer) map[string]*object.Builtin {
+	return printBuiltins(w)
+}
+
+// Apply calls fn, which is a lambda or a builtin, with args from Go code.
+func Apply(fn object.Object, args []object.Object) object.Object {
+	caller := object.NewEnvironment()
+	if lambda, ok := fn.(*object.Lambda); ok {
+		caller = lambda.Env
+	}
+	return applyFunction(fn, args, caller, -1)
+}
+
+// ResolveImport finds the file imported by code in importer.
+func ResolveImport(importer, path string) string {
+	return resolveImport(importer, path)
+}
+
+// LoadModule reads and parses the module at path, expanding its macros and resolving its variables.
+func LoadModule(path string) (ast.Expression, *object.Error) {
+	return loadModule(path)
+}
+
+func ExportsToHashmap(bindings map[string]object.Object) *object.Hashmap {
+	return exportsToHashmap(bindings)
+}
+
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// BuiltinNames returns names of all builtins, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func RestArgs(val object.Object) []object.Object {
+	return restArgs(val)
+}
+
+func ObjectToAST(obj object.Object) ast.Expression {
+	return objectToAST(obj)
+}
+
+// UnquoteCalls returns all unquote(...) calls inside quoted code, ordered by their position in the
+// source code. Calls that appear more than once (eg after macro expansion) are returned only once.
+func UnquoteCalls(node ast.Expression) []*ast.CallExpression {
+	calls := []*ast.CallExpression{}
+	seen := map[int]bool{}
+
+	ast.Modify(node, func(node ast.Expression) ast.Expression {
+		if isUnquoteCall(node) && !seen[node.Pos()] {
+			seen[node.Pos()] = true
+			calls = append(calls, node.(*ast.CallExpression))
+		}
+		return node
+	})
+
+	sort.Slice(calls, func(i, j int) bool { return calls[i].Pos() < calls[j].Pos() })
+
+	return calls
+}
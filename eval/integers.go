@@ -0,0 +1,137 @@
+package eval
+
+import (
+	"math"
+	"math/big"
+
+	"yy/object"
+)
+
+// AddInts adds integers, reporting false if the sum doesn't fit in int64.
+func AddInts(a, b int64) (int64, bool) {
+	sum := a + b
+	return sum, (sum > a) == (b > 0)
+}
+
+// SubInts subtracts integers, reporting false if the difference doesn't fit in int64.
+func SubInts(a, b int64) (int64, bool) {
+	diff := a - b
+	return diff, (diff < a) == (b > 0)
+}
+
+// MulInts multiplies integers, reporting false if the product doesn't fit in int64.
+func MulInts(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == math.MinInt64 && b == -1) {
+		return 0, false
+	}
+	return product, true
+}
+
+// evalIntegerInfixExpression applies op to integers, promoting the result to a BigInteger if it
+// doesn't fit in an Integer.
+func evalIntegerInfixExpression(op string, left, right int64) object.Object {
+	switch op {
+	case "+":
+		if sum, ok := AddInts(left, right); ok {
+			return &object.Integer{Value: sum}
+		}
+	case "-":
+		if diff, ok := SubInts(left, right); ok {
+			return &object.Integer{Value: diff}
+		}
+	case "*":
+		if product, ok := MulInts(left, right); ok {
+			return &object.Integer{Value: product}
+		}
+	case "/":
+		if right == 0 {
+			return newErrorWithoutPos("division by zero")
+		}
+		if left != math.MinInt64 || right != -1 {
+			return &object.Integer{Value: left / right}
+		}
+	case "%":
+		if right == 0 {
+			return newErrorWithoutPos("division by zero")
+		}
+		return &object.Integer{Value: left % right}
+	case "<":
+		return toYeetBool(left < right)
+	case ">":
+		return toYeetBool(left > right)
+	case "<=":
+		return toYeetBool(left <= right)
+	case ">=":
+		return toYeetBool(left >= right)
+	case "==":
+		return toYeetBool(left == right)
+	case "!=":
+		return toYeetBool(left != right)
+	default:
+		return nil
+	}
+
+	return evalBigIntegerInfixExpression(op, big.NewInt(left), big.NewInt(right))
+}
+
+// evalBigIntegerInfixExpression applies op to integers of any size. The result is demoted to an
+// Integer if it fits in one.
+func evalBigIntegerInfixExpression(op string, left, right *big.Int) object.Object {
+	switch op {
+	case "+":
+		return object.NewInteger(new(big.Int).Add(left, right))
+	case "-":
+		return object.NewInteger(new(big.Int).Sub(left, right))
+	case "*":
+		return object.NewInteger(new(big.Int).Mul(left, right))
+	case "/":
+		if right.Sign() == 0 {
+			return newErrorWithoutPos("division by zero")
+		}
+		// Quo & Rem truncate like int64 division does
+		return object.NewInteger(new(big.Int).Quo(left, right))
+	case "%":
+		if right.Sign() == 0 {
+			return newErrorWithoutPos("division by zero")
+		}
+		return object.NewInteger(new(big.Int).Rem(left, right))
+	case "<":
+		return toYeetBool(left.Cmp(right) < 0)
+	case ">":
+		return toYeetBool(left.Cmp(right) > 0)
+	case "<=":
+		return toYeetBool(left.Cmp(right) <= 0)
+	case ">=":
+		return toYeetBool(left.Cmp(right) >= 0)
+	case "==":
+		return toYeetBool(left.Cmp(right) == 0)
+	case "!=":
+		return toYeetBool(left.Cmp(right) != 0)
+	}
+	return nil
+}
+
+// toBigInt yeets the value of an Integer or a BigInteger as a big.Int.
+func toBigInt(obj object.Object) (*big.Int, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value), true
+	case *object.BigInteger:
+		return obj.Value, true
+	}
+	return nil, false
+}
+
+// bigToNumber converts a BigInteger to the closest Number.
+func bigToNumber(i *object.BigInteger) *object.Number {
+	f, _ := new(big.Float).SetInt(i.Value).Float64()
+	return &object.Number{Value: f}
+}
+
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIG_INTEGER_OBJ
+}
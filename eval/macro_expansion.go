@@ -0,0 +1,189 @@
+package eval
+
+import (
+	"yy/ast"
+	"yy/object"
+)
+
+// DefineMacros finds top-level macro declarations (ie 'name := @\x { ... }'), stores them in env
+// and removes them from the program, so the evaluator never sees them.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	definitions := []int{}
+
+	for i, expr := range program.Expressions {
+		if isMacroDefinition(expr) {
+			addMacro(expr, env)
+			definitions = append(definitions, i)
+		}
+	}
+
+	for i := len(definitions) - 1; i >= 0; i-- {
+		idx := definitions[i]
+		program.Expressions = append(program.Expressions[:idx], program.Expressions[idx+1:]...)
+	}
+}
+
+func isMacroDefinition(node ast.Expression) bool {
+	declExpr, ok := node.(*ast.DeclareExpression)
+	if !ok {
+		return false
+	}
+
+	_, ok = declExpr.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+func addMacro(expr ast.Expression, env *object.Environment) {
+	declExpr := expr.(*ast.DeclareExpression)
+	macroLiteral := declExpr.Value.(*ast.MacroLiteral)
+
+	macro := &object.Macro{
+		Parameters: macroLiteral.Parameters,
+		Env:        env,
+		Body:       macroLiteral.Body,
+	}
+
+	env.Set(declExpr.Name.Value, macro)
+}
+
+// ExpandMacros replaces every call to a macro defined in env with the code the macro yeets. Macro
+// arguments aren't evaluated, they're passed to the macro as quoted code instead. If a macro
+// can't be expanded, the first error encountered is returned alongside the partially expanded tree.
+func ExpandMacros(program ast.Expression, env *object.Environment) (expanded ast.Expression, expansionErr *object.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			expanded, expansionErr = program, newErrorWithoutPos("internal error: %v", r)
+		}
+	}()
+
+	expanded = ast.Modify(program, func(node ast.Expression) ast.Expression {
+		if expansionErr != nil {
+			return node
+		}
+
+		callExpr, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		macro, ok := isMacroCall(callExpr, env)
+		if !ok {
+			return node
+		}
+
+		for _, arg := range callExpr.Arguments {
+			if named, ok := arg.(*ast.NamedArgument); ok {
+				expansionErr = newError(named.Pos(), "named args can't be passed to macro %s", callExpr.Function.TokenLiteral())
+				return node
+			}
+		}
+
+		if len(macro.Parameters) != len(callExpr.Arguments) {
+			expansionErr = newError(
+				callExpr.Pos(),
+				"wrong number of args for macro %s (got %d, want %d)",
+				callExpr.Function.TokenLiteral(), len(callExpr.Arguments), len(macro.Parameters))
+			return node
+		}
+
+		evalEnv := object.NewEnclosedEnvironment(macro.Env)
+		for i, param := range macro.Parameters {
+			evalEnv.Set(param.Value, &object.Quote{Node: callExpr.Arguments[i]})
+		}
+
+		evaluated := Eval(macro.Body, evalEnv)
+		if returnValue, ok := evaluated.(*object.ReturnValue); ok {
+			evaluated = returnValue.Value
+		}
+
+		switch evaluated := evaluated.(type) {
+		case *object.Quote:
+			return evaluated.Node
+
+		case *object.Error:
+			expansionErr = evaluated
+			if expansionErr.Pos < 0 {
+				expansionErr.Pos = callExpr.Pos()
+			}
+
+		default:
+			expansionErr = newError(
+				callExpr.Pos(),
+				"macro %s must yeet quoted code (got %s)",
+				callExpr.Function.TokenLiteral(), typeName(evaluated))
+		}
+
+		return node
+	})
+
+	return expanded, expansionErr
+}
+
+func isMacroCall(expr *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := expr.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func typeName(obj object.Object) string {
+	if obj == nil {
+		return "nothing"
+	}
+	return obj.Type().String()
+}
+
+// quote is a special form: its argument is not evaluated but returned as code. Any unquote(...)
+// calls inside are evaluated and their results are spliced back into the quoted code.
+func quote(node ast.Expression, env *object.Environment) object.Object {
+	var unquoteErr object.Object
+
+	node = ast.Modify(node, func(node ast.Expression) ast.Expression {
+		if unquoteErr != nil || !isUnquoteCall(node) {
+			return node
+		}
+
+		call := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			unquoteErr = newError(call.Pos(), "wrong number of args for unquote (got %d, want 1)", len(call.Arguments))
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			unquoteErr = unquoted
+			return node
+		}
+
+		if newNode := objectToAST(unquoted); newNode != nil {
+			return newNode
+		}
+
+		unquoteErr = newError(call.Pos(), "cannot unquote %s", unquoted.Type())
+		return node
+	})
+
+	if unquoteErr != nil {
+		return unquoteErr
+	}
+
+	return &object.Quote{Node: node}
+}
+
+func isUnquoteCall(node ast.Expression) bool {
+	callExpr, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	ident, ok := callExpr.Function.(*ast.Identifier)
+	return ok && ident.Value == "unquote"
+}
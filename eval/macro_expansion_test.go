@@ -0,0 +1,272 @@
+package eval_test
+
+import (
+	"testing"
+
+	"yy/ast"
+	"yy/eval"
+	"yy/lexer"
+	"yy/object"
+	"yy/parser"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+
+	for _, b := range backends {
+		for _, tt := range tests {
+			testQuoteObject(t, b.eval(t, tt.input), tt.expected)
+		}
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`foobar := 8; quote(foobar)`, `foobar`},
+		{`foobar := 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(null))`, `null`},
+		{`quote(unquote(2.5))`, `2.5`},
+		{`quote(unquote("yarn"))`, `"yarn"`},
+		{`quote(unquote([1, 2 + 3]))`, `[1, 5]`},
+		{`quote(unquote(1..3))`, `(1..3)`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{
+			`quotedInfixExpression := quote(4 + 4);
+			quote(unquote(4 + 4) + unquote(quotedInfixExpression))`,
+			`(8 + (4 + 4))`,
+		},
+	}
+
+	for _, b := range backends {
+		for _, tt := range tests {
+			testQuoteObject(t, b.eval(t, tt.input), tt.expected)
+		}
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{`quote()`, errmsg{"wrong number of args for quote (got 0, want 1)"}},
+		{`quote(1, 2)`, errmsg{"wrong number of args for quote (got 2, want 1)"}},
+		{`quote(unquote(1, 2))`, errmsg{"wrong number of args for unquote (got 2, want 1)"}},
+		{`quote(unquote(\x { x }))`, errmsg{"cannot unquote FUNCTION"}},
+		{`quote(unquote(nope))`, errmsg{"identifier not found: nope"}},
+	})
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+number := 1
+function := \x, y { x + y }
+mymacro := @\x, y { x + y }
+`
+
+	env := object.NewEnvironment()
+	program := parseProgram(t, input)
+
+	eval.DefineMacros(program, env)
+
+	if len(program.Expressions) != 2 {
+		t.Fatalf("wrong number of expressions. got %d", len(program.Expressions))
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got %T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("wrong number of macro parameters. got %d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("wrong macro parameters. got %v", macro.Parameters)
+	}
+
+	expectedBody := "{ (x + y) }"
+	if macro.Body.String() != expectedBody {
+		t.Fatalf("wrong macro body. want %q, got %q", expectedBody, macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+infixExpression := @\ { quote(1 + 2) }
+infixExpression()`,
+			`(1 + 2)`,
+		},
+		{
+			`
+reverse := @\a, b { quote(unquote(b) - unquote(a)) }
+reverse(2 + 2, 10 - 5)`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+unless := @\cond, cons, alt {
+    quote(yif !(unquote(cond)) { unquote(cons) } yels { unquote(alt) })
+}
+unless(10 > 5, yap("not greater"), yap("greater"))`,
+			`yif (!(10 > 5)) { yap("not greater") } yels { yap("greater") }`,
+		},
+		{
+			`
+double := @\x { quote(unquote(x) * 2) }
+double(1); double(2)`,
+			`(1 * 2); (2 * 2)`,
+		},
+		{
+			`
+twice := @\x { quote({ unquote(x); unquote(x) }) }
+f := \ { twice(yap("hey")) }`,
+			`f := \ { { yap("hey"); yap("hey") } }`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := parseProgram(t, tt.expected)
+		program := parseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		eval.DefineMacros(program, env)
+
+		expanded, err := eval.ExpandMacros(program, env)
+		if err != nil {
+			t.Errorf("unexpected expansion error: %s (%s)", err.Msg, tt.input)
+			continue
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("wrong expansion. want %q, got %q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedMsg string
+	}{
+		{
+			`m := @\x { quote(unquote(x)) }; m()`,
+			"wrong number of args for macro m (got 0, want 1)",
+		},
+		{
+			`m := @\x { 5 }; m(1)`,
+			"macro m must yeet quoted code (got INTEGER)",
+		},
+		{
+			`m := @\x { yikes("nope") }; m(1)`,
+			"nope",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		eval.DefineMacros(program, env)
+
+		_, err := eval.ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected an error (%s)", tt.input)
+			continue
+		}
+		if err.Msg != tt.expectedMsg {
+			t.Errorf("wrong error message. want %q, got %q", tt.expectedMsg, err.Msg)
+		}
+		if err.Pos < 0 {
+			t.Errorf("error without a position (%s)", tt.input)
+		}
+	}
+}
+
+func TestMacroEvaluation(t *testing.T) {
+	runEvalTests(t, []evalTestCase{
+		{
+			`
+unless := @\cond, cons, alt {
+    quote(yif !(unquote(cond)) { unquote(cons) } yels { unquote(alt) })
+}
+unless(2 > 5, "not greater", "greater")`,
+			"not greater",
+		},
+		{
+			`
+times := @\n, body { quote(yall 1..unquote(n) { unquote(body) }) }
+count := 0; times(3, count += 2); count`,
+			6,
+		},
+		{
+			`
+m := @\x { quote(unquote(x) + 1) };
+[m(1), m(2), m(3)]`,
+			[]int64{2, 3, 4},
+		},
+		{`x := @\ { quote(1) }; \ { x }()`, errmsg{"identifier not found: x"}},
+		{`f := \ { @\ { quote(1) } }; f()`, errmsg{"macros can only be declared at the top level of a program"}},
+	})
+}
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		for _, err := range p.Errors() {
+			t.Error(err)
+		}
+		t.Fatalf("parsing errors, bailing")
+	}
+	return program
+}
+
+func testQuoteObject(t *testing.T, obj object.Object, expected string) {
+	t.Helper()
+
+	quote, ok := obj.(*object.Quote)
+	if !ok {
+		t.Errorf("object is not Quote. got %T (%+v)", obj, obj)
+		return
+	}
+	if quote.Node == nil {
+		t.Errorf("quote.Node is nil")
+		return
+	}
+	if quote.Node.String() != expected {
+		t.Errorf("wrong quoted code. want %q, got %q", expected, quote.Node.String())
+	}
+}
@@ -0,0 +1,122 @@
+package eval
+
+import (
+	"strings"
+
+	"yy/ast"
+	"yy/object"
+)
+
+func evalYmatchExpression(node *ast.YmatchExpression, env *object.Environment) object.Object {
+	subject := Eval(node.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range node.Arms {
+		if !Matches(arm.Pattern, subject) {
+			continue
+		}
+
+		armEnv := enterScope(arm.Scope, env)
+		if err := destructureTarget(arm.Pattern, subject, true, armEnv); err != nil {
+			return err
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, armEnv)
+	}
+
+	return newError(node.Pos(), "no pattern matches %s", subject.String())
+}
+
+// Matches reports whether val has the shape of a pattern of a ymatch arm. It doesn't bind anything,
+// that's left to destructuring once the arm is picked.
+func Matches(pattern ast.Expression, val object.Object) bool {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return true
+
+	case *ast.TypePattern:
+		return typeMatches(pattern.Type, val) && Matches(pattern.Target, val)
+
+	case *ast.RangeLiteral:
+		// ranges hold integers, so nothing else falls into them
+		if !isInteger(val) {
+			return false
+		}
+		start, end := literalValue(pattern.Start), literalValue(pattern.End)
+		return evalInfixExpression(">=", val, start, false) == object.TRUE &&
+			evalInfixExpression("<=", val, end, false) == object.TRUE
+
+	case *ast.ArrayPattern:
+		elements, err := UnpackArray(val, len(pattern.Elements), pattern.Required(), pattern.Rest != nil)
+		if err != nil {
+			return false
+		}
+		for i, el := range pattern.Elements {
+			if !elementMatches(el, elements[i]) {
+				return false
+			}
+		}
+		return pattern.Rest == nil || Matches(pattern.Rest, elements[len(elements)-1])
+
+	case *ast.HashmapPattern:
+		hashmap, err := UnpackHashmap(val)
+		if err != nil {
+			return false
+		}
+		for _, el := range pattern.Pairs {
+			val := UnpackKey(hashmap, literalValue(el.Key), el.Default != nil)
+			if isError(val) || !elementMatches(el, val) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return evalInfixExpression("==", literalValue(pattern), val, false) == object.TRUE
+}
+
+// elementMatches reports whether an element of an array or hashmap matches its part of a pattern.
+// A missing element matches if the pattern has a default value for it.
+func elementMatches(el ast.PatternElement, val object.Object) bool {
+	return val == object.NULL && el.Default != nil || Matches(el.Target, val)
+}
+
+func typeMatches(name string, val object.Object) bool {
+	switch val.Type() {
+	case object.BUILTIN_OBJ, object.COMPILED_FUNCTION_OBJ:
+		return name == "function"
+	}
+	return strings.ToLower(val.Type().String()) == name
+}
+
+// literalValue yeets the value of a literal in a pattern. The parser lets nothing else through.
+func literalValue(lit ast.Expression) object.Object {
+	switch lit := lit.(type) {
+	case *ast.IntegerLiteral:
+		if lit.Big != nil {
+			return &object.BigInteger{Value: lit.Big}
+		}
+		return &object.Integer{Value: lit.Value}
+	case *ast.NumberLiteral:
+		return &object.Number{Value: lit.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: lit.Value}
+	case *ast.BooleanLiteral:
+		return toYeetBool(lit.Value)
+	case *ast.PrefixExpression:
+		return evalPrefixExpression(lit.Operator, literalValue(lit.Right), false)
+	}
+	return object.NULL
+}
@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yy/ast"
+	"yy/lexer"
+	"yy/object"
+	"yy/parser"
+)
+
+// importModule runs the module imported by node, unless it has been run already, and yeets its
+// top-level bindings as a hashmap.
+func importModule(node *ast.ImportExpression, env *object.Environment) object.Object {
+	importer := env.Module()
+	if importer == nil {
+		importer = object.NewModule("")
+	}
+
+	path := resolveImport(importer.Path, node.Path)
+	if exports, ok := importer.Loaded(path); ok {
+		return exports
+	}
+	if cycle := importer.ImportCycle(path); cycle != nil {
+		return newError(node.Pos(), "import cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	program, err := loadModule(path)
+	if err != nil {
+		if err.File == "" {
+			err.Pos = node.Pos()
+			return err
+		}
+		err.Trace = append(err.Trace, object.TraceFrame{Fn: "<module>", Pos: node.Pos(), File: importer.Path})
+		return err
+	}
+
+	// modules don't see variables of the code importing them, only what the host added
+	module := importer.Import(path)
+	moduleEnv := object.NewEnvironment()
+	if host := env.Host(); host != nil {
+		moduleEnv = object.NewEnclosedEnvironment(host)
+	}
+	moduleEnv.SetModule(module)
+	moduleEnv.SetGuard(env.Guard())
+
+	result := Eval(program, moduleEnv)
+	if errObj, ok := result.(*object.Error); ok {
+		errObj.Trace = append(errObj.Trace, object.TraceFrame{Fn: "<module>", Pos: node.Pos(), File: importer.Path})
+		return errObj
+	}
+
+	exports := exportsToHashmap(moduleEnv.GetAll())
+	importer.SetLoaded(path, exports)
+	return exports
+}
+
+// resolveImport finds the file imported by code in importer. Relative paths start in the directory
+// of the importer.
+func resolveImport(importer, path string) string {
+	if filepath.IsAbs(path) || importer == "" {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(filepath.Dir(importer), path)
+}
+
+// loadModule reads and parses the module at path, expanding its macros and resolving its variables.
+// Errors in the code of the module point at the module's file, errors that stop it from being read
+// don't point anywhere.
+func loadModule(path string) (ast.Expression, *object.Error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return nil, newErrorWithoutPos("cannot import %s: %s", path, err)
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		parseErr := p.Errors()[0]
+		return nil, &object.Error{Msg: parseErr.Msg, Pos: parseErr.Offset, File: path}
+	}
+
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	expanded, macroErr := ExpandMacros(program, macroEnv)
+	if macroErr != nil {
+		macroErr.File = path
+		return nil, macroErr
+	}
+
+	Resolve(expanded)
+	return expanded, nil
+}
+
+// exportsToHashmap collects top-level bindings of a module, sorted by name.
+func exportsToHashmap(bindings map[string]object.Object) *object.Hashmap {
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hashmap := object.NewHashmap(len(names))
+	for _, name := range names {
+		hashmap.Set(&object.String{Value: name}, bindings[name])
+	}
+	return hashmap
+}
+
+// blame sets the file of err to the module of code running in env, unless it's known already.
+// Errors are blamed on their way out of a function or module, so the first blame is the right one.
+func blame(err *object.Error, env *object.Environment) {
+	if err.File == "" {
+		err.File = modulePath(env)
+	}
+}
+
+func modulePath(env *object.Environment) string {
+	if module := env.Module(); module != nil {
+		return module.Path
+	}
+	return ""
+}
@@ -0,0 +1,346 @@
+package eval
+
+import "yy/ast"
+
+// Resolve works out where variables used in program live, so the evaluator can find them in slots
+// of environments instead of looking them up by name through every enclosing environment. It fills
+// in Scope of blocks, lambdas & loops and resolution of identifiers. Macros have to be expanded
+// first, which is why Resolve takes what ExpandMacros yeets. Resolving is optional, unresolved code runs just the same, only slower.
+//
+// Variables declared in a scope are known in all of it, even before they're declared: a lambda can
+// use a variable declared after it. Until the declaration runs, the slot stays empty and lookup falls
+// back to going by name, which finds the variable in an outer scope (if any), like it would without
+// resolving. Top-level variables are global, they're always looked up by name, as other programs
+// (eg the following lines in the REPL) can declare them too. So are variables yolo mode may declare
+// by assigning to them.
+func Resolve(program ast.Expression) {
+	r := &resolver{}
+	r.expr(program, &scope{})
+
+	locations := map[*ast.Identifier]location{}
+	for _, ref := range r.refs {
+		loc := ref.locate()
+		// the same identifier can be found in different places, eg an argument a macro unquotes twice
+		if prev, ok := locations[ref.ident]; ok && prev != loc {
+			loc = location{resolution: ast.Unresolved}
+		}
+		locations[ref.ident] = loc
+	}
+
+	for ident, loc := range locations {
+		ident.Resolution, ident.Scope, ident.Depth, ident.Slot = loc.resolution, loc.scope, loc.depth, loc.slot
+	}
+}
+
+type resolver struct {
+	refs []reference
+}
+
+// scope is what the resolver knows about an ast.Scope while walking the code in it.
+type scope struct {
+	ast       *ast.Scope // nil for the top level
+	outer     *scope
+	shareable bool // the scope can share the enclosing environment if nothing is declared in it
+	lambda    bool // the scope of a lambda's parameters
+	yolo      bool
+	slots     map[string]int
+	dynamic   map[string]bool // variables yolo mode might declare in the scope
+}
+
+func newScope(outer *scope, node *ast.Scope, shareable bool) *scope {
+	return &scope{ast: node, outer: outer, shareable: shareable, yolo: outer.yolo, slots: map[string]int{}}
+}
+
+func (s *scope) declare(name string) {
+	if _, ok := s.slots[name]; !ok {
+		s.slots[name] = len(s.ast.Names)
+		s.ast.Names = append(s.ast.Names, name)
+	}
+}
+
+// declareSelf declares self in the lambda the scope belongs to, if any. Self is set when the lambda
+// is called as a method, even if the code around the lambda has a self of its own, otherwise it's
+// left empty and found by name in the code around the lambda.
+func (s *scope) declareSelf() {
+	for ; s.ast != nil; s = s.outer {
+		if s.lambda {
+			s.declare("self")
+			return
+		}
+	}
+}
+
+// hasEnvironment reports whether code in the scope runs in an environment of its own.
+func (s *scope) hasEnvironment() bool {
+	return !s.shareable || len(s.slots) > 0 || len(s.dynamic) > 0
+}
+
+// finish marks the scope shared if it turns out it doesn't need an environment, once everything in
+// it is known.
+func (s *scope) finish() {
+	s.ast.Shared = !s.hasEnvironment()
+}
+
+// reference is an identifier found in scope.
+type reference struct {
+	ident *ast.Identifier
+	scope *scope
+}
+
+type location struct {
+	resolution ast.Resolution
+	scope      *ast.Scope
+	depth      int
+	slot       int
+}
+
+func (ref reference) locate() location {
+	name := ref.ident.Value
+	depth := 0
+	for s := ref.scope; s.ast != nil; s = s.outer {
+		if slot, ok := s.slots[name]; ok {
+			return location{ast.Local, s.ast, depth, slot}
+		}
+		if s.dynamic[name] {
+			return location{resolution: ast.Unresolved}
+		}
+		if s.hasEnvironment() {
+			depth++
+		}
+	}
+	return location{resolution: ast.Global}
+}
+
+func (r *resolver) statements(exprs []ast.Expression, sc *scope) {
+	for _, expr := range exprs {
+		r.expr(expr, sc)
+	}
+}
+
+// assign walks the target of an assignment.
+func (r *resolver) assign(target ast.Expression, sc *scope) {
+	r.expr(target, sc)
+
+	// in yolo mode, assigning to a variable that doesn't exist declares it
+	if ident, ok := target.(*ast.Identifier); ok && sc.yolo {
+		if sc.dynamic == nil {
+			sc.dynamic = map[string]bool{}
+		}
+		sc.dynamic[ident.Value] = true
+	}
+}
+
+// pattern walks a destructuring pattern, declaring its targets (or assigning to them).
+func (r *resolver) pattern(pattern ast.Expression, declaring bool, sc *scope) {
+	var elements []ast.PatternElement
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		elements = pattern.Elements
+	case *ast.HashmapPattern:
+		elements = pattern.Pairs
+	case *ast.TypePattern:
+		r.pattern(pattern.Target, declaring, sc)
+		return
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			return
+		}
+		if !declaring {
+			r.assign(pattern, sc)
+			return
+		}
+		if sc.ast != nil {
+			sc.declare(pattern.Value)
+		}
+		r.refs = append(r.refs, reference{pattern, sc})
+		return
+	default:
+		r.assign(pattern, sc)
+		return
+	}
+
+	for _, el := range elements {
+		if el.Key != nil {
+			r.expr(el.Key, sc)
+		}
+		if el.Default != nil {
+			r.expr(el.Default, sc)
+		}
+		r.pattern(el.Target, declaring, sc)
+	}
+	if arr, ok := pattern.(*ast.ArrayPattern); ok && arr.Rest != nil {
+		r.pattern(arr.Rest, declaring, sc)
+	}
+}
+
+// block walks a block, in a scope of its own if it has one.
+func (r *resolver) block(block *ast.BlockExpression, sc *scope) {
+	if block == nil {
+		return
+	}
+	block.Scope = &ast.Scope{}
+	inner := newScope(sc, block.Scope, true)
+	r.statements(block.Expressions, inner)
+	inner.finish()
+}
+
+func (r *resolver) expr(node ast.Expression, sc *scope) {
+	switch node := node.(type) {
+	case *ast.Program:
+		r.statements(node.Expressions, sc)
+
+	case *ast.Identifier:
+		if node.Value == "self" {
+			sc.declareSelf()
+		}
+		r.refs = append(r.refs, reference{node, sc})
+
+	case *ast.DeclareExpression:
+		r.expr(node.Value, sc)
+		if sc.ast != nil {
+			sc.declare(node.Name.Value)
+		}
+		r.refs = append(r.refs, reference{node.Name, sc})
+
+	case *ast.AssignExpression:
+		r.expr(node.Value, sc)
+		r.assign(node.Left, sc)
+
+	case *ast.DestructureExpression:
+		r.expr(node.Value, sc)
+		r.pattern(node.Pattern, node.Declare(), sc)
+
+	case *ast.YeetExpression:
+		r.expr(node.ReturnValue, sc)
+
+	case *ast.YbreakExpression:
+		if node.Value != nil {
+			r.expr(node.Value, sc)
+		}
+
+	case *ast.PrefixExpression:
+		r.expr(node.Right, sc)
+
+	case *ast.InfixExpression:
+		r.expr(node.Left, sc)
+		r.expr(node.Right, sc)
+
+	case *ast.PipeExpression:
+		r.expr(node.Call, sc)
+
+	case *ast.AndExpression:
+		r.expr(node.Left, sc)
+		r.expr(node.Right, sc)
+
+	case *ast.OrExpression:
+		r.expr(node.Left, sc)
+		r.expr(node.Right, sc)
+
+	case *ast.IndexExpression:
+		r.expr(node.Left, sc)
+		r.expr(node.Index, sc)
+
+	case *ast.DotExpression:
+		r.expr(node.Left, sc)
+
+	case *ast.RangeLiteral:
+		r.expr(node.Start, sc)
+		r.expr(node.End, sc)
+
+	case *ast.ArrayLiteral:
+		r.statements(node.Elements, sc)
+
+	case *ast.HashmapLiteral:
+		for _, pair := range node.Pairs {
+			r.expr(pair.Key, sc)
+			r.expr(pair.Value, sc)
+		}
+
+	case *ast.TemplateStringLiteral:
+		r.statements(node.Values, sc)
+
+	case *ast.BlockExpression:
+		r.block(node, sc)
+
+	case *ast.YifExpression:
+		r.expr(node.Condition, sc)
+		r.block(node.Consequence, sc)
+		r.block(node.Alternative, sc)
+
+	case *ast.YoloExpression:
+		node.Scope = &ast.Scope{}
+		yolo := newScope(sc, node.Scope, false)
+		yolo.yolo = true
+		r.block(node.Body, yolo)
+
+	case *ast.YoyoExpression:
+		node.Scope = &ast.Scope{}
+		loop := newScope(sc, node.Scope, true)
+		r.expr(node.Condition, loop)
+		r.block(node.Body, loop)
+		loop.finish()
+
+	case *ast.YallExpression:
+		r.expr(node.Iterable, sc)
+		node.Scope = &ast.Scope{}
+		loop := newScope(sc, node.Scope, false)
+		if node.IndexName != "" {
+			loop.declare(node.IndexName)
+		}
+		loop.declare(node.ElemName)
+		r.block(node.Body, loop)
+
+	case *ast.YtryExpression:
+		r.block(node.Body, sc)
+		node.CatchScope = &ast.Scope{}
+		catch := newScope(sc, node.CatchScope, false)
+		catch.declare(node.ErrName)
+		r.block(node.Catch, catch)
+
+	case *ast.YmatchExpression:
+		r.expr(node.Subject, sc)
+		for _, arm := range node.Arms {
+			arm.Scope = &ast.Scope{}
+			inner := newScope(sc, arm.Scope, true)
+			r.pattern(arm.Pattern, true, inner)
+			if arm.Guard != nil {
+				r.expr(arm.Guard, inner)
+			}
+			r.expr(arm.Body, inner)
+			inner.finish()
+		}
+
+	case *ast.LambdaLiteral:
+		// parameters and variables of the body share the environment of a call
+		node.Scope = &ast.Scope{}
+		fn := newScope(sc, node.Scope, false)
+		fn.lambda = true
+		for _, param := range node.Parameters {
+			fn.declare(param.Value)
+		}
+		if node.Rest != nil {
+			fn.declare(node.Rest.Value)
+			r.refs = append(r.refs, reference{node.Rest, fn})
+		}
+		for _, def := range node.Defaults {
+			if def != nil {
+				r.expr(def, fn)
+			}
+		}
+		if node.Body != nil {
+			node.Body.Scope = &ast.Scope{Shared: true}
+			r.statements(node.Body.Expressions, fn)
+		}
+
+	case *ast.NamedArgument:
+		r.expr(node.Value, sc)
+
+	case *ast.CallExpression:
+		// quoted code isn't run, apart from unquote() calls which are left to be looked up by name
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return
+		}
+		r.expr(node.Function, sc)
+		r.statements(node.Arguments, sc)
+	}
+}
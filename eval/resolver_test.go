@@ -0,0 +1,97 @@
+package eval_test
+
+import (
+	"strings"
+	"testing"
+
+	"yy/ast"
+	"yy/eval"
+	"yy/object"
+)
+
+func TestResolvedScopes(t *testing.T) {
+	tests := []evalTestCase{
+		{"x := 1; { a := x; x := 2; [a, x] }", []int64{1, 2}},
+		{"f := \\n { n := n + 1; n }; f(1)", 2},
+		{"a := \\x { \\y { \\z { x + y + z } } }; a(1)(2)(3)", 6},
+		{"fns := []; yall 0..2 { fns << \\{ yt } }; map(fns, \\f { f() })", []int64{2, 2, 2}},
+		{"fns := []; yall 0..2 { i := yt; fns << \\{ i } }; map(fns, \\f { f() })", []int64{0, 1, 2}},
+		{"counter := \\{ n := 0; \\{ n += 1 } }; c := counter(); c(); c()", 2},
+		{"i := 0; yoyo i < 3 { i += 1 }; i", 3},
+		{"f := \\{ ytry { yikes(\"a\") } ycatch e { e[\"msg\"] } }; f()", "a"},
+		{"yolo { { x = 1; x } }", 1},
+		{"yolo { yif true { x = 1 }; x }", errmsg{"identifier not found: x"}},
+		{"yolo { f := \\{ y = 5; y }; f() }", 5},
+		{"x := 1; yolo { f := \\{ x = 5 }; f() }; x", 5},
+		{"twice := @\\e { quote(unquote(e) + \\{ unquote(e) }()) }; f := \\x { twice(x) }; f(2)", 4},
+		{"add := \\a b { c := a + b; c }; yolo { add5 := add + %{ \"b\": 5 }; add5(1) }", 6},
+	}
+
+	runEvalTests(t, tests)
+}
+
+// TestVariablesDeclaredLater checks code that uses variables before they're declared, which the
+// resolver handles by falling back to looking them up by name. The vm decides where variables live
+// when compiling and doesn't support it.
+func TestVariablesDeclaredLater(t *testing.T) {
+	tests := []evalTestCase{
+		{"outer := \\{ f := \\{ g() }; g := \\{ 5 }; f() }; outer()", 5},
+		{"x := 1; { f := \\{ x }; a := f(); x := 2; [a, f()] }", []int64{1, 2}},
+		{"x := 1; f := \\{ y := x; x := 2; [y, x] }; f()", []int64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		for _, evaluated := range []object.Object{testEval(t, tt.input), testEvalUnresolved(t, tt.input)} {
+			if err := testObject(evaluated, tt.expected); err != nil {
+				t.Errorf("%s (%s)", err, tt.input)
+			}
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	program := parseProgram(t, "top := 1; f := \\a { b := a; { c := b + top; yif c { c } }; yolo { d = 1 } }")
+	eval.Resolve(program)
+
+	lambda := program.Expressions[1].(*ast.DeclareExpression).Value.(*ast.LambdaLiteral)
+	body := lambda.Body.Expressions
+	declB := body[0].(*ast.DeclareExpression)
+	block := body[1].(*ast.BlockExpression)
+	declC := block.Expressions[0].(*ast.DeclareExpression)
+	sum := declC.Value.(*ast.InfixExpression)
+	yif := block.Expressions[1].(*ast.YifExpression)
+	yolo := body[2].(*ast.YoloExpression)
+
+	if names := strings.Join(lambda.Scope.Names, " "); names != "a b" {
+		t.Errorf("wrong variables of the lambda: %q", names)
+	}
+	if !lambda.Body.Scope.Shared || !yif.Consequence.Scope.Shared || block.Scope.Shared {
+		t.Errorf("wrong scopes shared")
+	}
+
+	tests := []struct {
+		ident      *ast.Identifier
+		resolution ast.Resolution
+		scope      *ast.Scope
+		depth      int
+		slot       int
+	}{
+		{program.Expressions[0].(*ast.DeclareExpression).Name, ast.Global, nil, 0, 0},
+		{declB.Name, ast.Local, lambda.Scope, 0, 1},
+		{declB.Value.(*ast.Identifier), ast.Local, lambda.Scope, 0, 0},
+		{declC.Name, ast.Local, block.Scope, 0, 0},
+		{sum.Left.(*ast.Identifier), ast.Local, lambda.Scope, 1, 1},
+		{sum.Right.(*ast.Identifier), ast.Global, nil, 0, 0},
+		{yif.Condition.(*ast.Identifier), ast.Local, block.Scope, 0, 0},
+		{yif.Consequence.Expressions[0].(*ast.Identifier), ast.Local, block.Scope, 0, 0},
+		{yolo.Body.Expressions[0].(*ast.AssignExpression).Left.(*ast.Identifier), ast.Unresolved, nil, 0, 0},
+	}
+
+	for _, tt := range tests {
+		ident := tt.ident
+		if ident.Resolution != tt.resolution || ident.Scope != tt.scope || ident.Depth != tt.depth || ident.Slot != tt.slot {
+			t.Errorf("%s: wrong resolution. expected=%d (depth %d, slot %d), got=%d (depth %d, slot %d)",
+				ident.Value, tt.resolution, tt.depth, tt.slot, ident.Resolution, ident.Depth, ident.Slot)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package eval
 
 import (
+	"math"
 	"strconv"
 	"strings"
 
@@ -36,17 +37,10 @@ func yoloPrefixExpression(op string, right object.Object) object.Object {
 			return object.TRUE
 
 		case *object.Hashmap:
-			newHash := &object.Hashmap{
-				Pairs: map[object.HashKey]object.HashPair{},
-			}
-
-			for _, pair := range right.Pairs {
+			newHash := object.NewHashmap(right.Len())
+			for _, pair := range right.Pairs() {
 				if hashable, ok := pair.Value.(object.Hashable); ok {
-					key := hashable.HashKey()
-					newHash.Pairs[key] = object.HashPair{
-						Key:   pair.Value,
-						Value: pair.Key,
-					}
+					newHash.Set(hashable, pair.Key)
 				}
 			}
 
@@ -70,6 +64,9 @@ func yoloPrefixExpression(op string, right object.Object) object.Object {
 
 			return &object.Lambda{
 				Parameters: right.Parameters,
+				Defaults:   right.Defaults,
+				Rest:       right.Rest,
+				BakedRest:  right.BakedRest,
 				Env:        right.Env,
 				Body:       newBody,
 			}
@@ -98,7 +95,11 @@ func yoloInfixExpression(op string, left, right object.Object) object.Object {
 				Elements: make([]object.Object, len(right.Elements)),
 			}
 			for i, v := range right.Elements {
-				result.Elements[i] = evalInfixExpression(op, left, v, true)
+				elt := evalInfixExpression(op, left, v, true)
+				if isError(elt) {
+					return elt
+				}
+				result.Elements[i] = elt
 			}
 			return result
 
@@ -185,6 +186,9 @@ func yoloInfixExpression(op string, left, right object.Object) object.Object {
 		case "*":
 			return &object.Range{Start: rng.Start * intVal, End: rng.End * intVal}
 		case "/":
+			if intVal == 0 {
+				return newErrorWithoutPos("division by zero")
+			}
 			return &object.Range{Start: rng.Start / intVal, End: rng.End / intVal}
 		}
 
@@ -200,21 +204,27 @@ func yoloInfixExpression(op string, left, right object.Object) object.Object {
 		case "*":
 			return &object.Range{Start: intVal * rng.Start, End: intVal * rng.End}
 		case "/":
+			if rng.Start == 0 || rng.End == 0 {
+				return newErrorWithoutPos("division by zero")
+			}
 			return &object.Range{Start: intVal / rng.Start, End: intVal / rng.End}
 		}
 
-	case left.Type() == object.FUNCTION_OBJ && right.Type() == object.FUNCTION_OBJ:
+	case isLambda(left) && isLambda(right):
 		fn := left.(*object.Lambda)
 		right := right.(*object.Lambda)
 
+		// result of the left function is passed to the right one, if it takes any args
+		var first ast.Expression = fn.Body
+		if len(right.Parameters) > 0 {
+			first = &ast.DeclareExpression{
+				Name:  right.Parameters[0],
+				Value: fn.Body,
+			}
+		}
+
 		newBody := &ast.BlockExpression{
-			Expressions: []ast.Expression{
-				&ast.DeclareExpression{
-					Name:  right.Parameters[0],
-					Value: fn.Body,
-				},
-				right.Body,
-			},
+			Expressions: []ast.Expression{first, right.Body},
 		}
 
 		extendedEnv := object.NewEnclosedEnvironment(fn.Env)
@@ -224,19 +234,22 @@ func yoloInfixExpression(op string, left, right object.Object) object.Object {
 
 		return &object.Lambda{
 			Parameters: fn.Parameters,
+			Defaults:   fn.Defaults,
+			Rest:       fn.Rest,
+			BakedRest:  fn.BakedRest,
 			Env:        extendedEnv,
 			Body:       newBody,
 		}
 
-	case left.Type() == object.FUNCTION_OBJ && op == "+":
+	case isLambda(left) && op == "+":
 		fn := left.(*object.Lambda)
 		return bakeArgs(fn, right)
 
-	case right.Type() == object.FUNCTION_OBJ && op == "+":
+	case isLambda(right) && op == "+":
 		fn := right.(*object.Lambda)
 		return bakeArgs(fn, left)
 
-	case left.Type() == object.FUNCTION_OBJ:
+	case isLambda(left):
 		fn := left.(*object.Lambda)
 		newBody := &ast.BlockExpression{
 			Expressions: []ast.Expression{
@@ -250,11 +263,14 @@ func yoloInfixExpression(op string, left, right object.Object) object.Object {
 
 		return &object.Lambda{
 			Parameters: fn.Parameters,
+			Defaults:   fn.Defaults,
+			Rest:       fn.Rest,
+			BakedRest:  fn.BakedRest,
 			Env:        fn.Env,
 			Body:       newBody,
 		}
 
-	case right.Type() == object.FUNCTION_OBJ:
+	case isLambda(right):
 		fn := right.(*object.Lambda)
 		newBody := &ast.BlockExpression{
 			Expressions: []ast.Expression{
@@ -268,6 +284,9 @@ func yoloInfixExpression(op string, left, right object.Object) object.Object {
 
 		return &object.Lambda{
 			Parameters: fn.Parameters,
+			Defaults:   fn.Defaults,
+			Rest:       fn.Rest,
+			BakedRest:  fn.BakedRest,
 			Env:        fn.Env,
 			Body:       newBody,
 		}
@@ -277,46 +296,122 @@ func yoloInfixExpression(op string, left, right object.Object) object.Object {
 	return &object.String{Value: left.String() + right.String()}
 }
 
+// repeatedLength is the length of the longest string yolo mode builds by repeating a string when
+// applying op to left and right, or 0 if it doesn't repeat any.
+func repeatedLength(op string, left, right object.Object) int {
+	if op != "*" {
+		return 0
+	}
+
+	switch l := left.(type) {
+	case *object.String, *object.Array:
+		if _, ok := right.(*object.Integer); ok {
+			return repeatedLength(op, right, l)
+		}
+	case *object.Integer:
+		switch r := right.(type) {
+		case *object.Array:
+			longest := 0
+			for _, elt := range r.Elements {
+				longest = max(longest, repeatedLength(op, l, elt))
+			}
+			return longest
+
+		case *object.String:
+			if _, err := strconv.Atoi(r.Value); err == nil || l.Value <= 0 {
+				return 0
+			}
+			if _, ok := collectiveNouns[strings.TrimSpace(r.Value)]; ok {
+				return 0
+			}
+			if len(r.Value) > 0 && l.Value > int64(math.MaxInt/len(r.Value)) {
+				return math.MaxInt
+			}
+			return len(r.Value) * int(l.Value)
+		}
+	}
+	return 0
+}
+
+// isLambda reports whether obj is a function created by the tree-walking evaluator. Only those can
+// be rewritten by the yolo rules above, as they carry their own source code.
+func isLambda(obj object.Object) bool {
+	_, ok := obj.(*object.Lambda)
+	return ok
+}
+
 func bakeArgs(fn *object.Lambda, right object.Object) *object.Lambda {
 	extendedEnv := object.NewEnclosedEnvironment(fn.Env)
 	newParams := []*ast.Identifier{}
+	var newDefaults []ast.Expression
+	bakedRest := append([]object.Object{}, fn.BakedRest...)
+	var extra []object.Object
 
 	switch right := right.(type) {
 	case *object.Hashmap:
-		for _, p := range fn.Parameters {
-			key := (&object.String{Value: p.Value}).HashKey()
-			if val, ok := right.Pairs[key]; ok {
-				extendedEnv.Set(p.Value, val.Value)
+		for i, p := range fn.Parameters {
+			if val, ok := right.Get(&object.String{Value: p.Value}); ok {
+				extendedEnv.Set(p.Value, val)
 			} else {
 				newParams = append(newParams, p)
+				if fn.Defaults != nil {
+					newDefaults = append(newDefaults, fn.Defaults[i])
+				}
+			}
+		}
+		if fn.Rest != nil {
+			if val, ok := right.Get(&object.String{Value: fn.Rest.Value}); ok {
+				extra = restArgs(val)
 			}
 		}
 
 	case *object.Array:
-		numSet := 0
-		for i, v := range right.Elements {
-			if i >= len(fn.Parameters) {
-				break
-			}
+		numSet := min(len(right.Elements), len(fn.Parameters))
+		for i, v := range right.Elements[:numSet] {
 			extendedEnv.Set(fn.Parameters[i].Value, v)
-			numSet++
 		}
 		newParams = fn.Parameters[numSet:]
+		if fn.Defaults != nil {
+			newDefaults = fn.Defaults[numSet:]
+		}
+		extra = right.Elements[numSet:]
 
 	default:
 		if len(fn.Parameters) > 0 {
 			extendedEnv.Set(fn.Parameters[0].Value, right)
 			newParams = fn.Parameters[1:]
+			if fn.Defaults != nil {
+				newDefaults = fn.Defaults[1:]
+			}
+		} else {
+			extra = []object.Object{right}
 		}
 	}
 
+	// args left over go to the rest parameter, functions without one ignore them
+	if fn.Rest != nil {
+		bakedRest = append(bakedRest, extra...)
+	}
+
 	return &object.Lambda{
 		Parameters: newParams,
+		Defaults:   newDefaults,
+		Rest:       fn.Rest,
+		BakedRest:  bakedRest,
 		Env:        extendedEnv,
 		Body:       fn.Body,
+		Name:       fn.Name,
 	}
 }
 
+// restArgs yeets args baked into a rest parameter by name: elements of an array, or a single value.
+func restArgs(val object.Object) []object.Object {
+	if arr, ok := val.(*object.Array); ok {
+		return arr.Elements
+	}
+	return []object.Object{val}
+}
+
 func rot13(ch rune) rune {
 	switch {
 	case 'A' <= ch && ch <= 'M', 'a' <= ch && ch <= 'm':
@@ -336,6 +431,13 @@ func objectToAST(obj object.Object) ast.Expression {
 		}
 		return &ast.IntegerLiteral{Token: tok, Value: obj.Value}
 
+	case *object.BigInteger:
+		tok := token.Token{
+			Type:    token.INT,
+			Literal: obj.Value.String(),
+		}
+		return &ast.IntegerLiteral{Token: tok, Big: obj.Value}
+
 	case *object.Boolean:
 		var tok token.Token
 		if obj.Value {
@@ -345,6 +447,49 @@ func objectToAST(obj object.Object) ast.Expression {
 		}
 		return &ast.BooleanLiteral{Token: tok, Value: obj.Value}
 
+	case *object.Number:
+		tok := token.Token{
+			Type:    token.NUMBER,
+			Literal: strconv.FormatFloat(obj.Value, 'f', -1, 64),
+		}
+		return &ast.NumberLiteral{Token: tok, Value: obj.Value}
+
+	case *object.String:
+		tok := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: tok, Value: obj.Value}
+
+	case *object.Null:
+		return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}
+
+	case *object.Range:
+		return &ast.RangeLiteral{
+			Token: token.Token{Type: token.RANGE, Literal: ".."},
+			Start: objectToAST(&object.Integer{Value: obj.Start}),
+			End:   objectToAST(&object.Integer{Value: obj.End}),
+		}
+
+	case *object.Array:
+		elts := make([]ast.Expression, len(obj.Elements))
+		for i, v := range obj.Elements {
+			elt := objectToAST(v)
+			if elt == nil {
+				return nil
+			}
+			elts[i] = elt
+		}
+		return &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "["}, Elements: elts}
+
+	case *object.Hashmap:
+		pairs := make([]ast.HashmapPair, 0, obj.Len())
+		for _, pair := range obj.Pairs() {
+			key, val := objectToAST(pair.Key), objectToAST(pair.Value)
+			if key == nil || val == nil {
+				return nil
+			}
+			pairs = append(pairs, ast.HashmapPair{Key: key, Value: val})
+		}
+		return &ast.HashmapLiteral{Token: token.Token{Type: token.HASHMAP, Literal: "%{"}, Pairs: pairs}
+
 	case *object.Quote:
 		return obj.Node
 
@@ -105,6 +105,22 @@ func TestYoloInfixExpressions(t *testing.T) {
 
 func TestYoloInfixFunctionObject(t *testing.T) {
 	runEvalTests(t, []evalTestCase{
+		{
+			`yolo {
+				fn  := \a, b = 1 { a + b }
+				fn2 := fn * 2;
+				[fn2(3), fn2(3, 2), fn2(b: 0, a: 5)]
+			}`,
+			[]int64{8, 10, 10},
+		},
+		{
+			`yolo {
+				fn  := \...xs { len(xs) }
+				fn2 := fn * 10
+				fn2(1, 2, 3)
+			}`,
+			30,
+		},
 		{
 			`yolo { 
 				fn  := \a b { yif a > b { a } yels { b } }
@@ -158,6 +174,8 @@ func TestYoloInfixFunctionObject(t *testing.T) {
 
 func TestYoloFunctionAdding(t *testing.T) {
 	runEvalTests(t, []evalTestCase{
+		{`yolo { f := \{ 1 } + \{ 2 }; f() }`, 2},
+		{`yolo { f := \a { a + 1 } + \{ 2 }; f(5) }`, 2},
 		{
 			`
 			add3 := \a { a + 3 }
@@ -389,6 +407,56 @@ func TestYoloArgumentsBaking(t *testing.T) {
 			6,
 		},
 
+		// default, rest and named parameters
+		{
+			`yolo {
+				add  := \a, b = 5 { a + b }
+				add1 := add + %{ "a": 1 };
+				[add1(), add1(2)]
+			}`,
+			[]int64{6, 3},
+		},
+		{
+			`yolo {
+				digits := \a, b, c { a * 100 + b * 10 + c }
+				with1  := digits + %{ "a": 1 }
+				with1(c: 3, b: 2)
+			}`,
+			123,
+		},
+		{
+			`yolo {
+				count   := \a, ...rest { a + len(rest) }
+				count10 := count + [10]
+				count10(1, 2, 3)
+			}`,
+			13,
+		},
+		{
+			`yolo {
+				greet   := \name, message = "hi", ...rest { [name, message] + rest }
+				greet_a := greet + ["A", "B", "C", "D"]
+				greet_a("E")
+			}`,
+			[]string{"A", "B", "C", "D", "E"},
+		},
+		{
+			`yolo {
+				count  := \a, ...rest { a + len(rest) }
+				count3 := count + %{ "a": 10, "rest": [1, 2] } + [3]
+				count3(4)
+			}`,
+			14,
+		},
+		{
+			`yolo {
+				add  := \a, b { a + b }
+				add1 := add + %{ "a": 1 }
+				add1(a: 2)
+			}`,
+			errmsg{"no parameter called a for add1"},
+		},
+
 		// null
 		{
 			`yolo {
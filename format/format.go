@@ -0,0 +1,800 @@
+// Package format prints YY source code in its canonical style. It's what 'yy fmt' is built on.
+//
+// The formatter works on the syntax tree, so the layout of the code is mostly decided by it, with
+// a few exceptions taken from the source: comments, single blank lines between expressions, and
+// whether blocks, calls, arrays and hashmaps are written on one line or spread over many.
+package format
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"yy/ast"
+	"yy/lexer"
+	"yy/parser"
+	"yy/token"
+	"yy/yikes"
+)
+
+const indentation = "    "
+
+// Markers left in the output where consecutive lines get aligned, replaced with padding at the end.
+const (
+	declMarker    = '\x01' // before ':=' in a declaration
+	keyMarker     = '\x02' // after ':' in a hashmap entry
+	commentMarker = '\x03' // before a comment following code on the same line
+)
+
+// Source formats YY source code. It returns the first syntax error, as a *yikes.YYError, if the
+// code doesn't parse.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if errs := p.Errors(); len(errs) > 0 {
+		err := errs[0]
+		return nil, &err
+	}
+
+	pr := &printer{src: string(src), comments: l.Comments, parenthesized: p.Parenthesized}
+	pr.statements(program.Expressions, len(src))
+	if pr.err != nil {
+		return nil, pr.err
+	}
+
+	out := align(string(pr.buf))
+	if out == "" {
+		return []byte{}, nil
+	}
+	return []byte(out + "\n"), nil
+}
+
+type printer struct {
+	src           string
+	comments      []token.Token
+	next          int // index of the first comment that hasn't been printed yet
+	parenthesized func(ast.Expression) bool
+
+	buf     []byte
+	indent  int
+	pending bool // whether the current line still needs indenting
+	opened  bool // whether a bracket was just opened, no blank lines are kept after it
+	err     error
+}
+
+func (p *printer) write(s string) {
+	if p.pending && s != "" {
+		for i := 0; i < p.indent; i++ {
+			p.buf = append(p.buf, indentation...)
+		}
+		p.pending = false
+	}
+	p.buf = append(p.buf, s...)
+}
+
+// writeLabel writes the label of a loop, if it has one.
+func (p *printer) writeLabel(label string) {
+	if label != "" {
+		p.write("@" + label + " ")
+	}
+}
+
+// newline starts a new line, preceded by a blank one if there's one in the source before offset.
+func (p *printer) newline(offset int) {
+	if len(p.buf) == 0 {
+		return
+	}
+	if p.blankLineBefore(offset) && !p.opened {
+		p.buf = append(p.buf, '\n')
+	}
+	p.buf = append(p.buf, '\n')
+	p.pending = true
+	p.opened = false
+}
+
+// open writes an opening bracket of something spread over many lines.
+func (p *printer) open(bracket string) {
+	p.write(bracket)
+	p.indent++
+	p.opened = true
+}
+
+// close writes a closing bracket at offset in the source on a separate line, after the comments
+// before it.
+func (p *printer) close(bracket string, offset int) {
+	p.flushComments(offset)
+	p.indent--
+	p.opened = false
+	p.buf = append(p.buf, '\n')
+	p.pending = true
+	p.write(bracket)
+}
+
+// blankLineBefore reports whether offset starts a line in the source, and the line above is blank.
+func (p *printer) blankLineBefore(offset int) bool {
+	start := strings.LastIndexByte(p.src[:offset], '\n') + 1
+	if strings.TrimSpace(p.src[start:offset]) != "" || start == 0 {
+		return false
+	}
+
+	prev := strings.LastIndexByte(p.src[:start-1], '\n') + 1
+	return strings.TrimSpace(p.src[prev:start-1]) == ""
+}
+
+// followsCode reports whether there's anything but whitespace before offset on its line.
+func (p *printer) followsCode(offset int) bool {
+	start := strings.LastIndexByte(p.src[:offset], '\n') + 1
+	return strings.TrimSpace(p.src[start:offset]) != ""
+}
+
+func (p *printer) hasNewline(from, to int) bool {
+	return from < to && strings.ContainsRune(p.src[from:to], '\n')
+}
+
+// flushComments prints the comments found in the source before offset. It's only called when a
+// new line is about to begin, so nothing gets swallowed by a comment.
+func (p *printer) flushComments(offset int) {
+	for p.next < len(p.comments) && p.comments[p.next].Offset < offset {
+		c := p.comments[p.next]
+		p.next++
+
+		if p.followsCode(c.Offset) && !p.lineHasComment() && len(p.buf) > 0 {
+			p.write(string(commentMarker) + " " + c.Literal)
+			continue
+		}
+
+		p.newline(c.Offset)
+		p.write(c.Literal)
+	}
+}
+
+func (p *printer) lineHasComment() bool {
+	line := p.buf[strings.LastIndexByte(string(p.buf), '\n')+1:]
+	return strings.IndexByte(string(line), commentMarker) >= 0 || strings.HasPrefix(strings.TrimSpace(string(line)), "//")
+}
+
+// statements prints each expression on its own line, followed by the comments found before end.
+func (p *printer) statements(exprs []ast.Expression, end int) {
+	prevEnd := -1
+	for _, expr := range exprs {
+		start := startOf(expr)
+		for start > 0 && strings.IndexByte("( \t", p.src[start-1]) >= 0 {
+			start-- // parentheses around the expression aren't in the tree
+		}
+		p.flushComments(start)
+		p.newline(start)
+
+		mark := len(p.buf)
+		p.statement(expr)
+
+		// newlines are mostly insignificant, so '(', '-' and '[' would continue the previous
+		// expression ('[' doesn't when it starts a pattern that can't be an index)
+		continues := "(-["
+		if destructure, ok := expr.(*ast.DestructureExpression); ok && !couldBeIndex(destructure) {
+			continues = "(-"
+		}
+		if text := strings.TrimLeft(string(p.buf[mark:]), " "); prevEnd >= 0 && text != "" && strings.IndexByte(continues, text[0]) >= 0 {
+			p.buf = append(p.buf[:prevEnd], append([]byte{';'}, p.buf[prevEnd:]...)...)
+		}
+		prevEnd = len(p.buf)
+	}
+	p.flushComments(end)
+}
+
+// couldBeIndex reports whether the pattern of node, at the start of a line, would be parsed as an
+// index of the line above: it's assigned to and has a single element.
+func couldBeIndex(node *ast.DestructureExpression) bool {
+	pattern, ok := node.Pattern.(*ast.ArrayPattern)
+	return ok && !node.Declare() && len(pattern.Elements) == 1 && pattern.Rest == nil
+}
+
+// statement prints an expression that's on a line of its own. The '=' of declarations and
+// assignments is aligned with the ones on neighbouring lines.
+func (p *printer) statement(expr ast.Expression) {
+	if p.parenthesized(expr) {
+		p.expr(expr)
+		return
+	}
+
+	switch node := expr.(type) {
+	case *ast.DeclareExpression:
+		p.write(node.Name.Value)
+		marker := len(p.buf)
+		p.write(string(declMarker) + " := ")
+		p.expr(node.Value)
+		p.dropMarkerIfMultiline(marker)
+
+	case *ast.AssignExpression:
+		if _, ok := node.Left.(*ast.Identifier); !ok || node.Token.Type != token.ASSIGN {
+			p.expr(expr)
+			return
+		}
+		p.expr(node.Left)
+		marker := len(p.buf)
+		p.write(string(declMarker) + " = ")
+		p.expr(node.Value)
+		p.dropMarkerIfMultiline(marker)
+
+	default:
+		p.expr(expr)
+	}
+}
+
+// dropMarkerIfMultiline removes the alignment marker at idx if a newline was printed after it, eg
+// a lambda is declared. Aligning the first line of it with one-liners around looks odd.
+func (p *printer) dropMarkerIfMultiline(idx int) {
+	if strings.IndexByte(string(p.buf[idx:]), '\n') >= 0 {
+		p.buf = append(p.buf[:idx], p.buf[idx+1:]...)
+	}
+}
+
+// expr prints an expression, keeping parentheses around it if it has them in the source. Those are
+// all the parentheses needed, the code is printed in the same order it was parsed in.
+func (p *printer) expr(expr ast.Expression) {
+	if p.parenthesized(expr) {
+		p.write("(")
+		p.node(expr)
+		p.write(")")
+		return
+	}
+	p.node(expr)
+}
+
+func (p *printer) node(expr ast.Expression) {
+	switch node := expr.(type) {
+	case *ast.Identifier:
+		p.write(node.Value)
+
+	case *ast.IntegerLiteral, *ast.NumberLiteral, *ast.BooleanLiteral, *ast.NullLiteral:
+		p.write(node.TokenLiteral())
+
+	case *ast.StringLiteral:
+		p.write(`"` + escape(node.Value) + `"`)
+
+	case *ast.TemplateStringLiteral:
+		p.template(node)
+
+	case *ast.DeclareExpression:
+		p.write(node.Name.Value + " := ")
+		p.expr(node.Value)
+
+	case *ast.AssignExpression:
+		p.assign(node)
+
+	case *ast.DestructureExpression:
+		p.expr(node.Pattern)
+		p.write(" " + node.Token.Literal + " ")
+		p.expr(node.Value)
+
+	case *ast.ArrayPattern:
+		p.write("[")
+		for i, el := range node.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.patternElement(el)
+		}
+		if node.Rest != nil {
+			if len(node.Elements) > 0 {
+				p.write(", ")
+			}
+			p.write("...")
+			p.expr(node.Rest)
+		}
+		p.write("]")
+
+	case *ast.HashmapPattern:
+		if len(node.Pairs) == 0 {
+			p.write("%{}")
+			return
+		}
+		p.write("%{ ")
+		for i, pair := range node.Pairs {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(pair.Key)
+			p.write(": ")
+			p.patternElement(pair)
+		}
+		p.write(" }")
+
+	case *ast.TypePattern:
+		p.write(node.Type + "(")
+		p.expr(node.Target)
+		p.write(")")
+
+	case *ast.YeetExpression:
+		p.write("yeet ")
+		p.expr(node.ReturnValue)
+
+	case *ast.YbreakExpression:
+		p.write("ybreak")
+		if node.Label != "" {
+			p.write(" @" + node.Label)
+		}
+		if node.Value != nil {
+			p.write(" ")
+			p.expr(node.Value)
+		}
+
+	case *ast.YcontinueExpression:
+		p.write("ycontinue")
+		if node.Label != "" {
+			p.write(" @" + node.Label)
+		}
+
+	case *ast.PrefixExpression:
+		p.write(node.Operator)
+		p.expr(node.Right)
+
+	case *ast.InfixExpression:
+		p.infix(node.Left, node.Operator, node.Right)
+
+	case *ast.PipeExpression:
+		p.pipe(node)
+
+	case *ast.AndExpression:
+		p.infix(node.Left, "&&", node.Right)
+
+	case *ast.OrExpression:
+		p.infix(node.Left, "||", node.Right)
+
+	case *ast.RangeLiteral:
+		p.expr(node.Start)
+		p.write("..")
+		p.expr(node.End)
+
+	case *ast.IndexExpression:
+		p.expr(node.Left)
+		p.write("[")
+		p.expr(node.Index)
+		p.write("]")
+
+	case *ast.DotExpression:
+		p.expr(node.Left)
+		p.write("." + node.Name)
+
+	case *ast.CallExpression:
+		p.expr(node.Function)
+		p.list("(", ")", node.Token.Offset, node.Rparen, node.Arguments)
+
+	case *ast.NamedArgument:
+		p.write(node.Name + ": ")
+		p.expr(node.Value)
+
+	case *ast.ArrayLiteral:
+		p.list("[", "]", node.Token.Offset, node.Rbracket, node.Elements)
+
+	case *ast.HashmapLiteral:
+		p.hashmap(node)
+
+	case *ast.BlockExpression:
+		p.block(node)
+
+	case *ast.LambdaLiteral:
+		p.write(`\`)
+		p.params(node.Parameters, node.Defaults, node.Rest)
+		p.block(node.Body)
+
+	case *ast.MacroLiteral:
+		p.write(`@\`)
+		p.params(node.Parameters, nil, nil)
+		p.block(node.Body)
+
+	case *ast.YifExpression:
+		p.yif(node)
+
+	case *ast.YoloExpression:
+		p.write("yolo ")
+		p.block(node.Body)
+
+	case *ast.YoyoExpression:
+		p.writeLabel(node.Label)
+		p.write("yoyo ")
+		if node.Condition.Pos() != node.Body.Pos() { // 'yoyo {' has no condition in the source
+			p.expr(node.Condition)
+			p.write(" ")
+		}
+		p.block(node.Body)
+
+	case *ast.YallExpression:
+		p.writeLabel(node.Label)
+		p.write("yall ")
+		head := p.src[node.Token.Offset+len("yall") : startOf(node.Iterable)]
+		if node.IndexName != "" {
+			p.write(node.IndexName + ", ")
+		}
+		if strings.HasSuffix(strings.TrimSpace(head), ":") {
+			p.write(node.ElemName + ": ")
+		}
+		p.expr(node.Iterable)
+		p.write(" ")
+		p.block(node.Body)
+
+	case *ast.YtryExpression:
+		p.write("ytry ")
+		p.block(node.Body)
+		p.write(" ycatch ")
+		head := strings.Fields(p.src[node.Body.Rbrace+1 : node.Catch.Pos()])
+		if len(head) > 1 { // the name of the error is optional
+			p.write(node.ErrName + " ")
+		}
+		p.block(node.Catch)
+
+	case *ast.YmatchExpression:
+		p.write("ymatch ")
+		p.expr(node.Subject)
+		p.write(" ")
+		p.ymatch(node)
+
+	case *ast.ImportExpression:
+		p.write(`yimport "` + escape(node.Path) + `"`)
+
+	default:
+		p.fail(expr, "cannot format %T", expr)
+	}
+}
+
+func (p *printer) fail(expr ast.Expression, format string, args ...any) {
+	if p.err == nil {
+		p.err = &yikes.YYError{Msg: fmt.Sprintf(format, args...), Offset: expr.Pos()}
+	}
+}
+
+func (p *printer) infix(left ast.Expression, op string, right ast.Expression) {
+	p.expr(left)
+	p.write(" " + op + " ")
+	p.expr(right)
+}
+
+// pipe prints a pipeline, keeping stages that begin lines in the source on separate lines, indented
+// under the first one.
+func (p *printer) pipe(node *ast.PipeExpression) {
+	stages := []*ast.PipeExpression{node}
+	for {
+		left, ok := stages[0].Left.(*ast.PipeExpression)
+		if !ok || p.parenthesized(left) {
+			break
+		}
+		stages = append([]*ast.PipeExpression{left}, stages...)
+	}
+
+	p.expr(stages[0].Left)
+	indented := false
+	for _, stage := range stages {
+		if p.followsCode(stage.Token.Offset) {
+			p.write(" |> ")
+		} else {
+			if !indented {
+				p.indent++
+				indented = true
+			}
+			p.flushComments(stage.Token.Offset)
+			p.newline(stage.Token.Offset)
+			p.write("|> ")
+		}
+		p.expr(stage.Right)
+	}
+	if indented {
+		p.indent--
+	}
+}
+
+func (p *printer) assign(node *ast.AssignExpression) {
+	p.expr(node.Left)
+
+	// 'a += 1' is desugared into 'a = a + 1' by the parser, the original form is kept
+	if infix, ok := node.Value.(*ast.InfixExpression); ok && node.Token.Type != token.ASSIGN && infix.Left == node.Left {
+		p.write(" " + node.Token.Literal + " ")
+		p.expr(infix.Right)
+		return
+	}
+
+	p.write(" = ")
+	p.expr(node.Value)
+}
+
+func (p *printer) patternElement(el ast.PatternElement) {
+	p.expr(el.Target)
+	if el.Default != nil {
+		p.write(" = ")
+		p.expr(el.Default)
+	}
+}
+
+func (p *printer) template(node *ast.TemplateStringLiteral) {
+	parts := strings.Split(node.Template, "%s")
+	if len(parts) != len(node.Values)+1 {
+		p.fail(node, "cannot format template string containing '%%s'")
+		return
+	}
+
+	p.write(`"` + escape(parts[0]))
+	for i, value := range node.Values {
+		p.write("{")
+		p.expr(value)
+		p.write("}" + escape(parts[i+1]))
+	}
+	p.write(`"`)
+}
+
+func escape(s string) string {
+	s = strings.ReplaceAll(s, "{", "{{")
+	return strings.ReplaceAll(s, "}", "}}")
+}
+
+func (p *printer) params(params []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier) {
+	for i, param := range params {
+		p.write(param.Value + " ")
+		if def := ast.DefaultOf(defaults, i); def != nil {
+			p.write("= ")
+			p.expr(def)
+			p.write(" ")
+		}
+	}
+	if rest != nil {
+		p.write("..." + rest.Value + " ")
+	}
+}
+
+func (p *printer) yif(node *ast.YifExpression) {
+	p.write("yif ")
+	p.expr(node.Condition)
+	p.write(" ")
+	p.block(node.Consequence)
+
+	if node.Alternative == nil {
+		return
+	}
+
+	p.write(" yels ")
+
+	// 'yels yif' is parsed into a block holding just the nested yif
+	if node.Alternative.Token.Type == 0 && len(node.Alternative.Expressions) == 1 {
+		if nested, ok := node.Alternative.Expressions[0].(*ast.YifExpression); ok {
+			p.yif(nested)
+			return
+		}
+	}
+	p.block(node.Alternative)
+}
+
+// block prints a block on a single line if it's written that way in the source.
+func (p *printer) block(node *ast.BlockExpression) {
+	start, end := node.Pos(), node.Rbrace
+	multiline := p.hasNewline(start, end)
+
+	if len(node.Expressions) == 0 && !(multiline && p.hasComments(end)) {
+		p.write("{}")
+		return
+	}
+
+	if !multiline {
+		p.write("{ ")
+		for i, expr := range node.Expressions {
+			if i > 0 {
+				p.write("; ")
+			}
+			p.expr(expr)
+		}
+		p.write(" }")
+		return
+	}
+
+	p.open("{")
+	p.statements(node.Expressions, end)
+	p.close("}", end)
+}
+
+func (p *printer) hasComments(before int) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Offset < before
+}
+
+// commentsBetween reports whether there are comments between elems, or after the last one, before
+// end. Comments inside of the elements (eg in the body of a lambda) don't count.
+func (p *printer) commentsBetween(elems []ast.Expression, end int) bool {
+	for i := p.next; i < len(p.comments) && p.comments[i].Offset < end; i++ {
+		c := p.comments[i]
+		next := end
+		for _, elem := range elems {
+			if start := startOf(elem); start > c.Offset {
+				next = start
+				break
+			}
+		}
+
+		// only commas, other comments and parentheses around the element may follow the comment
+		rest := strings.TrimLeft(p.src[c.Offset+len(c.Literal):next], " \t\r\n,(")
+		for strings.HasPrefix(rest, "//") {
+			rest = strings.TrimLeft(rest[strings.IndexByte(rest+"\n", '\n'):], " \t\r\n,(")
+		}
+		if rest == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// list prints the elements of an array or the arguments of a call. They are put on separate lines
+// if the first one is on a different line than the opening bracket, or if there are comments
+// between them.
+func (p *printer) list(open, close string, start, end int, elems []ast.Expression) {
+	p.write(open)
+
+	if len(elems) == 0 || !p.hasNewline(start, startOf(elems[0])) && !p.commentsBetween(elems, end) {
+		for i, elem := range elems {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(elem)
+		}
+		p.write(close)
+		return
+	}
+
+	p.open("")
+	for _, elem := range elems {
+		p.flushComments(startOf(elem))
+		p.newline(startOf(elem))
+		p.expr(elem)
+		p.write(",")
+	}
+	p.close(close, end)
+}
+
+func (p *printer) hashmap(node *ast.HashmapLiteral) {
+	if len(node.Pairs) == 0 {
+		p.write("%{}")
+		return
+	}
+
+	if !p.hasNewline(node.Token.Offset, startOf(node.Pairs[0].Key)) {
+		p.write("%{ ")
+		for i, pair := range node.Pairs {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(pair.Key)
+			p.write(": ")
+			p.expr(pair.Value)
+		}
+		p.write(" }")
+		return
+	}
+
+	p.open("%{")
+	for _, pair := range node.Pairs {
+		p.flushComments(startOf(pair.Key))
+		p.newline(startOf(pair.Key))
+		p.expr(pair.Key)
+		p.write(":")
+		marker := len(p.buf)
+		p.write(string(keyMarker) + " ")
+		p.expr(pair.Value)
+		p.write(",")
+		p.dropMarkerIfMultiline(marker)
+	}
+	p.close("}", node.Rbrace)
+}
+
+// ymatch prints the arms of a ymatch, on separate lines if the first one is on a different line than
+// the opening brace.
+func (p *printer) ymatch(node *ast.YmatchExpression) {
+	if len(node.Arms) == 0 {
+		p.write("{}")
+		return
+	}
+
+	if !p.hasNewline(node.Lbrace, startOf(node.Arms[0].Pattern)) {
+		p.write("{ ")
+		for i, arm := range node.Arms {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.arm(arm)
+		}
+		p.write(" }")
+		return
+	}
+
+	p.open("{")
+	for _, arm := range node.Arms {
+		p.flushComments(startOf(arm.Pattern))
+		p.newline(startOf(arm.Pattern))
+		p.arm(arm)
+		p.write(",")
+	}
+	p.close("}", node.Rbrace)
+}
+
+func (p *printer) arm(arm *ast.MatchArm) {
+	p.expr(arm.Pattern)
+	if arm.Guard != nil {
+		p.write(" yif ")
+		p.expr(arm.Guard)
+	}
+	p.write(" => ")
+	p.expr(arm.Body)
+}
+
+// startOf returns the offset where an expression begins in the source.
+func startOf(expr ast.Expression) int {
+	switch node := expr.(type) {
+	case *ast.DeclareExpression:
+		return node.Name.Pos()
+	case *ast.AssignExpression:
+		return startOf(node.Left)
+	case *ast.DestructureExpression:
+		return node.Pattern.Pos()
+	case *ast.InfixExpression:
+		return startOf(node.Left)
+	case *ast.PipeExpression:
+		return startOf(node.Left)
+	case *ast.AndExpression:
+		return startOf(node.Left)
+	case *ast.OrExpression:
+		return startOf(node.Left)
+	case *ast.RangeLiteral:
+		return startOf(node.Start)
+	case *ast.IndexExpression:
+		return startOf(node.Left)
+	case *ast.DotExpression:
+		return startOf(node.Left)
+	case *ast.CallExpression:
+		return startOf(node.Function)
+	case *ast.YoyoExpression:
+		if node.Label != "" {
+			return node.LabelPos
+		}
+	case *ast.YallExpression:
+		if node.Label != "" {
+			return node.LabelPos
+		}
+	case *ast.StringLiteral, *ast.TemplateStringLiteral:
+		return node.Pos() - 1 // the opening quote
+	}
+	return expr.Pos()
+}
+
+// align replaces markers with padding, so that they line up in consecutive lines with the same
+// indentation. Comments are lined up in lines further apart too, as long as those in between are
+// indented differently, eg in a chain of 'yels yif' blocks.
+func align(out string) string {
+	lines := strings.Split(out, "\n")
+	for _, marker := range []byte{declMarker, keyMarker, commentMarker} {
+		for i := 0; i < len(lines); i++ {
+			if strings.IndexByte(lines[i], marker) < 0 {
+				continue
+			}
+
+			group := []int{}
+			for j := i; j < len(lines) && lines[j] != ""; j++ {
+				if indentOf(lines[j]) != indentOf(lines[i]) && marker == commentMarker {
+					continue
+				}
+				if indentOf(lines[j]) != indentOf(lines[i]) || strings.IndexByte(lines[j], marker) < 0 {
+					break
+				}
+				group = append(group, j)
+			}
+
+			width := 0
+			for _, j := range group {
+				width = max(width, utf8.RuneCountInString(lines[j][:strings.IndexByte(lines[j], marker)]))
+			}
+			for _, j := range group {
+				idx := strings.IndexByte(lines[j], marker)
+				pad := strings.Repeat(" ", width-utf8.RuneCountInString(lines[j][:idx]))
+				lines[j] = lines[j][:idx] + pad + lines[j][idx+1:]
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
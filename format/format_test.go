@@ -0,0 +1,188 @@
+package format_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yy"
+	"yy/format"
+	"yy/yikes"
+)
+
+const examplesDir = "../examples"
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"x:=1+2*3", "x := 1 + 2 * 3\n"},
+		{"  yap( 1 ,2 )  ", "yap(1, 2)\n"},
+		{"a+=1; b-=2; c*=3; d/=4; e%=5", "a += 1\nb -= 2\nc *= 3\nd /= 4\ne %= 5\n"},
+		{"x = (a+b)*c", "x = (a + b) * c\n"},
+		{"yif (x) { 1 }", "yif (x) { 1 }\n"},
+		{"-x*-(y)..!z[0]", "-x * -(y)..!z[0]\n"},
+		{"a && b || c << d", "a && b || c << d\n"},
+		{`s := "a {{b}} {x+1} c"`, "s := \"a {{b}} {x + 1} c\"\n"},
+		{`yimport "lib/math.yeet"`, "yimport \"lib/math.yeet\"\n"},
+		{"a.b.c=x.y(1)[2]; a.b+=1", "a.b.c = x.y(1)[2]\na.b += 1\n"},
+		{"x|>f(1)|>g", "x |> f(1) |> g\n"},
+		{"x := nums\n|> map(\\n { n*2 })   // double\n  |> sum", "x := nums\n    |> map(\\n { n * 2 }) // double\n    |> sum\n"},
+		{"(a |> f) |> (g |> h)", "(a |> f) |> (g |> h)\n"},
+		{"[a,[b,c]=[1,2],...d]:=x", "[a, [b, c] = [1, 2], ...d] := x\n"},
+		{`%{"n":n,"a":a=1}:=p; [h[0],x.y]=[1,2]`, "%{ \"n\": n, \"a\": a = 1 } := p\n[h[0], x.y] = [1, 2]\n"},
+		{"f()\n[a, b] := x", "f()\n[a, b] := x\n"},
+
+		// optional commas in parameters
+		{`add := \a, b {a+b}`, "add := \\a b { a + b }\n"},
+		{`gen := \{ 5 }`, "gen := \\{ 5 }\n"},
+		{`unless := @\c,t,f { quote(c) }`, "unless := @\\c t f { quote(c) }\n"},
+		{`f := \a,b=10,...rest {a+b}`, "f := \\a b = 10 ...rest { a + b }\n"},
+		{`f := \a = \x {x} { a(1) }`, "f := \\a = \\x { x } { a(1) }\n"},
+
+		// named args
+		{`greet(message:"hi",name: "Bob")`, "greet(message: \"hi\", name: \"Bob\")\n"},
+		{"greet(\nname:\"Bob\")", "greet(\n    name: \"Bob\",\n)\n"},
+
+		// blocks
+		{"yif x {\n1\n} yels yif y {\n2\n} yels {3}", "yif x {\n    1\n} yels yif y {\n    2\n} yels { 3 }\n"},
+		{"yoyo {\n  x\n}", "yoyo {\n    x\n}\n"},
+		{"yoyo i < 3 { i += 1 }", "yoyo i < 3 { i += 1 }\n"},
+		{"yall [1] {\n}", "yall [1] {}\n"},
+		{"yall k:0..3 {yap(k)}", "yall k: 0..3 { yap(k) }\n"},
+		{"yall k ,v:h {yap(k,v)}", "yall k, v: h { yap(k, v) }\n"},
+		{"yall [1] {ybreak;ycontinue}", "yall [1] { ybreak; ycontinue }\n"},
+		{"x := 1\n\n@outer  yoyo {\nyall [1] { yif yt { ybreak  @outer yt*2 } ; ycontinue   @outer }\n}", "x := 1\n\n@outer yoyo {\n    yall [1] { yif yt { ybreak @outer yt * 2 }; ycontinue @outer }\n}\n"},
+		{"ytry { 1 } ycatch e { e }; ytry { 1 } ycatch { err }", "ytry { 1 } ycatch e { e }\nytry { 1 } ycatch { err }\n"},
+		{"f := \\x {\n\n\n  y := x\n\n\n  y\n\n}", "f := \\x {\n    y := x\n\n    y\n}\n"},
+		{"{ a := 1; b := 2 }", "{ a := 1; b := 2 }\n"},
+		{"ymatch x {1=>a,integer(n) yif n>0=>b,[_,...r]=>r,}", "ymatch x { 1 => a, integer(n) yif n > 0 => b, [_, ...r] => r }\n"},
+		{"ymatch x {\n-1..1 => 0, // small\n%{\"k\":k}=>{\nk\n}}", "ymatch x {\n    -1..1 => 0, // small\n    %{ \"k\": k } => {\n        k\n    },\n}\n"},
+
+		// statements that would otherwise continue the previous one
+		{"a; (b); [c]; -d", "a;\n(b);\n[c];\n-d\n"},
+		{"a; [b, c] := d", "a\n[b, c] := d\n"},
+		{"a; [b, c] = d", "a\n[b, c] = d\n"},
+		{"a; [b] = d", "a;\n[b] = d\n"},
+
+		// lists
+		{"[1,2,]", "[1, 2]\n"},
+		{"[\n1,\n2]", "[\n    1,\n    2,\n]\n"},
+		{"f(a,\n  b)", "f(a, b)\n"},
+		{"f(\na, \\x {\n x\n})", "f(\n    a,\n    \\x {\n        x\n    },\n)\n"},
+		{"%{}", "%{}\n"},
+		{`%{"a":1,"b":2}`, "%{ \"a\": 1, \"b\": 2 }\n"},
+		{
+			"%{\n\"name\": \"Yakub\",\n\"age\":2,\n42: \\x {\nx\n},\n\"alive\": true }",
+			"%{\n    \"name\": \"Yakub\",\n    \"age\":  2,\n    42: \\x {\n        x\n    },\n    \"alive\": true,\n}\n",
+		},
+
+		// alignment
+		{
+			"a:=1\nlong_name:=2\nb = 3\nb += 4",
+			"a         := 1\nlong_name := 2\nb         = 3\nb += 4\n",
+		},
+		{
+			"a := 1\nf := \\{\n  1\n}\nbb := 2\n\nccc := 3",
+			"a := 1\nf := \\{\n    1\n}\nbb := 2\n\nccc := 3\n",
+		},
+
+		// comments
+		{"// only a comment   ", "// only a comment\n"},
+		{
+			"// header\n\n\n\nx := 1 // one\nlong := 2 // two\n\n// about y\ny := 3\n// the end",
+			"// header\n\nx    := 1 // one\nlong := 2 // two\n\n// about y\ny := 3\n// the end\n",
+		},
+		{
+			"yif a { // first\n  1\n} yels yif b { // second\n  2\n} yels { // third\n  3\n}",
+			"yif a {        // first\n    1\n} yels yif b { // second\n    2\n} yels {       // third\n    3\n}\n",
+		},
+		{"f := \\{\n  x // trailing\n  // before end\n}", "f := \\{\n    x // trailing\n    // before end\n}\n"},
+		{"[\n  1, // one\n  // before end\n]", "[\n    1, // one\n    // before end\n]\n"},
+		// comments between args keep them on separate lines, so they stay next to the right code
+		{"f(a, // kept\n  b)\nx", "f(\n    a, // kept\n    b,\n)\nx\n"},
+		{"f(1, // first\n  2) // second", "f(\n    1, // first\n    2,\n) // second\n"},
+		{"[1, 2 // last\n]", "[\n    1,\n    2, // last\n]\n"},
+		{"map(xs, \\x {\n  // inside\n  x\n})", "map(xs, \\x {\n    // inside\n    x\n})\n"},
+	}
+
+	for _, tt := range tests {
+		result, err := format.Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if string(result) != tt.expected {
+			t.Errorf("%q: wrong result.\nexpected:\n%s\ngot:\n%s", tt.input, tt.expected, result)
+			continue
+		}
+
+		again, err := format.Source(result)
+		if err != nil || string(again) != string(result) {
+			t.Errorf("%q: formatting isn't idempotent.\nfirst:\n%s\nsecond:\n%s", tt.input, result, again)
+		}
+	}
+}
+
+func TestSourceWithSyntaxError(t *testing.T) {
+	_, err := format.Source([]byte("x := 1\ny := (2 + "))
+
+	var yyErr *yikes.YYError
+	if !errors.As(err, &yyErr) {
+		t.Fatalf("expected *yikes.YYError, got %T (%v)", err, err)
+	}
+	if yyErr.Offset != 15 {
+		t.Errorf("wrong offset. expected=15, got=%d", yyErr.Offset)
+	}
+}
+
+// TestExampleFiles checks formatting examples is idempotent, keeps all comments, and doesn't break
+// them: they're full of assertions.
+func TestExampleFiles(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(examplesDir, "*.yeet"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("couldn't find example files: %v", err)
+	}
+
+	// examples import each other, so all of them get formatted first
+	dir := t.TempDir()
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		formatted, err := format.Source(src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", file, err)
+			continue
+		}
+
+		again, err := format.Source(formatted)
+		if err != nil || string(again) != string(formatted) {
+			t.Errorf("%s: formatting isn't idempotent", file)
+		}
+
+		if expected, got := strings.Count(string(src), "//"), strings.Count(string(formatted), "//"); got != expected {
+			t.Errorf("%s: wrong number of comments. expected=%d, got=%d", file, expected, got)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(file)), formatted, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, file := range files {
+		interp := yy.New()
+		interp.Stdout = io.Discard
+		interp.Stderr = io.Discard
+		if _, err := interp.RunFile(filepath.Join(dir, filepath.Base(file))); err != nil {
+			t.Errorf("%s: formatted example failed: %s", file, err)
+		}
+	}
+}
@@ -3,7 +3,7 @@
 # set -e
 
 rm -f yy
-go build -cover -o yy .
+go build -cover -o yy ./cmd/yy
 
 rm -rf covdatafiles
 mkdir covdatafiles
@@ -0,0 +1,250 @@
+// Package yy lets Go programs embed the YY interpreter.
+//
+//	interp := yy.New()
+//	interp.RegisterBuiltin("shout", strings.ToUpper)
+//	interp.SetGlobal("name", "yak")
+//	result, err := interp.Run(`shout(name)`)
+package yy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"yy/eval"
+	"yy/lexer"
+	"yy/object"
+	"yy/parser"
+	"yy/yikes"
+)
+
+// Interpreter runs YY code. Globals, functions and macros declared by one call to Run are visible to
+// the calls that follow, and modules imported by it aren't run again.
+type Interpreter struct {
+	// Stdout is where yap, yelp & yowl print to. Defaults to os.Stdout.
+	Stdout io.Writer
+	// Stderr, if set, is where errors get pretty printed to, on top of being returned. Nil by
+	// default, errors are only returned then.
+	Stderr io.Writer
+	// Limits restrict each run, so untrusted code can't hang or exhaust the host. No limits by
+	// default.
+	Limits object.Limits
+
+	// host holds builtins and globals added by Go code, modules imported by the code see them too
+	host     *object.Environment
+	env      *object.Environment
+	macroEnv *object.Environment
+	// module caches modules imported by all the runs
+	module *object.Module
+}
+
+func New() *Interpreter {
+	host := object.NewEnvironment()
+	i := &Interpreter{
+		Stdout:   os.Stdout,
+		host:     host,
+		env:      object.NewEnclosedEnvironment(host),
+		macroEnv: object.NewEnvironment(),
+		module:   object.NewModule(""),
+	}
+
+	// print builtins look up Stdout on every call, so it can be swapped at any time
+	stdout := writerFunc(func(p []byte) (int, error) { return i.Stdout.Write(p) })
+	for name, builtin := range eval.PrintBuiltins(stdout) {
+		i.host.Set(name, builtin)
+	}
+
+	return i
+}
+
+// Run runs src and returns the value of its last expression converted to a Go value (see ToGo).
+// Errors raised by the code are returned as *Error. Modules imported by src are looked up relative
+// to the working directory.
+func (i *Interpreter) Run(src string) (any, error) {
+	return i.RunContext(context.Background(), src)
+}
+
+// RunContext is like Run, but the code is stopped with an error once ctx is done.
+func (i *Interpreter) RunContext(ctx context.Context, src string) (any, error) {
+	i.env.SetModule(i.module)
+	return i.run(ctx, []byte(src), "<input>")
+}
+
+// RunFile runs the script at path, like Run. Modules imported by the script are looked up relative
+// to its directory.
+func (i *Interpreter) RunFile(path string) (any, error) {
+	return i.RunFileContext(context.Background(), path)
+}
+
+// RunFileContext is like RunFile, but the script is stopped with an error once ctx is done.
+func (i *Interpreter) RunFileContext(ctx context.Context, path string) (any, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	i.env.SetModule(i.module.Open(path))
+	return i.run(ctx, src, path)
+}
+
+func (i *Interpreter) run(ctx context.Context, src []byte, file string) (any, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		if i.Stderr != nil {
+			for _, parseErr := range p.Errors() {
+				fmt.Fprintln(i.Stderr, yikes.PrettyError(src, parseErr.Offset, parseErr.Msg))
+			}
+		}
+		first := p.Errors()[0]
+		return nil, &Error{Msg: first.Msg, File: file, Pos: first.Offset, src: src, mainFile: file}
+	}
+
+	eval.DefineMacros(program, i.macroEnv)
+	expanded, macroErr := eval.ExpandMacros(program, i.macroEnv)
+	if macroErr != nil {
+		return nil, i.fail(newError(macroErr, file, src))
+	}
+
+	eval.Resolve(expanded)
+	i.env.Module().SetSource(src)
+	i.env.SetGuard(object.NewGuard(ctx, i.Limits))
+	defer i.env.SetGuard(nil)
+	result := eval.Eval(expanded, i.env)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, i.fail(newError(errObj, file, src))
+	}
+
+	return ToGo(result), nil
+}
+
+func (i *Interpreter) fail(err *Error) *Error {
+	if i.Stderr != nil {
+		fmt.Fprintln(i.Stderr, err.Pretty())
+	}
+	return err
+}
+
+// SetGlobal declares a global variable, converting value to a YY object (see ToObject).
+func (i *Interpreter) SetGlobal(name string, value any) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	i.host.Set(name, obj)
+	return nil
+}
+
+// Get returns the value of a global variable converted to a Go value (see ToGo).
+func (i *Interpreter) Get(name string) (any, bool) {
+	obj, ok := i.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return ToGo(obj), true
+}
+
+// RegisterBuiltin makes fn callable from YY code. fn is either an object.BuiltinFunction, which
+// deals with YY objects directly, or any Go function. Arguments and results of the latter are
+// converted on the fly; if its last result is an error, a non-nil error is raised in YY code.
+func (i *Interpreter) RegisterBuiltin(name string, fn any) error {
+	var builtin *object.Builtin
+	switch fn := fn.(type) {
+	case object.BuiltinFunction:
+		builtin = &object.Builtin{Fn: fn}
+	case func(args ...object.Object) object.Object:
+		builtin = &object.Builtin{Fn: fn}
+	default:
+		obj, err := ToObject(fn)
+		if err != nil {
+			return err
+		}
+		b, ok := obj.(*object.Builtin)
+		if !ok {
+			return fmt.Errorf("can't register %T as a builtin, it's not a function", fn)
+		}
+		builtin = b
+	}
+
+	i.host.Set(name, builtin)
+	return nil
+}
+
+// Error is an error raised by YY code.
+type Error struct {
+	Msg string
+	// File is where the error was raised, it's either the file that was run or a module it imported.
+	File string
+	// Pos is the offset in File where the error was raised, -1 if it's unknown.
+	Pos int
+	// Payload holds the arguments passed to yikes, converted to Go values.
+	Payload any
+	// Trace lists the calls that led to the error, innermost first.
+	Trace []yikes.StackFrame
+	// Fatal is set if the code was stopped because it exceeded Limits, or its context was done, or if
+	// the interpreter broke (a panic, eg in a registered builtin).
+	Fatal bool
+
+	src      []byte
+	mainFile string
+}
+
+func newError(err *object.Error, file string, src []byte) *Error {
+	var payload any
+	if err.Payload != nil {
+		payload = ToGo(err.Payload)
+	}
+
+	errFile := err.File
+	if errFile == "" {
+		errFile = file
+	}
+
+	return &Error{
+		Msg:      err.Msg,
+		File:     errFile,
+		Pos:      err.Pos,
+		Payload:  payload,
+		Trace:    StackTrace(err),
+		Fatal:    err.Fatal,
+		src:      src,
+		mainFile: file,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Pretty renders the error along with the offending line of code and the stack trace.
+func (e *Error) Pretty() string {
+	if len(e.Trace) == 0 {
+		return yikes.PrettyError(e.src, e.Pos, e.Msg)
+	}
+	return yikes.PrettyTrace(e.src, e.mainFile, e.Msg, e.Trace)
+}
+
+// StackTrace lists places in the code that were being executed when err was raised, innermost first.
+func StackTrace(err *object.Error) []yikes.StackFrame {
+	frames := []yikes.StackFrame{}
+
+	pos, file := err.Pos, err.File
+	for _, call := range err.Trace {
+		name := call.Fn
+		if name == "" {
+			name = "<lambda>"
+		}
+		frames = append(frames, yikes.StackFrame{Fn: name, File: file, Offset: pos})
+		pos, file = call.Pos, call.File
+	}
+
+	return append(frames, yikes.StackFrame{Fn: "<main>", File: file, Offset: pos})
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,481 @@
+package yy_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"yy"
+	"yy/object"
+	"yy/yikes"
+)
+
+func newInterpreter() (*yy.Interpreter, *bytes.Buffer) {
+	var out bytes.Buffer
+	interp := yy.New()
+	interp.Stdout = &out
+	return interp, &out
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"5 + 5", 10},
+		{"2.5 * 2", 5.0},
+		{`"yeet" + "yoink"`, "yeetyoink"},
+		{"5 > 3", true},
+		{"null", nil},
+		{"[1, 2, 3]", []any{1, 2, 3}},
+		{`%{"a": 1, "b": [true]}`, map[string]any{"a": 1, "b": []any{true}}},
+		{`%{1: "a"}`, map[any]any{1: "a"}},
+	}
+
+	for _, tt := range tests {
+		interp, _ := newInterpreter()
+		result, err := interp.Run(tt.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%q: wrong result. want=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestRunKeepsState(t *testing.T) {
+	interp, _ := newInterpreter()
+
+	steps := []string{
+		"x := 2",
+		`double := \a { a * 2 }`,
+		`twice := @\e { quote(unquote(e) + unquote(e)) }`,
+	}
+	for _, step := range steps {
+		if _, err := interp.Run(step); err != nil {
+			t.Fatalf("%q: unexpected error: %s", step, err)
+		}
+	}
+
+	result, err := interp.Run("twice(double(x))")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result != 8 {
+		t.Errorf("wrong result. want=8, got=%v", result)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.yeet")
+	if err := os.WriteFile(path, []byte("yap(\"hi\")\n21 * 2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	interp, out := newInterpreter()
+	result, err := interp.RunFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result != 42 {
+		t.Errorf("wrong result. want=42, got=%v", result)
+	}
+	if out.String() != "hi\n" {
+		t.Errorf("wrong output. want=%q, got=%q", "hi\n", out.String())
+	}
+
+	if _, err := interp.RunFile(filepath.Join(t.TempDir(), "nope.yeet")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestRunImports(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.yeet")
+	if err := os.WriteFile(path, []byte("yap(\"loading\")\nvalue := shout(name)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// modules see builtins and globals of the host, and are run only once
+	interp, out := newInterpreter()
+	interp.RegisterBuiltin("shout", strings.ToUpper)
+	interp.SetGlobal("name", "yak")
+	for i := 0; i < 2; i++ {
+		result, err := interp.Run(fmt.Sprintf(`(yimport %q)["value"]`, path))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result != "YAK" {
+			t.Errorf("wrong result. want=%q, got=%v", "YAK", result)
+		}
+	}
+	if out.String() != "loading\n" {
+		t.Errorf("wrong output. want=%q, got=%q", "loading\n", out.String())
+	}
+}
+
+func TestStdout(t *testing.T) {
+	interp, out := newInterpreter()
+
+	if _, err := interp.Run(`yap("hello", 5); yelp("no newline"); yowl("loud")`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := "hello 5\nno newlineLOUD\n\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+
+	// writers can be swapped between runs
+	var other bytes.Buffer
+	interp.Stdout = &other
+	interp.Run(`yap("elsewhere")`)
+	if other.String() != "elsewhere\n" {
+		t.Errorf("wrong output. want=%q, got=%q", "elsewhere\n", other.String())
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		msg     string
+		pos     int
+		payload any
+		trace   int
+	}{
+		{"5 +", "unexpected token 'EOF'", 1, nil, 0},
+		{"1 / 0", "division by zero", 2, nil, 1},
+		{`yikes("boom", 42)`, "boom", 0, []any{"boom", 42}, 1},
+		{`f := \ { yikes("boom") }; f()`, "boom", 9, "boom", 2},
+	}
+
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		interp, _ := newInterpreter()
+		interp.Stderr = &stderr
+
+		_, err := interp.Run(tt.input)
+
+		var yyErr *yy.Error
+		if !errors.As(err, &yyErr) {
+			t.Errorf("%q: expected *yy.Error, got=%T (%v)", tt.input, err, err)
+			continue
+		}
+		if !strings.HasPrefix(yyErr.Msg, tt.msg) {
+			t.Errorf("%q: wrong message. want=%q, got=%q", tt.input, tt.msg, yyErr.Msg)
+		}
+		if yyErr.Pos != tt.pos {
+			t.Errorf("%q: wrong position. want=%d, got=%d", tt.input, tt.pos, yyErr.Pos)
+		}
+		if !reflect.DeepEqual(yyErr.Payload, tt.payload) {
+			t.Errorf("%q: wrong payload. want=%#v, got=%#v", tt.input, tt.payload, yyErr.Payload)
+		}
+		if len(yyErr.Trace) != tt.trace {
+			t.Errorf("%q: wrong trace length. want=%d, got=%d", tt.input, tt.trace, len(yyErr.Trace))
+		}
+		if !strings.Contains(stderr.String(), tt.msg) {
+			t.Errorf("%q: error not written to stderr, got=%q", tt.input, stderr.String())
+		}
+	}
+}
+
+func TestErrorsNotPrintedByDefault(t *testing.T) {
+	stderr, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+	realStderr := os.Stderr
+	os.Stderr = stderr
+	defer func() { os.Stderr = realStderr }()
+
+	for _, input := range []string{"5 +", "1 / 0"} {
+		if _, err := yy.New().Run(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+
+	written, err := os.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) > 0 {
+		t.Errorf("errors written to stderr: %q", written)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input  string
+		limits object.Limits
+		msg    string
+		pos    int
+	}{
+		{"x := 0\nyoyo { x += 1 }", object.Limits{MaxSteps: 100}, "step limit exceeded (max 100 steps)", 12},
+		{`yolo { "yak" * 1000000000 }`, object.Limits{MaxLength: 100}, "length limit exceeded (max 100)", 13},
+	}
+
+	for _, tt := range tests {
+		interp, _ := newInterpreter()
+		interp.Limits = tt.limits
+
+		_, err := interp.Run(tt.input)
+
+		var yyErr *yy.Error
+		if !errors.As(err, &yyErr) {
+			t.Errorf("%q: expected *yy.Error, got=%T (%v)", tt.input, err, err)
+			continue
+		}
+		if yyErr.Msg != tt.msg || yyErr.Pos != tt.pos || !yyErr.Fatal {
+			t.Errorf("%q: wrong error. want=%q at %d (fatal), got=%q at %d (fatal: %t)",
+				tt.input, tt.msg, tt.pos, yyErr.Msg, yyErr.Pos, yyErr.Fatal)
+		}
+	}
+
+	// limits apply to each run separately
+	interp, _ := newInterpreter()
+	interp.Limits = object.Limits{MaxSteps: 100}
+	for i := 0; i < 3; i++ {
+		if _, err := interp.Run("x := 0; yoyo x < 10 { x += 1 }"); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+
+	// depth of recursion can be lowered or raised for each interpreter
+	deep := `f := \n { yif n == 0 { yeet 0 }; 1 + f(n - 1) }; f(15000)`
+	shallow, _ := newInterpreter()
+	shallow.Limits = object.Limits{MaxDepth: 100}
+	if _, err := shallow.Run(deep); err == nil || !strings.Contains(err.Error(), "maximum recursion depth exceeded") {
+		t.Errorf("expected recursion depth error, got=%v", err)
+	}
+	raised, _ := newInterpreter()
+	raised.Limits = object.Limits{MaxDepth: 20_000}
+	if result, err := raised.Run(deep); err != nil || result != 15000 {
+		t.Errorf("wrong result. want=15000, got=%v (%v)", result, err)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	interp, _ := newInterpreter()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := interp.RunContext(ctx, "yoyo { }")
+
+	var yyErr *yy.Error
+	if !errors.As(err, &yyErr) || yyErr.Msg != "timeout exceeded" || !yyErr.Fatal {
+		t.Fatalf("expected fatal timeout error, got=%v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = interp.RunContext(ctx, "f := \\n { f(n + 1) + 1 }; ytry { f(0) } ycatch { 1 }")
+	if !errors.As(err, &yyErr) || yyErr.Msg != "execution cancelled" {
+		t.Fatalf("expected cancellation error, got=%v", err)
+	}
+
+	// the interpreter keeps working afterwards
+	if result, err := interp.Run("1 + 1"); err != nil || result != 2 {
+		t.Errorf("wrong result after cancellation. want=2, got=%v (%v)", result, err)
+	}
+}
+
+func TestSetGlobalAndGet(t *testing.T) {
+	interp, _ := newInterpreter()
+
+	globals := map[string]any{
+		"i":   7,
+		"f":   float32(1.5),
+		"s":   "yak",
+		"b":   true,
+		"arr": []string{"a", "b"},
+		"m":   map[string]int{"x": 1},
+		"n":   nil,
+	}
+	for name, val := range globals {
+		if err := interp.SetGlobal(name, val); err != nil {
+			t.Fatalf("SetGlobal(%q): unexpected error: %s", name, err)
+		}
+	}
+
+	result, err := interp.Run(`[i + 1, f * 2, s + "!", !b, arr[1], m["x"], n]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []any{8, 3.0, "yak!", false, "b", 1, nil}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("wrong result. want=%#v, got=%#v", expected, result)
+	}
+
+	interp.Run("x := s + s")
+	if x, ok := interp.Get("x"); !ok || x != "yakyak" {
+		t.Errorf("wrong value of x. want=%q, got=%v (%t)", "yakyak", x, ok)
+	}
+	if _, ok := interp.Get("nope"); ok {
+		t.Errorf("expected nope to be undefined")
+	}
+
+	if err := interp.SetGlobal("ch", make(chan int)); err == nil {
+		t.Errorf("expected an error for an unsupported type")
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	interp, _ := newInterpreter()
+
+	huge, _ := new(big.Int).SetString("100000000000000000000", 10)
+	interp.SetGlobal("huge", huge)
+	interp.SetGlobal("u", uint64(math.MaxUint64))
+
+	result, err := interp.Run("[huge / 10, u + 1, 9223372036854775807 + 1 - 1]")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	u, _ := new(big.Int).SetString("18446744073709551616", 10)
+	expected := []any{new(big.Int).Div(huge, big.NewInt(10)), u, math.MaxInt64}
+	if fmt.Sprint(result) != fmt.Sprint(expected) {
+		t.Errorf("wrong result. want=%v, got=%v", expected, result)
+	}
+	if _, ok := result.([]any)[0].(*big.Int); !ok {
+		t.Errorf("expected *big.Int, got=%T", result.([]any)[0])
+	}
+}
+
+func TestGetFunction(t *testing.T) {
+	interp, _ := newInterpreter()
+	interp.Run(`add := \a, b { a + b }; fail := \ { yikes("nope") }`)
+
+	add, _ := interp.Get("add")
+	fn, ok := add.(func(...any) (any, error))
+	if !ok {
+		t.Fatalf("expected a func, got=%T", add)
+	}
+
+	result, err := fn(2, 3)
+	if err != nil || result != 5 {
+		t.Errorf("wrong result. want=5, got=%v (%v)", result, err)
+	}
+
+	if _, err := fn(1); err == nil || !strings.Contains(err.Error(), "wrong number of args") {
+		t.Errorf("expected wrong number of args error, got=%v", err)
+	}
+
+	fail, _ := interp.Get("fail")
+	if _, err := fail.(func(...any) (any, error))(); err == nil || err.Error() != "nope" {
+		t.Errorf("expected error %q, got=%v", "nope", err)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	interp, _ := newInterpreter()
+
+	builtins := map[string]any{
+		"upper": strings.ToUpper,
+		"sum": func(nums ...int) int {
+			total := 0
+			for _, n := range nums {
+				total += n
+			}
+			return total
+		},
+		"half": func(n float64) (float64, error) {
+			if n < 0 {
+				return 0, fmt.Errorf("negative number: %v", n)
+			}
+			return n / 2, nil
+		},
+		"keys": func(m map[string]any) int { return len(m) },
+		"call": func(fn func(...any) (any, error), arg any) (any, error) { return fn(arg) },
+		"noop": func() {},
+		"raw": object.BuiltinFunction(func(args ...object.Object) object.Object {
+			return &object.Integer{Value: int64(len(args))}
+		}),
+	}
+	for name, fn := range builtins {
+		if err := interp.RegisterBuiltin(name, fn); err != nil {
+			t.Fatalf("RegisterBuiltin(%q): unexpected error: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`upper("yak")`, "YAK"},
+		{"sum()", 0},
+		{"sum(1, 2, 3)", 6},
+		{"half(5)", 2.5},
+		{`keys(%{"a": 1, "b": 2})`, 2},
+		{`call(\x { x * 10 }, 4)`, 40},
+		{"noop()", nil},
+		{"raw(1, 2, 3)", 3},
+	}
+
+	for _, tt := range tests {
+		result, err := interp.Run(tt.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%q: wrong result. want=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+
+	errTests := []struct {
+		input string
+		msg   string
+	}{
+		{"half(-1)", "negative number: -1"},
+		{"upper(1)", "wrong type of arg #1"},
+		{"upper()", "wrong number of args (got 0, want 1)"},
+		{`sum(1, "a")`, "wrong type of arg #2"},
+	}
+
+	for _, tt := range errTests {
+		_, err := interp.Run(tt.input)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.msg) {
+			t.Errorf("%q: wrong error. want=%q, got=%v", tt.input, tt.msg, err)
+		}
+	}
+
+	if err := interp.RegisterBuiltin("two", func() (int, int) { return 1, 2 }); err == nil {
+		t.Errorf("expected an error for a func returning too many values")
+	}
+	if err := interp.RegisterBuiltin("five", 5); err == nil {
+		t.Errorf("expected an error for a non-func builtin")
+	}
+}
+
+func TestPanickingBuiltin(t *testing.T) {
+	interp, _ := newInterpreter()
+	interp.RegisterBuiltin("boom", func() { panic("kaboom") })
+
+	_, err := interp.Run("f := \\{ 1 + boom() }\nytry { f() } ycatch { 0 }")
+
+	var yyErr *yy.Error
+	if !errors.As(err, &yyErr) {
+		t.Fatalf("expected *yy.Error, got=%T (%v)", err, err)
+	}
+	if yyErr.Msg != "internal error: kaboom" || !yyErr.Fatal {
+		t.Errorf("wrong error. want fatal %q, got=%q (fatal: %t)", "internal error: kaboom", yyErr.Msg, yyErr.Fatal)
+	}
+	if yyErr.Pos != 16 {
+		t.Errorf("wrong position. want=16, got=%d", yyErr.Pos)
+	}
+	want := []yikes.StackFrame{{Fn: "f", Offset: 16}, {Fn: "<main>", Offset: 28}}
+	if !reflect.DeepEqual(yyErr.Trace, want) {
+		t.Errorf("wrong stack trace. want=%+v, got=%+v", want, yyErr.Trace)
+	}
+}
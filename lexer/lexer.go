@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"strings"
+
 	"yy/token"
 )
 
@@ -11,6 +13,8 @@ type Lexer struct {
 	ch           byte   // current char under examination
 	numBrackets  int    // depth of string interpolation
 	brackets     [5]int // stack of interpolations
+
+	Comments []token.Token // comments skipped so far, for tools that care about them, eg the formatter
 }
 
 func New(input string) *Lexer {
@@ -69,7 +73,12 @@ func (l *Lexer) NextToken() token.Token {
 	case '/':
 		tok = l.switchEq(token.SLASH, token.DIV_ASSIGN)
 	case '=':
-		tok = l.switchEq(token.ASSIGN, token.EQ)
+		if l.peek() == '>' {
+			l.advance()
+			tok = l.newToken(token.FAT_ARROW)
+		} else {
+			tok = l.switchEq(token.ASSIGN, token.EQ)
+		}
 	case '!':
 		tok = l.switchEq(token.BANG, token.NOT_EQ)
 	case ':':
@@ -79,10 +88,23 @@ func (l *Lexer) NextToken() token.Token {
 
 	case '.':
 		tok = l.switch2(token.DOT, token.RANGE, '.')
+		if tok.Type == token.RANGE && l.peek() == '.' {
+			l.advance()
+			tok = l.newToken(token.ELLIPSIS)
+		}
 	case '&':
 		tok = l.switch2(token.AMPERSAND, token.AND, '&')
 	case '|':
-		tok = l.switch2(token.PIPE, token.OR, '|')
+		switch l.peek() {
+		case '|':
+			l.advance()
+			tok = l.newToken(token.OR)
+		case '>':
+			l.advance()
+			tok = l.newToken(token.PIPE_GT)
+		default:
+			tok = l.newToken(token.PIPE)
+		}
 	case '@':
 		tok = l.switch2(token.AT, token.MACRO, '\\')
 
@@ -281,9 +303,12 @@ func (l *Lexer) skipWhitespace() {
 		case '/':
 			if l.peek() == '/' {
 				// treating comments as whitespace, sue me
+				start := l.position
 				for l.ch != '\n' && l.ch != 0 {
 					l.advance()
 				}
+				text := strings.TrimRight(l.Input[start:l.position], " \t\r")
+				l.Comments = append(l.Comments, token.Token{Type: token.COMMENT, Literal: text, Offset: start})
 			} else {
 				return
 			}
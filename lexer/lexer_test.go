@@ -103,6 +103,53 @@ func TestNextToken(t *testing.T) {
 
 func TestLexingYifExpression(t *testing.T) {
 	runLexerTests(t, []lexerTestCase{
+		{
+			"a.b..c.d 1.5 x.1",
+			[]token.Token{
+				{Type: token.IDENT, Literal: "a"},
+				{Type: token.DOT, Literal: "."},
+				{Type: token.IDENT, Literal: "b"},
+				{Type: token.RANGE, Literal: ".."},
+				{Type: token.IDENT, Literal: "c"},
+				{Type: token.DOT, Literal: "."},
+				{Type: token.IDENT, Literal: "d"},
+				{Type: token.NUMBER, Literal: "1.5"},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.DOT, Literal: "."},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.EOF, Literal: "EOF"},
+			},
+		},
+		{
+			"a | b || c |> f()",
+			[]token.Token{
+				{Type: token.IDENT, Literal: "a"},
+				{Type: token.PIPE, Literal: "|"},
+				{Type: token.IDENT, Literal: "b"},
+				{Type: token.OR, Literal: "||"},
+				{Type: token.IDENT, Literal: "c"},
+				{Type: token.PIPE_GT, Literal: "|>"},
+				{Type: token.IDENT, Literal: "f"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.EOF, Literal: "EOF"},
+			},
+		},
+		{
+			"[a, ...b] 1...2",
+			[]token.Token{
+				{Type: token.LBRACKET, Literal: "["},
+				{Type: token.IDENT, Literal: "a"},
+				{Type: token.COMMA, Literal: ","},
+				{Type: token.ELLIPSIS, Literal: "..."},
+				{Type: token.IDENT, Literal: "b"},
+				{Type: token.RBRACKET, Literal: "]"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.ELLIPSIS, Literal: "..."},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.EOF, Literal: "EOF"},
+			},
+		},
 		{
 			"yif 5 > 8 { 5 } yels { 8 }",
 			[]token.Token{
@@ -123,6 +170,67 @@ func TestLexingYifExpression(t *testing.T) {
 	})
 }
 
+func TestLexingYmatchExpression(t *testing.T) {
+	runLexerTests(t, []lexerTestCase{
+		{
+			"ymatch x { 1 => a, _ yif y == 2 => b }",
+			[]token.Token{
+				{Type: token.YMATCH, Literal: "ymatch"},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.FAT_ARROW, Literal: "=>"},
+				{Type: token.IDENT, Literal: "a"},
+				{Type: token.COMMA, Literal: ","},
+				{Type: token.IDENT, Literal: "_"},
+				{Type: token.YIF, Literal: "yif"},
+				{Type: token.IDENT, Literal: "y"},
+				{Type: token.EQ, Literal: "=="},
+				{Type: token.INT, Literal: "2"},
+				{Type: token.FAT_ARROW, Literal: "=>"},
+				{Type: token.IDENT, Literal: "b"},
+				{Type: token.RBRACE, Literal: "}"},
+				{Type: token.EOF, Literal: "EOF"},
+			},
+		},
+	})
+}
+
+func TestLexingYtryExpression(t *testing.T) {
+	runLexerTests(t, []lexerTestCase{
+		{
+			"ytry { 5 } ycatch e { e }",
+			[]token.Token{
+				{Type: token.YTRY, Literal: "ytry"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.INT, Literal: "5"},
+				{Type: token.RBRACE, Literal: "}"},
+				{Type: token.YCATCH, Literal: "ycatch"},
+				{Type: token.IDENT, Literal: "e"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.IDENT, Literal: "e"},
+				{Type: token.RBRACE, Literal: "}"},
+				{Type: token.EOF, Literal: "EOF"},
+			},
+		},
+	})
+}
+
+func TestLexingYimportExpression(t *testing.T) {
+	runLexerTests(t, []lexerTestCase{
+		{
+			`m := yimport "m.yeet"`,
+			[]token.Token{
+				{Type: token.IDENT, Literal: "m"},
+				{Type: token.WALRUS, Literal: ":="},
+				{Type: token.YIMPORT, Literal: "yimport"},
+				{Type: token.STRING, Literal: "m.yeet"},
+				{Type: token.EOF, Literal: "EOF"},
+			},
+		},
+	})
+}
+
 func TestLexingInterpolatedStrings(t *testing.T) {
 	runLexerTests(t, []lexerTestCase{
 		{
@@ -198,6 +306,30 @@ func TestLexingInterpolatedStrings(t *testing.T) {
 	})
 }
 
+func TestComments(t *testing.T) {
+	input := "// header  \nx := 1 // one\n\n//\n"
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.COMMENT {
+			t.Errorf("comments should be skipped, got %q", tok.Literal)
+		}
+	}
+
+	expected := []token.Token{
+		{Type: token.COMMENT, Literal: "// header", Offset: 0},
+		{Type: token.COMMENT, Literal: "// one", Offset: 19},
+		{Type: token.COMMENT, Literal: "//", Offset: 27},
+	}
+	if len(l.Comments) != len(expected) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(expected), len(l.Comments))
+	}
+	for i, exp := range expected {
+		if l.Comments[i] != exp {
+			t.Errorf("comments[%d] wrong. expected=%+v, got=%+v", i, exp, l.Comments[i])
+		}
+	}
+}
+
 func runLexerTests(t *testing.T, testCases []lexerTestCase) {
 	for _, tc := range testCases {
 		l := lexer.New(tc.input)
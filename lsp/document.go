@@ -0,0 +1,570 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"yy/ast"
+	"yy/lexer"
+	"yy/parser"
+	"yy/yikes"
+)
+
+// document is an open .yeet file along with what the server knows about its code.
+type document struct {
+	uri   string
+	text  string
+	lines []int // offsets of the first byte of every line
+
+	errors  []yikes.YYError
+	root    *scope
+	idents  []*ident // every identifier in the code, ordered by position
+	symbols []DocumentSymbol
+}
+
+// scope is a part of the code where variables declared in it are visible: the whole program,
+// a block or a lambda.
+type scope struct {
+	start, end int
+	parent     *scope
+	children   []*scope
+	decls      []*decl
+}
+
+type declKind int
+
+const (
+	declVariable declKind = iota
+	declParameter
+)
+
+type decl struct {
+	name  string
+	pos   int
+	kind  declKind
+	value ast.Expression // nil for parameters
+}
+
+// ident is an identifier found in the code, either a use of a variable or its declaration.
+type ident struct {
+	name  string
+	pos   int
+	scope *scope
+	decl  *decl // set if the identifier declares a variable
+}
+
+func newDocument(uri, text string) *document {
+	doc := &document{uri: uri, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			doc.lines = append(doc.lines, i+1)
+		}
+	}
+
+	program, errors := parse(text)
+	doc.errors = errors
+	doc.root = &scope{start: 0, end: len(text)}
+
+	a := &analyzer{doc: doc, symbols: &doc.symbols}
+	a.walk(program, doc.root)
+	sort.Slice(doc.idents, func(i, j int) bool { return doc.idents[i].pos < doc.idents[j].pos })
+
+	return doc
+}
+
+// parse never fails: the parser recovers from syntax errors, and a panic is reported as an error too.
+func parse(text string) (program *ast.Program, errors []yikes.YYError) {
+	defer func() {
+		if r := recover(); r != nil {
+			program = &ast.Program{}
+			errors = append(errors, yikes.YYError{Msg: fmt.Sprintf("parser crashed: %v", r), Offset: 0})
+		}
+	}()
+
+	p := parser.New(lexer.New(text))
+	program = p.ParseProgram()
+	return program, p.Errors()
+}
+
+// position converts a byte offset to an LSP position.
+func (d *document) position(offset int) Position {
+	offset = max(0, min(offset, len(d.text)))
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+
+	character := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		character += len(utf16.Encode([]rune{r}))
+	}
+	return Position{Line: line, Character: character}
+}
+
+// offset converts an LSP position to a byte offset.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+
+	offset := d.lines[pos.Line]
+	for character := 0; character < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset
+}
+
+func (d *document) rangeOf(start, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, err := range d.errors {
+		end := err.Offset
+		if end < len(d.text) && d.text[end] != '\n' {
+			end++
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.rangeOf(err.Offset, end),
+			Severity: severityError,
+			Source:   "yy",
+			Message:  err.Msg,
+		})
+	}
+	return diagnostics
+}
+
+// scopeAt returns the innermost scope that contains offset.
+func (d *document) scopeAt(offset int) *scope {
+	sc := d.root
+	for {
+		inner := (*scope)(nil)
+		for _, child := range sc.children {
+			if child.start <= offset && offset <= child.end {
+				inner = child
+			}
+		}
+		if inner == nil {
+			return sc
+		}
+		sc = inner
+	}
+}
+
+// identAt returns the identifier under the cursor at offset, if there's one.
+func (d *document) identAt(offset int) *ident {
+	for _, id := range d.idents {
+		if id.pos <= offset && offset <= id.pos+len(id.name) {
+			return id
+		}
+	}
+	return nil
+}
+
+// resolve finds the declaration an identifier refers to.
+func (id *ident) resolve() *decl {
+	if id.decl != nil {
+		return id.decl
+	}
+	return id.scope.lookup(id.name, id.pos)
+}
+
+// lookup finds the declaration of name visible at offset pos. Within a scope, the latest declaration
+// before pos wins; if there's none, it's a reference to a variable declared later (eg a lambda calling
+// a function declared below it).
+func (sc *scope) lookup(name string, pos int) *decl {
+	for s := sc; s != nil; s = s.parent {
+		var first, last *decl
+		for _, d := range s.decls {
+			if d.name != name {
+				continue
+			}
+			if first == nil {
+				first = d
+			}
+			if d.pos <= pos {
+				last = d
+			}
+		}
+		if last != nil {
+			return last
+		}
+		if first != nil {
+			return first
+		}
+	}
+	return nil
+}
+
+// visible lists declarations visible at offset, inner ones shadowing outer ones. Top-level
+// declarations are visible everywhere, others only after they're declared.
+func (sc *scope) visible(offset int) []*decl {
+	decls := []*decl{}
+	seen := map[string]bool{}
+	for s := sc; s != nil; s = s.parent {
+		for i := len(s.decls) - 1; i >= 0; i-- {
+			d := s.decls[i]
+			if seen[d.name] || (s.parent != nil && d.pos >= offset) {
+				continue
+			}
+			seen[d.name] = true
+			decls = append(decls, d)
+		}
+	}
+	return decls
+}
+
+// signature describes a declaration, eg "add := \a, b".
+func (d *decl) signature() string {
+	switch value := d.value.(type) {
+	case *ast.LambdaLiteral:
+		return fmt.Sprintf("%s := \\%s", d.name, lambdaParams(value))
+	case *ast.MacroLiteral:
+		return fmt.Sprintf("%s := @\\%s", d.name, joinParams(value.Parameters))
+	}
+
+	if d.kind == declParameter {
+		return "(parameter) " + d.name
+	}
+	return "(variable) " + d.name
+}
+
+func (d *decl) isFunction() bool {
+	switch d.value.(type) {
+	case *ast.LambdaLiteral, *ast.MacroLiteral:
+		return true
+	}
+	return false
+}
+
+// lambdaParams lists parameters of fn as they're declared, with their default values and the rest.
+func lambdaParams(fn *ast.LambdaLiteral) string {
+	params := make([]string, 0, len(fn.Parameters)+1)
+	for i, param := range fn.Parameters {
+		if def := ast.DefaultOf(fn.Defaults, i); def != nil {
+			params = append(params, param.Value+" = "+def.String())
+		} else {
+			params = append(params, param.Value)
+		}
+	}
+	if fn.Rest != nil {
+		params = append(params, "..."+fn.Rest.Value)
+	}
+	return strings.Join(params, ", ")
+}
+
+func joinParams(params []*ast.Identifier) string {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+// analyzer walks the code, collecting scopes, identifiers and symbols of a document.
+type analyzer struct {
+	doc     *document
+	symbols *[]DocumentSymbol // where symbols of the declarations being walked go
+}
+
+func (a *analyzer) walk(node ast.Expression, sc *scope) {
+	switch node := node.(type) {
+	case *ast.Program:
+		a.walkAll(node.Expressions, sc)
+
+	case *ast.BlockExpression:
+		a.walkBlock(node, sc)
+
+	case *ast.Identifier:
+		a.doc.idents = append(a.doc.idents, &ident{name: node.Value, pos: node.Pos(), scope: sc})
+
+	case *ast.DeclareExpression:
+		d := a.declare(sc, node.Name, declVariable, node.Value)
+
+		symbol := DocumentSymbol{
+			Name:           d.name,
+			Kind:           symbolVariable,
+			Range:          a.doc.rangeOf(d.pos, d.pos+len(d.name)),
+			SelectionRange: a.doc.rangeOf(d.pos, d.pos+len(d.name)),
+		}
+		if d.isFunction() {
+			symbol.Kind = symbolFunction
+			symbol.Detail = d.signature()
+			symbol.Range = a.doc.rangeOf(d.pos, functionEnd(node.Value))
+		}
+
+		outer := a.symbols
+		a.symbols = &symbol.Children
+		a.walk(node.Value, sc)
+		a.symbols = outer
+		*a.symbols = append(*a.symbols, symbol)
+
+	case *ast.AssignExpression:
+		a.walk(node.Left, sc)
+		a.walk(node.Value, sc)
+
+	case *ast.DestructureExpression:
+		a.walk(node.Value, sc)
+		a.walkPattern(node.Pattern, node.Declare(), sc)
+
+	case *ast.YeetExpression:
+		a.walk(node.ReturnValue, sc)
+
+	case *ast.YbreakExpression:
+		if node.Value != nil {
+			a.walk(node.Value, sc)
+		}
+
+	case *ast.PrefixExpression:
+		a.walk(node.Right, sc)
+
+	case *ast.InfixExpression:
+		a.walk(node.Left, sc)
+		a.walk(node.Right, sc)
+
+	case *ast.PipeExpression:
+		a.walk(node.Call, sc)
+
+	case *ast.AndExpression:
+		a.walk(node.Left, sc)
+		a.walk(node.Right, sc)
+
+	case *ast.OrExpression:
+		a.walk(node.Left, sc)
+		a.walk(node.Right, sc)
+
+	case *ast.IndexExpression:
+		a.walk(node.Left, sc)
+		a.walk(node.Index, sc)
+
+	case *ast.DotExpression:
+		a.walk(node.Left, sc)
+
+	case *ast.YifExpression:
+		a.walk(node.Condition, sc)
+		a.walkBlock(node.Consequence, sc)
+		a.walkBlock(node.Alternative, sc)
+
+	case *ast.YoloExpression:
+		a.walkBlock(node.Body, sc)
+
+	case *ast.YoyoExpression:
+		a.walk(node.Condition, sc)
+		a.walkBlock(node.Body, sc)
+
+	case *ast.YallExpression:
+		a.walk(node.Iterable, sc)
+		if node.Body == nil {
+			return
+		}
+		body := a.newScope(sc, node.Body.Pos(), node.Body.Rbrace)
+		if node.IndexName != "" {
+			a.declareImplicit(body, node.IndexName, node.Pos(), node.Iterable.Pos())
+		}
+		a.declareImplicit(body, node.ElemName, node.Pos(), node.Iterable.Pos())
+		a.walkAll(node.Body.Expressions, body)
+
+	case *ast.YtryExpression:
+		a.walkBlock(node.Body, sc)
+		if node.Body == nil || node.Catch == nil {
+			return
+		}
+		catch := a.newScope(sc, node.Catch.Pos(), node.Catch.Rbrace)
+		a.declareImplicit(catch, node.ErrName, node.Body.Rbrace, node.Catch.Pos())
+		a.walkAll(node.Catch.Expressions, catch)
+
+	case *ast.YmatchExpression:
+		a.walk(node.Subject, sc)
+		for _, arm := range node.Arms {
+			// arms overlap, but the scope of a later arm wins where it starts
+			inner := a.newScope(sc, arm.Pattern.Pos(), node.Rbrace)
+			a.walkPattern(arm.Pattern, true, inner)
+			if arm.Guard != nil {
+				a.walk(arm.Guard, inner)
+			}
+			a.walk(arm.Body, inner)
+		}
+
+	case *ast.LambdaLiteral:
+		a.walkFunction(node.Pos(), node.Parameters, node.Defaults, node.Rest, node.Body, sc)
+
+	case *ast.MacroLiteral:
+		a.walkFunction(node.Pos(), node.Parameters, nil, nil, node.Body, sc)
+
+	case *ast.CallExpression:
+		a.walk(node.Function, sc)
+		a.walkAll(node.Arguments, sc)
+
+	case *ast.NamedArgument:
+		a.walk(node.Value, sc)
+
+	case *ast.ArrayLiteral:
+		a.walkAll(node.Elements, sc)
+
+	case *ast.HashmapLiteral:
+		for _, pair := range node.Pairs {
+			a.walk(pair.Key, sc)
+			a.walk(pair.Value, sc)
+		}
+
+	case *ast.RangeLiteral:
+		a.walk(node.Start, sc)
+		a.walk(node.End, sc)
+
+	case *ast.TemplateStringLiteral:
+		a.walkAll(node.Values, sc)
+	}
+}
+
+func (a *analyzer) walkAll(exprs []ast.Expression, sc *scope) {
+	for _, expr := range exprs {
+		a.walk(expr, sc)
+	}
+}
+
+func (a *analyzer) walkBlock(block *ast.BlockExpression, sc *scope) {
+	if block == nil {
+		return
+	}
+	a.walkAll(block.Expressions, a.newScope(sc, block.Pos(), block.Rbrace))
+}
+
+// walkPattern walks a destructuring pattern, declaring the variables in it (or assigning to them).
+func (a *analyzer) walkPattern(pattern ast.Expression, declaring bool, sc *scope) {
+	var elements []ast.PatternElement
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		elements = pattern.Elements
+	case *ast.HashmapPattern:
+		elements = pattern.Pairs
+	case *ast.TypePattern:
+		a.walkPattern(pattern.Target, declaring, sc)
+		return
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			return
+		}
+		if !declaring {
+			a.walk(pattern, sc)
+			return
+		}
+		d := a.declare(sc, pattern, declVariable, nil)
+		*a.symbols = append(*a.symbols, DocumentSymbol{
+			Name:           d.name,
+			Kind:           symbolVariable,
+			Range:          a.doc.rangeOf(d.pos, d.pos+len(d.name)),
+			SelectionRange: a.doc.rangeOf(d.pos, d.pos+len(d.name)),
+		})
+		return
+	default:
+		a.walk(pattern, sc)
+		return
+	}
+
+	for _, el := range elements {
+		if el.Key != nil {
+			a.walk(el.Key, sc)
+		}
+		if el.Default != nil {
+			a.walk(el.Default, sc)
+		}
+		a.walkPattern(el.Target, declaring, sc)
+	}
+	if arr, ok := pattern.(*ast.ArrayPattern); ok && arr.Rest != nil {
+		a.walkPattern(arr.Rest, declaring, sc)
+	}
+}
+
+func (a *analyzer) walkFunction(pos int, params []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier, body *ast.BlockExpression, sc *scope) {
+	if body == nil {
+		return
+	}
+
+	fn := a.newScope(sc, pos, body.Rbrace)
+	a.declareImplicit(fn, "self", pos, pos)
+	for _, param := range params {
+		a.declare(fn, param, declParameter, nil)
+	}
+	if rest != nil {
+		a.declare(fn, rest, declParameter, nil)
+	}
+	for _, def := range defaults {
+		if def != nil {
+			a.walk(def, fn)
+		}
+	}
+	a.walkAll(body.Expressions, fn)
+}
+
+func (a *analyzer) newScope(parent *scope, start, end int) *scope {
+	sc := &scope{start: start, end: end, parent: parent}
+	parent.children = append(parent.children, sc)
+	return sc
+}
+
+func (a *analyzer) declare(sc *scope, name *ast.Identifier, kind declKind, value ast.Expression) *decl {
+	d := &decl{name: name.Value, pos: name.Pos(), kind: kind, value: value}
+	sc.decls = append(sc.decls, d)
+	a.doc.idents = append(a.doc.idents, &ident{name: d.name, pos: d.pos, scope: sc, decl: d})
+	return d
+}
+
+// declareImplicit declares a variable that doesn't come with an identifier in the AST, like the
+// element of a yall loop. If the name was spelled out in the code between from & to, that's where
+// it's declared, otherwise it's declared at from.
+func (a *analyzer) declareImplicit(sc *scope, name string, from, to int) {
+	if from < 0 || to > len(a.doc.text) || from > to {
+		return
+	}
+
+	d := &decl{name: name, pos: from, kind: declVariable}
+	sc.decls = append(sc.decls, d)
+
+	if i := indexWord(a.doc.text[from:to], name); i >= 0 {
+		d.pos = from + i
+		a.doc.idents = append(a.doc.idents, &ident{name: name, pos: d.pos, scope: sc, decl: d})
+	}
+}
+
+// indexWord finds name in s as a whole word.
+func indexWord(s, name string) int {
+	for i := 0; i+len(name) <= len(s); i++ {
+		if s[i:i+len(name)] != name {
+			continue
+		}
+		before := i == 0 || !isIdentChar(s[i-1])
+		after := i+len(name) == len(s) || !isIdentChar(s[i+len(name)])
+		if before && after {
+			return i
+		}
+	}
+	return -1
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9'
+}
+
+// functionEnd returns the offset just past the closing brace of a lambda or macro.
+func functionEnd(fn ast.Expression) int {
+	var body *ast.BlockExpression
+	switch fn := fn.(type) {
+	case *ast.LambdaLiteral:
+		body = fn.Body
+	case *ast.MacroLiteral:
+		body = fn.Body
+	}
+	if body == nil {
+		return fn.Pos()
+	}
+	return body.Rbrace + 1
+}
@@ -0,0 +1,244 @@
+// Package lsp implements a language server for YY, talking the Language Server Protocol over
+// JSON-RPC. It's built on top of the lexer and parser, so it knows about syntax only: the code is
+// never run.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"yy/eval"
+	"yy/token"
+)
+
+type Server struct {
+	conn     *conn
+	docs     map[string]*document
+	shutdown bool
+}
+
+// NewServer creates a server that reads requests from in and writes responses to out, eg stdin
+// and stdout.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{conn: newConn(in, out), docs: map[string]*document{}}
+}
+
+// Serve handles messages until the client asks the server to exit or closes the connection.
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		var rpcErr *rpcError
+		if errors.As(err, &rpcErr) {
+			s.conn.write(errorResponse{JSONRPC: "2.0", Error: rpcErr})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+
+		if msg.ID == nil {
+			s.handleNotification(msg)
+			continue
+		}
+
+		result, err := s.handleRequest(msg)
+		if errors.As(err, &rpcErr) {
+			err = s.conn.write(errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr})
+		} else {
+			err = s.conn.write(response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handleRequest(msg *message) (any, error) {
+	if s.shutdown {
+		return nil, &rpcError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:       syncFull,
+				HoverProvider:          true,
+				DefinitionProvider:     true,
+				DocumentSymbolProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "yy"},
+		}, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params), nil
+
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return doc.symbols, nil
+
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+}
+
+// handleNotification handles messages the client doesn't expect a response to. Unknown ones, as
+// well as malformed ones, are ignored.
+func (s *Server) handleNotification(msg *message) {
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if unmarshalParams(msg, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if unmarshalParams(msg, &params) == nil && len(params.ContentChanges) > 0 {
+			last := params.ContentChanges[len(params.ContentChanges)-1]
+			s.update(params.TextDocument.URI, last.Text)
+		}
+
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if unmarshalParams(msg, &params) == nil {
+			delete(s.docs, params.TextDocument.URI)
+			s.publishDiagnostics(params.TextDocument.URI, []Diagnostic{})
+		}
+	}
+}
+
+func unmarshalParams(msg *message, params any) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// update analyses the new text of a document and publishes its parse errors.
+func (s *Server) update(uri, text string) {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	s.publishDiagnostics(uri, doc.diagnostics())
+}
+
+func (s *Server) publishDiagnostics(uri string, diagnostics []Diagnostic) {
+	s.conn.write(notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics},
+	})
+}
+
+// completion suggests variables visible at the cursor, builtins and keywords.
+func (s *Server) completion(params TextDocumentPositionParams) CompletionList {
+	list := CompletionList{Items: []CompletionItem{}}
+
+	if doc, ok := s.docs[params.TextDocument.URI]; ok {
+		offset := doc.offset(params.Position)
+		for _, d := range doc.scopeAt(offset).visible(offset) {
+			item := CompletionItem{Label: d.name, Kind: completionVariable, Detail: d.signature()}
+			if d.isFunction() {
+				item.Kind = completionFunction
+			}
+			list.Items = append(list.Items, item)
+		}
+	}
+
+	for _, name := range eval.BuiltinNames() {
+		list.Items = append(list.Items, CompletionItem{Label: name, Kind: completionFunction, Detail: "(builtin) " + name})
+	}
+	for _, keyword := range token.Keywords() {
+		list.Items = append(list.Items, CompletionItem{Label: keyword, Kind: completionKeyword})
+	}
+
+	return list
+}
+
+// hover shows what the identifier under the cursor is, eg parameters of a lambda.
+func (s *Server) hover(params TextDocumentPositionParams) *Hover {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	id := doc.identAt(doc.offset(params.Position))
+	if id == nil {
+		return nil
+	}
+
+	var text string
+	if d := id.resolve(); d != nil {
+		text = d.signature()
+	} else if _, ok := eval.LookupBuiltin(id.name); ok {
+		text = "(builtin) " + id.name
+	} else {
+		return nil
+	}
+
+	r := doc.rangeOf(id.pos, id.pos+len(id.name))
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```yy\n" + text + "\n```"},
+		Range:    &r,
+	}
+}
+
+// definition finds where the variable under the cursor was declared.
+func (s *Server) definition(params TextDocumentPositionParams) *Location {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	id := doc.identAt(doc.offset(params.Position))
+	if id == nil {
+		return nil
+	}
+
+	d := id.resolve()
+	if d == nil {
+		return nil
+	}
+
+	return &Location{URI: doc.uri, Range: doc.rangeOf(d.pos, d.pos+len(d.name))}
+}
@@ -0,0 +1,415 @@
+package lsp_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"yy/lsp"
+)
+
+const uri = "file:///test.yeet"
+
+const src = `add := \a, b { a + b }
+total := 0
+yall n: [1, 2, 3] {
+    total = add(total, n)
+}
+twice := @\e { quote(unquote(e) + unquote(e)) }
+run := \ {
+    local := 5
+    helper(local)
+}
+helper := \x { x }
+yap(total)
+greet := \name, msg = "hi", ...rest { msg + len(rest) }
+`
+
+func TestInitialize(t *testing.T) {
+	c := newClient(t)
+
+	var result lsp.InitializeResult
+	c.call("initialize", map[string]any{"capabilities": map[string]any{}}, &result)
+
+	caps := result.Capabilities
+	if caps.TextDocumentSync != 1 || !caps.HoverProvider || !caps.DefinitionProvider || !caps.DocumentSymbolProvider {
+		t.Errorf("wrong capabilities: %+v", caps)
+	}
+
+	var unknown json.RawMessage
+	if err := c.tryCall("yeet/yoink", nil, &unknown); err == nil || !strings.Contains(err.Error(), "-32601") {
+		t.Errorf("expected method not found error, got %v", err)
+	}
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := c.wait(); err != nil {
+		t.Errorf("unexpected error from Serve: %s", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, "x := 5 +\ny := (1")
+
+	diags := c.diagnostics(uri)
+	if len(diags) == 0 {
+		t.Fatalf("expected diagnostics, got none")
+	}
+	if diags[0].Range.Start.Line != 1 || diags[0].Severity != 1 {
+		t.Errorf("wrong diagnostic: %+v", diags[0])
+	}
+
+	c.change(uri, "x := 5 + 1")
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("expected no diagnostics after fixing the code, got %+v", diags)
+	}
+
+	c.change(uri, "yif true")
+	diags = c.diagnostics(uri)
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %+v", diags)
+	}
+
+	c.notify("textDocument/didClose", map[string]any{"textDocument": map[string]any{"uri": uri}})
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("expected diagnostics to be cleared on close, got %+v", diags)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, src)
+	c.diagnostics(uri)
+
+	tests := []struct {
+		line, char int
+		present    []string
+		absent     []string
+	}{
+		// inside the body of 'add'
+		{0, 15, []string{"a", "b", "add", "total", "helper", "yall", "len", "map"}, []string{"n", "local", "x"}},
+		// inside the yall loop
+		{3, 4, []string{"n", "total", "add"}, []string{"a", "local"}},
+		// inside 'run', after 'local' is declared
+		{8, 4, []string{"local", "helper", "run"}, []string{"n", "x"}},
+		// top level
+		{11, 0, []string{"add", "total", "twice", "run", "helper", "yimport", "true"}, []string{"n", "local", "a"}},
+	}
+
+	for _, tt := range tests {
+		var list lsp.CompletionList
+		c.call("textDocument/completion", positionParams(uri, tt.line, tt.char), &list)
+
+		labels := map[string]bool{}
+		for _, item := range list.Items {
+			labels[item.Label] = true
+		}
+		for _, label := range tt.present {
+			if !labels[label] {
+				t.Errorf("%d:%d: expected completion %q", tt.line, tt.char, label)
+			}
+		}
+		for _, label := range tt.absent {
+			if labels[label] {
+				t.Errorf("%d:%d: unexpected completion %q", tt.line, tt.char, label)
+			}
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, src)
+	c.diagnostics(uri)
+
+	tests := []struct {
+		line, char int
+		expected   string
+	}{
+		{3, 13, `add := \a, b`},
+		{0, 1, `add := \a, b`},
+		{0, 15, "(parameter) a"},
+		{1, 2, "(variable) total"},
+		{3, 23, "(variable) n"},
+		{5, 2, `twice := @\e`},
+		{11, 1, "(builtin) yap"},
+		{8, 6, `helper := \x`},
+		{6, 5, ""},
+		{12, 1, `greet := \name, msg = "hi", ...rest`},
+		{12, 49, "(parameter) rest"},
+	}
+
+	for _, tt := range tests {
+		var hover *lsp.Hover
+		c.call("textDocument/hover", positionParams(uri, tt.line, tt.char), &hover)
+
+		if tt.expected == "" {
+			if hover != nil {
+				t.Errorf("%d:%d: expected no hover, got %+v", tt.line, tt.char, hover)
+			}
+			continue
+		}
+		if hover == nil {
+			t.Errorf("%d:%d: expected hover %q, got none", tt.line, tt.char, tt.expected)
+			continue
+		}
+		if !strings.Contains(hover.Contents.Value, tt.expected) {
+			t.Errorf("%d:%d: wrong hover. want %q, got %q", tt.line, tt.char, tt.expected, hover.Contents.Value)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, src)
+	c.diagnostics(uri)
+
+	tests := []struct {
+		line, char int
+		expected   *lsp.Position
+	}{
+		{3, 13, &lsp.Position{Line: 0, Character: 0}}, // add
+		{3, 23, &lsp.Position{Line: 2, Character: 5}}, // n
+		{0, 16, &lsp.Position{Line: 0, Character: 8}}, // a
+		{8, 5, &lsp.Position{Line: 10, Character: 0}}, // helper, declared below
+		{8, 12, &lsp.Position{Line: 7, Character: 4}}, // local
+		{3, 4, &lsp.Position{Line: 1, Character: 0}},  // total
+		{11, 1, nil}, // yap is a builtin
+	}
+
+	for _, tt := range tests {
+		var loc *lsp.Location
+		c.call("textDocument/definition", positionParams(uri, tt.line, tt.char), &loc)
+
+		if tt.expected == nil {
+			if loc != nil {
+				t.Errorf("%d:%d: expected no definition, got %+v", tt.line, tt.char, loc)
+			}
+			continue
+		}
+		if loc == nil {
+			t.Errorf("%d:%d: expected definition at %+v, got none", tt.line, tt.char, *tt.expected)
+			continue
+		}
+		if loc.URI != uri || loc.Range.Start != *tt.expected {
+			t.Errorf("%d:%d: wrong definition. want %+v, got %+v", tt.line, tt.char, *tt.expected, loc.Range.Start)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, src)
+	c.diagnostics(uri)
+
+	var symbols []lsp.DocumentSymbol
+	c.call("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}}, &symbols)
+
+	got := []string{}
+	for _, s := range symbols {
+		got = append(got, fmt.Sprintf("%s:%d", s.Name, s.Kind))
+		for _, child := range s.Children {
+			got = append(got, fmt.Sprintf("%s.%s:%d", s.Name, child.Name, child.Kind))
+		}
+	}
+
+	expected := "add:12 total:13 twice:12 run:12 run.local:13 helper:12 greet:12"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("wrong symbols. want %q, got %q", expected, strings.Join(got, " "))
+	}
+
+	if symbols[3].Range.End.Line != 9 {
+		t.Errorf("wrong range of 'run'. want it to end on line 9, got %+v", symbols[3].Range)
+	}
+}
+
+func TestPositionsInUTF16(t *testing.T) {
+	c := newClient(t)
+	c.open(uri, "s := \"🦬\"; x := 5; x")
+	c.diagnostics(uri)
+
+	// the yak emoji is 4 bytes long, but only 2 UTF-16 code units
+	var loc *lsp.Location
+	c.call("textDocument/definition", positionParams(uri, 0, 19), &loc)
+	if loc == nil || loc.Range.Start != (lsp.Position{Line: 0, Character: 11}) {
+		t.Errorf("wrong definition, got %+v", loc)
+	}
+}
+
+//
+// HELPERS
+//
+
+// client talks to a server running in the same process.
+type client struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	msgs   chan map[string]json.RawMessage
+	done   chan error
+	nextID int
+	queued []map[string]json.RawMessage
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{t: t, in: clientOut, msgs: make(chan map[string]json.RawMessage, 100), done: make(chan error, 1)}
+
+	go func() {
+		c.done <- lsp.NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+
+	go func() {
+		defer close(c.msgs)
+		for {
+			var length int
+			if _, err := fmt.Fscanf(clientIn, "Content-Length: %d\r\n\r\n", &length); err != nil {
+				return
+			}
+			body := make([]byte, length)
+			if _, err := io.ReadFull(clientIn, body); err != nil {
+				return
+			}
+			msg := map[string]json.RawMessage{}
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("invalid message from server: %s", body)
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+
+	t.Cleanup(func() { clientOut.Close() })
+	return c
+}
+
+func (c *client) send(msg map[string]any) {
+	c.t.Helper()
+
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) next() map[string]json.RawMessage {
+	c.t.Helper()
+
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for a message from the server")
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(map[string]any{"method": method, "params": params})
+}
+
+func (c *client) tryCall(method string, params, result any) error {
+	c.t.Helper()
+
+	c.nextID++
+	id := c.nextID
+	c.send(map[string]any{"id": id, "method": method, "params": params})
+
+	for {
+		msg := c.next()
+		if _, ok := msg["id"]; !ok {
+			c.queued = append(c.queued, msg)
+			continue
+		}
+		if string(msg["id"]) != fmt.Sprint(id) {
+			c.t.Fatalf("unexpected response id %s, want %d", msg["id"], id)
+		}
+		if rpcErr, ok := msg["error"]; ok {
+			return fmt.Errorf("%s", rpcErr)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(msg["result"], result)
+	}
+}
+
+func (c *client) call(method string, params, result any) {
+	c.t.Helper()
+	if err := c.tryCall(method, params, result); err != nil {
+		c.t.Fatalf("%s: %s", method, err)
+	}
+}
+
+func (c *client) open(uri, text string) {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "yy", "version": 1, "text": text},
+	})
+}
+
+func (c *client) change(uri, text string) {
+	c.t.Helper()
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []map[string]any{{"text": text}},
+	})
+}
+
+// diagnostics waits for the next diagnostics published for uri.
+func (c *client) diagnostics(uri string) []lsp.Diagnostic {
+	c.t.Helper()
+
+	for {
+		var msg map[string]json.RawMessage
+		if len(c.queued) > 0 {
+			msg, c.queued = c.queued[0], c.queued[1:]
+		} else {
+			msg = c.next()
+		}
+
+		if string(msg["method"]) != `"textDocument/publishDiagnostics"` {
+			continue
+		}
+		var params lsp.PublishDiagnosticsParams
+		if err := json.Unmarshal(msg["params"], &params); err != nil {
+			c.t.Fatal(err)
+		}
+		if params.URI == uri {
+			return params.Diagnostics
+		}
+	}
+}
+
+func (c *client) wait() error {
+	select {
+	case err := <-c.done:
+		return err
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server to exit")
+		return nil
+	}
+}
+
+func positionParams(uri string, line, char int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": char},
+	}
+}
@@ -1,8 +1,19 @@
 package object
 
+import "yy/ast"
+
+// Environment holds variables of a part of the code. Variables the resolver knows about live in
+// slots, laid out as described by the scope of the environment. The rest (all of them in code that
+// hasn't been resolved, top-level variables and ones declared by yolo mode) are looked up by name.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store  map[string]Object // created on first use
+	scope  *ast.Scope
+	slots  []Object // nil until the variable is declared
+	outer  *Environment
+	depth  int     // number of function calls in progress
+	yolo   bool    // inherited from the outer environment
+	guard  *Guard  // inherited like yolo, nil if the run isn't limited
+	module *Module // set only in the top-level environment of a module
 }
 
 func NewEnvironment() *Environment {
@@ -11,50 +22,192 @@ func NewEnvironment() *Environment {
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return &Environment{
-		store: map[string]Object{},
 		outer: outer,
+		depth: outer.depth,
+		yolo:  outer.yolo,
+		guard: outer.guard,
+	}
+}
+
+// NewScopeEnvironment creates an environment for code in scope, enclosed by outer.
+func NewScopeEnvironment(outer *Environment, scope *ast.Scope) *Environment {
+	return &Environment{
+		scope: scope,
+		slots: make([]Object, len(scope.Names)),
+		outer: outer,
+		depth: outer.depth,
+		yolo:  outer.yolo,
+		guard: outer.guard,
+	}
+}
+
+// NewCallEnvironment creates an environment for a call of a function declared in outer. The call is
+// made from caller, which is one call shallower and runs under the same guard. Scope is the scope of
+// the function, if it's known.
+func NewCallEnvironment(outer, caller *Environment, scope *ast.Scope) *Environment {
+	env := &Environment{
+		scope: scope,
+		outer: outer,
+		depth: caller.depth + 1,
+		yolo:  outer.yolo,
+		guard: caller.guard,
+	}
+	if scope != nil {
+		env.slots = make([]Object, len(scope.Names))
 	}
+	return env
+}
+
+func (e *Environment) Depth() int {
+	return e.depth
+}
+
+// Module returns the module the code running in this environment comes from, or nil if it's
+// unknown.
+func (e *Environment) Module() *Module {
+	for ; e != nil; e = e.outer {
+		if e.module != nil {
+			return e.module
+		}
+	}
+	return nil
+}
+
+func (e *Environment) SetModule(m *Module) {
+	e.module = m
+}
+
+// Host returns the environment enclosing the top-level environment of the module the code running
+// in this environment comes from. It holds what the host running the code added to it, eg print
+// builtins, and is shared by all the modules the code imports. Nil if there's none.
+func (e *Environment) Host() *Environment {
+	for ; e != nil; e = e.outer {
+		if e.module != nil {
+			return e.outer
+		}
+	}
+	return nil
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+	for env := e; env != nil; env = env.outer {
+		if i := env.slotOf(name); i >= 0 && env.slots[i] != nil {
+			return env.slots[i], true
+		}
+		if obj, ok := env.store[name]; ok {
+			return obj, true
+		}
 	}
+	return nil, false
+}
 
-	return obj, ok
+// GetGlobal is like Get, but only looks at variables kept by name. The resolver makes sure none of
+// the slots could hold the variable.
+func (e *Environment) GetGlobal(name string) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if obj, ok := env.store[name]; ok {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+// Slot returns the slot of a variable in the environment depth levels up, which must have been
+// created for scope. If it wasn't (eg because yolo mode rewrote the function the code comes from),
+// it returns nil and the variable has to be looked up by name.
+func (e *Environment) Slot(scope *ast.Scope, depth, slot int) *Object {
+	env := e
+	for ; depth > 0 && env != nil; depth-- {
+		env = env.outer
+	}
+	if env == nil || env.scope != scope {
+		return nil
+	}
+	return &env.slots[slot]
 }
 
+// GetAll returns all variables declared in the environment (but not the outer ones).
 func (e *Environment) GetAll() map[string]Object {
-	return e.store
+	if e.scope == nil {
+		return e.store
+	}
+
+	all := map[string]Object{}
+	for i, name := range e.scope.Names {
+		if e.slots[i] != nil {
+			all[name] = e.slots[i]
+		}
+	}
+	for name, obj := range e.store {
+		all[name] = obj
+	}
+	return all
 }
 
 func (e *Environment) Set(name string, val Object) {
+	if i := e.slotOf(name); i >= 0 {
+		e.slots[i] = val
+		return
+	}
+	if e.store == nil {
+		e.store = map[string]Object{}
+	}
 	e.store[name] = val
 }
 
 // Update tries to update a value for a given name. It first checks if the given name exsist. If it
 // does, it updates the value and returns true. If it doesn't, it returns false.
 func (e *Environment) Update(name string, val Object) bool {
-	if _, ok := e.store[name]; ok {
-		e.store[name] = val
-		return true
+	for env := e; env != nil; env = env.outer {
+		if i := env.slotOf(name); i >= 0 && env.slots[i] != nil {
+			env.slots[i] = val
+			return true
+		}
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
 	}
+	return false
+}
 
-	if e.outer != nil {
-		return e.outer.Update(name, val)
+// UpdateGlobal is like Update, but only looks at variables kept by name, like GetGlobal.
+func (e *Environment) UpdateGlobal(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
 	}
-
 	return false
 }
 
-const yoloKey = "$$yolo"
+func (e *Environment) slotOf(name string) int {
+	if e.scope == nil {
+		return -1
+	}
+	for i, n := range e.scope.Names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
 
 func (e *Environment) SetYoloMode() {
-	e.Set(yoloKey, TRUE)
+	e.yolo = true
 }
 
 func (e *Environment) IsYoloMode() bool {
-	_, ok := e.Get(yoloKey)
-	return ok
+	return e.yolo
+}
+
+// SetGuard makes the guard watch over code running in the environment, and environments created
+// from it afterwards.
+func (e *Environment) SetGuard(g *Guard) {
+	e.guard = g
+}
+
+func (e *Environment) Guard() *Guard {
+	return e.guard
 }
@@ -0,0 +1,82 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxDepth is the maximum number of nested function calls unless Limits say otherwise.
+const DefaultMaxDepth = 10_000
+
+// Limits restrict what a program may do, so untrusted code can't hang or exhaust the process
+// running it. Zero values mean no limit, except for MaxDepth.
+type Limits struct {
+	MaxSteps  int // evaluated expressions, or executed instructions in the vm
+	MaxDepth  int // nested function calls, DefaultMaxDepth if zero
+	MaxLength int // elements of arrays & bytes of strings built by operators, templates & builtins
+}
+
+// ctxCheckInterval is how many steps are made between checks whether the context is done, as
+// checking it on every step would slow things down noticeably.
+const ctxCheckInterval = 1 << 10
+
+// Guard enforces limits and cancellation of a context on a single run of a program. A nil guard
+// doesn't enforce anything.
+type Guard struct {
+	Limits
+
+	ctx     context.Context
+	steps   int
+	stopped string // why the run has been stopped, every following step fails the same way
+}
+
+func NewGuard(ctx context.Context, limits Limits) *Guard {
+	return &Guard{Limits: limits, ctx: ctx}
+}
+
+// Step counts a step of the program, and returns an error if the program has to be stopped.
+func (g *Guard) Step() *Error {
+	if g == nil {
+		return nil
+	}
+	if g.stopped != "" {
+		return g.stop(g.stopped)
+	}
+
+	g.steps++
+	if g.MaxSteps > 0 && g.steps > g.MaxSteps {
+		return g.stop(fmt.Sprintf("step limit exceeded (max %d steps)", g.MaxSteps))
+	}
+	if g.ctx != nil && g.steps%ctxCheckInterval == 0 {
+		switch err := g.ctx.Err(); {
+		case errors.Is(err, context.DeadlineExceeded):
+			return g.stop("timeout exceeded")
+		case err != nil:
+			return g.stop("execution cancelled")
+		}
+	}
+	return nil
+}
+
+// CheckLength returns an error if a string or array of length n would be too long.
+func (g *Guard) CheckLength(n int) *Error {
+	if g == nil || g.MaxLength <= 0 || n <= g.MaxLength {
+		return nil
+	}
+	return g.stop(fmt.Sprintf("length limit exceeded (max %d)", g.MaxLength))
+}
+
+// MaxCallDepth returns the maximum depth of nested function calls.
+func (g *Guard) MaxCallDepth() int {
+	if g == nil || g.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return g.MaxDepth
+}
+
+// stop makes every following step fail. Errors it yeets are fatal, so they can't be caught by ytry.
+func (g *Guard) stop(msg string) *Error {
+	g.stopped = msg
+	return &Error{Msg: msg, Pos: -1, Fatal: true}
+}
@@ -0,0 +1,79 @@
+package object
+
+import "path/filepath"
+
+// Module is a file with YY code. All the modules imported by a program share the same cache, so
+// each module is run only once, no matter how many times it's imported.
+type Module struct {
+	Path     string  // path to the file, empty for code that doesn't come from a file (eg REPL input)
+	Importer *Module // module that imported this one, nil for the main module
+
+	key     string // absolute path, identifies the module in the cache
+	loaded  map[string]Object
+	sources map[string][]byte // source code of modules, if it's known
+}
+
+func NewModule(path string) *Module {
+	return &Module{Path: path, key: moduleKey(path), loaded: map[string]Object{}, sources: map[string][]byte{}}
+}
+
+// Import creates a module for the file at path, imported by m.
+func (m *Module) Import(path string) *Module {
+	return &Module{Path: path, Importer: m, key: moduleKey(path), loaded: m.loaded, sources: m.sources}
+}
+
+// Open creates a module for the file at path, run as the main module of the same program as m: it
+// shares modules imported by m, which aren't run again.
+func (m *Module) Open(path string) *Module {
+	return &Module{Path: path, key: moduleKey(path), loaded: m.loaded, sources: m.sources}
+}
+
+// SetSource records the source code of the module, eg for code that doesn't come from a file.
+func (m *Module) SetSource(src []byte) {
+	m.sources[m.key] = src
+}
+
+// Source returns the source code of the module at path, imported by the same program as m, or nil
+// if it isn't known.
+func (m *Module) Source(path string) []byte {
+	return m.sources[moduleKey(path)]
+}
+
+// Loaded returns whatever the module at path exported, if it has been run already.
+func (m *Module) Loaded(path string) (Object, bool) {
+	exports, ok := m.loaded[moduleKey(path)]
+	return exports, ok
+}
+
+func (m *Module) SetLoaded(path string, exports Object) {
+	m.loaded[moduleKey(path)] = exports
+}
+
+// ImportCycle checks if importing the file at path from m would create a cycle. If so, it returns
+// paths of the modules in the cycle, starting and ending with path.
+func (m *Module) ImportCycle(path string) []string {
+	key := moduleKey(path)
+
+	cycle := []string{path}
+	for mod := m; mod != nil; mod = mod.Importer {
+		cycle = append(cycle, mod.Path)
+		if mod.key == key {
+			// modules were collected from the importer upwards
+			for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+				cycle[i], cycle[j] = cycle[j], cycle[i]
+			}
+			return cycle
+		}
+	}
+	return nil
+}
+
+func moduleKey(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
@@ -2,10 +2,13 @@ package object
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"strconv"
 	"strings"
 
 	"yy/ast"
+	"yy/code"
 )
 
 type Object interface {
@@ -17,6 +20,7 @@ type Type int
 
 const (
 	INTEGER_OBJ Type = iota
+	BIG_INTEGER_OBJ
 	NUMBER_OBJ
 	BOOLEAN_OBJ
 	STRING_OBJ
@@ -30,17 +34,21 @@ const (
 	BUILTIN_OBJ
 	QUOTE_OBJ
 	MACRO_OBJ
+	COMPILED_FUNCTION_OBJ
+	ITERATOR_OBJ
 
 	ERROR_OBJ
 	RETURN_VALUE_OBJ
+	BREAK_OBJ
 )
 
 var objectTypes = [...]string{
-	INTEGER_OBJ: "INTEGER",
-	NUMBER_OBJ:  "NUMBER",
-	BOOLEAN_OBJ: "BOOLEAN",
-	STRING_OBJ:  "STRING",
-	NULL_OBJ:    "NULL",
+	INTEGER_OBJ:     "INTEGER",
+	BIG_INTEGER_OBJ: "INTEGER",
+	NUMBER_OBJ:      "NUMBER",
+	BOOLEAN_OBJ:     "BOOLEAN",
+	STRING_OBJ:      "STRING",
+	NULL_OBJ:        "NULL",
 
 	ARRAY_OBJ:   "ARRAY",
 	HASHMAP_OBJ: "HASHMAP",
@@ -51,8 +59,12 @@ var objectTypes = [...]string{
 	QUOTE_OBJ:    "QUOTE",
 	MACRO_OBJ:    "MACRO",
 
+	COMPILED_FUNCTION_OBJ: "COMPILED_FUNCTION",
+	ITERATOR_OBJ:          "ITERATOR",
+
 	ERROR_OBJ:        "ERROR",
 	RETURN_VALUE_OBJ: "RETURN_VALUE",
+	BREAK_OBJ:        "BREAK",
 }
 
 var (
@@ -73,6 +85,24 @@ type Integer struct {
 func (i *Integer) Type() Type     { return INTEGER_OBJ }
 func (i *Integer) String() string { return strconv.FormatInt(i.Value, 10) }
 
+// BigInteger is an integer that doesn't fit in Integer. Integer arithmetic that overflows yeets
+// one, and it turns back into Integer as soon as the value fits again, so values of the two never
+// overlap. To YY code, both are just integers.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (i *BigInteger) Type() Type     { return BIG_INTEGER_OBJ }
+func (i *BigInteger) String() string { return i.Value.String() }
+
+// NewInteger yeets n as an Integer if it fits in one, or as a BigInteger otherwise.
+func NewInteger(n *big.Int) Object {
+	if n.IsInt64() {
+		return &Integer{Value: n.Int64()}
+	}
+	return &BigInteger{Value: n}
+}
+
 type Number struct {
 	Value float64
 }
@@ -125,11 +155,13 @@ func (a *Array) String() string {
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
+// HashKey is what hashmaps look keys up by. Different keys may have the same HashKey, so keys
+// found by it still have to be compared with EqualKeys.
 type HashKey struct {
-	// TODO handle hash collisions
 	Type  Type
 	Value uint64
 }
@@ -150,8 +182,15 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (i *BigInteger) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: hashString(i.String())}
+}
+
 func (n *Number) HashKey() HashKey {
-	return HashKey{Type: n.Type(), Value: uint64(n.Value)}
+	if n.Value == 0 {
+		return HashKey{Type: n.Type()} // so that -0 is the same key as 0, as they're equal
+	}
+	return HashKey{Type: n.Type(), Value: math.Float64bits(n.Value)}
 }
 
 func (s *String) HashKey() HashKey {
@@ -159,15 +198,24 @@ func (s *String) HashKey() HashKey {
 }
 
 func (a *Array) HashKey() HashKey {
-	return HashKey{Type: a.Type(), Value: hashString(a.String())}
+	hash := uint64(len(a.Elements))
+	for _, e := range a.Elements {
+		hash = hash*31 + hashOf(e)
+	}
+	return HashKey{Type: a.Type(), Value: hash}
 }
 
+// HashKey of a hashmap doesn't depend on the order of its pairs, as it doesn't matter for equality.
 func (h *Hashmap) HashKey() HashKey {
-	return HashKey{Type: h.Type(), Value: hashString(h.String())}
+	var hash uint64
+	for _, pair := range h.pairs {
+		hash += mix(hashOf(pair.Key)*31 + hashOf(pair.Value))
+	}
+	return HashKey{Type: h.Type(), Value: hash}
 }
 
 func (r *Range) HashKey() HashKey {
-	return HashKey{Type: r.Type(), Value: hashString(r.String())}
+	return HashKey{Type: r.Type(), Value: uint64(r.Start)*31 + uint64(r.End)}
 }
 
 func hashString(key string) uint64 {
@@ -180,19 +228,155 @@ func hashString(key string) uint64 {
 	return hash
 }
 
+// hashOf hashes an element of an array or a hashmap, which doesn't have to be hashable itself.
+func hashOf(obj Object) uint64 {
+	if h, ok := obj.(Hashable); ok {
+		key := h.HashKey()
+		return key.Value ^ uint64(key.Type)
+	}
+	return hashString(obj.String())
+}
+
+// mix scrambles bits of a hash (splitmix64 finalizer), so that summing hashes of pairs doesn't
+// cancel them out.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// EqualKeys reports whether a and b are the same key of a hashmap. Keys are compared by value,
+// apart from ones that aren't hashable (eg functions in an array), which are compared by identity.
+func EqualKeys(a, b Object) bool {
+	switch a := a.(type) {
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *BigInteger:
+		b, ok := b.(*BigInteger)
+		return ok && a.Value.Cmp(b.Value) == 0
+	case *Number:
+		b, ok := b.(*Number)
+		return ok && (a.Value == b.Value || math.IsNaN(a.Value) && math.IsNaN(b.Value))
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Range:
+		b, ok := b.(*Range)
+		return ok && a.Start == b.Start && a.End == b.End
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !EqualKeys(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hashmap:
+		b, ok := b.(*Hashmap)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, pair := range a.pairs {
+			val, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !EqualKeys(pair.Value, val) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
 type HashPair struct {
 	Key   Object
 	Value Object
 }
 
+// Hashmap maps keys to values. It remembers the order keys were first inserted in, and iterates
+// over them in that order.
 type Hashmap struct {
-	Pairs map[HashKey]HashPair
+	pairs      []HashPair
+	index      map[HashKey]int   // position of the first pair with the HashKey in pairs
+	collisions map[HashKey][]int // positions of the following ones, created on first collision
+}
+
+func NewHashmap(size int) *Hashmap {
+	return &Hashmap{pairs: make([]HashPair, 0, size), index: make(map[HashKey]int, size)}
+}
+
+func (h *Hashmap) Get(key Hashable) (Object, bool) {
+	i := h.find(key, key.HashKey())
+	if i < 0 {
+		return nil, false
+	}
+	return h.pairs[i].Value, true
+}
+
+// Set inserts a pair, or replaces the value of an existing one, which keeps its position.
+func (h *Hashmap) Set(key Hashable, val Object) {
+	hashKey := key.HashKey()
+	if i := h.find(key, hashKey); i >= 0 {
+		h.pairs[i].Value = val
+		return
+	}
+
+	if h.index == nil {
+		h.index = map[HashKey]int{}
+	}
+	if _, taken := h.index[hashKey]; taken {
+		if h.collisions == nil {
+			h.collisions = map[HashKey][]int{}
+		}
+		h.collisions[hashKey] = append(h.collisions[hashKey], len(h.pairs))
+	} else {
+		h.index[hashKey] = len(h.pairs)
+	}
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: val})
+}
+
+// find returns the position of the pair with the key in pairs, or -1 if there isn't one.
+func (h *Hashmap) find(key Hashable, hashKey HashKey) int {
+	i, ok := h.index[hashKey]
+	if !ok {
+		return -1
+	}
+	if EqualKeys(h.pairs[i].Key, key) {
+		return i
+	}
+	for _, i := range h.collisions[hashKey] {
+		if EqualKeys(h.pairs[i].Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *Hashmap) Len() int {
+	return len(h.pairs)
+}
+
+// Pairs returns pairs of the hashmap in order of insertion. They mustn't be modified.
+func (h *Hashmap) Pairs() []HashPair {
+	return h.pairs
 }
 
 func (h *Hashmap) Type() Type { return HASHMAP_OBJ }
 func (h *Hashmap) String() string {
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.pairs {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.String(), pair.Value.String()))
 	}
 
@@ -206,9 +390,30 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() Type     { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) String() string { return rv.Value.String() }
 
+// Break bubbles up from ybreak (or ycontinue, if Continue is set) to the loop it refers to.
+type Break struct {
+	Label    string // empty for the innermost loop
+	Value    Object
+	Continue bool
+}
+
+func (b *Break) Type() Type     { return BREAK_OBJ }
+func (b *Break) String() string { return b.Value.String() }
+
 type Error struct {
-	Msg string
-	Pos int
+	Msg     string
+	Pos     int
+	File    string       // file Pos refers to, empty if it's unknown
+	Payload Object       // value passed to yikes, if any
+	Trace   []TraceFrame // function calls the error went through, innermost first
+	Fatal   bool         // the program has been stopped (see Guard) or the interpreter broke, ytry can't catch it
+}
+
+// TraceFrame is a function call that was in progress when an error was raised.
+type TraceFrame struct {
+	Fn   string // name of the called function, empty for anonymous functions
+	Pos  int    // offset of the function being called
+	File string // file Pos refers to, empty if it's unknown
 }
 
 func (e *Error) Type() Type     { return ERROR_OBJ }
@@ -216,8 +421,13 @@ func (e *Error) String() string { return e.Msg }
 
 type Lambda struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // default values of the parameters, nil for the required ones
+	Rest       *ast.Identifier  // nil if the lambda has no rest parameter
+	BakedRest  []Object         // args baked into the rest parameter in yolo mode, ahead of args of the call
 	Body       *ast.BlockExpression
 	Env        *Environment
+	Name       string
+	Scope      *ast.Scope // nil if the lambda hasn't been resolved, or has been rewritten by yolo mode
 }
 
 func (f *Lambda) Type() Type { return FUNCTION_OBJ }
@@ -225,9 +435,16 @@ func (f *Lambda) String() string {
 	var b strings.Builder
 
 	params := []string{}
-	for _, p := range f.Parameters {
+	for i, p := range f.Parameters {
+		if def := ast.DefaultOf(f.Defaults, i); def != nil {
+			params = append(params, p.String()+" = "+def.String())
+			continue
+		}
 		params = append(params, p.String())
 	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
 
 	b.WriteString("fun")
 	b.WriteString("(")
@@ -266,16 +483,79 @@ func (m *Macro) String() string {
 
 type BuiltinFunction func(args ...Object) Object
 
+// ApplyFunction calls fn (a lambda, a builtin etc) with args. Every backend calls functions in its
+// own way, so it provides one of these to builtins that need to call functions passed to them.
+type ApplyFunction func(fn Object, args ...Object) Object
+
+// HigherOrderFunction is a builtin that calls functions passed to it, eg map or filter.
+type HigherOrderFunction func(apply ApplyFunction, args ...Object) Object
+
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn          BuiltinFunction
+	HigherOrder HigherOrderFunction
 }
 
 func (b *Builtin) Type() Type     { return BUILTIN_OBJ }
 func (b *Builtin) String() string { return "builtin function" }
 
+// Call calls the builtin, using apply to call any functions it was given.
+func (b *Builtin) Call(apply ApplyFunction, args ...Object) Object {
+	if b.HigherOrder != nil {
+		return b.HigherOrder(apply, args...)
+	}
+	return b.Fn(args...)
+}
+
 type Quote struct {
 	Node ast.Expression
 }
 
 func (q *Quote) Type() Type     { return QUOTE_OBJ }
 func (q *Quote) String() string { return fmt.Sprintf("QUOTE(%s)", q.Node) }
+
+// Upvalue describes a variable captured by a compiled function: either a local of the enclosing
+// function (IsLocal) or one of the enclosing function's own upvalues.
+type Upvalue struct {
+	IsLocal bool
+	Index   int
+}
+
+type CompiledFunction struct {
+	Instructions code.Instructions
+	NumLocals    int
+	Parameters   []string
+	Required     int    // number of parameters without a default value
+	Rest         string // name of the rest parameter, which takes the args left over, empty if there's none
+	Upvalues     []Upvalue
+	Yolo         bool
+	Body         *ast.BlockExpression
+	Name         string
+	File         string
+
+	// Self tells where self goes when the function is called as a method: to local Self. Called any
+	// other way, the function gets self of the code it was declared in, from upvalue OuterSelf (or
+	// global, if OuterSelfGlobal is set). UsesSelf is false if the function doesn't refer to self.
+	UsesSelf        bool
+	Self            int
+	OuterSelf       int
+	OuterSelfGlobal bool
+
+	// SourceMap maps offsets of instructions that can fail to the code they were compiled from.
+	SourceMap map[int]ast.Expression
+}
+
+func (cf *CompiledFunction) Type() Type { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) String() string {
+	var b strings.Builder
+
+	b.WriteString("fun")
+	b.WriteString("(")
+	b.WriteString(strings.Join(cf.Parameters, ", "))
+	b.WriteString(") {\n")
+	if cf.Body != nil {
+		b.WriteString(cf.Body.String())
+	}
+	b.WriteString("\n}")
+
+	return b.String()
+}
@@ -1,9 +1,15 @@
 package object_test
 
 import (
+	"math"
+	"math/big"
+	"strings"
 	"testing"
 
+	"yy/ast"
+	"yy/lexer"
 	"yy/object"
+	"yy/parser"
 )
 
 func TestStringHashKey(t *testing.T) {
@@ -22,3 +28,117 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestNewInteger(t *testing.T) {
+	small := object.NewInteger(big.NewInt(42))
+	if i, ok := small.(*object.Integer); !ok || i.Value != 42 {
+		t.Errorf("expected Integer 42, got %T (%s)", small, small)
+	}
+
+	n, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	huge1, huge2 := object.NewInteger(n), object.NewInteger(new(big.Int).Set(n))
+	if _, ok := huge1.(*object.BigInteger); !ok || huge1.String() != "123456789012345678901234567890" {
+		t.Errorf("expected BigInteger 123456789012345678901234567890, got %T (%s)", huge1, huge1)
+	}
+	if huge1.(object.Hashable).HashKey() != huge2.(object.Hashable).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+}
+
+func TestHashmapOrder(t *testing.T) {
+	h := object.NewHashmap(0)
+	keys := []string{"c", "a", "b"}
+	for i, key := range keys {
+		h.Set(&object.String{Value: key}, &object.Integer{Value: int64(i)})
+	}
+	h.Set(&object.String{Value: "a"}, &object.Integer{Value: 42})
+
+	if h.Len() != len(keys) {
+		t.Fatalf("expected %d pairs, got %d", len(keys), h.Len())
+	}
+	for i, pair := range h.Pairs() {
+		if pair.Key.String() != keys[i] {
+			t.Errorf("expected key %q at %d, got %q", keys[i], i, pair.Key)
+		}
+	}
+	if val, ok := h.Get(&object.String{Value: "a"}); !ok || val.String() != "42" {
+		t.Errorf("expected 42 for key a, got %v", val)
+	}
+	if _, ok := h.Get(&object.String{Value: "d"}); ok {
+		t.Errorf("expected no value for key d")
+	}
+}
+
+func TestHashKeyCollisions(t *testing.T) {
+	// made up so their hash keys are the same
+	a := &object.Range{Start: 0, End: 31}
+	b := &object.Range{Start: 1, End: 0}
+	c := &object.Array{Elements: []object.Object{&object.Integer{Value: 0}, &object.Integer{Value: 31}}}
+	d := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 0}}}
+	if a.HashKey() != b.HashKey() || c.HashKey() != d.HashKey() {
+		t.Fatalf("expected keys to collide")
+	}
+
+	h := object.NewHashmap(0)
+	keys := []object.Hashable{a, b, c, d}
+	for i, key := range keys {
+		h.Set(key, &object.Integer{Value: int64(i)})
+	}
+	h.Set(&object.Range{Start: 1, End: 0}, &object.Integer{Value: 42})
+
+	if h.Len() != len(keys) {
+		t.Fatalf("expected %d pairs, got %d", len(keys), h.Len())
+	}
+	expected := []string{"0", "42", "2", "3"}
+	for i, key := range keys {
+		val, ok := h.Get(key)
+		if !ok || val.String() != expected[i] {
+			t.Errorf("expected %s for key %s, got %v", expected[i], key, val)
+		}
+	}
+}
+
+func TestHashKeys(t *testing.T) {
+	tests := []struct {
+		a, b  object.Hashable
+		equal bool
+	}{
+		{&object.Number{Value: 1.5}, &object.Number{Value: 1.7}, false},
+		{&object.Number{Value: 0}, &object.Number{Value: math.Copysign(0, -1)}, true},
+		{&object.Integer{Value: 1}, &object.Number{Value: 1}, false},
+		{&object.String{Value: "1"}, &object.Integer{Value: 1}, false},
+		{newHashmap("a", "b"), newHashmap("b", "a"), true},
+		{newHashmap("a", "b"), newHashmap("a"), false},
+	}
+
+	for _, tt := range tests {
+		if tt.equal && tt.a.HashKey() != tt.b.HashKey() {
+			t.Errorf("expected %s and %s to have the same hash key", tt.a, tt.b)
+		}
+		if object.EqualKeys(tt.a, tt.b) != tt.equal {
+			t.Errorf("expected EqualKeys(%s, %s) to be %t", tt.a, tt.b, tt.equal)
+		}
+	}
+}
+
+func TestLambdaString(t *testing.T) {
+	p := parser.New(lexer.New(`\a, b = 2, ...r { a }`))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	ll := program.Expressions[0].(*ast.LambdaLiteral)
+	f := &object.Lambda{Parameters: ll.Parameters, Defaults: ll.Defaults, Rest: ll.Rest, Body: ll.Body}
+
+	if s := f.String(); !strings.HasPrefix(s, "fun(a, b = 2, ...r) {") {
+		t.Errorf("expected the parameters to be (a, b = 2, ...r), got %q", s)
+	}
+}
+
+func newHashmap(keys ...string) *object.Hashmap {
+	h := object.NewHashmap(len(keys))
+	for _, key := range keys {
+		h.Set(&object.String{Value: key}, &object.Boolean{Value: true})
+	}
+	return h
+}
@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"yy/ast"
 	"yy/lexer"
@@ -24,6 +27,12 @@ type Parser struct {
 	errors    []yikes.YYError
 	panicMode bool
 
+	grouped map[ast.Expression]bool // expressions wrapped in parentheses
+	rests   map[ast.Expression]bool // '...' in array literals that haven't turned out to be patterns (yet)
+
+	loops []string    // labels of loops being parsed (empty if a loop has none), the innermost last
+	label token.Token // '@' of the label of the loop about to be parsed, if it has one
+
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
 }
@@ -32,6 +41,12 @@ func (p *Parser) Errors() []yikes.YYError {
 	return p.errors
 }
 
+// Parenthesized reports whether expr was wrapped in parentheses in the source. The syntax tree
+// doesn't keep them, but the formatter wants to.
+func (p *Parser) Parenthesized(expr ast.Expression) bool {
+	return p.grouped[expr]
+}
+
 func (p *Parser) advance() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
@@ -69,6 +84,7 @@ const (
 	_ Precedence = iota
 	LOWEST
 	ASSIGNMENT  // = :=
+	PIPELINE    // |>
 	OR          // ||
 	AND         // &&
 	EQUALS      // == !=
@@ -78,7 +94,7 @@ const (
 	PRODUCT     // * /
 	PREFIX      // -x !x
 	CALL        // function(x)
-	INDEX       // array[idx]
+	INDEX       // array[idx] hashmap.field
 )
 
 var precedences = map[token.Type]Precedence{
@@ -90,6 +106,7 @@ var precedences = map[token.Type]Precedence{
 	token.DIV_ASSIGN: ASSIGNMENT,
 	token.MOD_ASSIGN: ASSIGNMENT,
 	token.LT_LT:      ASSIGNMENT,
+	token.PIPE_GT:    PIPELINE,
 	token.OR:         OR,
 	token.AND:        AND,
 	token.EQ:         EQUALS,
@@ -106,6 +123,7 @@ var precedences = map[token.Type]Precedence{
 	token.PERCENT:    PRODUCT,
 	token.LPAREN:     CALL,
 	token.LBRACKET:   INDEX,
+	token.DOT:        INDEX,
 }
 
 func getPrecedence(tok token.Token) Precedence {
@@ -116,7 +134,7 @@ func getPrecedence(tok token.Token) Precedence {
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l}
+	p := &Parser{l: l, grouped: map[ast.Expression]bool{}, rests: map[ast.Expression]bool{}}
 
 	p.prefixParseFns = map[token.Type]prefixParseFn{
 		token.IDENT:        p.parseIdentifier,
@@ -138,6 +156,12 @@ func New(l *lexer.Lexer) *Parser {
 		token.YOLO:         p.parseYoloExpression,
 		token.YALL:         p.parseYallExpression,
 		token.YOYO:         p.parseYoyoExpression,
+		token.YBREAK:       p.parseYbreakExpression,
+		token.YCONTINUE:    p.parseYcontinueExpression,
+		token.AT:           p.parseLabeledLoop,
+		token.YTRY:         p.parseYtryExpression,
+		token.YIMPORT:      p.parseImportExpression,
+		token.YMATCH:       p.parseYmatchExpression,
 		token.BACKSLASH:    p.parseLambdaLiteral,
 		token.MACRO:        p.parseMacroLiteral,
 	}
@@ -158,6 +182,7 @@ func New(l *lexer.Lexer) *Parser {
 		token.GT_EQ:      p.parseInfixExpression,
 		token.LT_LT:      p.parseInfixExpression,
 		token.RANGE:      p.parseRangeLiteral,
+		token.PIPE_GT:    p.parsePipeExpression,
 		token.WALRUS:     p.parseDeclareExpression,
 		token.ASSIGN:     p.parseAssignExpression,
 		token.ADD_ASSIGN: p.parseAssignExpression,
@@ -167,6 +192,7 @@ func New(l *lexer.Lexer) *Parser {
 		token.MOD_ASSIGN: p.parseAssignExpression,
 		token.LPAREN:     p.parseCallExpression,
 		token.LBRACKET:   p.parseIndexExpression,
+		token.DOT:        p.parseDotExpression,
 	}
 
 	// read two tokens, so curToken and peekToken are both set
@@ -181,6 +207,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for p.curToken.Type != token.EOF {
 		expr := p.parseExpression(LOWEST)
+		p.checkRests()
 
 		p.skipSemicolons()
 
@@ -224,6 +251,10 @@ func (p *Parser) parseExpression(precedence Precedence) ast.Expression {
 		if infix == nil {
 			return leftExp
 		}
+		// a line starting with a pattern is a new expression, unless it could be an index
+		if p.peekIs(token.LBRACKET) && p.peekOnNextLine() && p.peekStartsPattern() {
+			return leftExp
+		}
 		p.advance()
 		leftExp = infix(leftExp)
 	}
@@ -250,8 +281,14 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	val, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// integers grow as big as needed, literals too
+		if n, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.IntegerLiteral{Token: p.curToken, Big: n}
+		}
+	}
 	if err != nil {
-		p.errorAtCurrent("could not parse %s as integer", p.peekToken.Literal)
+		p.errorAtCurrent("could not parse %s as integer", p.curToken.Literal)
 		return &ast.BadExpression{Token: p.curToken}
 	}
 
@@ -261,7 +298,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 func (p *Parser) parseNumberLiteral() ast.Expression {
 	val, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		p.errorAtCurrent("could not parse %s as float", p.peekToken.Literal)
+		p.errorAtCurrent("could not parse %s as float", p.curToken.Literal)
 		return &ast.BadExpression{Token: p.curToken}
 	}
 
@@ -269,6 +306,7 @@ func (p *Parser) parseNumberLiteral() ast.Expression {
 }
 
 func (p *Parser) parseTemplatedStringLiteral() ast.Expression {
+	first := p.curToken
 	template := p.curToken.Literal
 	values := []ast.Expression{}
 
@@ -291,7 +329,7 @@ func (p *Parser) parseTemplatedStringLiteral() ast.Expression {
 	}
 
 	return &ast.TemplateStringLiteral{
-		Token:    p.curToken,
+		Token:    first,
 		Template: template,
 		Values:   values,
 	}
@@ -317,6 +355,7 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 		return &ast.BadExpression{Token: p.curToken}
 	}
 
+	p.grouped[expr] = true
 	return expr
 }
 
@@ -332,6 +371,7 @@ func (p *Parser) parseBlockExpression() *ast.BlockExpression {
 		block.Expressions = append(block.Expressions, stmt)
 		p.advance()
 	}
+	block.Rbrace = p.curToken.Offset
 	return block
 }
 
@@ -386,6 +426,7 @@ func (p *Parser) parseYoloExpression() ast.Expression {
 
 func (p *Parser) parseYoyoExpression() ast.Expression {
 	yoyoExpr := &ast.YoyoExpression{Token: p.curToken}
+	yoyoExpr.Label, yoyoExpr.LabelPos = p.takeLabel()
 	p.advance()
 
 	if p.curIs(token.LBRACE) {
@@ -398,17 +439,32 @@ func (p *Parser) parseYoyoExpression() ast.Expression {
 		}
 	}
 
-	yoyoExpr.Body = p.parseBlockExpression()
+	yoyoExpr.Body = p.parseLoopBody(yoyoExpr.Label)
 
 	return yoyoExpr
 }
 
 func (p *Parser) parseYallExpression() ast.Expression {
-	yallExpr := &ast.YallExpression{Token: p.curToken, KeyName: "yt"}
+	yallExpr := &ast.YallExpression{Token: p.curToken, ElemName: "yt"}
+	yallExpr.Label, yallExpr.LabelPos = p.takeLabel()
 	p.advance()
 
-	if p.curIs(token.IDENT) && p.peekIs(token.COLON) {
-		yallExpr.KeyName = p.curToken.Literal
+	// optionally, the loop can name the element, or the index (or the key of a hashmap) and the element
+	if p.curIs(token.IDENT) && p.peekIs(token.COMMA) {
+		yallExpr.IndexName = p.curToken.Literal
+		p.advance()
+
+		if !p.eat(token.IDENT, "missing element name after ',' in 'yall'") {
+			return &ast.BadExpression{Token: p.curToken}
+		}
+		yallExpr.ElemName = p.curToken.Literal
+
+		if !p.eat(token.COLON, "missing ':' after names in 'yall'") {
+			return &ast.BadExpression{Token: p.curToken}
+		}
+		p.advance()
+	} else if p.curIs(token.IDENT) && p.peekIs(token.COLON) {
+		yallExpr.ElemName = p.curToken.Literal
 
 		p.advance()
 		p.advance()
@@ -420,18 +476,237 @@ func (p *Parser) parseYallExpression() ast.Expression {
 		return &ast.BadExpression{Token: p.curToken}
 	}
 
-	yallExpr.Body = p.parseBlockExpression()
+	yallExpr.Body = p.parseLoopBody(yallExpr.Label)
 
 	return yallExpr
 }
 
+// parseLabeledLoop parses a loop preceded by a label, eg '@outer yall arr { ... }'.
+func (p *Parser) parseLabeledLoop() ast.Expression {
+	at := p.curToken
+	if !p.eat(token.IDENT, "missing name of the label after '@'") {
+		return &ast.BadExpression{Token: p.curToken}
+	}
+	label := token.Token{Type: token.AT, Literal: p.curToken.Literal, Offset: at.Offset}
+
+	if !p.peekIs(token.YALL) && !p.peekIs(token.YOYO) {
+		msg := fmt.Sprintf("label has to be followed by a loop (expected 'yall' or 'yoyo', found '%s')", p.peekToken.Literal)
+		p.newError(msg, p.peekToken.Offset)
+		return &ast.BadExpression{Token: p.curToken}
+	}
+	p.advance()
+
+	p.label = label
+	if p.curIs(token.YALL) {
+		return p.parseYallExpression()
+	}
+	return p.parseYoyoExpression()
+}
+
+// takeLabel returns the label of the loop about to be parsed and the offset of it, if it has one.
+func (p *Parser) takeLabel() (string, int) {
+	label := p.label
+	p.label = token.Token{}
+	return label.Literal, label.Offset
+}
+
+func (p *Parser) parseLoopBody(label string) *ast.BlockExpression {
+	p.loops = append(p.loops, label)
+	defer func() { p.loops = p.loops[:len(p.loops)-1] }()
+
+	return p.parseBlockExpression()
+}
+
+func (p *Parser) parseYbreakExpression() ast.Expression {
+	ybreakExpr := &ast.YbreakExpression{Token: p.curToken}
+	if !p.parseLoopLabel(&ybreakExpr.Label) {
+		return &ast.BadExpression{Token: p.curToken}
+	}
+
+	// the value is optional, it has to start on the same line if it's there
+	if !p.peekIs(token.SEMICOLON) && !p.peekIs(token.RBRACE) && !p.peekIs(token.COMMA) && !p.peekIs(token.EOF) && !p.peekOnNextLine() {
+		p.advance()
+		ybreakExpr.Value = p.parseExpression(LOWEST)
+	}
+
+	p.skipSemicolons()
+
+	return ybreakExpr
+}
+
+func (p *Parser) parseYcontinueExpression() ast.Expression {
+	ycontinueExpr := &ast.YcontinueExpression{Token: p.curToken}
+	if !p.parseLoopLabel(&ycontinueExpr.Label) {
+		return &ast.BadExpression{Token: p.curToken}
+	}
+
+	p.skipSemicolons()
+
+	return ycontinueExpr
+}
+
+// parseLoopLabel parses the optional label after ybreak or ycontinue, and makes sure there's a loop
+// it can refer to.
+func (p *Parser) parseLoopLabel(label *string) bool {
+	keyword := p.curToken
+
+	if p.peekIs(token.AT) {
+		p.advance()
+		if !p.eat(token.IDENT, "missing name of the label after '@'") {
+			return false
+		}
+		*label = p.curToken.Literal
+	}
+
+	if len(p.loops) == 0 {
+		p.newError(fmt.Sprintf("'%s' outside of a loop", keyword.Literal), keyword.Offset)
+		return false
+	}
+	if *label == "" {
+		return true
+	}
+	for _, l := range p.loops {
+		if l == *label {
+			return true
+		}
+	}
+	p.errorAtCurrent("unknown loop label '@%s'", *label)
+	return false
+}
+
+// peekOnNextLine reports whether the next token is on a line below the current one.
+func (p *Parser) peekOnNextLine() bool {
+	return strings.Contains(p.l.Input[p.curToken.Offset:p.peekToken.Offset], "\n")
+}
+
+// peekStartsPattern reports whether the next token, '[', starts a destructuring pattern that can't
+// be meant to index the line above: the matching ']' is followed by ':=', which can't follow an
+// index, or by '=' when there's more than one element (or a rest element) between the brackets.
+// The tokens are read from a copy of the lexer, so the parser stays where it is.
+func (p *Parser) peekStartsPattern() bool {
+	l := *p.l
+	index := true
+	for depth := 1; depth > 0; {
+		switch l.NextToken().Type {
+		case token.LBRACKET, token.LPAREN, token.LBRACE, token.HASHMAP:
+			depth++
+		case token.RBRACKET, token.RPAREN, token.RBRACE:
+			depth--
+		case token.COMMA, token.ELLIPSIS:
+			if depth == 1 {
+				index = false
+			}
+		case token.EOF:
+			return false
+		}
+	}
+
+	next := l.NextToken().Type
+	return next == token.WALRUS || (next == token.ASSIGN && !index)
+}
+
+func (p *Parser) parseYtryExpression() ast.Expression {
+	ytryExpr := &ast.YtryExpression{Token: p.curToken, ErrName: "err"}
+
+	if !p.eat(token.LBRACE, "missing opening '{' after 'ytry'") {
+		return &ast.BadExpression{Token: p.curToken}
+	}
+
+	ytryExpr.Body = p.parseBlockExpression()
+
+	if !p.eat(token.YCATCH, "missing 'ycatch' after 'ytry' block") {
+		return &ast.BadExpression{Token: p.curToken}
+	}
+
+	// optionally, the error can be given a name
+	if p.peekIs(token.IDENT) {
+		p.advance()
+		ytryExpr.ErrName = p.curToken.Literal
+	}
+
+	if !p.eat(token.LBRACE, "missing opening '{' after 'ycatch'") {
+		return &ast.BadExpression{Token: p.curToken}
+	}
+
+	ytryExpr.Catch = p.parseBlockExpression()
+
+	return ytryExpr
+}
+
+func (p *Parser) parseYmatchExpression() ast.Expression {
+	ymatchExpr := &ast.YmatchExpression{Token: p.curToken}
+
+	p.advance()
+	ymatchExpr.Subject = p.parseExpression(LOWEST)
+
+	if !p.eat(token.LBRACE, "missing opening '{' after 'ymatch' subject") {
+		return &ast.BadExpression{Token: p.curToken}
+	}
+	ymatchExpr.Lbrace = p.curToken.Offset
+
+	for !p.peekIs(token.RBRACE) && !p.peekIs(token.EOF) {
+		p.advance()
+
+		arm := &ast.MatchArm{Pattern: p.toMatchPattern(p.toPattern(p.parseExpression(LOWEST)))}
+
+		if p.peekIs(token.YIF) {
+			p.advance()
+			p.advance()
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+
+		if !p.eat(token.FAT_ARROW, "missing '=>' after pattern in 'ymatch'") {
+			return &ast.BadExpression{Token: p.curToken}
+		}
+
+		p.advance()
+		arm.Body = p.parseExpression(LOWEST)
+
+		ymatchExpr.Arms = append(ymatchExpr.Arms, arm)
+
+		if p.peekIs(token.COMMA) {
+			p.advance()
+		} else if !p.peekIs(token.RBRACE) {
+			p.errorAtPeek(token.COMMA, "missing comma after arm in 'ymatch'")
+			return &ast.BadExpression{Token: p.curToken}
+		}
+	}
+
+	if !p.eat(token.RBRACE, "missing closing '}' in 'ymatch'") {
+		return &ast.BadExpression{Token: p.curToken}
+	}
+
+	ymatchExpr.Rbrace = p.curToken.Offset
+	return ymatchExpr
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	importExpr := &ast.ImportExpression{Token: p.curToken}
+
+	if !p.eat(token.STRING, "missing path to the module after 'yimport'") {
+		return &ast.BadExpression{Token: p.curToken}
+	}
+
+	importExpr.Path = p.curToken.Literal
+	return importExpr
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	arr := &ast.ArrayLiteral{Token: p.curToken}
 
 	for !p.peekIs(token.RBRACKET) && !p.peekIs(token.EOF) {
 		p.advance()
 
-		arr.Elements = append(arr.Elements, p.parseExpression(LOWEST))
+		if p.curIs(token.ELLIPSIS) {
+			// only valid if the array turns out to be a pattern
+			rest := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
+			p.advance()
+			rest.Right = p.parseExpression(PREFIX)
+			p.rests[rest] = true
+			arr.Elements = append(arr.Elements, rest)
+		} else {
+			arr.Elements = append(arr.Elements, p.parseExpression(LOWEST))
+		}
 
 		if p.peekIs(token.COMMA) {
 			p.advance()
@@ -445,14 +720,12 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 		return &ast.BadExpression{Token: p.curToken}
 	}
 
+	arr.Rbracket = p.curToken.Offset
 	return arr
 }
 
 func (p *Parser) parseHashmapLiteral() ast.Expression {
-	hashmap := &ast.HashmapLiteral{
-		Token: p.curToken,
-		Pairs: map[ast.Expression]ast.Expression{},
-	}
+	hashmap := &ast.HashmapLiteral{Token: p.curToken}
 
 	for !p.peekIs(token.RBRACE) && !p.peekIs(token.EOF) {
 		p.advance()
@@ -466,7 +739,7 @@ func (p *Parser) parseHashmapLiteral() ast.Expression {
 		p.advance()
 		val := p.parseExpression(LOWEST)
 
-		hashmap.Pairs[key] = val
+		hashmap.Pairs = append(hashmap.Pairs, ast.HashmapPair{Key: key, Value: val})
 
 		if p.peekIs(token.COMMA) {
 			p.advance()
@@ -480,6 +753,7 @@ func (p *Parser) parseHashmapLiteral() ast.Expression {
 		return &ast.BadExpression{Token: p.curToken}
 	}
 
+	hashmap.Rbrace = p.curToken.Offset
 	return hashmap
 }
 
@@ -489,13 +763,41 @@ func (p *Parser) parseLambdaLiteral() ast.Expression {
 	for !p.peekIs(token.LBRACE) && !p.peekIs(token.EOF) {
 		p.advance()
 
-		if p.curIs(token.IDENT) {
-			param := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-			fn.Parameters = append(fn.Parameters, param)
-		} else {
+		if fn.Rest != nil {
+			p.errorAtCurrent("'...' has to be the last parameter")
+			return &ast.BadExpression{Token: p.curToken}
+		}
+		rest := p.curIs(token.ELLIPSIS)
+		if rest {
+			p.advance()
+		}
+
+		if !p.curIs(token.IDENT) {
 			p.errorAtCurrent("expected a parameter in lambda declaration, found " + p.curToken.Literal)
 			return &ast.BadExpression{Token: p.curToken}
 		}
+		param := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		switch {
+		case rest:
+			fn.Rest = param
+
+		case p.peekIs(token.ASSIGN):
+			p.advance()
+			p.advance()
+			if fn.Defaults == nil {
+				fn.Defaults = make([]ast.Expression, len(fn.Parameters))
+			}
+			fn.Parameters = append(fn.Parameters, param)
+			fn.Defaults = append(fn.Defaults, p.parseExpression(LOWEST))
+
+		case fn.Defaults != nil:
+			p.errorAtCurrent("a parameter without a default value can't follow one with a default value")
+			return &ast.BadExpression{Token: p.curToken}
+
+		default:
+			fn.Parameters = append(fn.Parameters, param)
+		}
 
 		if p.peekIs(token.COMMA) { // comma is optional
 			p.advance()
@@ -506,7 +808,11 @@ func (p *Parser) parseLambdaLiteral() ast.Expression {
 		return &ast.BadExpression{Token: p.curToken}
 	}
 
+	// the body runs when it's called, outside of loops it's declared in
+	loops := p.loops
+	p.loops = nil
 	fn.Body = p.parseBlockExpression()
+	p.loops = loops
 
 	return fn
 }
@@ -534,7 +840,11 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 		return &ast.BadExpression{Token: p.curToken}
 	}
 
+	// like the body of a lambda, it doesn't run in loops it's declared in
+	loops := p.loops
+	p.loops = nil
 	fn.Body = p.parseBlockExpression()
+	p.loops = loops
 
 	return fn
 }
@@ -555,6 +865,12 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expr
 }
 
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	p.advance()
+	return ast.NewPipeExpression(tok, left, p.parseExpression(PIPELINE))
+}
+
 func (p *Parser) parseAndExpression(left ast.Expression) ast.Expression {
 	expr := &ast.AndExpression{
 		Token: p.curToken,
@@ -580,6 +896,11 @@ func (p *Parser) parseOrExpression(left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseDeclareExpression(maybeIdent ast.Expression) ast.Expression {
+	switch maybeIdent.(type) {
+	case *ast.ArrayLiteral, *ast.HashmapLiteral:
+		return p.parseDestructureExpression(maybeIdent)
+	}
+
 	ident, ok := maybeIdent.(*ast.Identifier)
 	if !ok {
 		p.errorAtCurrent("expected a name when declaring a variable (got '%s')", maybeIdent.TokenLiteral())
@@ -594,6 +915,10 @@ func (p *Parser) parseDeclareExpression(maybeIdent ast.Expression) ast.Expressio
 	p.advance()
 	declExpr.Value = p.parseExpression(LOWEST)
 
+	if lambda, ok := declExpr.Value.(*ast.LambdaLiteral); ok {
+		lambda.Name = ident.Value
+	}
+
 	return declExpr
 }
 
@@ -601,8 +926,14 @@ func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
 	assExpr := &ast.AssignExpression{Token: p.curToken}
 
 	switch left.(type) {
-	case *ast.Identifier, *ast.IndexExpression:
+	case *ast.Identifier, *ast.IndexExpression, *ast.DotExpression:
 		assExpr.Left = left
+	case *ast.ArrayLiteral, *ast.HashmapLiteral:
+		if p.curIs(token.ASSIGN) {
+			return p.parseDestructureExpression(left)
+		}
+		p.errorAtCurrent("destructuring works only with '=' (got '%s')", p.curToken.Literal)
+		return &ast.BadExpression{Token: p.curToken}
 	default:
 		p.errorAtCurrent("expected a variable name or index expression when assigning a value (got '%s')", left.TokenLiteral())
 		return &ast.BadExpression{Token: p.curToken}
@@ -611,21 +942,208 @@ func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
 	p.advance()
 	assExpr.Value = p.parseExpression(LOWEST)
 
-	// desugar a += 5 into a = a + 5
-	switch assExpr.Token.Type {
-	case token.ADD_ASSIGN, token.SUB_ASSIGN, token.MUL_ASSIGN, token.DIV_ASSIGN, token.MOD_ASSIGN:
+	// desugar a += 5 into a = a + 5, keeping the original token around for the formatter
+	if op, ok := compoundOperators[assExpr.Token.Type]; ok {
 		assExpr.Value = &ast.InfixExpression{
+			Token:    token.Token{Type: op, Literal: op.String(), Offset: assExpr.Token.Offset},
 			Left:     assExpr.Left,
 			Right:    assExpr.Value,
-			Operator: string(assExpr.Token.Literal[0]),
+			Operator: op.String(),
 		}
-		assExpr.Token.Type = token.ASSIGN
-		assExpr.Token.Literal = "="
 	}
 
 	return assExpr
 }
 
+// parseDestructureExpression parses a declaration or assignment with a pattern on the left, which
+// was parsed as an array or hashmap literal.
+func (p *Parser) parseDestructureExpression(left ast.Expression) ast.Expression {
+	expr := &ast.DestructureExpression{Token: p.curToken, Pattern: p.toPattern(left)}
+	p.checkTargets(expr.Pattern, expr.Declare())
+
+	p.advance()
+	expr.Value = p.parseExpression(LOWEST)
+
+	return expr
+}
+
+// toPattern turns an array or hashmap literal into a pattern: elements of the literal become
+// targets, and assignments in it targets with default values. Whether the targets can be assigned
+// to is left to checkTargets.
+func (p *Parser) toPattern(expr ast.Expression) ast.Expression {
+	switch expr := expr.(type) {
+	case *ast.ArrayLiteral:
+		pattern := &ast.ArrayPattern{Token: expr.Token, Rbracket: expr.Rbracket}
+		for i, el := range expr.Elements {
+			if p.rests[el] {
+				delete(p.rests, el)
+				if i < len(expr.Elements)-1 {
+					p.newError("'...' has to be the last element of a pattern", el.Pos())
+				}
+				pattern.Rest = p.toPattern(el.(*ast.PrefixExpression).Right)
+				continue
+			}
+			pattern.Elements = append(pattern.Elements, p.toPatternElement(el))
+		}
+		return pattern
+
+	case *ast.HashmapLiteral:
+		pattern := &ast.HashmapPattern{Token: expr.Token, Rbrace: expr.Rbrace}
+		for _, pair := range expr.Pairs {
+			el := p.toPatternElement(pair.Value)
+			el.Key = pair.Key
+			pattern.Pairs = append(pattern.Pairs, el)
+		}
+		return pattern
+	}
+
+	return expr
+}
+
+func (p *Parser) toPatternElement(expr ast.Expression) ast.PatternElement {
+	switch expr := expr.(type) {
+	case *ast.AssignExpression:
+		if expr.Token.Type == token.ASSIGN {
+			return ast.PatternElement{Target: p.toPattern(expr.Left), Default: expr.Value}
+		}
+	case *ast.DestructureExpression:
+		// a nested pattern with a default value has already been parsed as a pattern
+		if !expr.Declare() {
+			return ast.PatternElement{Target: expr.Pattern, Default: expr.Value}
+		}
+	}
+	return ast.PatternElement{Target: p.toPattern(expr)}
+}
+
+// checkTargets reports targets of a pattern that can't be declared (or assigned to).
+func (p *Parser) checkTargets(target ast.Expression, declare bool) {
+	switch target := target.(type) {
+	case *ast.ArrayPattern:
+		for _, el := range target.Elements {
+			p.checkTargets(el.Target, declare)
+		}
+		if target.Rest != nil {
+			p.checkTargets(target.Rest, declare)
+		}
+		return
+	case *ast.HashmapPattern:
+		for _, el := range target.Pairs {
+			p.checkTargets(el.Target, declare)
+		}
+		return
+	case *ast.Identifier:
+		return
+	case *ast.IndexExpression, *ast.DotExpression:
+		if !declare {
+			return
+		}
+	}
+
+	msg := "expected a variable name or index expression in a pattern (got '%s')"
+	if declare {
+		msg = "expected a name when declaring a variable (got '%s')"
+	}
+	p.newError(fmt.Sprintf(msg, target), target.Pos())
+}
+
+// typeNames are the types a type pattern can match.
+var typeNames = map[string]bool{
+	"integer": true, "number": true, "string": true, "boolean": true,
+	"array": true, "hashmap": true, "range": true, "function": true,
+}
+
+// toMatchPattern checks a pattern of a ymatch arm, which can hold literals, ranges of integers and
+// type patterns on top of what destructuring patterns do. Calls of type names become type patterns.
+func (p *Parser) toMatchPattern(pattern ast.Expression) ast.Expression {
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		for i, el := range pattern.Elements {
+			pattern.Elements[i].Target = p.toMatchPattern(el.Target)
+		}
+		if pattern.Rest != nil {
+			pattern.Rest = p.toMatchPattern(pattern.Rest)
+		}
+		return pattern
+
+	case *ast.HashmapPattern:
+		for i, el := range pattern.Pairs {
+			if !isLiteralPattern(el.Key) {
+				p.newError(fmt.Sprintf("keys in a 'ymatch' pattern have to be literals (got '%s')", el.Key), el.Key.Pos())
+			}
+			pattern.Pairs[i].Target = p.toMatchPattern(el.Target)
+		}
+		return pattern
+
+	case *ast.CallExpression:
+		ident, ok := pattern.Function.(*ast.Identifier)
+		if !ok || !typeNames[ident.Value] || len(pattern.Arguments) != 1 {
+			break
+		}
+		target := p.toMatchPattern(p.toPattern(pattern.Arguments[0]))
+		return &ast.TypePattern{Token: ident.Token, Type: ident.Value, Target: target}
+
+	case *ast.RangeLiteral:
+		if isIntegerPattern(pattern.Start) && isIntegerPattern(pattern.End) {
+			return pattern
+		}
+		p.newError(fmt.Sprintf("ranges in a 'ymatch' pattern have to be made of integers (got '%s')", pattern), pattern.Pos())
+		return pattern
+
+	case *ast.Identifier, *ast.BadExpression:
+		return pattern
+	}
+
+	if !isLiteralPattern(pattern) {
+		p.newError(fmt.Sprintf("expected a pattern in 'ymatch' (got '%s')", pattern), pattern.Pos())
+	}
+	return pattern
+}
+
+func isLiteralPattern(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral, *ast.NumberLiteral, *ast.StringLiteral, *ast.BooleanLiteral, *ast.NullLiteral:
+		return true
+	case *ast.PrefixExpression:
+		_, ok := expr.Right.(*ast.NumberLiteral)
+		return ok && expr.Operator == "-" || isIntegerPattern(expr)
+	}
+	return false
+}
+
+func isIntegerPattern(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return true
+	case *ast.PrefixExpression:
+		_, ok := expr.Right.(*ast.IntegerLiteral)
+		return ok && expr.Operator == "-"
+	}
+	return false
+}
+
+// checkRests reports the first '...' used outside of patterns. The expression it's in parsed fine
+// otherwise, so there's nothing to recover from.
+func (p *Parser) checkRests() {
+	first := -1
+	for rest := range p.rests {
+		if first < 0 || rest.Pos() < first {
+			first = rest.Pos()
+		}
+		delete(p.rests, rest)
+	}
+	if first >= 0 && !p.panicMode {
+		p.errors = append(p.errors, yikes.YYError{Msg: "'...' can only be used in a pattern", Offset: first})
+	}
+}
+
+var compoundOperators = map[token.Type]token.Type{
+	token.ADD_ASSIGN: token.PLUS,
+	token.SUB_ASSIGN: token.MINUS,
+	token.MUL_ASSIGN: token.ASTERISK,
+	token.DIV_ASSIGN: token.SLASH,
+	token.MOD_ASSIGN: token.PERCENT,
+}
+
 func (p *Parser) parseRangeLiteral(left ast.Expression) ast.Expression {
 	rangeLit := &ast.RangeLiteral{
 		Token: p.curToken,
@@ -633,7 +1151,7 @@ func (p *Parser) parseRangeLiteral(left ast.Expression) ast.Expression {
 	}
 
 	p.advance()
-	rangeLit.End = p.parseExpression(LOWEST)
+	rangeLit.End = p.parseExpression(RANGE)
 
 	return rangeLit
 }
@@ -654,16 +1172,43 @@ func (p *Parser) parseIndexExpression(array ast.Expression) ast.Expression {
 	return indexExpr
 }
 
+func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	dotExpr := &ast.DotExpression{
+		Token: p.curToken,
+		Left:  left,
+	}
+
+	if !p.eat(token.IDENT, "missing field name after '.'") {
+		return &ast.BadExpression{Token: p.curToken}
+	}
+	dotExpr.Name, dotExpr.NamePos = p.curToken.Literal, p.curToken.Offset
+
+	return dotExpr
+}
+
 func (p *Parser) parseCallExpression(fn ast.Expression) ast.Expression {
 	callExpr := &ast.CallExpression{
 		Token:    p.curToken,
 		Function: fn,
 	}
 
+	named := false
 	for !p.peekIs(token.RPAREN) && !p.peekIs(token.EOF) {
 		p.advance()
 
-		callExpr.Arguments = append(callExpr.Arguments, p.parseExpression(LOWEST))
+		if p.curIs(token.IDENT) && p.peekIs(token.COLON) {
+			arg := &ast.NamedArgument{Token: p.curToken, Name: p.curToken.Literal}
+			p.advance()
+			p.advance()
+			arg.Value = p.parseExpression(LOWEST)
+			callExpr.Arguments = append(callExpr.Arguments, arg)
+			named = true
+		} else if named {
+			p.errorAtCurrent("positional args have to come before named ones")
+			return &ast.BadExpression{Token: p.curToken}
+		} else {
+			callExpr.Arguments = append(callExpr.Arguments, p.parseExpression(LOWEST))
+		}
 
 		if p.peekIs(token.COMMA) {
 			p.advance()
@@ -677,6 +1222,7 @@ func (p *Parser) parseCallExpression(fn ast.Expression) ast.Expression {
 		return &ast.BadExpression{Token: p.curToken}
 	}
 
+	callExpr.Rparen = p.curToken.Offset
 	return callExpr
 }
 
@@ -715,7 +1261,7 @@ func (p *Parser) sync() {
 		}
 
 		switch p.peekToken.Type {
-		case token.YEET, token.YIF, token.YALL, token.YOYO, token.YOLO, token.BACKSLASH, token.MACRO:
+		case token.YEET, token.YIF, token.YALL, token.YOYO, token.YBREAK, token.YCONTINUE, token.YOLO, token.YTRY, token.YIMPORT, token.YMATCH, token.BACKSLASH, token.MACRO:
 			return
 
 		default:
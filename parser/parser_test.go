@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 
 	"yy/ast"
@@ -50,6 +51,110 @@ func TestAssignExpression(t *testing.T) {
 	}
 }
 
+func TestDestructureExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[a, b] := x", "([a, b] := x)"},
+		{"[head, ...tail] = [1, 2, 3]", "([head, ...tail] = [1, 2, 3])"},
+		{`%{"name": n, "age": a = 30} := person`, `({"name":n, "age":a = 30} := person)`},
+		{"[a, [b, c] = [1, 2], %{1: d}] := x", "([a, [b, c] = [1, 2], {1:d}] := x)"},
+		{"[h[0], h.x, ...r] = x", "([(h[0]), (h.x), ...r] = x)"},
+		{"f()\n[a, b] := x", "f()"},
+		{"f()\n[a, b] = x", "f()"},
+		{"f()\n[...a] = x", "f()"},
+		// a line starting with '[' that could be an index indexes the line above, like it always has
+		{"arr\n[i] = 5", "((arr[i]) = 5)"},
+		{"arr\n[f(a, b)] = 5", "((arr[f(a, b)]) = 5)"},
+		{"b := a\n[1]", "(b := (a[1]))"},
+		{"b := a\n[f([1])]\n[0] += 1", "(b := (((a[f([1])])[0]) = (((a[f([1])])[0]) + 1)))"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if program.Expressions[0].String() != tt.expected {
+			t.Errorf("wrong expression. want %s, got %s", tt.expected, program.Expressions[0].String())
+		}
+	}
+
+	errTests := []struct {
+		input          string
+		expectedErrMsg string
+	}{
+		{"[a, b.c] := x", "expected a name when declaring a variable (got '(b.c)')"},
+		{"[a, 1] = x", "expected a variable name or index expression in a pattern (got '1')"},
+		{"[...a, b] := x", "'...' has to be the last element of a pattern"},
+		{"x := [...a]", "'...' can only be used in a pattern"},
+		{"[a, b] += x", "destructuring works only with '=' (got '+=')"},
+	}
+
+	for _, tt := range errTests {
+		parser := parser.New(lexer.New(tt.input))
+		_ = parser.ParseProgram()
+		errors := parser.Errors()
+
+		if len(errors) == 0 {
+			t.Errorf("expected parsing error for %q", tt.input)
+			continue
+		}
+
+		if errors[0].Msg != tt.expectedErrMsg {
+			t.Errorf("Wrong error msg, want `%s`, got `%s`", tt.expectedErrMsg, errors[0].Msg)
+		}
+	}
+}
+
+func TestYmatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`ymatch x { 1 => "one", _ => "many" }`, `ymatch x { 1 => "one", _ => "many" }`},
+		{"ymatch x {}", "ymatch x {  }"},
+		{"ymatch x { -1..9 => a, -2.5 => b, null => c }", "ymatch x { ((-1)..9) => a, (-2.5) => b, null => c }"},
+		{"ymatch f(x) { n yif n > 0 => n }", "ymatch f(x) { n yif (n > 0) => n }"},
+		{"ymatch x { [a, integer(b), ...r] => a, %{\"k\": string(s), \"n\": n = 0} => s }", "ymatch x { [a, integer(b), ...r] => a, {\"k\":string(s), \"n\":n = 0} => s }"},
+		{"ymatch x { array([a]) => a, integer(_) => 0 }", "ymatch x { array([a]) => a, integer(_) => 0 }"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if program.Expressions[0].String() != tt.expected {
+			t.Errorf("wrong expression. want %s, got %s", tt.expected, program.Expressions[0].String())
+		}
+	}
+
+	errTests := []struct {
+		input          string
+		expectedErrMsg string
+	}{
+		{"ymatch x { a + 1 => a }", "expected a pattern in 'ymatch' (got '(a + 1)')"},
+		{"ymatch x { 1..n => a }", "ranges in a 'ymatch' pattern have to be made of integers (got '(1..n)')"},
+		{"ymatch x { %{k: v} => v }", "keys in a 'ymatch' pattern have to be literals (got 'k')"},
+		{"ymatch x { foo(a) => a }", "expected a pattern in 'ymatch' (got 'foo(a)')"},
+		{"ymatch x { [h.x] => 1 }", "expected a pattern in 'ymatch' (got '(h.x)')"},
+		{"ymatch x { 1 2 }", "missing '=>' after pattern in 'ymatch' (expected '=>', found '2')"},
+		{"ymatch x { 1 => 2 3 => 4 }", "missing comma after arm in 'ymatch' (expected ',', found '3')"},
+		{"ymatch x 1 => 2", "missing opening '{' after 'ymatch' subject (expected '{', found '1')"},
+	}
+
+	for _, tt := range errTests {
+		parser := parser.New(lexer.New(tt.input))
+		_ = parser.ParseProgram()
+		errors := parser.Errors()
+
+		if len(errors) == 0 {
+			t.Errorf("expected parsing error for %q", tt.input)
+			continue
+		}
+
+		if errors[0].Msg != tt.expectedErrMsg {
+			t.Errorf("Wrong error msg, want `%s`, got `%s`", tt.expectedErrMsg, errors[0].Msg)
+		}
+	}
+}
+
 func TestYeetExpressions(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -99,6 +204,20 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	expr := parseSingleExpr(t, "15511210043330985984000000;")
+	lit, ok := expr.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("expr not *ast.IntegerLiteral. got=%T", expr)
+	}
+	if lit.Big == nil || lit.Big.String() != "15511210043330985984000000" {
+		t.Errorf("lit.Big not 15511210043330985984000000. got=%v", lit.Big)
+	}
+	if lit.String() != "15511210043330985984000000" {
+		t.Errorf("lit.String() not 15511210043330985984000000. got=%s", lit.String())
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	testCases := []struct {
 		input    string
@@ -258,6 +377,7 @@ func TestParsingHashLiterals(t *testing.T) {
 
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `%{"one": 1, "two": 2, "three": 3}`
+	expectedKeys := []string{"one", "two", "three"}
 	expected := map[string]int64{"one": 1, "two": 2, "three": 3}
 
 	expr := parseSingleExpr(t, input)
@@ -269,13 +389,18 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		// pairs keep the order they were written in
+		if i < len(expectedKeys) && literal.Value != expectedKeys[i] {
+			t.Errorf("key %d is wrong. want=%q, got=%q", i, expectedKeys[i], literal.Value)
 		}
 		expectedValue := expected[literal.Value]
-		if err := testIntegerLiteral(value, expectedValue); err != nil {
+		if err := testIntegerLiteral(pair.Value, expectedValue); err != nil {
 			t.Error(err)
 		}
 	}
@@ -304,10 +429,10 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 			continue
 		}
 		testFunc, ok := tests[literal.Value]
@@ -315,7 +440,7 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			t.Errorf("No test function for key %q found", literal.Value)
 			continue
 		}
-		if err := testFunc(value); err != nil {
+		if err := testFunc(pair.Value); err != nil {
 			t.Error(err)
 		}
 	}
@@ -337,6 +462,44 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingDotExpressions(t *testing.T) {
+	expr := parseSingleExpr(t, "doggo.name")
+	dotExpr, ok := expr.(*ast.DotExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.DotExpression. got=%T", expr)
+	}
+	if err := testIdentifier(dotExpr.Left, "doggo"); err != nil {
+		t.Error(err)
+	}
+	if dotExpr.Name != "name" || dotExpr.NamePos != 6 {
+		t.Errorf("wrong field. want name at 6, got %s at %d", dotExpr.Name, dotExpr.NamePos)
+	}
+
+	tests := []struct {
+		input          string
+		expectedErrMsg string
+	}{
+		{"a.", "missing field name after '.'"},
+		{"a.1", "missing field name after '.'"},
+		{"a.\\{ 1 }", "missing field name after '.'"},
+	}
+
+	for _, tt := range tests {
+		parser := parser.New(lexer.New(tt.input))
+		_ = parser.ParseProgram()
+		errors := parser.Errors()
+
+		if len(errors) == 0 {
+			t.Errorf("expected parsing error for %q", tt.input)
+			continue
+		}
+
+		if !strings.HasPrefix(errors[0].Msg, tt.expectedErrMsg) {
+			t.Errorf("Wrong error msg, want `%s`, got `%s`", tt.expectedErrMsg, errors[0].Msg)
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -550,6 +713,46 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"r := 1 + 2 .. 8 * 2",
 			"(r := ((1 + 2)..(8 * 2)));",
 		},
+		{
+			"a.b.c",
+			"((a.b).c);",
+		},
+		{
+			"-a.b(1)[2]",
+			"(-((a.b)(1)[2]));",
+		},
+		{
+			"a.b..c.d",
+			"((a.b)..(c.d));",
+		},
+		{
+			"a.b += 1.5",
+			"((a.b) = ((a.b) + 1.5));",
+		},
+		{
+			"1..n |> f",
+			"((1..n) |> f);",
+		},
+		{
+			"a |> f",
+			"(a |> f);",
+		},
+		{
+			"a + 1 |> f(b) |> g",
+			"(((a + 1) |> f(b)) |> g);",
+		},
+		{
+			"a || b |> f",
+			"((a || b) |> f);",
+		},
+		{
+			"x := a |> f",
+			"(x := (a |> f));",
+		},
+		{
+			"a |> (f |> g)",
+			"(a |> (f |> g));",
+		},
 	}
 
 	for _, tt := range tests {
@@ -666,28 +869,46 @@ func TestYoyoExpression(t *testing.T) {
 func TestYallExpression(t *testing.T) {
 	tests := []struct {
 		input    string
+		index    string
 		name     string
 		iterable string
 		body     string
 	}{
 		{
 			"yall array { yt }",
+			"",
 			"yt",
 			"array",
 			"{ yt }",
 		},
 		{
 			"yall i: array { i }",
+			"",
 			"i",
 			"array",
 			"{ i }",
 		},
 		{
 			"yall yt: array { yt }",
+			"",
 			"yt",
 			"array",
 			"{ yt }",
 		},
+		{
+			"yall i, elt: array { elt }",
+			"i",
+			"elt",
+			"array",
+			"{ elt }",
+		},
+		{
+			`yall k, v: %{"a": 1} { v }`,
+			"k",
+			"v",
+			`{"a":1}`,
+			"{ v }",
+		},
 	}
 
 	for _, tt := range tests {
@@ -698,8 +919,12 @@ func TestYallExpression(t *testing.T) {
 			t.Fatalf("expr is not ast.YallExpression. got=%T", expr)
 		}
 
-		if yallExpr.KeyName != tt.name {
-			t.Errorf("KeyName is not %s. got=%s", tt.name, yallExpr.KeyName)
+		if yallExpr.IndexName != tt.index {
+			t.Errorf("IndexName is not %q. got=%q", tt.index, yallExpr.IndexName)
+		}
+
+		if yallExpr.ElemName != tt.name {
+			t.Errorf("ElemName is not %s. got=%s", tt.name, yallExpr.ElemName)
 		}
 
 		if yallExpr.Iterable.String() != tt.iterable {
@@ -712,6 +937,183 @@ func TestYallExpression(t *testing.T) {
 	}
 }
 
+func TestYallErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrMsg string
+	}{
+		{"yall arr", "missing opening '{' after 'yall'"},
+		{"yall i, : arr { i }", "missing element name after ',' in 'yall'"},
+		{"yall i, elt arr { i }", "missing ':' after names in 'yall'"},
+	}
+
+	for _, tt := range tests {
+		parser := parser.New(lexer.New(tt.input))
+		_ = parser.ParseProgram()
+		errors := parser.Errors()
+
+		if len(errors) == 0 {
+			t.Errorf("expected parsing error for %q", tt.input)
+			continue
+		}
+
+		if !strings.HasPrefix(errors[0].Msg, tt.expectedErrMsg) {
+			t.Errorf("Wrong error msg, want `%s`, got `%s`", tt.expectedErrMsg, errors[0].Msg)
+		}
+	}
+}
+
+func TestYbreakYcontinue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"yall arr { ybreak }", "yall yt: arr { { ybreak } }"},
+		{"yall arr { ybreak yt + 1 }", "yall yt: arr { { ybreak (yt + 1) } }"},
+		{"yall arr { ybreak; 1 }", "yall yt: arr { { ybreak; 1 } }"},
+		{"yall arr { ybreak\n1 }", "yall yt: arr { { ybreak; 1 } }"},
+		{"yoyo { yif x { ycontinue } }", "yoyo { { { yif x { ycontinue } } }"},
+		{"@outer yall arr { ybreak @outer 5 }", "@outer yall yt: arr { { ybreak @outer 5 } }"},
+		{"@outer yoyo { yall arr { ycontinue @outer } }", "@outer yoyo { { { yall yt: arr { { ycontinue @outer } } } }"},
+	}
+
+	for _, tt := range tests {
+		expr := parseSingleExpr(t, tt.input)
+		if expr.String() != tt.expected {
+			t.Errorf("wrong String() of %q. want %q, got %q", tt.input, tt.expected, expr.String())
+		}
+	}
+}
+
+func TestYbreakYcontinueErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrMsg string
+	}{
+		{"ybreak", "'ybreak' outside of a loop"},
+		{"ycontinue", "'ycontinue' outside of a loop"},
+		{"yif x { ybreak 1 }", "'ybreak' outside of a loop"},
+		{"yall arr { \\{ ybreak } }", "'ybreak' outside of a loop"},
+		{"yoyo ybreak { 1 }", "'ybreak' outside of a loop"},
+		{"yall arr { ybreak @outer }", "unknown loop label '@outer'"},
+		{"@outer yall arr { 1 }; yall arr { ycontinue @outer }", "unknown loop label '@outer'"},
+		{"yall arr { ybreak @ }", "missing name of the label after '@'"},
+		{"@outer x", "label has to be followed by a loop (expected 'yall' or 'yoyo', found 'x')"},
+	}
+
+	for _, tt := range tests {
+		parser := parser.New(lexer.New(tt.input))
+		_ = parser.ParseProgram()
+		errors := parser.Errors()
+
+		if len(errors) == 0 {
+			t.Errorf("expected parsing error for %q", tt.input)
+			continue
+		}
+
+		if !strings.HasPrefix(errors[0].Msg, tt.expectedErrMsg) {
+			t.Errorf("Wrong error msg, want `%s`, got `%s`", tt.expectedErrMsg, errors[0].Msg)
+		}
+	}
+}
+
+func TestYtryExpression(t *testing.T) {
+	tests := []struct {
+		input   string
+		body    string
+		errName string
+		catch   string
+	}{
+		{
+			"ytry { x } ycatch { err }",
+			"{ x }",
+			"err",
+			"{ err }",
+		},
+		{
+			"ytry { yikes(1) } ycatch e { e; 2 }",
+			"{ yikes(1) }",
+			"e",
+			"{ e; 2 }",
+		},
+	}
+
+	for _, tt := range tests {
+		expr := parseSingleExpr(t, tt.input)
+
+		ytryExpr, ok := expr.(*ast.YtryExpression)
+		if !ok {
+			t.Fatalf("expr is not ast.YtryExpression. got=%T", expr)
+		}
+
+		if ytryExpr.Body.String() != tt.body {
+			t.Errorf("Body is not %s. got=%s", tt.body, ytryExpr.Body)
+		}
+
+		if ytryExpr.ErrName != tt.errName {
+			t.Errorf("ErrName is not %s. got=%s", tt.errName, ytryExpr.ErrName)
+		}
+
+		if ytryExpr.Catch.String() != tt.catch {
+			t.Errorf("Catch is not %s. got=%s", tt.catch, ytryExpr.Catch)
+		}
+	}
+}
+
+func TestYtryErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrMsg string
+	}{
+		{"ytry x", "missing opening '{' after 'ytry'"},
+		{"ytry { x }", "missing 'ycatch' after 'ytry' block"},
+		{"ytry { x } ycatch err", "missing opening '{' after 'ycatch'"},
+	}
+
+	for _, tt := range tests {
+		parser := parser.New(lexer.New(tt.input))
+		_ = parser.ParseProgram()
+		errors := parser.Errors()
+
+		if len(errors) == 0 {
+			t.Errorf("expected parsing error for %q", tt.input)
+			continue
+		}
+
+		if !strings.HasPrefix(errors[0].Msg, tt.expectedErrMsg) {
+			t.Errorf("Wrong error msg, want `%s`, got `%s`", tt.expectedErrMsg, errors[0].Msg)
+		}
+	}
+}
+
+func TestImportExpression(t *testing.T) {
+	expr := parseSingleExpr(t, `yimport "lib/regex.yeet"`)
+
+	importExpr, ok := expr.(*ast.ImportExpression)
+	if !ok {
+		t.Fatalf("expr is not ast.ImportExpression. got=%T", expr)
+	}
+
+	if importExpr.Path != "lib/regex.yeet" {
+		t.Errorf("Path is not %q. got=%q", "lib/regex.yeet", importExpr.Path)
+	}
+
+	for _, input := range []string{"yimport", "yimport regex"} {
+		parser := parser.New(lexer.New(input))
+		_ = parser.ParseProgram()
+		errors := parser.Errors()
+
+		if len(errors) == 0 {
+			t.Errorf("expected parsing error for %q", input)
+			continue
+		}
+
+		if !strings.HasPrefix(errors[0].Msg, "missing path to the module after 'yimport'") {
+			t.Errorf("Wrong error msg, want `%s`, got `%s`", "missing path to the module after 'yimport'", errors[0].Msg)
+		}
+	}
+}
+
 func TestYifYelsExpression(t *testing.T) {
 	input := `yif (x < y) { x } yels { y }`
 	expr := parseSingleExpr(t, input)
@@ -833,6 +1235,35 @@ func TestLambdaLiteralParsing(t *testing.T) {
 	}
 }
 
+func TestLambdaLiteralWithName(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedName string
+	}{
+		{`my_fun := \{ 1 }`, "my_fun"},
+		{`my_fun = \{ 1 }`, ""},
+		{`\{ 1 }`, ""},
+	}
+
+	for _, tt := range tests {
+		var lambda *ast.LambdaLiteral
+		switch expr := parseSingleExpr(t, tt.input).(type) {
+		case *ast.DeclareExpression:
+			lambda = expr.Value.(*ast.LambdaLiteral)
+		case *ast.AssignExpression:
+			lambda = expr.Value.(*ast.LambdaLiteral)
+		case *ast.LambdaLiteral:
+			lambda = expr
+		default:
+			t.Fatalf("unexpected expr type %T", expr)
+		}
+
+		if lambda.Name != tt.expectedName {
+			t.Errorf("lambda name wrong. want %q, got %q", tt.expectedName, lambda.Name)
+		}
+	}
+}
+
 func TestMacroLiteralParsing(t *testing.T) {
 	input := `@\x, y { x + y; }`
 	expr := parseSingleExpr(t, input)
@@ -889,6 +1320,77 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`\a, b = 10 { a }`, "\\(a, b = 10) { a }"},
+		{`\a = 1 + 2, b = a * 2 { a }`, "\\(a = (1 + 2), b = (a * 2)) { a }"},
+		{`\...rest { rest }`, "\\(...rest) { rest }"},
+		{`\a, b = [1, 2], ...rest { a }`, "\\(a, b = [1, 2], ...rest) { a }"},
+		{`\a b = 1 ...rest { a }`, "\\(a, b = 1, ...rest) { a }"},
+		{`\a = \x { x } { a }`, "\\(a = \\(x) { x }) { a }"},
+	}
+
+	for _, tt := range tests {
+		expr := parseSingleExpr(t, tt.input)
+		if _, ok := expr.(*ast.LambdaLiteral); !ok {
+			t.Fatalf("expr is not ast.LambdaLiteral. got=%T", expr)
+		}
+		if got := expr.String(); got != tt.expected {
+			t.Errorf("wrong lambda for %q. want %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`greet(name: "Bob")`, `greet(name: "Bob")`},
+		{`greet(message: "hi", name: "Bob")`, `greet(message: "hi", name: "Bob")`},
+		{`greet(1, n: 2 + 3)`, `greet(1, n: (2 + 3))`},
+		{`greet(%{"a": 1}, n: x)`, `greet({"a":1}, n: x)`},
+		{`x |> greet(n: 1)`, `(x |> greet(n: 1))`},
+	}
+
+	for _, tt := range tests {
+		expr := parseSingleExpr(t, tt.input)
+		if got := expr.String(); got != tt.expected {
+			t.Errorf("wrong call for %q. want %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestParameterErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrMsg string
+	}{
+		{`\...rest, a { a }`, "'...' has to be the last parameter"},
+		{`\a = 1, b { a }`, "a parameter without a default value can't follow one with a default value"},
+		{`\a = { a }`, "missing opening '{' before lambda body"},
+		{`f(a: 1, 2)`, "positional args have to come before named ones"},
+	}
+
+	for _, tt := range tests {
+		parser := parser.New(lexer.New(tt.input))
+		_ = parser.ParseProgram()
+		errors := parser.Errors()
+
+		if len(errors) == 0 {
+			t.Errorf("expected parsing error for %q", tt.input)
+			continue
+		}
+
+		if !strings.HasPrefix(errors[0].Msg, tt.expectedErrMsg) {
+			t.Errorf("Wrong error msg, want `%s`, got `%s`", tt.expectedErrMsg, errors[0].Msg)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "myFunction(1, 2 * 3, 4 + 5);"
 	expr := parseSingleExpr(t, input)
@@ -914,6 +1416,33 @@ func TestCallExpressionParsing(t *testing.T) {
 	}
 }
 
+func TestParenthesized(t *testing.T) {
+	l := lexer.New("(a + b) * (c) - d")
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	sub := program.Expressions[0].(*ast.InfixExpression)
+	mul := sub.Left.(*ast.InfixExpression)
+
+	tests := []struct {
+		expr     ast.Expression
+		expected bool
+	}{
+		{sub, false},
+		{mul, false},
+		{mul.Left, true},
+		{mul.Right, true},
+		{sub.Right, false},
+	}
+
+	for _, tt := range tests {
+		if got := p.Parenthesized(tt.expr); got != tt.expected {
+			t.Errorf("Parenthesized(%s) wrong. expected=%t, got=%t", tt.expr, tt.expected, got)
+		}
+	}
+}
+
 func TestCallExpressionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input        string
@@ -1,5 +1,7 @@
 package token
 
+import "sort"
+
 type Token struct {
 	Type    Type
 	Literal string
@@ -12,6 +14,7 @@ const (
 	_ Type = iota
 	EOF
 	ERROR
+	COMMENT
 
 	// Identifiers + literals.
 
@@ -49,8 +52,11 @@ const (
 	LT_EQ
 	GT_EQ
 	LT_LT
+	PIPE_GT
+	FAT_ARROW
 	WALRUS
 	RANGE
+	ELLIPSIS
 	MACRO
 	HASHMAP
 
@@ -77,12 +83,19 @@ const (
 	YOYO
 	YOLO
 	YALL
+	YBREAK
+	YCONTINUE
 	YET
+	YTRY
+	YCATCH
+	YIMPORT
+	YMATCH
 )
 
 var tokens = [...]string{
-	EOF:   "EOF",
-	ERROR: "ERROR",
+	EOF:     "EOF",
+	ERROR:   "ERROR",
+	COMMENT: "COMMENT",
 
 	// Identifiers + literals
 
@@ -121,8 +134,11 @@ var tokens = [...]string{
 	LT_EQ:      "<=",
 	GT_EQ:      ">=",
 	LT_LT:      "<<",
+	PIPE_GT:    "|>",
+	FAT_ARROW:  "=>",
 	WALRUS:     ":=",
 	RANGE:      "..",
+	ELLIPSIS:   "...",
 	MACRO:      `@\`,
 	HASHMAP:    "%{",
 
@@ -140,15 +156,21 @@ var tokens = [...]string{
 
 	// Keywords
 
-	TRUE:  "TRUE",
-	FALSE: "FALSE",
-	NULL:  "NULL",
-	YIF:   "YIF",
-	YELS:  "YELS",
-	YEET:  "YEET",
-	YOLO:  "YOLO",
-	YALL:  "YALL",
-	YET:   "YET",
+	TRUE:      "TRUE",
+	FALSE:     "FALSE",
+	NULL:      "NULL",
+	YIF:       "YIF",
+	YELS:      "YELS",
+	YEET:      "YEET",
+	YOLO:      "YOLO",
+	YALL:      "YALL",
+	YBREAK:    "YBREAK",
+	YCONTINUE: "YCONTINUE",
+	YET:       "YET",
+	YTRY:      "YTRY",
+	YCATCH:    "YCATCH",
+	YIMPORT:   "YIMPORT",
+	YMATCH:    "YMATCH",
 }
 
 func (tok Type) String() string {
@@ -156,15 +178,31 @@ func (tok Type) String() string {
 }
 
 var keywords = map[string]Type{
-	"true":  TRUE,
-	"false": FALSE,
-	"null":  NULL,
-	"yif":   YIF,
-	"yels":  YELS,
-	"yeet":  YEET,
-	"yolo":  YOLO,
-	"yoyo":  YOYO,
-	"yall":  YALL,
+	"true":      TRUE,
+	"false":     FALSE,
+	"null":      NULL,
+	"yif":       YIF,
+	"yels":      YELS,
+	"yeet":      YEET,
+	"yolo":      YOLO,
+	"yoyo":      YOYO,
+	"yall":      YALL,
+	"ybreak":    YBREAK,
+	"ycontinue": YCONTINUE,
+	"ytry":      YTRY,
+	"ycatch":    YCATCH,
+	"yimport":   YIMPORT,
+	"ymatch":    YMATCH,
+}
+
+// Keywords returns all keywords, sorted.
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func LookupIdent(ident string) Type {
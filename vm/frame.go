@@ -0,0 +1,157 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"yy/object"
+)
+
+type Frame struct {
+	cl *closure
+	ip int
+	bp int // base pointer: where locals of the function start on the stack
+}
+
+// closure is a compiled function bundled with variables it captured. Every module has its own
+// globals, so closures also keep track of globals of the module they were declared in.
+type closure struct {
+	Fn       *object.CompiledFunction
+	Upvalues []*upvalue
+	globals  []object.Object
+}
+
+func (c *closure) Type() object.Type { return object.FUNCTION_OBJ }
+func (c *closure) String() string    { return c.Fn.String() }
+
+// upvalue is a variable captured by a closure. As long as the variable is in scope, the upvalue
+// points at its slot on the stack (it's open). When the variable goes out of scope, its value is
+// moved into the upvalue itself (it's closed), so the closure can still use it.
+type upvalue struct {
+	location *object.Object
+	closed   object.Object
+	slot     int
+}
+
+func (u *upvalue) close() {
+	u.closed = *u.location
+	u.location = &u.closed
+	u.slot = -1
+}
+
+// iterator walks over collections in yall loops.
+type iterator struct {
+	// next yeets the next element, and its index (or the key of a hashmap) if withIndex is set.
+	// Without the index, the element of a hashmap is its key.
+	next func(withIndex bool) (index, elt object.Object, ok bool)
+}
+
+func (it *iterator) Type() object.Type { return object.ITERATOR_OBJ }
+func (it *iterator) String() string    { return "iterator" }
+
+func newIterator(obj object.Object) (*iterator, bool) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		elements := obj.Elements
+		i := 0
+		return &iterator{next: func(withIndex bool) (object.Object, object.Object, bool) {
+			if i >= len(elements) {
+				return nil, nil, false
+			}
+			i++
+			return indexObject(withIndex, i-1), elements[i-1], true
+		}}, true
+
+	case *object.String:
+		runes := []rune(obj.Value)
+		i := 0
+		return &iterator{next: func(withIndex bool) (object.Object, object.Object, bool) {
+			if i >= len(runes) {
+				return nil, nil, false
+			}
+			i++
+			return indexObject(withIndex, i-1), &object.String{Value: string(runes[i-1])}, true
+		}}, true
+
+	case *object.Hashmap:
+		pairs := obj.Pairs()
+		i := 0
+		return &iterator{next: func(withIndex bool) (object.Object, object.Object, bool) {
+			if i >= len(pairs) {
+				return nil, nil, false
+			}
+			i++
+			if !withIndex {
+				return nil, pairs[i-1].Key, true
+			}
+			return pairs[i-1].Key, pairs[i-1].Value, true
+		}}, true
+
+	case *object.Range:
+		return newRangeIterator(obj.Start, obj.End), true
+
+	case *object.Integer:
+		if obj.Value < 0 {
+			return newRangeIterator(obj.Value, 0), true
+		}
+		return newRangeIterator(0, obj.Value), true
+
+	default:
+		return nil, false
+	}
+}
+
+// newRangeIterator iterates from start to end inclusive, in either direction.
+func newRangeIterator(start, end int64) *iterator {
+	incr := int64(1)
+	if start > end {
+		incr = -1
+	}
+
+	i := start
+	done := false
+	return &iterator{next: func(withIndex bool) (object.Object, object.Object, bool) {
+		if done {
+			return nil, nil, false
+		}
+		cur := i
+		if cur == end {
+			done = true
+		}
+		i += incr
+		return indexObject(withIndex, int((cur-start)*incr)), &object.Integer{Value: cur}, true
+	}}
+}
+
+// indexObject yeets index as an object, if it's needed.
+func indexObject(needed bool, index int) object.Object {
+	if !needed {
+		return nil
+	}
+	return &object.Integer{Value: int64(index)}
+}
+
+// loopState is what OpEnterLoop saves, so ybreak & ycontinue can get back to it from anywhere in the
+// loop body.
+type loopState struct {
+	sp       int
+	handlers int // number of ytry handlers
+}
+
+func (ls *loopState) Type() object.Type { return object.ITERATOR_OBJ }
+func (ls *loopState) String() string    { return "loop" }
+
+// yoloFunction is a function conjured up in yolo mode, eg by adding two functions together or by
+// baking arguments into a function.
+type yoloFunction struct {
+	params   []string
+	required int    // number of params that have to be given an arg
+	rest     string // name of the parameter the args left over are passed on to, after the params
+	yolo     bool
+	call     func(vm *VM, args []object.Object) object.Object
+}
+
+func (yf *yoloFunction) Type() object.Type { return object.FUNCTION_OBJ }
+func (yf *yoloFunction) String() string {
+	return fmt.Sprintf("fun(%s) {\n...\n}", strings.Join(yf.params, ", "))
+}
@@ -0,0 +1,87 @@
+package vm
+
+import (
+	"strings"
+
+	"yy/ast"
+	"yy/compiler"
+	"yy/eval"
+	"yy/object"
+	"yy/yikes"
+)
+
+// importModule runs the module imported by node from code in file, unless it has been run already,
+// and yeets its top-level bindings as a hashmap. Modules are compiled when they're imported for the
+// first time, each gets its own globals.
+func (vm *VM) importModule(node *ast.ImportExpression, file string) object.Object {
+	importer, ok := vm.modules[file]
+	if !ok {
+		importer = object.NewModule(file)
+		vm.modules[file] = importer
+	}
+
+	path := eval.ResolveImport(importer.Path, node.Path)
+	if exports, ok := importer.Loaded(path); ok {
+		return exports
+	}
+	if cycle := importer.ImportCycle(path); cycle != nil {
+		return newError(node.Pos(), "import cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	moduleFrame := object.TraceFrame{Fn: "<module>", Pos: node.Pos(), File: file}
+
+	program, err := eval.LoadModule(path)
+	if err != nil {
+		if err.File == "" {
+			return withPos(err, node.Pos())
+		}
+		err.Trace = append(err.Trace, moduleFrame)
+		return err
+	}
+
+	symbolTable := compiler.NewSymbolTable()
+	for name := range vm.host {
+		symbolTable.Define(name)
+	}
+	comp := compiler.NewWithState(symbolTable, vm.constants)
+	comp.SetFile(path)
+	if err := comp.Compile(program); err != nil {
+		compileErr := err.(*yikes.YYError)
+		return &object.Error{
+			Msg:   compileErr.Msg,
+			Pos:   compileErr.Offset,
+			File:  path,
+			Trace: []object.TraceFrame{moduleFrame},
+		}
+	}
+
+	bytecode := comp.Bytecode()
+	bytecode.Main.Name = "<module>"
+	vm.constants = bytecode.Constants
+	vm.modules[path] = importer.Import(path)
+
+	symbols := symbolTable.Globals()
+	globals := make([]object.Object, len(symbols))
+	for _, sym := range symbols {
+		if val, ok := vm.host[sym.Name]; ok {
+			globals[sym.Index] = val
+		}
+	}
+
+	result := vm.callValue(&closure{Fn: bytecode.Main, globals: globals}, nil, false)
+	if isError(result) {
+		return result
+	}
+
+	bindings := map[string]object.Object{}
+	for _, sym := range symbols {
+		// what the host added isn't exported, unless the module has replaced it
+		if val := globals[sym.Index]; val != nil && val != vm.host[sym.Name] {
+			bindings[sym.Name] = val
+		}
+	}
+
+	exports := eval.ExportsToHashmap(bindings)
+	importer.SetLoaded(path, exports)
+	return exports
+}
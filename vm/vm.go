@@ -0,0 +1,1078 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+
+	"yy/ast"
+	"yy/code"
+	"yy/compiler"
+	"yy/eval"
+	"yy/object"
+)
+
+const (
+	StackSize   = 1 << 16 // initial size of the stack, it grows when it's full
+	GlobalsSize = 1 << 16
+)
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpGreaterThan:  ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+	code.OpAppend:       "<<",
+}
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // always points to the next free slot, top of the stack is stack[sp-1]
+
+	frames      []Frame
+	framesIndex int
+
+	// upvalues still pointing at the stack, sorted by their slot
+	openUpvalues []*upvalue
+
+	// ytry blocks being executed, the innermost one on top
+	handlers []handler
+
+	// modules the program consists of, by their path
+	modules map[string]*object.Module
+	// globals added by the host running the program (eg print builtins), modules see them too
+	host map[string]object.Object
+
+	guard *object.Guard // nil if the run isn't limited
+}
+
+// handler remembers the state of the vm at the start of a ytry block, so it can be restored when
+// an error is raised.
+type handler struct {
+	framesIndex int
+	sp          int
+	catchIP     int
+	closeFrom   int // first stack slot of locals declared in ytry block
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobals(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobals creates a vm that shares globals with previous runs, eg in the REPL.
+func NewWithGlobals(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainClosure := &closure{Fn: bytecode.Main, globals: globals}
+
+	vm := &VM{
+		constants: bytecode.Constants,
+		stack:     make([]object.Object, StackSize),
+		frames:    make([]Frame, object.DefaultMaxDepth+1), // main function takes up a frame too
+		modules:   map[string]*object.Module{bytecode.Main.File: object.NewModule(bytecode.Main.File)},
+	}
+
+	// main function is called like any other function, with its closure sitting below its locals
+	vm.stack[0] = mainClosure
+	vm.frames[0] = Frame{cl: mainClosure, bp: 1}
+	vm.framesIndex = 1
+	vm.sp = 1 + bytecode.Main.NumLocals
+
+	return vm
+}
+
+// SetHost makes globals the host added to the program visible to modules it imports as well.
+func (vm *VM) SetHost(globals map[string]object.Object) {
+	vm.host = globals
+}
+
+// SetSource records the source code of the program, so errors caught by the program can tell
+// where they were raised in it. Code read from files is found without it.
+func (vm *VM) SetSource(src []byte) {
+	vm.modules[vm.frames[0].cl.Fn.File].SetSource(src)
+}
+
+// SetGuard limits the run of the program, the guard counts each executed instruction as a step.
+// It has to be called before Run.
+func (vm *VM) SetGuard(guard *object.Guard) {
+	vm.guard = guard
+	if n := guard.MaxCallDepth() + 1; n > len(vm.frames) {
+		frames := make([]Frame, n)
+		copy(frames, vm.frames)
+		vm.frames = frames
+	}
+}
+
+// Run executes the program and returns the value of its last expression. If the program fails,
+// the returned value is an *object.Error.
+func (vm *VM) Run() (result object.Object) {
+	base := vm.framesIndex
+	defer func() {
+		// bad code can't take down the whole interpreter, the error is fatal as the vm may be left
+		// in a bad state
+		if r := recover(); r != nil {
+			frame := &vm.frames[vm.framesIndex-1]
+			err := newError(frame.pos(frame.executing()), "internal error: %v", r)
+			err.Fatal = true
+			err.File = frame.cl.Fn.File
+			vm.unwind(err, base)
+			result = err
+		}
+	}()
+
+	return vm.run()
+}
+
+// run executes instructions until the frame that's on top of the frame stack returns. Errors are
+// handled by ytry blocks entered during this run, errors it can't handle are returned.
+func (vm *VM) run() object.Object {
+	base := vm.framesIndex
+
+	for {
+		result := vm.execute(base)
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			return result
+		}
+		if errObj.File == "" {
+			errObj.File = vm.frames[vm.framesIndex-1].cl.Fn.File
+		}
+		if !vm.catch(errObj, base) {
+			vm.unwind(errObj, base)
+			return errObj
+		}
+	}
+}
+
+// unwind drops frames of this run, recording them in the error's stack trace.
+func (vm *VM) unwind(err *object.Error, base int) {
+	// the first run executes the main function, it doesn't make it into the trace
+	bottom := max(base-1, 1)
+
+	for i := vm.framesIndex - 1; i >= bottom; i-- {
+		caller := &vm.frames[i-1]
+		frame := object.TraceFrame{Fn: vm.frames[i].cl.Fn.Name, Pos: -1, File: caller.cl.Fn.File}
+
+		// OpImport, or OpCall and its operand, have been read already
+		if node, ok := caller.source(caller.ip - 1).(*ast.ImportExpression); ok {
+			frame.Pos = node.Pos()
+		} else if call, ok := caller.source(caller.ip - 2).(*ast.CallExpression); ok {
+			frame.Pos = call.Function.Pos()
+		}
+		err.Trace = append(err.Trace, frame)
+	}
+
+	vm.framesIndex = min(vm.framesIndex, base-1)
+}
+
+// catch unwinds the vm to the innermost ytry block and jumps to its ycatch block. It reports false
+// if there's no ytry block to catch the error in frames from base upwards.
+func (vm *VM) catch(err *object.Error, base int) bool {
+	if len(vm.handlers) == 0 {
+		return false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	if h.framesIndex < base || err.Fatal {
+		return false
+	}
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.closeUpvalues(h.closeFrom)
+	vm.framesIndex = h.framesIndex
+	vm.sp = h.sp
+	vm.frames[vm.framesIndex-1].ip = h.catchIP
+	vm.push(eval.ErrorToHashmap(err, vm.modules[vm.frames[0].cl.Fn.File]))
+
+	return true
+}
+
+func (vm *VM) execute(base int) object.Object {
+	frame := &vm.frames[vm.framesIndex-1]
+	ins := frame.cl.Fn.Instructions
+
+	for {
+		start := frame.ip
+		if err := vm.guard.Step(); err != nil {
+			return withPos(err, frame.pos(start))
+		}
+		op := code.Opcode(ins[start])
+		frame.ip++
+
+		switch op {
+		case code.OpConstant:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			vm.push(copyConstant(vm.constants[idx]))
+
+		case code.OpPop:
+			vm.sp--
+
+		case code.OpDup:
+			vm.push(vm.stack[vm.sp-1])
+
+		case code.OpTrue:
+			vm.push(object.TRUE)
+
+		case code.OpFalse:
+			vm.push(object.FALSE)
+
+		case code.OpNull:
+			vm.push(object.NULL)
+
+		// OPERATORS
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan,
+			code.OpLessEqual, code.OpGreaterEqual, code.OpAppend:
+			right := vm.pop()
+			left := vm.pop()
+			result := vm.binaryOp(op, left, right)
+			if isError(result) {
+				return withPos(result, frame.source(start).Pos())
+			}
+			vm.push(result)
+
+		case code.OpYoloInfix:
+			operator := infixOperators[code.Opcode(ins[frame.ip])]
+			frame.ip++
+			right := vm.pop()
+			left := vm.pop()
+			result := vm.infix(operator, left, right, true)
+			if isError(result) {
+				return withPos(result, frame.source(start).Pos())
+			}
+			vm.push(result)
+
+		case code.OpMinus:
+			right := vm.pop()
+			var result object.Object
+			if integer, ok := right.(*object.Integer); ok && integer.Value != math.MinInt64 {
+				result = &object.Integer{Value: -integer.Value}
+			} else {
+				result = eval.Prefix("-", right, false)
+			}
+			if isError(result) {
+				return withPos(result, frame.source(start).Pos())
+			}
+			vm.push(result)
+
+		case code.OpYoloMinus:
+			result := vm.prefix("-", vm.pop(), true)
+			if isError(result) {
+				return withPos(result, frame.source(start).Pos())
+			}
+			vm.push(result)
+
+		case code.OpBang:
+			vm.push(toYeetBool(!eval.IsTruthy(vm.pop())))
+
+		// CONTROL FLOW
+
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[frame.ip:]))
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			if !eval.IsTruthy(vm.pop()) {
+				frame.ip = pos
+			}
+
+		case code.OpJumpTruthy:
+			pos := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			if eval.IsTruthy(vm.pop()) {
+				frame.ip = pos
+			}
+
+		case code.OpGetIter:
+			iterable := vm.pop()
+			it, ok := newIterator(iterable)
+			if !ok {
+				yall := frame.source(start).(*ast.YallExpression)
+				return eval.CannotIterate(yall.Iterable.Pos(), iterable)
+			}
+			vm.push(it)
+
+		case code.OpIterNext:
+			slot := int(code.ReadUint16(ins[frame.ip:]))
+			pos := int(code.ReadUint16(ins[frame.ip+2:]))
+			withIndex := code.ReadUint8(ins[frame.ip+4:]) == 1
+			frame.ip += 5
+
+			it := vm.stack[frame.bp+slot].(*iterator)
+			index, elt, ok := it.next(withIndex)
+			if !ok {
+				frame.ip = pos
+				break
+			}
+			if withIndex {
+				vm.push(index)
+			}
+			vm.push(elt)
+
+		case code.OpEnterLoop:
+			slot := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			vm.stack[frame.bp+slot] = &loopState{sp: vm.sp, handlers: len(vm.handlers)}
+
+		case code.OpBreak:
+			slot := int(code.ReadUint16(ins[frame.ip:]))
+			pos := int(code.ReadUint16(ins[frame.ip+2:]))
+			bodyLocals := int(code.ReadUint16(ins[frame.ip+4:]))
+
+			// leave whatever the loop body was in the middle of, keeping the value on top
+			state := vm.stack[frame.bp+slot].(*loopState)
+			val := vm.pop()
+			vm.sp = state.sp
+			vm.handlers = vm.handlers[:state.handlers]
+			vm.closeUpvalues(frame.bp + bodyLocals)
+			vm.push(val)
+			frame.ip = pos
+
+		// VARIABLES
+
+		case code.OpTry:
+			catchIP := int(code.ReadUint16(ins[frame.ip:]))
+			firstLocal := int(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			vm.handlers = append(vm.handlers, handler{
+				framesIndex: vm.framesIndex,
+				sp:          vm.sp,
+				catchIP:     catchIP,
+				closeFrom:   frame.bp + firstLocal,
+			})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpImport:
+			node := frame.source(start).(*ast.ImportExpression)
+			exports := vm.importModule(node, frame.cl.Fn.File)
+			if isError(exports) {
+				return exports
+			}
+			vm.push(exports)
+
+		case code.OpGetGlobal:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+
+			val := frame.cl.globals[idx]
+			if val == nil {
+				ident := frame.source(start).(*ast.Identifier)
+				builtin, ok := eval.LookupBuiltin(ident.Value)
+				if !ok {
+					return newError(ident.Pos(), "identifier not found: %s", ident.Value)
+				}
+				val = builtin
+			}
+			vm.push(val)
+
+		case code.OpSetGlobal:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			frame.cl.globals[idx] = vm.stack[vm.sp-1]
+
+		case code.OpAssignGlobal:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+
+			if frame.cl.globals[idx] == nil {
+				ident := frame.source(start).(*ast.Identifier)
+				return newError(
+					ident.Pos(),
+					"identifier not found: %s (to declare a variable use := operator)",
+					ident.Value)
+			}
+			frame.cl.globals[idx] = vm.stack[vm.sp-1]
+
+		case code.OpGetLocal:
+			idx := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			val := vm.stack[frame.bp+idx]
+			if val == nil {
+				return identNotFound(frame.source(start))
+			}
+			vm.push(val)
+
+		case code.OpSetLocal:
+			idx := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			vm.stack[frame.bp+idx] = vm.stack[vm.sp-1]
+
+		case code.OpGetFree:
+			idx := code.ReadUint8(ins[frame.ip:])
+			frame.ip++
+
+			val := *frame.cl.Upvalues[idx].location
+			if val == nil {
+				return identNotFound(frame.source(start))
+			}
+			vm.push(val)
+
+		case code.OpSetFree:
+			idx := code.ReadUint8(ins[frame.ip:])
+			frame.ip++
+			*frame.cl.Upvalues[idx].location = vm.stack[vm.sp-1]
+
+		case code.OpCloseUpvalues:
+			slot := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			vm.closeUpvalues(frame.bp + slot)
+
+		case code.OpGetLocalOr:
+			idx := int(code.ReadUint16(ins[frame.ip:]))
+			target := int(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			if val := vm.stack[frame.bp+idx]; val != nil {
+				vm.push(val)
+				frame.ip = target
+			}
+
+		case code.OpSetLocalOr:
+			idx := int(code.ReadUint16(ins[frame.ip:]))
+			target := int(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			if vm.stack[frame.bp+idx] != nil {
+				vm.stack[frame.bp+idx] = vm.stack[vm.sp-1]
+				frame.ip = target
+			}
+
+		case code.OpGetFreeOr:
+			idx := code.ReadUint8(ins[frame.ip:])
+			target := int(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 3
+
+			if val := *frame.cl.Upvalues[idx].location; val != nil {
+				vm.push(val)
+				frame.ip = target
+			}
+
+		case code.OpSetFreeOr:
+			idx := code.ReadUint8(ins[frame.ip:])
+			target := int(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 3
+
+			if location := frame.cl.Upvalues[idx].location; *location != nil {
+				*location = vm.stack[vm.sp-1]
+				frame.ip = target
+			}
+
+		case code.OpClearLocals:
+			slot := int(code.ReadUint16(ins[frame.ip:]))
+			n := int(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			clear(vm.stack[frame.bp+slot : frame.bp+slot+n])
+
+		// DATA STRUCTURES
+
+		case code.OpArray:
+			n := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			vm.push(&object.Array{Elements: elements})
+
+		case code.OpHashmap:
+			n := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			hashmap := object.NewHashmap(n / 2)
+			for i := vm.sp - n; i < vm.sp; i += 2 {
+				key, val := vm.stack[i], vm.stack[i+1]
+				hashKey, ok := key.(object.Hashable)
+				if !ok {
+					return newError(frame.source(start).Pos(), "key not hashable: %s", key.Type())
+				}
+				hashmap.Set(hashKey, val)
+			}
+			vm.sp -= n
+			vm.push(hashmap)
+
+		case code.OpRange:
+			to := vm.pop()
+			from := vm.pop()
+			result := eval.NewRange(from, to)
+			if isError(result) {
+				rng := frame.source(start).(*ast.RangeLiteral)
+				return withPos(result, rng.Start.Pos())
+			}
+			vm.push(result)
+
+		case code.OpTemplate:
+			idx := code.ReadUint16(ins[frame.ip:])
+			n := int(code.ReadUint8(ins[frame.ip+2:]))
+			frame.ip += 3
+
+			vals := make([]any, n)
+			for i := range vals {
+				vals[i] = vm.stack[vm.sp-n+i]
+			}
+			vm.sp -= n
+
+			template := vm.constants[idx].(*object.String).Value
+			result := eval.CheckLength(&object.String{Value: fmt.Sprintf(template, vals...)}, vm.guard)
+			if isError(result) {
+				return withPos(result, frame.source(start).Pos())
+			}
+			vm.push(result)
+
+		case code.OpIndex:
+			idx := vm.pop()
+			left := vm.pop()
+			result := eval.Index(left, idx)
+			if isError(result) {
+				node := frame.source(start).(*ast.IndexExpression)
+				return withPos(result, node.Index.Pos())
+			}
+			vm.push(result)
+
+		case code.OpSetIndex:
+			idx := vm.pop()
+			left := vm.pop()
+			val := vm.pop()
+			node := frame.source(start).(*ast.IndexExpression)
+			result := eval.SetIndex(left, idx, val, node.Left.String())
+			if isError(result) {
+				return withPos(result, node.Index.Pos())
+			}
+			vm.push(result)
+
+		case code.OpGetField:
+			name := vm.constants[code.ReadUint16(ins[frame.ip:])].(*object.String).Value
+			frame.ip += 2
+
+			result := eval.Field(vm.pop(), name)
+			if isError(result) {
+				return withPos(result, frame.source(start).(*ast.DotExpression).NamePos)
+			}
+			vm.push(result)
+
+		case code.OpSetField:
+			name := vm.constants[code.ReadUint16(ins[frame.ip:])].(*object.String).Value
+			frame.ip += 2
+
+			left := vm.pop()
+			val := vm.pop()
+			result := eval.SetField(left, name, val)
+			if isError(result) {
+				return withPos(result, frame.source(start).(*ast.DotExpression).NamePos)
+			}
+			vm.push(result)
+
+		case code.OpUnpackArray:
+			n := int(code.ReadUint16(ins[frame.ip:]))
+			required := int(code.ReadUint16(ins[frame.ip+2:]))
+			rest := code.ReadUint8(ins[frame.ip+4:]) == 1
+			frame.ip += 5
+
+			elements, err := eval.UnpackArray(vm.pop(), n, required, rest)
+			if err != nil {
+				return withPos(err, frame.source(start).Pos())
+			}
+			// the rest (if any) goes to the bottom, and the first element to the top
+			for i := len(elements) - 1; i >= 0; i-- {
+				vm.push(elements[i])
+			}
+
+		case code.OpUnpackHashmap:
+			if _, err := eval.UnpackHashmap(vm.stack[vm.sp-1]); err != nil {
+				return withPos(err, frame.source(start).Pos())
+			}
+
+		case code.OpUnpackKey:
+			optional := code.ReadUint8(ins[frame.ip:]) == 1
+			frame.ip++
+
+			key := vm.pop()
+			hashmap := vm.pop().(*object.Hashmap)
+			result := eval.UnpackKey(hashmap, key, optional)
+			if isError(result) {
+				return withPos(result, frame.source(start).Pos())
+			}
+			vm.push(result)
+
+		case code.OpMatch:
+			pos := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			if !eval.Matches(frame.source(start), vm.stack[vm.sp-1]) {
+				vm.pop()
+				frame.ip = pos
+			}
+
+		case code.OpNoMatch:
+			subject := vm.pop()
+			return newError(frame.source(start).Pos(), "no pattern matches %s", subject.String())
+
+		case code.OpQuote:
+			idx := code.ReadUint16(ins[frame.ip:])
+			n := int(code.ReadUint8(ins[frame.ip+2:]))
+			frame.ip += 3
+
+			result := quote(vm.constants[idx].(*object.Quote), vm.stack[vm.sp-n:vm.sp])
+			if isError(result) {
+				return result
+			}
+			vm.sp -= n
+			vm.push(result)
+
+		// FUNCTIONS
+
+		case code.OpClosure:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+
+			fn := vm.constants[idx].(*object.CompiledFunction)
+			upvalues := make([]*upvalue, len(fn.Upvalues))
+			for i, uv := range fn.Upvalues {
+				if uv.IsLocal {
+					upvalues[i] = vm.captureUpvalue(frame.bp + uv.Index)
+				} else {
+					upvalues[i] = frame.cl.Upvalues[uv.Index]
+				}
+			}
+			vm.push(&closure{Fn: fn, Upvalues: upvalues, globals: frame.cl.globals})
+
+		case code.OpCall, code.OpCallMethod:
+			argc := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+
+			// the receiver of a method call is taken off the stack, so the call looks like any other
+			var receiver *object.Hashmap
+			if op == code.OpCallMethod {
+				receiver, _ = vm.stack[vm.sp-2-argc].(*object.Hashmap)
+				copy(vm.stack[vm.sp-2-argc:], vm.stack[vm.sp-1-argc:vm.sp])
+				vm.sp--
+			}
+
+			call := frame.source(start).(*ast.CallExpression)
+			callee := vm.stack[vm.sp-1-argc]
+			names := argNames(call)
+
+			switch callee := callee.(type) {
+			case *closure:
+				if names != nil || argc != len(callee.Fn.Parameters) || callee.Fn.Rest != "" {
+					args := append([]object.Object{}, vm.stack[vm.sp-argc:vm.sp]...)
+					bound, err := bindArgs(eval.CalleeName(call.Function), callee, args, names, false)
+					if err != nil {
+						return withPos(err, call.Pos())
+					}
+					vm.sp -= argc
+					argc = vm.pushArgs(callee, bound)
+				}
+				if err := vm.pushFrame(callee, argc, receiver); err != nil {
+					// same place as the frames of the calls in the stack trace
+					return withPos(err, call.Function.Pos())
+				}
+
+				frame = &vm.frames[vm.framesIndex-1]
+				ins = frame.cl.Fn.Instructions
+
+			case *object.Builtin:
+				if names != nil {
+					return newError(call.Pos(), "named args can't be passed to builtins")
+				}
+				result := eval.CheckLength(callee.Call(vm.apply, vm.stack[vm.sp-argc:vm.sp]...), vm.guard)
+				if errObj, ok := result.(*object.Error); ok {
+					// errors raised by functions the builtin called already point at the right place
+					if errObj.Pos < 0 {
+						errObj.Pos = call.Function.Pos()
+					}
+					return errObj
+				}
+				vm.sp -= argc + 1
+				vm.push(result)
+
+			case *yoloFunction:
+				args := append([]object.Object{}, vm.stack[vm.sp-argc:vm.sp]...)
+				bound, err := bindArgs(eval.CalleeName(call.Function), callee, args, names, false)
+				if err != nil {
+					return withPos(err, call.Pos())
+				}
+				result := callee.call(vm, bound)
+				if errObj, ok := result.(*object.Error); ok {
+					if errObj.Pos < 0 {
+						errObj.Pos = call.Pos()
+					}
+					return errObj
+				}
+				vm.sp -= argc + 1
+				vm.push(result)
+
+			default:
+				return newError(call.Pos(), "not a function: %s", callee.Type())
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			vm.closeUpvalues(frame.bp)
+			vm.framesIndex--
+			vm.sp = frame.bp - 1 // drop the callee as well
+
+			// yeeting out of ytry block leaves it too
+			for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].framesIndex > vm.framesIndex {
+				vm.handlers = vm.handlers[:len(vm.handlers)-1]
+			}
+
+			if vm.framesIndex < base {
+				return returnValue
+			}
+
+			vm.push(returnValue)
+			frame = &vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+
+		default:
+			def, _ := code.Lookup(byte(op))
+			return newError(-1, "unexpected instruction: %v", def)
+		}
+	}
+}
+
+// callValue calls fn with args from Go code. Lenient calls ignore surplus args and leave
+// parameters with no matching arg undefined.
+func (vm *VM) callValue(fn object.Object, args []object.Object, lenient bool) object.Object {
+	switch fn := fn.(type) {
+	case *closure:
+		bound, err := bindArgs("", fn, args, nil, lenient)
+		if err != nil {
+			return err
+		}
+
+		vm.push(fn)
+		argc := vm.pushArgs(fn, bound)
+		if err := vm.pushFrame(fn, argc, nil); err != nil {
+			return err
+		}
+		return vm.run()
+
+	case *yoloFunction:
+		bound, err := bindArgs("", fn, args, nil, lenient)
+		if err != nil {
+			return err
+		}
+		return fn.call(vm, bound)
+
+	case *object.Builtin:
+		return eval.CheckLength(fn.Call(vm.apply, args...), vm.guard)
+
+	default:
+		return newError(-1, "not a function: %s", fn.Type())
+	}
+}
+
+// apply lets builtins call functions passed to them.
+func (vm *VM) apply(fn object.Object, args ...object.Object) object.Object {
+	return vm.callValue(fn, args, false)
+}
+
+// argNames yeets names of the args passed by name in a call, which come after the others.
+func argNames(call *ast.CallExpression) []string {
+	if n := len(call.Arguments); n == 0 {
+		return nil
+	} else if _, ok := call.Arguments[n-1].(*ast.NamedArgument); !ok {
+		return nil
+	}
+
+	var names []string
+	for _, arg := range call.Arguments {
+		if named, ok := arg.(*ast.NamedArgument); ok {
+			names = append(names, named.Name)
+		}
+	}
+	return names
+}
+
+// bindArgs matches args of a call with parameters of fn like eval.BindArgs does. It yeets an arg
+// for each parameter, followed by the args left over for the rest parameter. Lenient calls ignore
+// surplus args and leave required parameters with no matching arg undefined.
+func bindArgs(callee string, fn object.Object, args []object.Object, names []string, lenient bool) ([]object.Object, *object.Error) {
+	params, required, rest := signatureOf(fn)
+	if !lenient {
+		bound, extra, err := eval.BindArgs(callee, params, required, rest != "", args, names)
+		if err != nil {
+			return nil, err
+		}
+		return append(bound[:len(bound):len(bound)], extra...), nil
+	}
+
+	fitted := make([]object.Object, len(params))
+	copy(fitted, args)
+	for i := required; i < len(params); i++ {
+		if fitted[i] == nil {
+			fitted[i] = object.NULL
+		}
+	}
+	if rest != "" && len(args) > len(params) {
+		fitted = append(fitted, args[len(params):]...)
+	}
+	return fitted, nil
+}
+
+// pushArgs pushes args matched with parameters of cl by bindArgs, collecting the ones left over in
+// an array if cl has a rest parameter. It yeets the number of values pushed.
+func (vm *VM) pushArgs(cl *closure, args []object.Object) int {
+	n := len(cl.Fn.Parameters)
+	for _, arg := range args[:n] {
+		vm.push(arg)
+	}
+	if cl.Fn.Rest != "" {
+		vm.push(&object.Array{Elements: append([]object.Object{}, args[n:]...)})
+		n++
+	}
+	return n
+}
+
+// pushFrame sets up a frame for a closure whose args are on top of the stack. Receiver is the
+// hashmap the closure is called as a method of, if any.
+func (vm *VM) pushFrame(cl *closure, argc int, receiver *object.Hashmap) *object.Error {
+	bp := vm.sp - argc
+	if vm.framesIndex > vm.guard.MaxCallDepth() {
+		return newError(-1, "maximum recursion depth exceeded")
+	}
+	vm.growStack(bp + cl.Fn.NumLocals)
+
+	// clear locals left over by previous calls, so they don't leak into this one
+	for i := vm.sp; i < bp+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+
+	if fn := cl.Fn; fn.UsesSelf {
+		switch {
+		case receiver != nil:
+			vm.stack[bp+fn.Self] = receiver
+		case fn.OuterSelfGlobal:
+			vm.stack[bp+fn.Self] = cl.globals[fn.OuterSelf]
+		default:
+			vm.stack[bp+fn.Self] = *cl.Upvalues[fn.OuterSelf].location
+		}
+	}
+
+	vm.frames[vm.framesIndex] = Frame{cl: cl, bp: bp}
+	vm.framesIndex++
+	vm.sp = bp + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) captureUpvalue(slot int) *upvalue {
+	i := len(vm.openUpvalues)
+	for i > 0 && vm.openUpvalues[i-1].slot >= slot {
+		if vm.openUpvalues[i-1].slot == slot {
+			return vm.openUpvalues[i-1]
+		}
+		i--
+	}
+
+	uv := &upvalue{location: &vm.stack[slot], slot: slot}
+
+	vm.openUpvalues = append(vm.openUpvalues, nil)
+	copy(vm.openUpvalues[i+1:], vm.openUpvalues[i:])
+	vm.openUpvalues[i] = uv
+
+	return uv
+}
+
+// closeUpvalues closes all open upvalues pointing at slots from the given one upwards.
+func (vm *VM) closeUpvalues(from int) {
+	for len(vm.openUpvalues) > 0 {
+		last := vm.openUpvalues[len(vm.openUpvalues)-1]
+		if last.slot < from {
+			return
+		}
+		last.close()
+		vm.openUpvalues = vm.openUpvalues[:len(vm.openUpvalues)-1]
+	}
+}
+
+func (vm *VM) push(obj object.Object) {
+	if vm.sp == len(vm.stack) {
+		vm.growStack(vm.sp + 1)
+	}
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+// growStack makes room for at least n values on the stack. Open upvalues are moved over to the new
+// stack along with the values they point at.
+func (vm *VM) growStack(n int) {
+	if n <= len(vm.stack) {
+		return
+	}
+
+	stack := make([]object.Object, max(2*len(vm.stack), n))
+	copy(stack, vm.stack)
+	vm.stack = stack
+	for _, uv := range vm.openUpvalues {
+		uv.location = &vm.stack[uv.slot]
+	}
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+func (f *Frame) source(ip int) ast.Expression {
+	return f.cl.Fn.SourceMap[ip]
+}
+
+// executing returns where the instruction being executed starts. The ip of the frame has moved past
+// its opcode already, maybe past its operands too.
+func (f *Frame) executing() int {
+	ins := f.cl.Fn.Instructions
+	start := 0
+	for i := 0; i < f.ip && i < len(ins); {
+		start = i
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			break
+		}
+		i++
+		for _, w := range def.OperandWidths {
+			i += w
+		}
+	}
+	return start
+}
+
+// pos returns the offset of the code the instruction at ip comes from. Only instructions that can
+// fail are mapped to their code, for others it makes do with the closest mapped one that follows
+// (eg the jump back to the start of a loop the instruction is in) or precedes it.
+func (f *Frame) pos(ip int) int {
+	for i := ip; i < len(f.cl.Fn.Instructions); i++ {
+		if node, ok := f.cl.Fn.SourceMap[i]; ok {
+			return node.Pos()
+		}
+	}
+	for i := ip - 1; i >= 0; i-- {
+		if node, ok := f.cl.Fn.SourceMap[i]; ok {
+			return node.Pos()
+		}
+	}
+	return -1
+}
+
+// binaryOp applies a non-yolo operator, handling the most common cases without any detours.
+func (vm *VM) binaryOp(op code.Opcode, left, right object.Object) object.Object {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case code.OpAdd:
+				if sum, ok := eval.AddInts(l.Value, r.Value); ok {
+					return &object.Integer{Value: sum}
+				}
+			case code.OpSub:
+				if diff, ok := eval.SubInts(l.Value, r.Value); ok {
+					return &object.Integer{Value: diff}
+				}
+			case code.OpMul:
+				if product, ok := eval.MulInts(l.Value, r.Value); ok {
+					return &object.Integer{Value: product}
+				}
+			case code.OpEqual:
+				return toYeetBool(l.Value == r.Value)
+			case code.OpNotEqual:
+				return toYeetBool(l.Value != r.Value)
+			case code.OpLessThan:
+				return toYeetBool(l.Value < r.Value)
+			case code.OpGreaterThan:
+				return toYeetBool(l.Value > r.Value)
+			case code.OpLessEqual:
+				return toYeetBool(l.Value <= r.Value)
+			case code.OpGreaterEqual:
+				return toYeetBool(l.Value >= r.Value)
+			}
+		}
+	}
+
+	return eval.Infix(infixOperators[op], left, right, false, vm.guard)
+}
+
+// quote splices unquoted values into quoted code. Values are ordered the same way as
+// eval.UnquoteCalls orders unquote(...) calls.
+func quote(q *object.Quote, vals []object.Object) object.Object {
+	unquoted := map[int]object.Object{}
+	for i, call := range eval.UnquoteCalls(q.Node) {
+		unquoted[call.Pos()] = vals[i]
+	}
+
+	var err *object.Error
+	node := ast.Modify(q.Node, func(node ast.Expression) ast.Expression {
+		if _, ok := node.(*ast.CallExpression); !ok || err != nil {
+			return node
+		}
+
+		val, ok := unquoted[node.Pos()]
+		if !ok {
+			return node
+		}
+
+		if newNode := eval.ObjectToAST(val); newNode != nil {
+			return newNode
+		}
+
+		err = newError(node.Pos(), "cannot unquote %s", val.Type())
+		return node
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+// copyConstant returns a fresh copy of mutable constants, as values can be changed in place
+// (eg by yoink), which shouldn't affect the constant itself.
+func copyConstant(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: obj.Value}
+	case *object.Number:
+		return &object.Number{Value: obj.Value}
+	case *object.String:
+		return &object.String{Value: obj.Value}
+	default:
+		return obj
+	}
+}
+
+func toYeetBool(b bool) object.Object {
+	if b {
+		return object.TRUE
+	}
+	return object.FALSE
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+func identNotFound(node ast.Expression) *object.Error {
+	return newError(node.Pos(), "identifier not found: %s", node.String())
+}
+
+func withPos(obj object.Object, pos int) object.Object {
+	obj.(*object.Error).Pos = pos
+	return obj
+}
+
+func newError(pos int, format string, args ...any) *object.Error {
+	return &object.Error{Msg: fmt.Sprintf(format, args...), Pos: pos}
+}
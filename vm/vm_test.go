@@ -0,0 +1,123 @@
+package vm_test
+
+import (
+	"reflect"
+	"testing"
+
+	"yy/ast"
+	"yy/compiler"
+	"yy/lexer"
+	"yy/object"
+	"yy/parser"
+	"yy/vm"
+)
+
+// most of the VM is tested against the same tables as the tree-walking evaluator, in eval package;
+// these tests cover what's specific to the VM: how closures capture variables that live on the stack
+
+func TestUpvalues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// closures share captured variables with the scope they were created in
+		{`a := 1; f := \{ a }; a = 5; f()`, 5},
+		{`f := \{ a := 1; g := \{ a = a + 1 }; g(); g(); a }; f()`, 3},
+		// captured variable outlives the function it was declared in
+		{`counter := \{ n := 0; \{ n = n + 1 } }; c := counter(); c(); c(); c()`, 3},
+		{`counter := \{ n := 0; \{ n = n + 1 } }; c1 := counter(); c2 := counter(); c1(); c1(); c2()`, 1},
+		// loop variable is shared by all iterations, but every run of the body gets its own scope
+		{`fns := []; yall i: 0..2 { fns = fns << \{ i } }; fns[0]() + fns[1]() * 10 + fns[2]() * 100`, 222},
+		{`fns := []; yall i: 0..2 { x := i; fns = fns << \{ x } }; fns[0]() + fns[1]() * 10 + fns[2]() * 100`, 210},
+		{`fns := []; i := 0; yoyo i < 3 { x := i; fns = fns << \{ x }; i = i + 1 }; fns[0]() + fns[2]()`, 2},
+		// variables captured through multiple levels of nesting
+		{`\a { \b { \c { a + b + c } } }(1)(2)(3)`, 6},
+		{`f := \{ a := 1; \{ \{ a = a * 10; a } } }; g := f()(); g(); g()`, 100},
+	}
+
+	for _, tt := range tests {
+		result := run(t, tt.input)
+		integer, ok := result.(*object.Integer)
+		if !ok {
+			t.Errorf("result is not Integer. got %T (%+v) (%s)", result, result, tt.input)
+			continue
+		}
+		if integer.Value != tt.expected {
+			t.Errorf("result has wrong value. want %d, got %d (%s)", tt.expected, integer.Value, tt.input)
+		}
+	}
+}
+
+func TestGlobalsSurviveBetweenRuns(t *testing.T) {
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+
+	var result object.Object
+	for _, input := range []string{`a := 3`, `f := \x { x * a }`, `f(7)`} {
+		c := compiler.NewWithState(symbolTable, constants)
+		if err := c.Compile(parse(t, input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := c.Bytecode()
+		constants = bytecode.Constants
+		result = vm.NewWithGlobals(bytecode, globals).Run()
+	}
+
+	integer, ok := result.(*object.Integer)
+	if !ok || integer.Value != 21 {
+		t.Errorf("wrong result. want 21, got %+v", result)
+	}
+}
+
+func TestPanicsBecomeErrors(t *testing.T) {
+	symbolTable := compiler.NewSymbolTable()
+	globals := make([]object.Object, vm.GlobalsSize)
+	boom := &object.Builtin{Fn: func(args ...object.Object) object.Object { panic("kaboom") }}
+	globals[symbolTable.Define("boom").Index] = boom
+
+	c := compiler.NewWithState(symbolTable, []object.Object{})
+	c.SetFile("boom.yeet")
+	if err := c.Compile(parse(t, "f := \\{ 1 + boom() }\nytry { f() } ycatch { 0 }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	result := vm.NewWithGlobals(c.Bytecode(), globals).Run()
+
+	err, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("result is not Error. got %T (%+v)", result, result)
+	}
+	if err.Msg != "internal error: kaboom" || !err.Fatal {
+		t.Errorf("wrong error. want fatal %q, got %q (fatal: %t)", "internal error: kaboom", err.Msg, err.Fatal)
+	}
+	if err.Pos != 16 || err.File != "boom.yeet" {
+		t.Errorf("wrong position. want boom.yeet:16, got %s:%d", err.File, err.Pos)
+	}
+	want := []object.TraceFrame{{Fn: "f", Pos: 28, File: "boom.yeet"}}
+	if !reflect.DeepEqual(err.Trace, want) {
+		t.Errorf("wrong stack trace. want %+v, got %+v", want, err.Trace)
+	}
+}
+
+func run(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	c := compiler.New()
+	if err := c.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	return vm.New(c.Bytecode()).Run()
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
@@ -0,0 +1,198 @@
+package vm
+
+import (
+	"yy/eval"
+	"yy/object"
+)
+
+// infix applies an operator, following yolo rules for functions if yoloOK is set. Functions
+// compiled to bytecode don't carry their source code, so unlike the tree-walking evaluator, the vm
+// can't rewrite them. Instead, it wraps them in new functions that do the same thing.
+func (vm *VM) infix(op string, left, right object.Object, yoloOK bool) object.Object {
+	leftFn, rightFn := isFunction(left), isFunction(right)
+
+	switch {
+	case !yoloOK || (!leftFn && !rightFn):
+		break
+
+	// these don't depend on yolo rules
+	case left.Type() == object.ARRAY_OBJ && op == "<<":
+		break
+	case left.Type() != right.Type() && (op == "==" || op == "!="):
+		break
+
+	case leftFn && rightFn:
+		return compose(left, right)
+
+	case leftFn && op == "+":
+		return bakeArgs(left, right)
+
+	case rightFn && op == "+":
+		return bakeArgs(right, left)
+
+	case leftFn:
+		return mapResult(left, func(vm *VM, result object.Object) object.Object {
+			return vm.infix(op, result, right, yoloOf(left))
+		})
+
+	case rightFn:
+		return mapResult(right, func(vm *VM, result object.Object) object.Object {
+			return vm.infix(op, left, result, yoloOf(right))
+		})
+	}
+
+	return eval.Infix(op, left, right, yoloOK, vm.guard)
+}
+
+func (vm *VM) prefix(op string, right object.Object, yoloOK bool) object.Object {
+	if yoloOK && op == "-" && isFunction(right) {
+		return mapResult(right, func(vm *VM, result object.Object) object.Object {
+			return vm.prefix(op, result, yoloOf(right))
+		})
+	}
+
+	return eval.Prefix(op, right, yoloOK)
+}
+
+// mapResult creates a function that calls fn and transforms whatever it yeets.
+func mapResult(fn object.Object, transform func(vm *VM, result object.Object) object.Object) *yoloFunction {
+	params, required, rest := signatureOf(fn)
+	return &yoloFunction{
+		params:   params,
+		required: required,
+		rest:     rest,
+		yolo:     yoloOf(fn),
+		call: func(vm *VM, args []object.Object) object.Object {
+			result := vm.callValue(fn, args, false)
+			if isError(result) {
+				return result
+			}
+			return transform(vm, result)
+		},
+	}
+}
+
+// compose creates a function that passes the result of left to right. If right takes more than
+// one argument, the remaining ones are left undefined.
+func compose(left, right object.Object) *yoloFunction {
+	params, required, rest := signatureOf(left)
+	return &yoloFunction{
+		params:   params,
+		required: required,
+		rest:     rest,
+		yolo:     yoloOf(left),
+		call: func(vm *VM, args []object.Object) object.Object {
+			result := vm.callValue(left, args, false)
+			if isError(result) {
+				return result
+			}
+			return vm.callValue(right, []object.Object{result}, true)
+		},
+	}
+}
+
+// bakeArgs creates a function with some of the arguments of fn already filled in: by name when
+// baking in a hashmap, in order when baking in an array, or just the first one otherwise. Args
+// left over go to the rest parameter, ahead of the ones the function gets called with.
+func bakeArgs(fn, val object.Object) *yoloFunction {
+	params, required, rest := signatureOf(fn)
+	baked := make([]object.Object, len(params))
+	var extra []object.Object
+
+	switch val := val.(type) {
+	case *object.Hashmap:
+		for i, p := range params {
+			if v, ok := val.Get(&object.String{Value: p}); ok {
+				baked[i] = v
+			}
+		}
+		if rest != "" {
+			if v, ok := val.Get(&object.String{Value: rest}); ok {
+				extra = eval.RestArgs(v)
+			}
+		}
+
+	case *object.Array:
+		n := copy(baked, val.Elements)
+		extra = val.Elements[n:]
+
+	default:
+		if len(params) > 0 {
+			baked[0] = val
+		} else {
+			extra = []object.Object{val}
+		}
+	}
+
+	// functions without a rest parameter ignore args left over
+	if rest == "" {
+		extra = nil
+	}
+
+	newParams := []string{}
+	newRequired := 0
+	for i, p := range params {
+		if baked[i] == nil {
+			newParams = append(newParams, p)
+			if i < required {
+				newRequired++
+			}
+		}
+	}
+
+	return &yoloFunction{
+		params:   newParams,
+		required: newRequired,
+		rest:     rest,
+		yolo:     yoloOf(fn),
+		call: func(vm *VM, args []object.Object) object.Object {
+			allArgs := make([]object.Object, len(params))
+			next := 0
+			for i := range params {
+				if baked[i] != nil {
+					allArgs[i] = baked[i]
+				} else {
+					allArgs[i] = args[next]
+					next++
+				}
+			}
+			// args left over go to the rest parameter, after the baked ones
+			allArgs = append(allArgs, extra...)
+			allArgs = append(allArgs, args[next:]...)
+			return vm.callValue(fn, allArgs, false)
+		},
+	}
+}
+
+func isFunction(obj object.Object) bool {
+	switch obj.(type) {
+	case *closure, *yoloFunction:
+		return true
+	default:
+		return false
+	}
+}
+
+// signatureOf yeets parameters of fn, how many of them are required and the name of the parameter
+// taking the args left over, if fn has one.
+func signatureOf(fn object.Object) (params []string, required int, rest string) {
+	switch fn := fn.(type) {
+	case *closure:
+		return fn.Fn.Parameters, fn.Fn.Required, fn.Fn.Rest
+	case *yoloFunction:
+		return fn.params, fn.required, fn.rest
+	default:
+		return nil, 0, ""
+	}
+}
+
+func yoloOf(fn object.Object) bool {
+	switch fn := fn.(type) {
+	case *closure:
+		return fn.Fn.Yolo
+	case *yoloFunction:
+		return fn.yolo
+	default:
+		return false
+	}
+}
@@ -2,6 +2,7 @@ package yikes
 
 import (
 	"fmt"
+	"os"
 	"strings"
 )
 
@@ -13,7 +14,7 @@ type YYError struct {
 func (e *YYError) Error() string { return e.Msg }
 
 func PrettyError(src []byte, offset int, errMsg string) string {
-	if offset < 0 {
+	if offset < 0 || offset > len(src) {
 		return "error: " + errMsg
 	}
 
@@ -50,3 +51,95 @@ func PrettyError(src []byte, offset int, errMsg string) string {
 
 	return b.String()
 }
+
+// StackFrame is a place in the code a function was executing when an error was raised.
+type StackFrame struct {
+	Fn     string
+	File   string // empty if it's the file the error is reported for
+	Offset int
+}
+
+// maxTraceLines limits how much of a stack trace is shown, deep recursion can produce thousands of
+// frames.
+const maxTraceLines = 20
+
+// PrettyTrace formats an error like PrettyError, followed by a line for every frame of the stack
+// trace, innermost frame first. Repeated frames are collapsed into a single line. Frames in other
+// files than the one src comes from are looked up on disk.
+func PrettyTrace(src []byte, file string, errMsg string, frames []StackFrame) string {
+	sources := map[string][]byte{"": src, file: src}
+	source := func(f string) []byte {
+		if _, ok := sources[f]; !ok {
+			sources[f], _ = os.ReadFile(f)
+		}
+		return sources[f]
+	}
+
+	var b strings.Builder
+	if len(frames) > 0 {
+		b.WriteString(PrettyError(source(frames[0].File), frames[0].Offset, errMsg))
+	} else {
+		b.WriteString(PrettyError(src, -1, errMsg))
+	}
+
+	lines := []string{}
+	for i := 0; i < len(frames); i++ {
+		f := frames[i]
+
+		frameFile := f.File
+		if frameFile == "" {
+			frameFile = file
+		}
+
+		var line string
+		if fsrc := source(f.File); f.Offset < 0 || f.Offset > len(fsrc) {
+			line = fmt.Sprintf("  at %s in %s", frameFile, f.Fn)
+		} else {
+			ln, col := Position(fsrc, f.Offset)
+			line = fmt.Sprintf("  at %s:%d:%d in %s", frameFile, ln, col, f.Fn)
+		}
+
+		repeated := 0
+		for i+1 < len(frames) && frames[i+1] == f {
+			repeated++
+			i++
+		}
+		if repeated > 0 {
+			line += fmt.Sprintf(" (repeated %d more times)", repeated)
+		}
+
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxTraceLines {
+		skipped := len(lines) - maxTraceLines
+		half := maxTraceLines / 2
+		lines = append(
+			append(lines[:half:half], fmt.Sprintf("  ... %d more lines ...", skipped)),
+			lines[len(lines)-half:]...)
+	}
+
+	for _, line := range lines {
+		b.WriteString("\n")
+		b.WriteString(line)
+	}
+
+	return b.String()
+}
+
+// Position returns line and column (both starting at 1) of the byte at offset.
+func Position(src []byte, offset int) (int, int) {
+	line := 1
+	col := 1
+
+	for i := 0; i < offset && i < len(src); i++ {
+		if src[i] == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+
+	return line, col
+}
@@ -0,0 +1,60 @@
+package yikes_test
+
+import (
+	"testing"
+
+	"yy/yikes"
+)
+
+func TestPrettyTrace(t *testing.T) {
+	src := []byte("f := \\{\n  yikes()\n}\nf()")
+
+	frames := []yikes.StackFrame{
+		{Fn: "f", Offset: 10},
+		{Fn: "<main>", Offset: 20},
+	}
+
+	expected := `error: oops
+  2 |   yikes()
+        ^
+  at main.yeet:2:3 in f
+  at main.yeet:4:1 in <main>`
+
+	got := yikes.PrettyTrace(src, "main.yeet", "oops", frames)
+	if got != expected {
+		t.Errorf("wrong trace.\nwant:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestPrettyTraceWithoutPosition(t *testing.T) {
+	frames := []yikes.StackFrame{{Fn: "<main>", Offset: -1}}
+
+	expected := "error: oops\n  at main.yeet in <main>"
+
+	got := yikes.PrettyTrace([]byte("f()"), "main.yeet", "oops", frames)
+	if got != expected {
+		t.Errorf("wrong trace.\nwant:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestPrettyTraceRepeatedFrames(t *testing.T) {
+	src := []byte("f := \\n { f(n) }\nf(1)")
+
+	frames := []yikes.StackFrame{
+		{Fn: "f", Offset: 10},
+		{Fn: "f", Offset: 10},
+		{Fn: "f", Offset: 10},
+		{Fn: "<main>", Offset: 17},
+	}
+
+	expected := `error: oops
+  1 | f := \n { f(n) }
+                ^
+  at main.yeet:1:11 in f (repeated 2 more times)
+  at main.yeet:2:1 in <main>`
+
+	got := yikes.PrettyTrace(src, "main.yeet", "oops", frames)
+	if got != expected {
+		t.Errorf("wrong trace.\nwant:\n%s\ngot:\n%s", expected, got)
+	}
+}